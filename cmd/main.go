@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"plugin"
@@ -51,7 +52,15 @@ func main() {
 		fmt.Println("That is not a driver")
 		os.Exit(1)
 	}
-	drv.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = drv.New(ctx, driver.ConnectOptions{
+		Reconnect: driver.ReconnectPolicy{Enabled: true},
+	}); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer drv.Close()
 
 	_, err = drv.ImagesPull("quay.io/skupper/qdrouterd:0.4", driver.ImagePullOptions{})
 	if err != nil {
@@ -73,7 +82,7 @@ func main() {
 	}
 
 	fmt.Println("Inspecting image")
-	imageData, err := drv.ImageInspect("quay.io/skupper/qdrouterd:0.4")
+	imageData, err := drv.ImageInspect("quay.io/skupper/qdrouterd:0.4", driver.ImageInspectOptions{})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -81,21 +90,20 @@ func main() {
 	fmt.Println("Image data ", imageData)
 
 	fmt.Println("Creating Container")
-	resp, err := drv.ContainerCreate("quay.io/skupper/qdrouterd:0.4")
+	resp, err := drv.ContainerCreate(driver.ContainerSpec{
+		Name:  "skupper-router",
+		Image: "quay.io/skupper/qdrouterd:0.4",
+	})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-
-	fmt.Println("Starting Container")
-	err = drv.ContainerStart(resp.ID)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	for _, w := range resp.Warnings {
+		fmt.Println("Create warning:", w)
 	}
 
-	fmt.Println("Wait for container to be running")
-	err = drv.ContainerWait(resp.ID, "running", time.Second*30, time.Second*5)
+	fmt.Println("Starting Container and waiting for it to be ready")
+	err = driver.StartAndWaitReady(drv, resp.ID, time.Second*30)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -133,11 +141,12 @@ func main() {
 	fmt.Println("The network name is", ni.Name)
 
 	fmt.Println("Connect container to network")
-	err = drv.NetworkConnect("skupper-network", resp.ID, []string{})
+	endpoint, err := drv.NetworkConnect("skupper-network", resp.ID, []string{})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	fmt.Println("Container address on network is", endpoint.IPv4Address)
 
 	fmt.Println("Exec a command")
 	execResult, err := drv.ContainerExec(resp.ID, []string{"qdstat", "-g"})
@@ -178,14 +187,14 @@ func main() {
 	//	fmt.Printf("Latest container is %s and state is %s \n", containerList[0].Names[0], containerList[0].State)
 
 	fmt.Println("Remove the container")
-	err = drv.ContainerRemove(resp.ID)
+	err = drv.ContainerRemove(resp.ID, driver.RemoveOptions{Force: true})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
 	fmt.Println("And remove the network too")
-	err = drv.NetworkRemove("skupper-network")
+	err = drv.NetworkRemove("skupper-network", false)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
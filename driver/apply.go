@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Apply makes the container named spec.Name match spec. A missing
+// container is created; one created from a different spec, going by its
+// SpecHashLabel, is stopped, removed and created again; a matching one is
+// left alone. The returned bool reports whether a container was created,
+// and the response holds the ID of the container either way.
+func Apply(d Driver, spec ContainerSpec) (ContainerCreateResponse, bool, error) {
+	if spec.Name == "" {
+		return ContainerCreateResponse{}, false, fmt.Errorf("Apply requires a container name")
+	}
+	existing, err := findContainer(d, spec.Name)
+	if err != nil {
+		return ContainerCreateResponse{}, false, err
+	}
+	if existing != nil {
+		matches, err := d.ContainerMatchesSpec(existing.ID, spec)
+		if err != nil {
+			return ContainerCreateResponse{}, false, err
+		}
+		if matches {
+			return ContainerCreateResponse{ID: existing.ID}, false, nil
+		}
+		if existing.State == "running" {
+			if err := d.ContainerStop(existing.ID); err != nil {
+				return ContainerCreateResponse{}, false, fmt.Errorf("Couldn't stop container %s: %w", spec.Name, err)
+			}
+		}
+		if err := d.ContainerRemove(existing.ID, RemoveOptions{Force: true}); err != nil {
+			return ContainerCreateResponse{}, false, fmt.Errorf("Couldn't remove container %s: %w", spec.Name, err)
+		}
+	}
+	resp, err := d.ContainerCreate(spec)
+	if err != nil {
+		return ContainerCreateResponse{}, false, err
+	}
+	return resp, true, nil
+}
+
+// ReuseExisting returns the container called spec.Name, for a create that
+// lost a race for the name, as long as it runs spec.Image. Otherwise it
+// returns a NameConflictError.
+func ReuseExisting(d Driver, spec ContainerSpec) (ContainerCreateResponse, error) {
+	existing, err := findContainer(d, spec.Name)
+	if err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	if existing == nil {
+		return ContainerCreateResponse{}, fmt.Errorf("Container %s not found", spec.Name)
+	}
+	icd, err := d.ContainerInspect(existing.ID)
+	if err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	if icd.ImageName != spec.Image {
+		// the engine may have normalized the name, so compare IDs too
+		image, err := d.ImageInspect(spec.Image, ImageInspectOptions{})
+		if err != nil || image.ID != icd.Image {
+			return ContainerCreateResponse{}, &NameConflictError{
+				Name:        spec.Name,
+				ContainerID: icd.ID,
+				Image:       icd.ImageName,
+			}
+		}
+	}
+	return ContainerCreateResponse{ID: icd.ID}, nil
+}
+
+// findContainer returns the container called name, or nil if there is none.
+func findContainer(d Driver, name string) (*Container, error) {
+	list, err := d.ContainerList(ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		for _, n := range list[i].Names {
+			if strings.TrimPrefix(n, "/") == name {
+				return &list[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:1.0", ImageInspect{})
+	m.addImage("quay.io/skupper/router:1.1", ImageInspect{})
+	spec := ContainerSpec{Name: "router", Image: "quay.io/skupper/router:1.0"}
+
+	created, changed, err := Apply(m, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || created.ID == "" {
+		t.Fatalf("Expected a missing container to be created, got %+v (changed %v)", created, changed)
+	}
+	if err := m.ContainerStart(created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	same, changed, err := Apply(m, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || same.ID != created.ID {
+		t.Errorf("Expected a matching container to be left alone, got %s (changed %v)", same.ID, changed)
+	}
+	if n := m.called("ContainerStop") + m.called("ContainerRemove"); n != 0 {
+		t.Errorf("Expected no stop or remove for a matching container, got %d", n)
+	}
+
+	spec.Image = "quay.io/skupper/router:1.1"
+	recreated, changed, err := Apply(m, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || recreated.ID == created.ID {
+		t.Errorf("Expected a drifted container to be recreated, got %s (changed %v)", recreated.ID, changed)
+	}
+	if m.called("ContainerStop") != 1 || m.called("ContainerRemove") != 1 {
+		t.Errorf("Expected the running container to be stopped and removed")
+	}
+	if _, err := m.ContainerInspect(created.ID); err == nil {
+		t.Errorf("Expected the old container to be gone")
+	}
+	icd, err := m.ContainerInspect(recreated.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.ImageName != spec.Image {
+		t.Errorf("Expected the new container to run %s, got %s", spec.Image, icd.ImageName)
+	}
+}
+
+func TestContainerCreateReuseExisting(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:1.0", ImageInspect{})
+	m.addImage("quay.io/skupper/router:1.1", ImageInspect{})
+	spec := ContainerSpec{Name: "router", Image: "quay.io/skupper/router:1.0"}
+	first, err := m.ContainerCreate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the reconciler that lost the race
+	if _, err := m.ContainerCreate(spec); !IsNameConflict(err) {
+		t.Fatalf("Expected a name conflict without ReuseExisting, got %v", err)
+	}
+	spec.ReuseExisting = true
+	reused, err := m.ContainerCreate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.ID != first.ID {
+		t.Errorf("Expected %s to be reused, got %s", first.ID, reused.ID)
+	}
+
+	spec.Image = "quay.io/skupper/router:1.1"
+	_, err = m.ContainerCreate(spec)
+	var conflict *NameConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected a NameConflictError for another image, got %v", err)
+	}
+	if conflict.ContainerID != first.ID || conflict.Image != "quay.io/skupper/router:1.0" {
+		t.Errorf("Expected the conflict to name %s running router:1.0, got %+v", first.ID, conflict)
+	}
+}
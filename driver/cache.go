@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type cachedInspect struct {
+	image   *ImageInspect
+	expires time.Time
+}
+
+type cachedList struct {
+	images  []ImageSummary
+	expires time.Time
+}
+
+// cachingDriver serves ImageInspect and ImagesList from results no older
+// than ttl. Any call that may change the local images drops the cache.
+type cachingDriver struct {
+	Driver
+	ttl time.Duration
+
+	lock sync.Mutex
+	// generation is bumped on every invalidation so that a lookup which
+	// raced with one does not store its stale result.
+	generation uint64
+	inspects   map[string]cachedInspect
+	lists      map[bool]cachedList
+}
+
+// CachingDriver wraps base with a cache of ImageInspect and ImagesList
+// results that live for ttl. ImagesPull, ImagesPrune and ContainerCreate,
+// which may pull, invalidate the whole cache. Changes made to the images
+// behind the driver's back are only seen once entries expire.
+func CachingDriver(base Driver, ttl time.Duration) Driver {
+	return &cachingDriver{
+		Driver:   base,
+		ttl:      ttl,
+		inspects: map[string]cachedInspect{},
+		lists:    map[bool]cachedList{},
+	}
+}
+
+// CachingMiddleware returns a Middleware wrapping drivers with
+// CachingDriver.
+func CachingMiddleware(ttl time.Duration) Middleware {
+	return func(d Driver) Driver {
+		return CachingDriver(d, ttl)
+	}
+}
+
+func (c *cachingDriver) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	c.inspects = map[string]cachedInspect{}
+	c.lists = map[bool]cachedList{}
+}
+
+// copyImageInspect returns a deep copy of image, so that callers cannot
+// change a cached entry through the slices and map it shares.
+func copyImageInspect(image *ImageInspect) *ImageInspect {
+	dup := *image
+	dup.RepoTags = copyStrings(image.RepoTags)
+	dup.RepoDigests = copyStrings(image.RepoDigests)
+	dup.Labels = copyLabels(image.Labels)
+	return &dup
+}
+
+// copyImageSummaries returns a deep copy of images.
+func copyImageSummaries(images []ImageSummary) []ImageSummary {
+	dup := append([]ImageSummary(nil), images...)
+	for i := range dup {
+		dup[i].RepoTags = copyStrings(dup[i].RepoTags)
+		dup[i].RepoDigests = copyStrings(dup[i].RepoDigests)
+		dup[i].Labels = copyLabels(dup[i].Labels)
+	}
+	return dup
+}
+
+func copyStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	return append([]string{}, list...)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	dup := make(map[string]string, len(labels))
+	for k, v := range labels {
+		dup[k] = v
+	}
+	return dup
+}
+
+func (c *cachingDriver) ImageInspect(id string, options ImageInspectOptions) (*ImageInspect, error) {
+	key := id + " " + options.Platform
+	c.lock.Lock()
+	entry, ok := c.inspects[key]
+	generation := c.generation
+	c.lock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copyImageInspect(entry.image), nil
+	}
+
+	image, err := c.Driver.ImageInspect(id, options)
+	if err != nil {
+		return image, err
+	}
+	cached := copyImageInspect(image)
+	c.lock.Lock()
+	if c.generation == generation {
+		c.inspects[key] = cachedInspect{image: cached, expires: time.Now().Add(c.ttl)}
+	}
+	c.lock.Unlock()
+	return image, nil
+}
+
+func (c *cachingDriver) ImagesList(options ImageListOptions) ([]ImageSummary, error) {
+	c.lock.Lock()
+	entry, ok := c.lists[options.All]
+	generation := c.generation
+	c.lock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copyImageSummaries(entry.images), nil
+	}
+
+	images, err := c.Driver.ImagesList(options)
+	if err != nil {
+		return images, err
+	}
+	c.lock.Lock()
+	if c.generation == generation {
+		c.lists[options.All] = cachedList{
+			images:  copyImageSummaries(images),
+			expires: time.Now().Add(c.ttl),
+		}
+	}
+	c.lock.Unlock()
+	return images, nil
+}
+
+func (c *cachingDriver) ImagesPull(refStr string, options ImagePullOptions) ([]string, error) {
+	defer c.invalidate()
+	return c.Driver.ImagesPull(refStr, options)
+}
+
+func (c *cachingDriver) ImageLoad(ctx context.Context, r io.Reader) ([]string, error) {
+	defer c.invalidate()
+	return c.Driver.ImageLoad(ctx, r)
+}
+
+func (c *cachingDriver) ImageTag(src string, dst string) error {
+	defer c.invalidate()
+	return c.Driver.ImageTag(src, dst)
+}
+
+func (c *cachingDriver) ImagesPrune(filters Filters) (PruneReport, error) {
+	defer c.invalidate()
+	return c.Driver.ImagesPrune(filters)
+}
+
+func (c *cachingDriver) ContainerCreate(spec ContainerSpec) (ContainerCreateResponse, error) {
+	defer c.invalidate()
+	return c.Driver.ContainerCreate(spec)
+}
@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachingDriver(t *testing.T) {
+	m := newMockDriver()
+	ref := "quay.io/skupper/router:1.0"
+	m.addImage(ref, ImageInspect{})
+	d := CachingDriver(m, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.ImageInspect(ref, ImageInspectOptions{}); err != nil {
+				t.Error(err)
+			}
+			if _, err := d.ImagesList(ImageListOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	first := m.called("ImageInspect")
+	if _, err := d.ImageInspect(ref, ImageInspectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ImagesList(ImageListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ImageInspect"); n != first {
+		t.Errorf("Expected a cached inspect not to reach the driver, got %d calls after %d", n, first)
+	}
+	if n := m.called("ImagesList"); n > 10 {
+		t.Errorf("Expected cached lists not to reach the driver, got %d calls", n)
+	}
+
+	if _, err := d.ImagesPull(ref, ImagePullOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ImageInspect(ref, ImageInspectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ImageInspect"); n != first+1 {
+		t.Errorf("Expected the pull to invalidate the cache, got %d calls after %d", n, first)
+	}
+}
+
+func TestCachingDriverExpires(t *testing.T) {
+	m := newMockDriver()
+	ref := "quay.io/skupper/router:1.0"
+	m.addImage(ref, ImageInspect{})
+	d := CachingDriver(m, 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := d.ImageInspect(ref, ImageInspectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := m.called("ImageInspect"); n != 1 {
+		t.Errorf("Expected one inspect within the ttl, got %d", n)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := d.ImageInspect(ref, ImageInspectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ImageInspect"); n != 2 {
+		t.Errorf("Expected an expired entry to be inspected again, got %d calls", n)
+	}
+}
+
+func TestCachingDriverCopiesResults(t *testing.T) {
+	m := newMockDriver()
+	ref := "quay.io/skupper/router:1.0"
+	m.addImage(ref, ImageInspect{Labels: map[string]string{"version": "1.0"}})
+	d := CachingDriver(m, time.Minute)
+	// fill the cache; the results the mock returns are its own
+	if _, err := d.ImageInspect(ref, ImageInspectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ImagesList(ImageListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		image, err := d.ImageInspect(ref, ImageInspectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(image.RepoTags) == 0 || image.RepoTags[0] != ref || image.Labels["version"] != "1.0" {
+			t.Fatalf("Expected the cached image to be unchanged, got %+v", image)
+		}
+		image.RepoTags[0] = "changed"
+		image.Labels["version"] = "changed"
+
+		images, err := d.ImagesList(ImageListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(images) != 1 || len(images[0].RepoTags) == 0 || images[0].RepoTags[0] != ref {
+			t.Fatalf("Expected the cached list to be unchanged, got %+v", images)
+		}
+		images[0].RepoTags[0] = "changed"
+	}
+}
@@ -2,6 +2,10 @@ package driver
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -16,28 +20,78 @@ const (
 
 	// defaultImagePullingProgressReportInterval is the default interval of image pulling progress reporting.
 	DefaultImagePullingProgressReportInterval = 10 * time.Second
+
+	// DefaultReconnectInterval is the minimum time between two attempts to
+	// re-establish a dropped engine connection.
+	DefaultReconnectInterval = 5 * time.Second
+
+	// DefaultMaxConcurrentPulls is the number of image pulls a driver runs
+	// at once when ConnectOptions does not say otherwise.
+	DefaultMaxConcurrentPulls = 3
 )
 
 type ContainerStatus int
 
 type Driver interface {
-	New() error
-	ImageInspect(id string) (*ImageInspect, error)
+	New(ctx context.Context, options ConnectOptions) error
+	Reconnect(options ConnectOptions) error
+	Close() error
+	SupportsFeature(feature Feature) (bool, error)
+	ImageInspect(id string, options ImageInspectOptions) (*ImageInspect, error)
 	ImagesList(options ImageListOptions) ([]ImageSummary, error)
+	ImagesDiskUsage() (ImagesDiskReport, error)
 	ImagesPull(refStr string, options ImagePullOptions) ([]string, error)
-	ContainerCreate(image string) (ContainerCreateResponse, error)
+	ImageExists(ref string) (bool, error)
+	ImageWait(ctx context.Context, ref string, interval time.Duration) error
+	ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error)
+	ImageLoad(ctx context.Context, r io.Reader) ([]string, error)
+	ImageTag(src string, dst string) error
+	ContainerCreate(spec ContainerSpec) (ContainerCreateResponse, error)
 	ContainerStart(id string) error
 	ContainerWait(id string, state string, timeout time.Duration, interval time.Duration) error
+	ContainerWaitWithOptions(id string, opts WaitOptions) error
 	ContainerList(options ContainerListOptions) ([]Container, error)
 	ContainerInspect(id string) (*InspectContainerData, error)
+	ContainerImageDigest(id string) (string, error)
+	ImageUpToDate(id string, ref string) (bool, error)
+	ResolveContainer(nameOrPrefix string) (string, error)
+	ContainerSpecOf(id string) (ContainerSpec, error)
+	ContainerMatchesSpec(id string, spec ContainerSpec) (bool, error)
+	IsManaged(id string) (bool, error)
 	ContainerStop(id string) error
-	ContainerRemove(id string) error
+	ContainerStopWithTimeout(id string, timeout time.Duration) error
+	ContainerExitCode(id string) (int, error)
+	ContainerUptime(id string) (time.Duration, error)
+	ContainerRestartCount(id string) (int, error)
+	ContainerRemove(id string, options RemoveOptions) error
 	ContainerExec(id string, cmd []string) (ExecResult, error)
+	ContainerExecStream(id string, opts ExecOptions, stdout io.Writer, stderr io.Writer) (int, error)
+	ContainerExecWithOptions(id string, opts ExecOptions) (ExecResult, error)
+	ContainerExecDetached(id string, opts ExecOptions) (string, error)
+	WaitForPort(ctx context.Context, id string, port int, proto string) error
+	ExecInspect(execID string) (ExecInspect, error)
+	ContainerStatsSnapshot(id string) (ContainerStats, error)
+	ContainerSummary(id string) (ContainerSummary, error)
+	ContainerPorts(id string) ([]Port, error)
+	ContainerNetworkConfig(id string) (NetworkConfig, error)
+	ContainerStatPath(id string, path string) (PathStat, error)
+	WatchPath(ctx context.Context, id string, path string, interval time.Duration) (<-chan PathEvent, error)
+	ContainerEvents(ctx context.Context, id string) (<-chan Event, <-chan error, error)
+	ContainerLogs(ctx context.Context, id string, options LogOptions) (io.ReadCloser, error)
+	ContainerLogPath(id string) (string, error)
+	GenerateSystemdUnit(id string, opts SystemdOptions) (map[string]string, error)
 	NetworkCreate(name string, options NetworkCreateOptions) (NetworkCreateResponse, error)
 	NetworkInspect(id string) (NetworkResource, error)
-	NetworkRemove(id string) error
-	NetworkConnect(id string, container string, aliases []string) error
+	NetworkList(options NetworkListOptions) ([]NetworkResource, error)
+	NetworkRemove(id string, force bool) error
+	NetworkConnect(id string, container string, aliases []string) (EndpointResource, error)
 	NetworkDisconnect(id string, container string, force bool) error
+	ContainerConnectNetworks(id string, endpoints map[string]EndpointConfig, opts ConnectNetworksOptions) error
+	NetworkGateway(id string) (string, error)
+	ContainersPrune(filters Filters) (PruneReport, error)
+	ImagesPrune(filters Filters) (PruneReport, error)
+	NetworksPrune(filters Filters) (PruneReport, error)
+	VolumesPrune(filters Filters) (PruneReport, error)
 }
 
 // TODO: add Config
@@ -45,23 +99,161 @@ type ImageInspect struct {
 	ID       string   `json:"Id"`
 	Created  int64    `json:"Created"`
 	RepoTags []string `json:",omitempty"`
-	Size     int64    `json:"Size"`
+	// RepoDigests are the "repository@digest" references of the image.
+	RepoDigests []string `json:",omitempty"`
+	Size        int64    `json:"Size"`
+	// Labels are the labels in the image config.
+	Labels       map[string]string `json:",omitempty"`
+	Os           string            `json:",omitempty"`
+	Architecture string            `json:",omitempty"`
+	Variant      string            `json:",omitempty"`
+}
+
+// ImageInspectOptions configures ImageInspect.
+type ImageInspectOptions struct {
+	// Platform, when set as "os/arch[/variant]", makes ImageInspect fail
+	// unless the local image was built for that platform.
+	Platform string
+}
+
+// ReconnectPolicy controls whether and how often a driver re-establishes
+// a dropped connection to its engine before failing a call. Only calls
+// that are safe to repeat, such as inspects and lists, are retried on the
+// new connection; others fail with the connection error.
+type ReconnectPolicy struct {
+	Enabled bool
+	// MinInterval guards against reconnection storms; a zero value means
+	// DefaultReconnectInterval.
+	MinInterval time.Duration
+}
+
+type ConnectOptions struct {
+	// Host is the engine endpoint, e.g. "unix:///var/run/docker.sock" or
+	// "tcp://10.0.0.1:2376". Empty uses the engine's default.
+	Host string
+	// APIVersion pins the engine API version, e.g. "1.40", instead of
+	// negotiating the highest version both sides support.
+	APIVersion string
+	Reconnect  ReconnectPolicy
+	// MaxConcurrentPulls bounds the ImagesPull calls in flight; further
+	// pulls queue until a slot frees up. Zero or less means
+	// DefaultMaxConcurrentPulls.
+	MaxConcurrentPulls int
+	// Proxy is used for the driver's own connection to a remote engine.
+	Proxy ProxyConfig
+	// AutoAttachNetworks makes ContainerCreate connect each new container
+	// to the networks named in its NetworkLabel, creating missing ones.
+	AutoAttachNetworks bool
+}
+
+// ProxyConfig holds HTTP proxy settings. They only apply to requests the
+// driver makes itself: image pulls are run by the engine, which uses the
+// proxy configured in its own environment.
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+// IsZero reports whether no proxy is configured.
+func (p ProxyConfig) IsZero() bool {
+	return p == ProxyConfig{}
+}
+
+// BuildArgs returns the settings as the predefined proxy build args, in
+// both cases, for passing to an image build.
+func (p ProxyConfig) BuildArgs() map[string]*string {
+	args := map[string]*string{}
+	set := func(name string, value string) {
+		if value != "" {
+			v := value
+			args[name] = &v
+			args[strings.ToLower(name)] = &v
+		}
+	}
+	set("HTTP_PROXY", p.HTTPProxy)
+	set("HTTPS_PROXY", p.HTTPSProxy)
+	set("NO_PROXY", p.NoProxy)
+	return args
 }
 
 type ImagePullOptions struct {
 	All bool
+	// Progress, when set, is called with each progress update of the pull.
+	// Engines that do not stream pull progress never call it.
+	Progress func(PullProgress)
+	// Context, when set, aborts the pull once it is done; the pull then
+	// fails with a PullInterruptedError wrapping the context's error.
+	Context context.Context
+}
+
+// PullProgress is a progress update for one layer of an image pull.
+type PullProgress struct {
+	Ref    string
+	Layer  string
+	Status string
+	// Current and Total count bytes; Total is zero when unknown.
+	Current int64
+	Total   int64
+	// SpeedBytesPerSec is the layer's transfer rate since its previous
+	// update, or zero for its first one.
+	SpeedBytesPerSec float64
 }
 
 type ImageListOptions struct {
 	All bool
 }
 
+// Filters narrows list results, keyed by filter name (e.g. "label") with
+// the accepted values for each, as understood by docker and podman.
+type Filters map[string][]string
+
+// LabelFilter returns Filters matching containers whose label is set to
+// value.
+func LabelFilter(label string, value string) Filters {
+	return Filters{"label": {label + "=" + value}}
+}
+
+// MatchLabels reports whether labels satisfy every "key" or "key=value"
+// label filter, for engines that cannot filter by label themselves.
+func MatchLabels(labels map[string]string, filters []string) bool {
+	for _, f := range filters {
+		kv := strings.SplitN(f, "=", 2)
+		v, ok := labels[kv[0]]
+		if !ok || (len(kv) == 2 && v != kv[1]) {
+			return false
+		}
+	}
+	return true
+}
+
 type ContainerListOptions struct {
-	All bool
+	All     bool
+	Filters Filters
+	// Inspect back-fills each listed container with its env, mounts and
+	// state from a full inspect. This costs one inspect per container.
+	Inspect bool
+	// Limit returns only the Limit most recently created containers, in
+	// any state. Containers are listed newest first, so the next page
+	// starts Before the last container of the previous one.
+	Limit int
+	// Since and Before restrict the list to containers created after or
+	// before the container with the given ID or name.
+	Since  string
+	Before string
+}
+
+type RemoveOptions struct {
+	Force         bool
+	RemoveVolumes bool
+	// IncludeUnmanaged lets RemoveByLabel also remove containers that
+	// ContainerCreate did not make. It has no effect on ContainerRemove.
+	IncludeUnmanaged bool
 }
 
 type NetworkListOptions struct {
-	All bool
+	All     bool
+	Filters Filters
 }
 
 // TODO: podman Image has container config, where should this come from
@@ -74,8 +266,219 @@ type ImageSummary struct {
 	Size        int64             `json:"Size"`
 }
 
+// ImageDiskUsage is one image's entry in an ImagesDiskReport. Size counts
+// all of the image's layers; SharedSize those it shares with other images
+// and ExclusiveSize the rest, which removing the image would free.
+type ImageDiskUsage struct {
+	ID            string
+	RepoTags      []string
+	Size          int64
+	SharedSize    int64
+	ExclusiveSize int64
+}
+
+// ImagesDiskReport sizes the local images, counting shared layers once.
+type ImagesDiskReport struct {
+	Images []ImageDiskUsage
+	// TotalSize is the space all image layers take up on disk.
+	TotalSize int64
+}
+
+// PathStat describes a file in a container's filesystem. Symbolic links
+// are not followed.
+type PathStat struct {
+	Name       string
+	Size       int64
+	Mode       os.FileMode
+	Mtime      time.Time
+	LinkTarget string
+}
+
+// ContainerSpec describes a container to be created.
+type ContainerSpec struct {
+	Name  string
+	Image string
+	// Platform selects the "os/arch[/variant]" of a multi-arch image.
+	Platform string
+	Env      []string
+	EnvFiles []string
+	Labels   map[string]string
+	// Annotations are OCI annotations, kept apart from labels by podman
+	// and cri. Docker has no container annotations and rejects them.
+	Annotations   map[string]string
+	Mounts        []Mount
+	Ports         []PortMapping
+	RestartPolicy RestartPolicy
+	Ulimits       []Ulimit
+	// ShmSize is the size of /dev/shm in bytes; zero means DefaultShmSize.
+	ShmSize int64
+	// Init runs an init process as PID 1 to reap zombie processes.
+	Init bool
+	// Secrets are files delivered into the container at create time
+	// rather than baked into the image. Engines that can't keep them out
+	// of the container's writable layer return ErrNotSupported.
+	Secrets []SecretMount
+	// CgroupParent places the container under the given cgroup, e.g. a
+	// systemd slice.
+	CgroupParent string
+	// CgroupnsMode is one of the CgroupnsMode constants; empty uses the
+	// engine default.
+	CgroupnsMode string
+	// PidMode, IpcMode and UTSMode share a namespace with the host
+	// ("host") or with another container ("container:<name or id>");
+	// empty gives the container its own.
+	PidMode        string
+	IpcMode        string
+	UTSMode        string
+	Devices        []DeviceMapping
+	DeviceRequests []DeviceRequest
+	// User is a user name or uid, optionally followed by :group or :gid.
+	User string
+	// GroupAdd lists supplementary groups for the user, by name or gid.
+	GroupAdd []string
+	// WorkingDir is the directory commands run in; empty uses the image's.
+	WorkingDir string
+	// PullPolicy decides whether ContainerCreate pulls Image first; empty
+	// never pulls and leaves a missing image to the engine.
+	PullPolicy PullPolicy
+	// ReuseExisting makes ContainerCreate return the container already
+	// called Name, if it runs Image, instead of failing on the name
+	// conflict. One running another image yields a NameConflictError.
+	ReuseExisting bool
+	// Tmpfs mounts an in-memory filesystem at each path, with comma
+	// separated mount options such as "size=64m,mode=1777".
+	Tmpfs map[string]string
+	// StopSignal is sent to stop the container, by name ("SIGQUIT") or
+	// number; empty uses the image's or SIGTERM.
+	StopSignal string
+	// StopTimeout is how many seconds to wait after StopSignal before
+	// killing the container; nil uses the engine default.
+	StopTimeout *int
+	// ReadinessProbe, when set, is run by StartAndWaitReady once the
+	// container is up.
+	ReadinessProbe *Probe
+	Resources      Resources
+	// NetworkConfig sets the hostname and name resolution; zero values
+	// use the engine defaults.
+	NetworkConfig NetworkConfig
+	// Runtime is the OCI runtime, e.g. "crun" or "runc", or for cri the
+	// runtime handler; empty uses the engine default.
+	Runtime string
+}
+
+// NetworkConfig holds a container's hostname and name resolution settings.
+type NetworkConfig struct {
+	Hostname   string
+	DomainName string
+	// DNS lists the nameserver addresses.
+	DNS []string
+	// ExtraHosts are "host:ip" entries added to /etc/hosts.
+	ExtraHosts []string
+}
+
+// Resources limits what a container may use. Zero values leave the
+// engine's defaults in place.
+type Resources struct {
+	// Memory is the memory limit in bytes.
+	Memory int64
+	// MemoryReservation is a soft limit in bytes the kernel reclaims down
+	// to under memory pressure.
+	MemoryReservation int64
+	// NanoCPUs is the CPU quota in units of 1e-9 CPUs.
+	NanoCPUs int64
+	// CPUShares is the relative CPU weight.
+	CPUShares int64
+	// OOMScoreAdj, from -1000 to 1000, makes the container a less (lower)
+	// or more likely victim of the OOM killer.
+	OOMScoreAdj int
+}
+
+// Probe is a command exec'd in a container that exits zero once the
+// container is ready.
+type Probe struct {
+	Exec []string
+	// Interval between attempts; zero means DefaultReadinessInterval.
+	Interval time.Duration
+	// Timeout bounds a single attempt, which is killed once it elapses;
+	// zero leaves it bounded only by StartAndWaitReady's timeout.
+	Timeout time.Duration
+	// Retries is the number of attempts; zero means DefaultProbeRetries.
+	Retries int
+}
+
+// PullPolicy is one of PullAlways, PullMissing or PullNever.
+type PullPolicy string
+
+const (
+	PullAlways  PullPolicy = "always"
+	PullMissing PullPolicy = "missing"
+	PullNever   PullPolicy = "never"
+)
+
+// DeviceMapping exposes a host device in the container. CgroupPermissions
+// is a combination of r, w and m; empty means "rwm".
+type DeviceMapping struct {
+	PathOnHost        string
+	PathInContainer   string
+	CgroupPermissions string
+}
+
+// DeviceRequest asks the engine's device driver (e.g. "nvidia") for
+// devices such as GPUs, either Count of them (-1 for all) or by DeviceIDs.
+type DeviceRequest struct {
+	Driver       string
+	Count        int
+	DeviceIDs    []string
+	Capabilities [][]string
+	Options      map[string]string
+}
+
+const (
+	CgroupnsModeHost    = "host"
+	CgroupnsModePrivate = "private"
+)
+
+// NamespaceModeContainer prefixes the name or ID of the container whose
+// namespace a PidMode, IpcMode or UTSMode shares.
+const NamespaceModeContainer = "container:"
+
+// SecretMount delivers the host file Source at the absolute path Target in
+// the container, owned by UID:GID with permissions Mode (0400 when zero).
+type SecretMount struct {
+	Source string
+	Target string
+	Mode   os.FileMode
+	UID    int
+	GID    int
+}
+
+// Mount attaches a volume, host path or tmpfs at Target. Source is the
+// volume name for volume mounts and the host path for bind mounts.
+type Mount struct {
+	Type     MountType
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// RestartPolicy is one of "no", "always", "on-failure" or "unless-stopped"
+// by Name; MaximumRetryCount only applies to "on-failure".
+type RestartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+// Ulimit is a process resource limit, named as for ulimit (e.g. "nofile").
+type Ulimit struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
 type ContainerCreateResponse struct {
-	ID       string   `json:"Id"`
+	ID string `json:"Id"`
+	// Warnings are reported by the engine for options it ignored or
+	// adjusted, e.g. a memory limit without swap accounting.
 	Warnings []string `json:"Warnings"`
 }
 
@@ -91,10 +494,39 @@ type Container struct {
 	State   string
 	Status  string
 	Mounts  []MountPoint
+	Env     []string
 }
 
 type NetworkResource struct {
-	Name string
+	ID         string `json:"Id"`
+	Name       string
+	Driver     string
+	Labels     map[string]string
+	Options    map[string]string
+	IPAM       IPAMConfig
+	EnableIPv6 bool
+	// Containers maps the ID of each attached container to its endpoint
+	Containers map[string]EndpointResource
+}
+
+// EndpointConfig is how a container is attached to one network.
+type EndpointConfig struct {
+	Aliases []string
+}
+
+type ConnectNetworksOptions struct {
+	// Atomic disconnects the networks already connected when a later one
+	// fails, leaving the container as it was.
+	Atomic bool
+}
+
+type EndpointResource struct {
+	Name        string
+	EndpointID  string
+	MacAddress  string
+	IPv4Address string
+	IPv6Address string
+	Gateway     string
 }
 
 // NOTE: ContainerJSONBase    for docker
@@ -111,7 +543,49 @@ type InspectContainerData struct {
 	Name      string          `json:"Name"`
 	Mounts    []MountPoint
 	// Config
+	Env    []string
+	Labels map[string]string
+	// Entrypoint and Cmd are the effective command, after any override
+	// of the image's; the cri driver leaves them empty as CRI does not
+	// report them.
+	Entrypoint []string
+	Cmd        []string
+	// StopSignal and StopTimeout are the stop settings; podman reports
+	// the signal by number and the cri driver leaves them unset.
+	StopSignal  string
+	StopTimeout *int
+	// Annotations are only reported by podman and cri; docker has none,
+	// so for docker containers it is always empty.
+	Annotations map[string]string
+	// HostConfig
+	PortBindings  []Port
+	RestartPolicy RestartPolicy
+	// RestartCount is how many times the engine restarted the container
+	// under its restart policy; the cri driver leaves it zero as CRI
+	// leaves restarts to the kubelet.
+	RestartCount int
+	// Runtime is the OCI runtime the container runs with; the cri driver
+	// leaves it empty.
+	Runtime string
+	// PidMode, IpcMode and UTSMode are the namespace modes the container
+	// was created with; the cri driver leaves them empty.
+	PidMode string
+	IpcMode string
+	UTSMode string
+	// CgroupParent and CgroupnsMode are the cgroup settings the container
+	// was created with; the cri driver leaves them empty.
+	CgroupParent string
+	CgroupnsMode string
+	// Resources holds the effective limits; the cri driver leaves it zero
+	// as CRI does not report them.
+	Resources Resources
 	// NetworkSettings
+	// Networks maps the name of each network the container is attached
+	// to onto its endpoint there.
+	Networks map[string]EndpointResource
+	// NetworkConfig holds the effective hostname and DNS settings; the cri
+	// driver leaves it zero as CRI does not report them.
+	NetworkConfig NetworkConfig
 }
 
 type MountPoint struct {
@@ -145,16 +619,61 @@ const (
 )
 
 type Port struct {
-	IP            string `json: "IP,omitempty"`
+	IP            string `json:"IP,omitempty"`
 	ContainerPort uint16 `json:"ContainerPort"`
 	HostPort      uint16 `json:"HostPort,omitempty"`
 	Type          string `json:"Type"`
 }
 
+// ContainerStats is a single resource usage sample of a container.
+type ContainerStats struct {
+	ID            string
+	Read          time.Time
+	CPUPercent    float64
+	MemoryUsage   uint64
+	MemoryLimit   uint64
+	MemoryPercent float64
+	NetworkRx     uint64
+	NetworkTx     uint64
+	BlockRead     uint64
+	BlockWrite    uint64
+	PIDs          uint64
+}
+
 type ContainerState struct {
-	Status  string
-	Running bool
-	Paused  bool
+	Status     string
+	Running    bool
+	Paused     bool
+	Restarting bool
+	OOMKilled  bool
+	Dead       bool
+	Pid        int
+	ExitCode   int
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Health is nil when the container has no healthcheck
+	Health *Health
+}
+
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+type Health struct {
+	Status        string
+	FailingStreak int
+	Log           []HealthLog
+}
+
+// HealthLog is the result of a single healthcheck probe.
+type HealthLog struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
 }
 
 type NetworkCreateOptions struct {
@@ -162,6 +681,29 @@ type NetworkCreateOptions struct {
 	Driver         string
 	Options        map[string]string
 	Labels         map[string]string
+	IPAM           IPAMConfig
+	// EnableIPv6 is required for IPAM pools with an IPv6 subnet.
+	EnableIPv6 bool
+	// Owner, when set, is recorded in the NetworkOwnerLabel label so that
+	// EnsureNetwork can tell its own network from another of the same name.
+	Owner string
+}
+
+// IPAMConfig selects the IP address management driver and the address
+// pools of a network.
+type IPAMConfig struct {
+	Driver string
+	Config []IPAMPool
+}
+
+type IPAMPool struct {
+	// Subnet and IPRange are CIDRs; IPRange restricts the addresses
+	// handed to containers to part of Subnet.
+	Subnet  string
+	IPRange string
+	Gateway string
+	// AuxAddresses reserves named addresses within Subnet.
+	AuxAddresses map[string]string
 }
 
 type NetworkCreateResponse struct {
@@ -169,16 +711,114 @@ type NetworkCreateResponse struct {
 	Warning string
 }
 
+// ExecStderrMarker separates stdout from stderr in ExecResult.Combined
+// when the two streams were captured separately.
+const ExecStderrMarker = "\n--- stderr ---\n"
+
+// SystemdOptions configures GenerateSystemdUnit.
+type SystemdOptions struct {
+	// UseName refers to the container by name rather than ID in the unit.
+	UseName bool
+	// New makes the unit create the container on start and remove it on
+	// stop, instead of starting and stopping the existing container.
+	New bool
+	// RestartPolicy is the unit's systemd Restart= value, e.g. "always";
+	// empty means "on-failure".
+	RestartPolicy string
+	// StopTimeout is the number of seconds to wait for the container to
+	// stop; nil uses the container's stop timeout.
+	StopTimeout *int
+}
+
+// LogOptions configures ContainerLogs.
+type LogOptions struct {
+	// Follow keeps the stream open for new output until it is closed or
+	// the context is done.
+	Follow bool
+	// Tail limits the output to the last Tail lines; zero returns all.
+	Tail       int
+	Since      time.Time
+	Timestamps bool
+	// MaxOutputBytes, when positive, ends the stream after that many
+	// bytes. The stream is then a *LimitedReadCloser whose Truncated
+	// method reports whether output was cut off.
+	MaxOutputBytes int64
+}
+
+// ExecOptions configures a command exec'd in a container.
+type ExecOptions struct {
+	Cmd []string
+	// Env adds KEY=VALUE entries to the container's environment.
+	Env        []string
+	WorkingDir string
+	User       string
+	// Tty runs the command with a terminal, which merges stderr into
+	// stdout.
+	Tty bool
+	// Timeout, when set, bounds the exec. Once it elapses the attach is
+	// cancelled, the process is killed where the engine allows it and an
+	// ExecTimeoutError is returned.
+	Timeout time.Duration
+	// MaxOutputBytes, when positive, caps the bytes kept of each of
+	// stdout and stderr by ContainerExecWithOptions; the excess is
+	// discarded and the result marked Truncated.
+	MaxOutputBytes int64
+}
+
+// ExecInspect describes an exec session.
+type ExecInspect struct {
+	ID          string
+	ContainerID string
+	Running     bool
+	ExitCode    int
+	Pid         int
+}
+
 type ExecResult struct {
+	// ExecID identifies the exec session for ExecInspect, e.g. to find
+	// sessions left running after the attach was interrupted.
+	ExecID    string
 	ExitCode  int
 	OutBuffer *bytes.Buffer
 	ErrBuffer *bytes.Buffer
+	// Tty is set when the exec ran with a terminal, in which case the
+	// engine has already merged stderr into OutBuffer.
+	Tty bool
+	// RawStream is set when output expected to be multiplexed arrived
+	// as a plain stream, e.g. from an unexpected terminal, and was copied
+	// to OutBuffer as is.
+	RawStream bool
+	// Truncated is set when output beyond ExecOptions.MaxOutputBytes was
+	// discarded.
+	Truncated bool
+	// StartedAt and Duration time the whole exec, from creating the exec
+	// session until its exit code was read back.
+	StartedAt time.Time
+	Duration  time.Duration
 }
 
 func (res *ExecResult) Stderr() string {
+	if res.ErrBuffer == nil {
+		return ""
+	}
 	return res.ErrBuffer.String()
 }
 
 func (res *ExecResult) Stdout() string {
+	if res.OutBuffer == nil {
+		return ""
+	}
 	return res.OutBuffer.String()
 }
+
+// Combined returns stdout and stderr together. For TTY execs this is the
+// stream exactly as the engine produced it. Otherwise the original
+// interleaving is lost, so stderr is appended after stdout, separated by
+// ExecStderrMarker; the marker is omitted when stderr is empty.
+func (res *ExecResult) Combined() string {
+	stdout, stderr := res.Stdout(), res.Stderr()
+	if res.Tty || stderr == "" {
+		return stdout + stderr
+	}
+	return stdout + ExecStderrMarker + stderr
+}
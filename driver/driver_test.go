@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExecResultCombined(t *testing.T) {
+	tests := []struct {
+		name string
+		res  ExecResult
+		want string
+	}{
+		{
+			name: "tty",
+			res:  ExecResult{Tty: true, OutBuffer: bytes.NewBufferString("out\nerr\n")},
+			want: "out\nerr\n",
+		},
+		{
+			name: "separate streams",
+			res:  ExecResult{OutBuffer: bytes.NewBufferString("out\n"), ErrBuffer: bytes.NewBufferString("err\n")},
+			want: "out\n" + ExecStderrMarker + "err\n",
+		},
+		{
+			name: "no stderr",
+			res:  ExecResult{OutBuffer: bytes.NewBufferString("out\n"), ErrBuffer: &bytes.Buffer{}},
+			want: "out\n",
+		},
+		{
+			name: "nil buffers",
+			res:  ExecResult{},
+			want: "",
+		},
+		{
+			name: "stderr only",
+			res:  ExecResult{ErrBuffer: bytes.NewBufferString("err\n")},
+			want: ExecStderrMarker + "err\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.res.Combined(); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestProxyConfigBuildArgs(t *testing.T) {
+	if !(ProxyConfig{}).IsZero() {
+		t.Errorf("Expected an empty proxy config to be zero")
+	}
+	proxy := ProxyConfig{HTTPSProxy: "http://proxy.example.com:3128", NoProxy: "localhost,.svc"}
+	args := proxy.BuildArgs()
+	for name, want := range map[string]string{
+		"HTTPS_PROXY": "http://proxy.example.com:3128",
+		"https_proxy": "http://proxy.example.com:3128",
+		"NO_PROXY":    "localhost,.svc",
+		"no_proxy":    "localhost,.svc",
+	} {
+		if got := args[name]; got == nil || *got != want {
+			t.Errorf("Expected build arg %s=%s, got %v", name, want, got)
+		}
+	}
+	if _, ok := args["HTTP_PROXY"]; ok {
+		t.Errorf("Expected unset proxies to be left out, got %v", args)
+	}
+}
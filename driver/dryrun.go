@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DryRunDriver wraps a Driver so that mutating calls are recorded instead
+// of being sent to the engine. Read calls pass through to the wrapped
+// driver.
+type DryRunDriver struct {
+	Driver
+
+	lock    sync.Mutex
+	planned []string
+}
+
+// DryRun returns a Driver that previews mutating operations on d without
+// performing them.
+func DryRun(d Driver) Driver {
+	return &DryRunDriver{Driver: d}
+}
+
+// Planned returns the operations that would have been performed, in the
+// order they were requested.
+func (d *DryRunDriver) Planned() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]string(nil), d.planned...)
+}
+
+func (d *DryRunDriver) record(format string, a ...interface{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.planned = append(d.planned, fmt.Sprintf(format, a...))
+}
+
+func (d *DryRunDriver) ImagesPull(refStr string, options ImagePullOptions) ([]string, error) {
+	d.record("pull image %s", refStr)
+	return nil, nil
+}
+
+// ImageLoad drains r, so that a writer producing the archive, such as
+// CopyImage's save, is not left blocked.
+func (d *DryRunDriver) ImageLoad(ctx context.Context, r io.Reader) ([]string, error) {
+	d.record("load images from archive")
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (d *DryRunDriver) ImageTag(src string, dst string) error {
+	d.record("tag image %s as %s", src, dst)
+	return nil
+}
+
+func (d *DryRunDriver) ContainerCreate(spec ContainerSpec) (ContainerCreateResponse, error) {
+	d.record("create container %s from image %s", spec.Name, spec.Image)
+	return ContainerCreateResponse{}, nil
+}
+
+func (d *DryRunDriver) ContainerStart(id string) error {
+	d.record("start container %s", id)
+	return nil
+}
+
+func (d *DryRunDriver) ContainerStop(id string) error {
+	d.record("stop container %s", id)
+	return nil
+}
+
+func (d *DryRunDriver) ContainerStopWithTimeout(id string, timeout time.Duration) error {
+	d.record("stop container %s within %v", id, timeout)
+	return nil
+}
+
+func (d *DryRunDriver) ContainerRemove(id string, options RemoveOptions) error {
+	d.record("remove container %s", id)
+	return nil
+}
+
+func (d *DryRunDriver) ContainerExec(id string, cmd []string) (ExecResult, error) {
+	return d.ContainerExecWithOptions(id, ExecOptions{Cmd: cmd})
+}
+
+func (d *DryRunDriver) ContainerExecStream(id string, opts ExecOptions, stdout io.Writer, stderr io.Writer) (int, error) {
+	d.record("exec %v in container %s", opts.Cmd, id)
+	return 0, nil
+}
+
+func (d *DryRunDriver) ContainerExecWithOptions(id string, opts ExecOptions) (ExecResult, error) {
+	d.record("exec %v in container %s", opts.Cmd, id)
+	return ExecResult{OutBuffer: &bytes.Buffer{}, ErrBuffer: &bytes.Buffer{}, Tty: opts.Tty}, nil
+}
+
+func (d *DryRunDriver) ContainerExecDetached(id string, opts ExecOptions) (string, error) {
+	d.record("exec %v detached in container %s", opts.Cmd, id)
+	return "", nil
+}
+
+func (d *DryRunDriver) NetworkCreate(name string, options NetworkCreateOptions) (NetworkCreateResponse, error) {
+	d.record("create network %s", name)
+	return NetworkCreateResponse{}, nil
+}
+
+func (d *DryRunDriver) NetworkRemove(id string, force bool) error {
+	d.record("remove network %s", id)
+	return nil
+}
+
+func (d *DryRunDriver) NetworkConnect(id string, container string, aliases []string) (EndpointResource, error) {
+	d.record("connect container %s to network %s", container, id)
+	return EndpointResource{}, nil
+}
+
+func (d *DryRunDriver) NetworkDisconnect(id string, container string, force bool) error {
+	d.record("disconnect container %s from network %s", container, id)
+	return nil
+}
+
+func (d *DryRunDriver) ContainerConnectNetworks(id string, endpoints map[string]EndpointConfig, opts ConnectNetworksOptions) error {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.record("connect container %s to network %s", id, name)
+	}
+	return nil
+}
+
+func (d *DryRunDriver) ContainersPrune(filters Filters) (PruneReport, error) {
+	d.record("prune unused containers matching %v", filters)
+	return PruneReport{}, nil
+}
+
+func (d *DryRunDriver) ImagesPrune(filters Filters) (PruneReport, error) {
+	d.record("prune unused images matching %v", filters)
+	return PruneReport{}, nil
+}
+
+func (d *DryRunDriver) NetworksPrune(filters Filters) (PruneReport, error) {
+	d.record("prune unused networks matching %v", filters)
+	return PruneReport{}, nil
+}
+
+func (d *DryRunDriver) VolumesPrune(filters Filters) (PruneReport, error) {
+	d.record("prune unused volumes matching %v", filters)
+	return PruneReport{}, nil
+}
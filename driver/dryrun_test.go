@@ -0,0 +1,104 @@
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDryRunRemoveLeavesStateUnchanged(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.NetworkCreate("skupper", NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := DryRun(m)
+	if err := d.ContainerStop(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ContainerRemove(id, RemoveOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.NetworkRemove("skupper", false); err != nil {
+		t.Fatal(err)
+	}
+
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		t.Fatalf("Expected the container to survive the dry run, got %v", err)
+	}
+	if !icd.State.Running {
+		t.Errorf("Expected the container to still be running")
+	}
+	if _, err := m.NetworkInspect("skupper"); err != nil {
+		t.Errorf("Expected the network to survive the dry run, got %v", err)
+	}
+	want := []string{
+		"stop container " + id,
+		"remove container " + id,
+		"remove network skupper",
+	}
+	if got := d.(*DryRunDriver).Planned(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected planned %q, got %q", want, got)
+	}
+}
+
+func TestDryRunInterceptsMutatingCalls(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := m.called("ContainerStart")
+	d := DryRun(m)
+	if _, err := d.ImagesPull("quay.io/skupper/service-controller", ImagePullOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ContainerStart(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.NetworkConnect("skupper", id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ContainerExec(id, []string{"rm", "-rf", "/data"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ContainerExecDetached(id, ExecOptions{Cmd: []string{"sleep", "10"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ContainerStart"); n != started {
+		t.Errorf("Expected the start not to reach the engine, got %d calls", n-started)
+	}
+	for _, method := range []string{"ImagesPull", "NetworkConnect", "ContainerExecWithOptions", "ContainerExecDetached"} {
+		if n := m.called(method); n != 0 {
+			t.Errorf("Expected %s not to reach the engine, got %d calls", method, n)
+		}
+	}
+	if got := len(d.(*DryRunDriver).Planned()); got != 5 {
+		t.Errorf("Expected 5 planned operations, got %d", got)
+	}
+
+	// reads pass through
+	if _, err := d.ContainerInspect(id); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ContainerInspect"); n != 1 {
+		t.Errorf("Expected the inspect to reach the engine, got %d calls", n)
+	}
+}
+
+func TestDryRunCopyImageDoesNotBlock(t *testing.T) {
+	src := newMockDriver()
+	src.addImage("quay.io/skupper/router:1.0", ImageInspect{})
+	dst := newMockDriver()
+	if err := CopyImage(context.Background(), src, DryRun(dst), "quay.io/skupper/router:1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := dst.ImageExists("quay.io/skupper/router:1.0"); exists {
+		t.Errorf("Expected the dry run not to load the image")
+	}
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolveEnv merges spec.EnvFiles and spec.Env into a single list of
+// KEY=VALUE entries. Later files override earlier ones and Env overrides
+// all files.
+func (spec ContainerSpec) ResolveEnv() ([]string, error) {
+	if len(spec.EnvFiles) == 0 {
+		return spec.Env, nil
+	}
+	var (
+		keys   []string
+		values = map[string]string{}
+	)
+	set := func(entry string) {
+		key := strings.SplitN(entry, "=", 2)[0]
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = entry
+	}
+	for _, file := range spec.EnvFiles {
+		entries, err := ParseEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			set(entry)
+		}
+	}
+	for _, entry := range spec.Env {
+		set(entry)
+	}
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, values[key])
+	}
+	return env, nil
+}
+
+// ParseEnvFile reads KEY=VALUE lines from filename, skipping blank lines
+// and lines starting with '#'. As with docker's --env-file, a line holding
+// only a name takes its value from the current environment and is dropped
+// when the variable is unset.
+func ParseEnvFile(filename string) ([]string, error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read env file: %w", err)
+	}
+	defer fh.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(fh)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			return nil, fmt.Errorf("Invalid line %d in env file %s: no variable name", n, filename)
+		}
+		if strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("Invalid line %d in env file %s: variable %q contains whitespace", n, filename, name)
+		}
+		if len(parts) == 1 {
+			if value, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+value)
+			}
+			continue
+		}
+		env = append(env, name+"="+parts[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Couldn't read env file %s: %w", filename, err)
+	}
+	return env, nil
+}
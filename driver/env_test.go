@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveEnvPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := writeEnvFile(t, dir, "base.env", "# router defaults\nQDROUTERD_CONF=/etc/qpid-dispatch/qdrouterd.json\nSKUPPER_SITE_ID=base\n\nLOG_LEVEL=info\n")
+	site := writeEnvFile(t, dir, "site.env", "SKUPPER_SITE_ID=site\n  LOG_LEVEL=debug\n")
+
+	spec := ContainerSpec{
+		EnvFiles: []string{base, site},
+		Env:      []string{"LOG_LEVEL=trace"},
+	}
+	env, err := spec.ResolveEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"QDROUTERD_CONF=/etc/qpid-dispatch/qdrouterd.json",
+		"SKUPPER_SITE_ID=site",
+		"LOG_LEVEL=trace",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("Expected %q, got %q", want, env)
+	}
+}
+
+func TestResolveEnvMissingFile(t *testing.T) {
+	spec := ContainerSpec{EnvFiles: []string{"/nonexistent/router.env"}}
+	if _, err := spec.ResolveEnv(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file error, got %v", err)
+	}
+}
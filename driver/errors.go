@@ -0,0 +1,335 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported is returned, possibly wrapped, when an engine cannot
+// perform the requested operation or honor an option.
+var ErrNotSupported = errors.New("not supported")
+
+// ErrNotFound is matched, through an OperationError, by errors an engine
+// returns for a container, image or network that does not exist.
+var ErrNotFound = errors.New("not found")
+
+// OperationError carries the operation, resource and engine behind an error
+// returned by a driver. The engine's error stays reachable through Unwrap.
+type OperationError struct {
+	Op       string
+	Resource string
+	Engine   string
+	Err      error
+	notFound bool
+}
+
+func (e *OperationError) Error() string {
+	if e.Resource == "" {
+		return fmt.Sprintf("%s %s: %v", e.Engine, e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s %s: %v", e.Engine, e.Op, e.Resource, e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports a not found error from the engine as ErrNotFound.
+func (e *OperationError) Is(target error) bool {
+	return target == ErrNotFound && e.notFound
+}
+
+// WrapOperationError wraps err in an OperationError unless it is nil or
+// already wrapped, so that operations built on others keep the innermost
+// context. notFound marks err as the engine's report of a missing resource.
+func WrapOperationError(engine, op, resource string, err error, notFound bool) error {
+	if err == nil {
+		return nil
+	}
+	var opErr *OperationError
+	if errors.As(err, &opErr) {
+		return err
+	}
+	return &OperationError{Op: op, Resource: resource, Engine: engine, Err: err, notFound: notFound}
+}
+
+// NetworkInUseError is returned when a network cannot be removed because
+// containers are still attached to it.
+type NetworkInUseError struct {
+	Network   string
+	Endpoints []string
+}
+
+func (e *NetworkInUseError) Error() string {
+	return fmt.Sprintf("network %s is in use by containers %s", e.Network, strings.Join(e.Endpoints, ", "))
+}
+
+// NetworkOwnedByOtherError is returned by EnsureNetwork when a network of
+// the wanted name exists but was created for a different owner, or none.
+type NetworkOwnedByOtherError struct {
+	Network string
+	Owner   string
+	Want    string
+}
+
+func (e *NetworkOwnedByOtherError) Error() string {
+	if e.Owner == "" {
+		return fmt.Sprintf("network %s exists but is not owned by %s", e.Network, e.Want)
+	}
+	return fmt.Sprintf("network %s is owned by %s, not %s", e.Network, e.Owner, e.Want)
+}
+
+// ContainerRunningError is returned when an operation needs a stopped
+// container, e.g. reading its exit code.
+type ContainerRunningError struct {
+	ID string
+}
+
+func (e *ContainerRunningError) Error() string {
+	return fmt.Sprintf("container %s is still running", e.ID)
+}
+
+// ContainerNotRunningError is returned when an operation needs a running
+// container, e.g. reading its uptime.
+type ContainerNotRunningError struct {
+	ID string
+}
+
+func (e *ContainerNotRunningError) Error() string {
+	return fmt.Sprintf("container %s is not running", e.ID)
+}
+
+// AmbiguousReferenceError is returned when a short reference matches more
+// than one container.
+type AmbiguousReferenceError struct {
+	Ref     string
+	Matches []string
+}
+
+func (e *AmbiguousReferenceError) Error() string {
+	return fmt.Sprintf("reference %s matches multiple containers %s", e.Ref, strings.Join(e.Matches, ", "))
+}
+
+// PullInterruptedError is returned when a pull was cancelled or timed out
+// rather than rejected, so retrying it may succeed. CompletedLayers lists
+// the layers already downloaded, which a retry does not fetch again.
+type PullInterruptedError struct {
+	Ref             string
+	CompletedLayers []string
+	Err             error
+}
+
+func (e *PullInterruptedError) Error() string {
+	return fmt.Sprintf("pull of %s interrupted after %d layers: %v", e.Ref, len(e.CompletedLayers), e.Err)
+}
+
+func (e *PullInterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// PullStalledError is the Err of a PullInterruptedError when a pull was
+// abandoned because the engine reported no progress on it for Timeout. It
+// matches context.DeadlineExceeded.
+type PullStalledError struct {
+	Ref     string
+	Timeout time.Duration
+}
+
+func (e *PullStalledError) Error() string {
+	return fmt.Sprintf("pull of %s made no progress for %v", e.Ref, e.Timeout)
+}
+
+func (e *PullStalledError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// PathNotFoundError is returned when a path does not exist in a container.
+type PathNotFoundError struct {
+	ID   string
+	Path string
+}
+
+func (e *PathNotFoundError) Error() string {
+	return fmt.Sprintf("no such file or directory %s in container %s", e.Path, e.ID)
+}
+
+// ExecTimeoutError is returned when an exec outlives ExecOptions.Timeout.
+// Result holds the output captured until then, when the exec was buffered.
+// It matches context.DeadlineExceeded.
+type ExecTimeoutError struct {
+	ContainerID string
+	ExecID      string
+	Timeout     time.Duration
+	Result      ExecResult
+	// KillErr is nil when the exec's process was killed or had already
+	// exited, and otherwise says why it may still be running, e.g.
+	// ErrProcessNotLocal.
+	KillErr error
+}
+
+func (e *ExecTimeoutError) Error() string {
+	return fmt.Sprintf("exec in container %s timed out after %v", e.ContainerID, e.Timeout)
+}
+
+func (e *ExecTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// DigestMismatchError is returned when the local image a digest-pinned
+// reference resolves to does not carry that digest.
+type DigestMismatchError struct {
+	Ref      string
+	Expected string
+	// Actual lists the repo digests the local image does have.
+	Actual []string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("image %s does not match digest %s, it has %s", e.Ref, e.Expected, strings.Join(e.Actual, ", "))
+}
+
+// PortConflictError is returned when a container cannot start because a
+// host port it publishes is already taken. Port is the host address and
+// port as the engine reported it, e.g. "0.0.0.0:8080".
+type PortConflictError struct {
+	ContainerID string
+	Port        string
+	Err         error
+}
+
+func (e *PortConflictError) Error() string {
+	return fmt.Sprintf("container %s cannot start, port %s is already in use", e.ContainerID, e.Port)
+}
+
+func (e *PortConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ImageNotFoundError is returned when a container cannot start because
+// its image is no longer present.
+type ImageNotFoundError struct {
+	ContainerID string
+	Image       string
+	Err         error
+}
+
+func (e *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("container %s cannot start, image %s not found", e.ContainerID, e.Image)
+}
+
+func (e *ImageNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// StartError is returned when a container fails to start for a reason
+// that has no more specific error.
+type StartError struct {
+	ContainerID string
+	Err         error
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("container %s failed to start: %v", e.ContainerID, e.Err)
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// docker's userland proxy
+	portAllocatedPattern = regexp.MustCompile(`Bind for (\S+) failed: port is already allocated`)
+	// docker with the proxy disabled, podman and cri runtimes
+	addressInUsePattern = regexp.MustCompile(`listen (?:tcp|udp|sctp)[46]? (\S+): bind: address already in use`)
+	// docker reports "No such image: x", podman "x: image not known"
+	noSuchImagePattern   = regexp.MustCompile(`No such image: (\S+)`)
+	imageNotKnownPattern = regexp.MustCompile(`(\S+): image not known`)
+)
+
+// StartFailure classifies the error an engine returned when starting a
+// container as a PortConflictError, an ImageNotFoundError or else a
+// StartError. Context errors are returned as they are, since they say
+// nothing about the container.
+func StartFailure(containerID string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	msg := err.Error()
+	for _, pattern := range []*regexp.Regexp{portAllocatedPattern, addressInUsePattern} {
+		if m := pattern.FindStringSubmatch(msg); m != nil {
+			return &PortConflictError{ContainerID: containerID, Port: m[1], Err: err}
+		}
+	}
+	for _, pattern := range []*regexp.Regexp{noSuchImagePattern, imageNotKnownPattern} {
+		if m := pattern.FindStringSubmatch(msg); m != nil {
+			return &ImageNotFoundError{ContainerID: containerID, Image: m[1], Err: err}
+		}
+	}
+	return &StartError{ContainerID: containerID, Err: err}
+}
+
+// NameConflictError is returned when ContainerCreate finds its name taken
+// by a container running a different image.
+type NameConflictError struct {
+	Name        string
+	ContainerID string
+	Image       string
+}
+
+func (e *NameConflictError) Error() string {
+	return fmt.Sprintf("container name %s is in use by container %s running image %s", e.Name, e.ContainerID, e.Image)
+}
+
+// docker and podman report "is already in use", containerd "is reserved"
+var nameConflictPattern = regexp.MustCompile(`name "?/?\S*?"? is (?:already in use|reserved)`)
+
+// IsNameConflict reports whether err is an engine's refusal to create a
+// container under a name that is taken.
+func IsNameConflict(err error) bool {
+	return err != nil && nameConflictPattern.MatchString(err.Error())
+}
+
+// AggregateError collects the failures of a bulk operation that carries on
+// past individual errors.
+type AggregateError struct {
+	Errors []error
+}
+
+func (e *AggregateError) Error() string {
+	var msgs []string
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual failures for toolchains that walk
+// multi-error trees.
+func (e *AggregateError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is reports whether any of the individual failures matches target, so
+// errors.Is looks inside the aggregate on every supported toolchain.
+func (e *AggregateError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first individual failure that matches target.
+func (e *AggregateError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
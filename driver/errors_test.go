@@ -0,0 +1,79 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStartFailure(t *testing.T) {
+	portAllocated := errors.New("driver failed programming external connectivity on endpoint router: Bind for 0.0.0.0:8080 failed: port is already allocated")
+	var conflict *PortConflictError
+	if err := StartFailure("router", portAllocated); !errors.As(err, &conflict) || conflict.Port != "0.0.0.0:8080" {
+		t.Errorf("Expected a PortConflictError on 0.0.0.0:8080, got %v", err)
+	}
+	addressInUse := errors.New("rootlessport listen tcp 0.0.0.0:8443: bind: address already in use")
+	if err := StartFailure("router", addressInUse); !errors.As(err, &conflict) || conflict.Port != "0.0.0.0:8443" {
+		t.Errorf("Expected a PortConflictError on 0.0.0.0:8443, got %v", err)
+	}
+
+	var missing *ImageNotFoundError
+	for _, msg := range []string{
+		"No such image: quay.io/skupper/router:1.0",
+		"quay.io/skupper/router:1.0: image not known",
+	} {
+		if err := StartFailure("router", errors.New(msg)); !errors.As(err, &missing) || missing.Image != "quay.io/skupper/router:1.0" {
+			t.Errorf("Expected an ImageNotFoundError for %q, got %v", msg, err)
+		}
+	}
+
+	cause := errors.New("OCI runtime create failed")
+	var start *StartError
+	if err := StartFailure("router", cause); !errors.As(err, &start) || !errors.Is(err, cause) {
+		t.Errorf("Expected a StartError wrapping the cause, got %v", err)
+	}
+	deadline := fmt.Errorf("start: %w", context.DeadlineExceeded)
+	if err := StartFailure("router", deadline); err != deadline {
+		t.Errorf("Expected a context error to be returned as is, got %v", err)
+	}
+	if err := StartFailure("router", nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestWrapOperationError(t *testing.T) {
+	cause := errors.New("No such container: router")
+	err := WrapOperationError("docker", "ContainerInspect", "router", cause, true)
+	if err.Error() != "docker ContainerInspect router: No such container: router" {
+		t.Errorf("Expected the op and resource in the message, got %q", err)
+	}
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, cause) {
+		t.Errorf("Expected the error to match ErrNotFound and its cause")
+	}
+	if rewrapped := WrapOperationError("docker", "ContainerStart", "router", err, false); rewrapped != err {
+		t.Errorf("Expected the innermost operation to be kept, got %v", rewrapped)
+	}
+	if err := WrapOperationError("docker", "ContainerStart", "router", cause, false); errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an error not marked not found not to match ErrNotFound")
+	}
+	if err := WrapOperationError("docker", "ContainerStart", "router", nil, false); err != nil {
+		t.Errorf("Expected nil to stay nil, got %v", err)
+	}
+}
+
+func TestAggregateErrorMatchesMembers(t *testing.T) {
+	cause := errors.New("No such container: router")
+	missing := &ImageNotFoundError{Image: "quay.io/skupper/router:1.0"}
+	var err error = &AggregateError{Errors: []error{fmt.Errorf("remove: %w", cause), missing}}
+	if !errors.Is(err, cause) {
+		t.Errorf("Expected the aggregate to match a member error")
+	}
+	var notFound *ImageNotFoundError
+	if !errors.As(err, &notFound) || notFound != missing {
+		t.Errorf("Expected the aggregate to yield the ImageNotFoundError, got %v", notFound)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the aggregate not to match an unrelated error")
+	}
+}
@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"strings"
+	"time"
+)
+
+// Container event actions reported by ContainerEvents.
+const (
+	EventStart        = "start"
+	EventDie          = "die"
+	EventHealthStatus = "health_status"
+)
+
+// Event is a lifecycle event of a container.
+type Event struct {
+	ID     string
+	Action string
+	// Status carries the new health for EventHealthStatus events, e.g.
+	// "healthy".
+	Status     string
+	Attributes map[string]string
+	Time       time.Time
+}
+
+// NewContainerEvent normalizes an engine's container event, e.g. docker's
+// "health_status: healthy" or podman's "died", and reports whether it is
+// one of the actions ContainerEvents delivers.
+func NewContainerEvent(id string, action string, attributes map[string]string, t time.Time) (Event, bool) {
+	ev := Event{
+		ID:         id,
+		Action:     action,
+		Attributes: attributes,
+		Time:       t,
+	}
+	if i := strings.Index(action, ":"); i >= 0 {
+		ev.Action = action[:i]
+		ev.Status = strings.TrimSpace(action[i+1:])
+	}
+	if ev.Action == "died" {
+		ev.Action = EventDie
+	}
+	switch ev.Action {
+	case EventStart, EventDie, EventHealthStatus:
+		return ev, true
+	}
+	return Event{}, false
+}
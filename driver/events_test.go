@@ -0,0 +1,26 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewContainerEvent(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		action string
+		want   Event
+		ok     bool
+	}{
+		{"start", Event{ID: "abc", Action: EventStart, Time: now}, true},
+		{"died", Event{ID: "abc", Action: EventDie, Time: now}, true},
+		{"health_status: healthy", Event{ID: "abc", Action: EventHealthStatus, Status: "healthy", Time: now}, true},
+		{"exec_start: sh", Event{}, false},
+		{"attach", Event{}, false},
+	} {
+		ev, ok := NewContainerEvent("abc", tc.action, nil, now)
+		if ok != tc.ok || ev.ID != tc.want.ID || ev.Action != tc.want.Action || ev.Status != tc.want.Status {
+			t.Errorf("Expected %q to give %+v (%v), got %+v (%v)", tc.action, tc.want, tc.ok, ev, ok)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// fallbackDriver sends every call to primary and repeats it on secondary
+// when shouldFallback accepts the primary's error. The embedded Driver is
+// the primary.
+type fallbackDriver struct {
+	Driver
+	secondary      Driver
+	shouldFallback func(error) bool
+}
+
+// Fallback returns a Driver that uses primary and retries a call on
+// secondary when shouldFallback returns true for the primary's error, e.g.
+// when the docker daemon is unreachable.
+//
+// Retrying is only safe for idempotent operations: a create or remove that
+// reached the primary before failing may be applied twice, and resource IDs
+// returned by one engine mean nothing to the other.
+func Fallback(primary, secondary Driver, shouldFallback func(error) bool) Driver {
+	return &fallbackDriver{
+		Driver:         primary,
+		secondary:      secondary,
+		shouldFallback: shouldFallback,
+	}
+}
+
+func (f *fallbackDriver) try(fn func(d Driver) error) error {
+	err := fn(f.Driver)
+	if err != nil && f.shouldFallback(err) {
+		return fn(f.secondary)
+	}
+	return err
+}
+
+// New connects both drivers. It only fails if neither can connect, since
+// the secondary exists to cover an unavailable primary.
+func (f *fallbackDriver) New(ctx context.Context, options ConnectOptions) error {
+	perr := f.Driver.New(ctx, options)
+	serr := f.secondary.New(ctx, options)
+	if perr != nil && serr != nil {
+		return perr
+	}
+	return nil
+}
+
+func (f *fallbackDriver) Reconnect(options ConnectOptions) error {
+	perr := f.Driver.Reconnect(options)
+	serr := f.secondary.Reconnect(options)
+	if perr != nil && serr != nil {
+		return perr
+	}
+	return nil
+}
+
+func (f *fallbackDriver) Close() error {
+	perr := f.Driver.Close()
+	serr := f.secondary.Close()
+	if perr != nil {
+		return perr
+	}
+	return serr
+}
+
+func (f *fallbackDriver) SupportsFeature(feature Feature) (res bool, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.SupportsFeature(feature)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImageInspect(id string, options ImageInspectOptions) (res *ImageInspect, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImageInspect(id, options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImagesList(options ImageListOptions) (res []ImageSummary, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImagesList(options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImagesDiskUsage() (res ImagesDiskReport, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImagesDiskUsage()
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImagesPull(refStr string, options ImagePullOptions) (res []string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImagesPull(refStr, options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImageExists(ref string) (res bool, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImageExists(ref)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImageWait(ctx context.Context, ref string, interval time.Duration) error {
+	return f.try(func(d Driver) error {
+		return d.ImageWait(ctx, ref, interval)
+	})
+}
+
+func (f *fallbackDriver) ImageTag(src string, dst string) error {
+	return f.try(func(d Driver) error {
+		return d.ImageTag(src, dst)
+	})
+}
+
+func (f *fallbackDriver) ImageSave(ctx context.Context, refs []string) (res io.ReadCloser, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImageSave(ctx, refs)
+		return err
+	})
+	return res, err
+}
+
+// ImageLoad is not retried, since the primary may have consumed part of r
+// before failing.
+func (f *fallbackDriver) ImageLoad(ctx context.Context, r io.Reader) ([]string, error) {
+	return f.Driver.ImageLoad(ctx, r)
+}
+
+func (f *fallbackDriver) ContainerCreate(spec ContainerSpec) (res ContainerCreateResponse, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerCreate(spec)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerStart(id string) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerStart(id)
+	})
+}
+
+func (f *fallbackDriver) ContainerWait(id string, state string, timeout time.Duration, interval time.Duration) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerWait(id, state, timeout, interval)
+	})
+}
+
+func (f *fallbackDriver) ContainerWaitWithOptions(id string, opts WaitOptions) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerWaitWithOptions(id, opts)
+	})
+}
+
+func (f *fallbackDriver) ContainerList(options ContainerListOptions) (res []Container, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerList(options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerInspect(id string) (res *InspectContainerData, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerInspect(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerImageDigest(id string) (res string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerImageDigest(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImageUpToDate(id string, ref string) (res bool, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImageUpToDate(id, ref)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ResolveContainer(nameOrPrefix string) (res string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ResolveContainer(nameOrPrefix)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerSpecOf(id string) (res ContainerSpec, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerSpecOf(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerMatchesSpec(id string, spec ContainerSpec) (res bool, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerMatchesSpec(id, spec)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) IsManaged(id string) (res bool, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.IsManaged(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerStop(id string) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerStop(id)
+	})
+}
+
+func (f *fallbackDriver) ContainerStopWithTimeout(id string, timeout time.Duration) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerStopWithTimeout(id, timeout)
+	})
+}
+
+func (f *fallbackDriver) ContainerExitCode(id string) (res int, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerExitCode(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerUptime(id string) (res time.Duration, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerUptime(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerRestartCount(id string) (res int, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerRestartCount(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerRemove(id string, options RemoveOptions) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerRemove(id, options)
+	})
+}
+
+func (f *fallbackDriver) ContainerExec(id string, cmd []string) (res ExecResult, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerExec(id, cmd)
+		return err
+	})
+	return res, err
+}
+
+// ContainerExecStream is not retried, since the primary may have run the
+// command and written part of its output before failing.
+func (f *fallbackDriver) ContainerExecStream(id string, opts ExecOptions, stdout io.Writer, stderr io.Writer) (int, error) {
+	return f.Driver.ContainerExecStream(id, opts, stdout, stderr)
+}
+
+func (f *fallbackDriver) ContainerExecWithOptions(id string, opts ExecOptions) (res ExecResult, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerExecWithOptions(id, opts)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerExecDetached(id string, opts ExecOptions) (res string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerExecDetached(id, opts)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) WaitForPort(ctx context.Context, id string, port int, proto string) error {
+	return f.try(func(d Driver) error {
+		return d.WaitForPort(ctx, id, port, proto)
+	})
+}
+
+func (f *fallbackDriver) ExecInspect(execID string) (res ExecInspect, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ExecInspect(execID)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerSummary(id string) (res ContainerSummary, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerSummary(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerStatsSnapshot(id string) (res ContainerStats, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerStatsSnapshot(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerPorts(id string) (res []Port, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerPorts(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerNetworkConfig(id string) (res NetworkConfig, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerNetworkConfig(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerStatPath(id string, path string) (res PathStat, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerStatPath(id, path)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) WatchPath(ctx context.Context, id string, path string, interval time.Duration) (res <-chan PathEvent, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.WatchPath(ctx, id, path, interval)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerEvents(ctx context.Context, id string) (events <-chan Event, errs <-chan error, err error) {
+	err = f.try(func(d Driver) (err error) {
+		events, errs, err = d.ContainerEvents(ctx, id)
+		return err
+	})
+	return events, errs, err
+}
+
+func (f *fallbackDriver) ContainerLogs(ctx context.Context, id string, options LogOptions) (res io.ReadCloser, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerLogs(ctx, id, options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainerLogPath(id string) (res string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainerLogPath(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) GenerateSystemdUnit(id string, opts SystemdOptions) (res map[string]string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.GenerateSystemdUnit(id, opts)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) NetworkCreate(name string, options NetworkCreateOptions) (res NetworkCreateResponse, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.NetworkCreate(name, options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) NetworkInspect(id string) (res NetworkResource, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.NetworkInspect(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) NetworkList(options NetworkListOptions) (res []NetworkResource, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.NetworkList(options)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) NetworkRemove(id string, force bool) error {
+	return f.try(func(d Driver) error {
+		return d.NetworkRemove(id, force)
+	})
+}
+
+func (f *fallbackDriver) NetworkConnect(id string, container string, aliases []string) (res EndpointResource, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.NetworkConnect(id, container, aliases)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) NetworkDisconnect(id string, container string, force bool) error {
+	return f.try(func(d Driver) error {
+		return d.NetworkDisconnect(id, container, force)
+	})
+}
+
+func (f *fallbackDriver) ContainerConnectNetworks(id string, endpoints map[string]EndpointConfig, opts ConnectNetworksOptions) error {
+	return f.try(func(d Driver) error {
+		return d.ContainerConnectNetworks(id, endpoints, opts)
+	})
+}
+
+func (f *fallbackDriver) NetworkGateway(id string) (res string, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.NetworkGateway(id)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ContainersPrune(filters Filters) (res PruneReport, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ContainersPrune(filters)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) ImagesPrune(filters Filters) (res PruneReport, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.ImagesPrune(filters)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) NetworksPrune(filters Filters) (res PruneReport, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.NetworksPrune(filters)
+		return err
+	})
+	return res, err
+}
+
+func (f *fallbackDriver) VolumesPrune(filters Filters) (res PruneReport, err error) {
+	err = f.try(func(d Driver) (err error) {
+		res, err = d.VolumesPrune(filters)
+		return err
+	})
+	return res, err
+}
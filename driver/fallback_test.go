@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"errors"
+	"io/ioutil"
+	"syscall"
+	"testing"
+)
+
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func TestFallbackRetriesOnSecondary(t *testing.T) {
+	primary := newMockDriver()
+	primary.fail("ContainerCreate", syscall.ECONNREFUSED)
+	secondary := newMockDriver()
+	secondary.addImage("quay.io/skupper/router:latest", ImageInspect{})
+
+	d := Fallback(primary, secondary, isConnRefused)
+	res, err := d.ContainerCreate(ContainerSpec{Name: "router", Image: "quay.io/skupper/router:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secondary.ContainerInspect(res.ID); err != nil {
+		t.Errorf("Expected the container on the secondary, got %v", err)
+	}
+	if n := primary.called("ContainerCreate"); n != 1 {
+		t.Errorf("Expected the primary to be tried once, got %d", n)
+	}
+}
+
+func TestFallbackKeepsOtherErrors(t *testing.T) {
+	primary := newMockDriver()
+	secondary := newMockDriver()
+	if _, err := secondary.runContainer(ContainerSpec{Name: "router"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := Fallback(primary, secondary, isConnRefused)
+	if _, err := d.ContainerInspect("router"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the primary's not found error, got %v", err)
+	}
+	if n := secondary.called("ContainerInspect"); n != 0 {
+		t.Errorf("Expected no call on the secondary, got %d", n)
+	}
+}
+
+func TestFallbackDoesNotRetryExecStream(t *testing.T) {
+	primary := newMockDriver()
+	primary.fail("ContainerExecStream", syscall.ECONNREFUSED)
+	secondary := newMockDriver()
+
+	d := Fallback(primary, secondary, isConnRefused)
+	if _, err := d.ContainerExecStream("router", ExecOptions{Cmd: []string{"true"}}, ioutil.Discard, ioutil.Discard); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Expected the primary's error, got %v", err)
+	}
+	if n := secondary.called("ContainerExecStream"); n != 0 {
+		t.Errorf("Expected no call on the secondary, got %d", n)
+	}
+}
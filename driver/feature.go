@@ -0,0 +1,19 @@
+package driver
+
+// Feature names an engine capability that depends on how the daemon is
+// configured rather than on the driver, see SupportsFeature.
+type Feature string
+
+const (
+	// SwarmSecrets are available when the docker daemon is a swarm node.
+	SwarmSecrets Feature = "swarm-secrets"
+	// CgroupV2 reports that the engine runs containers under the unified
+	// cgroup v2 hierarchy.
+	CgroupV2 Feature = "cgroup-v2"
+	// BuildKit reports that the engine can build images with BuildKit.
+	BuildKit Feature = "buildkit"
+	// RootlessNetworking reports that the engine runs rootless, so
+	// container networking goes through a user mode network stack and
+	// ports below 1024 cannot be published.
+	RootlessNetworking Feature = "rootless-networking"
+)
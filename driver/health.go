@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContainerSummary is a container's state, uptime, restart count, resource
+// usage and health, e.g. for a dashboard row. Uptime and Stats are zero
+// unless the container is running.
+type ContainerSummary struct {
+	ID           string
+	Name         string
+	State        ContainerState
+	Uptime       time.Duration
+	RestartCount int
+	Stats        ContainerStats
+	// Health is one of the Health constants, or empty when the container
+	// has no healthcheck.
+	Health string
+}
+
+// ContainerHealthStatus is one container's entry in a HealthOverview.
+type ContainerHealthStatus struct {
+	ID     string
+	Name   string
+	Status string
+	// Health is one of the Health constants, or empty when the container
+	// has no healthcheck.
+	Health        string
+	FailingStreak int
+	// Err is set when the container's state could not be read, e.g.
+	// because it was removed after being listed.
+	Err error
+}
+
+// Ready reports whether the container is running and, when it has a
+// healthcheck, healthy.
+func (s ContainerHealthStatus) Ready() bool {
+	return s.Err == nil && s.Status == "running" && (s.Health == "" || s.Health == HealthHealthy)
+}
+
+// HealthOverview reports the state and health of every container, running
+// or not, matching filter. Containers are inspected concurrently, at most
+// DefaultInspectConcurrency at a time; a failed inspect is reported in the
+// container's entry rather than failing the overview.
+func HealthOverview(d Driver, filter Filters) ([]ContainerHealthStatus, error) {
+	list, err := d.ContainerList(ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+	overview := make([]ContainerHealthStatus, len(list))
+	var wg sync.WaitGroup
+	sem := NewSemaphore(DefaultInspectConcurrency)
+	for i, c := range list {
+		status := &overview[i]
+		status.ID = c.ID
+		status.Status = c.State
+		if len(c.Names) > 0 {
+			status.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer sem.Release()
+			icd, err := d.ContainerInspect(status.ID)
+			if err != nil {
+				status.Err = err
+				return
+			}
+			if icd.State == nil {
+				return
+			}
+			status.Status = icd.State.Status
+			if h := icd.State.Health; h != nil {
+				status.Health = h.Status
+				status.FailingStreak = h.FailingStreak
+			}
+		}()
+	}
+	wg.Wait()
+	return overview, nil
+}
+
+// ContainerUptime returns how long the container has been running, going
+// by the StartedAt time of its state. A container that is not running has
+// no uptime and returns a ContainerNotRunningError.
+func ContainerUptime(d Driver, id string) (time.Duration, error) {
+	icd, err := d.ContainerInspect(id)
+	if err != nil {
+		return 0, err
+	}
+	if icd.State == nil || !icd.State.Running || icd.State.StartedAt.IsZero() {
+		return 0, &ContainerNotRunningError{ID: id}
+	}
+	uptime := time.Since(icd.State.StartedAt)
+	if uptime < 0 {
+		// the engine's clock is ahead of ours
+		uptime = 0
+	}
+	return uptime, nil
+}
+
+// ContainerRestartCount returns how many times the engine restarted the
+// container under its restart policy, e.g. to detect a crash loop.
+func ContainerRestartCount(d Driver, id string) (int, error) {
+	icd, err := d.ContainerInspect(id)
+	if err != nil {
+		return 0, err
+	}
+	return icd.RestartCount, nil
+}
+
+// SummarizeContainer builds the container's ContainerSummary from a single
+// inspect and, while it runs, a stats snapshot. Engines that cannot take
+// stats leave Stats zero.
+func SummarizeContainer(d Driver, id string) (ContainerSummary, error) {
+	icd, err := d.ContainerInspect(id)
+	if err != nil {
+		return ContainerSummary{}, err
+	}
+	summary := ContainerSummary{
+		ID:           icd.ID,
+		Name:         strings.TrimPrefix(icd.Name, "/"),
+		RestartCount: icd.RestartCount,
+	}
+	if icd.State == nil {
+		return summary, nil
+	}
+	summary.State = *icd.State
+	if h := icd.State.Health; h != nil {
+		summary.Health = h.Status
+	}
+	if !icd.State.Running {
+		return summary, nil
+	}
+	if !icd.State.StartedAt.IsZero() {
+		if uptime := time.Since(icd.State.StartedAt); uptime > 0 {
+			summary.Uptime = uptime
+		}
+	}
+	stats, err := d.ContainerStatsSnapshot(id)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		return ContainerSummary{}, err
+	}
+	summary.Stats = stats
+	return summary, nil
+}
@@ -0,0 +1,135 @@
+package driver
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHealthOverview(t *testing.T) {
+	m := newMockDriver()
+	labels := map[string]string{"application": "skupper"}
+	run := func(name string) string {
+		id, err := m.runContainer(ContainerSpec{Name: name, Labels: labels})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	healthy := run("healthy")
+	m.update(healthy, func(c *mockContainer) {
+		c.icd.State.Health = &Health{Status: HealthHealthy}
+	})
+	unhealthy := run("unhealthy")
+	m.update(unhealthy, func(c *mockContainer) {
+		c.icd.State.Health = &Health{Status: HealthUnhealthy, FailingStreak: 3}
+	})
+	run("plain")
+	m.exit(run("exited"), 1)
+	if _, err := m.runContainer(ContainerSpec{Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	overview, err := HealthOverview(m, LabelFilter("application", "skupper"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(overview, func(i, j int) bool { return overview[i].Name < overview[j].Name })
+	want := []struct {
+		name, status, health string
+		streak               int
+		ready                bool
+	}{
+		{"exited", "exited", "", 0, false},
+		{"healthy", "running", HealthHealthy, 0, true},
+		{"plain", "running", "", 0, true},
+		{"unhealthy", "running", HealthUnhealthy, 3, false},
+	}
+	if len(overview) != len(want) {
+		t.Fatalf("Expected %d containers, got %+v", len(want), overview)
+	}
+	for i, w := range want {
+		got := overview[i]
+		if got.Name != w.name || got.Status != w.status || got.Health != w.health || got.FailingStreak != w.streak || got.Ready() != w.ready {
+			t.Errorf("Expected %+v, got %+v (ready %v)", w, got, got.Ready())
+		}
+	}
+}
+
+func TestContainerUptime(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	uptime, err := ContainerUptime(m, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime <= 0 || uptime > time.Minute {
+		t.Errorf("Expected a small positive uptime, got %v", uptime)
+	}
+
+	m.exit(id, 0)
+	uptime, err = ContainerUptime(m, id)
+	if !errors.As(err, new(*ContainerNotRunningError)) || uptime != 0 {
+		t.Errorf("Expected no uptime and a ContainerNotRunningError once stopped, got %v, %v", uptime, err)
+	}
+}
+
+func TestContainerRestartCount(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.update(id, func(c *mockContainer) { c.icd.RestartCount = 4 })
+	if count, err := ContainerRestartCount(m, id); err != nil || count != 4 {
+		t.Errorf("Expected 4 restarts, got %d, %v", count, err)
+	}
+	if _, err := ContainerRestartCount(m, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing container to be reported, got %v", err)
+	}
+}
+
+func TestSummarizeContainer(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.update(id, func(c *mockContainer) {
+		c.icd.RestartCount = 2
+		c.icd.State.Health = &Health{Status: HealthHealthy}
+		c.stats.MemoryUsage = 64 << 20
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	summary, err := SummarizeContainer(m, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != id || summary.Name != "router" || !summary.State.Running {
+		t.Errorf("Expected running container router, got %+v", summary)
+	}
+	if summary.Uptime <= 0 || summary.RestartCount != 2 || summary.Health != HealthHealthy {
+		t.Errorf("Expected uptime, 2 restarts and healthy, got %v, %d and %q", summary.Uptime, summary.RestartCount, summary.Health)
+	}
+	if summary.Stats.ID != id || summary.Stats.MemoryUsage != 64<<20 {
+		t.Errorf("Expected a stats snapshot, got %+v", summary.Stats)
+	}
+	if n := m.called("ContainerInspect"); n != 1 {
+		t.Errorf("Expected a single inspect, got %d", n)
+	}
+
+	m.exit(id, 1)
+	summary, err = SummarizeContainer(m, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Uptime != 0 || summary.Stats.ID != "" {
+		t.Errorf("Expected no uptime or stats once exited, got %v and %+v", summary.Uptime, summary.Stats)
+	}
+}
@@ -0,0 +1,248 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultTagConcurrency bounds the ImageTag calls RetagAll has in flight.
+const DefaultTagConcurrency = 4
+
+// ImageLabel returns the value of the label key in the config of image ref
+// and whether the label is set.
+func ImageLabel(d Driver, ref string, key string) (string, bool, error) {
+	image, err := d.ImageInspect(ref, ImageInspectOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := image.Labels[key]
+	return value, ok, nil
+}
+
+// ParsePlatform splits an "os/arch[/variant]" platform such as
+// "linux/arm64/v8" into its parts.
+func ParsePlatform(platform string) (os string, arch string, variant string, err error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", "", fmt.Errorf("Invalid platform %s, expected os/arch[/variant]", platform)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", "", "", fmt.Errorf("Invalid platform %s, expected os/arch[/variant]", platform)
+		}
+	}
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+	return parts[0], parts[1], variant, nil
+}
+
+// CheckPlatform returns an error unless image was built for platform. The
+// variant is only compared when both the platform and the image have one.
+func CheckPlatform(image *ImageInspect, platform string) error {
+	os, arch, variant, err := ParsePlatform(platform)
+	if err != nil {
+		return err
+	}
+	if image.Os != os || image.Architecture != arch || (variant != "" && image.Variant != "" && image.Variant != variant) {
+		actual := image.Os + "/" + image.Architecture
+		if image.Variant != "" {
+			actual += "/" + image.Variant
+		}
+		return fmt.Errorf("Image %s is for platform %s, not %s", image.ID, actual, platform)
+	}
+	return nil
+}
+
+// VerifyDigest checks that the local image of a reference pinned by digest,
+// e.g. "quay.io/skupper/router@sha256:...", carries that digest and fails
+// with a DigestMismatchError otherwise. References without a digest are
+// not checked.
+func VerifyDigest(d Driver, ref string) error {
+	i := strings.Index(ref, "@")
+	if i < 0 {
+		return nil
+	}
+	digest := ref[i+1:]
+	if !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("Unsupported digest %s in %s", digest, ref)
+	}
+	image, err := d.ImageInspect(ref, ImageInspectOptions{})
+	if err != nil {
+		return err
+	}
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+digest) {
+			return nil
+		}
+	}
+	return &DigestMismatchError{Ref: ref, Expected: digest, Actual: image.RepoDigests}
+}
+
+// repository returns ref without its tag or digest.
+func repository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// SplitTag splits ref into its repository and tag, which defaults to
+// "latest", for engines that take them apart. A digest cannot be a tag.
+func SplitTag(ref string) (string, string, error) {
+	if strings.Contains(ref, "@") {
+		return "", "", fmt.Errorf("Can't tag with digest reference %s", ref)
+	}
+	repo := repository(ref)
+	if repo == ref {
+		return repo, "latest", nil
+	}
+	return repo, ref[len(repo)+1:], nil
+}
+
+// imageDigest returns the digest image was pulled by from the repository
+// of ref, falling back to its first repo digest and then to its ID for an
+// image that never came from a registry.
+func imageDigest(image *ImageInspect, ref string) string {
+	repo := repository(ref)
+	for _, repoDigest := range image.RepoDigests {
+		if repository(repoDigest) == repo {
+			return repoDigest[strings.Index(repoDigest, "@")+1:]
+		}
+	}
+	for _, repoDigest := range image.RepoDigests {
+		if i := strings.Index(repoDigest, "@"); i >= 0 {
+			return repoDigest[i+1:]
+		}
+	}
+	return image.ID
+}
+
+// ContainerImageDigest returns the digest of the image the container runs,
+// which may no longer be the one its image reference points to.
+func ContainerImageDigest(d Driver, id string) (string, error) {
+	icd, err := d.ContainerInspect(id)
+	if err != nil {
+		return "", err
+	}
+	image, err := d.ImageInspect(icd.Image, ImageInspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't inspect image of container %s: %w", id, err)
+	}
+	return imageDigest(image, icd.ImageName), nil
+}
+
+// ImageUpToDate reports whether the container runs the image ref points to
+// locally. The container's image is compared by ID, so a ref retagged to a
+// new image, e.g. by a pull, is reported as an update.
+func ImageUpToDate(d Driver, id string, ref string) (bool, error) {
+	icd, err := d.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	image, err := d.ImageInspect(ref, ImageInspectOptions{})
+	if err != nil {
+		return false, err
+	}
+	if image.ID == icd.Image {
+		return true, nil
+	}
+	// cri runtimes report the container's image by repo digest
+	for _, repoDigest := range image.RepoDigests {
+		if repoDigest == icd.Image {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CopyImage copies ref from src to dst, e.g. from a docker engine to a
+// podman one, by streaming src's ImageSave into dst's ImageLoad. The pipe
+// between them only moves data as fast as dst reads it, and a failure on
+// either side closes it so the other side returns instead of blocking.
+func CopyImage(ctx context.Context, src Driver, dst Driver, ref string) error {
+	archive, err := src.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return fmt.Errorf("Couldn't save image %s: %w", ref, err)
+	}
+	defer archive.Close()
+
+	pr, pw := io.Pipe()
+	saved := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(pw, archive)
+		// report before closing, so the error is waiting once the load
+		// fails because of it
+		saved <- err
+		pw.CloseWithError(err)
+	}()
+
+	_, loadErr := dst.ImageLoad(ctx, pr)
+	var saveErr error
+	select {
+	case saveErr = <-saved:
+	default:
+		// the load returned before the archive ended; stop the copy and
+		// ignore the error that causes
+		pr.Close()
+		archive.Close()
+		<-saved
+	}
+	if ctxErr := ContextErr(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	if saveErr != nil {
+		return fmt.Errorf("Couldn't save image %s: %w", ref, saveErr)
+	}
+	if loadErr != nil {
+		return fmt.Errorf("Couldn't load image %s: %w", ref, loadErr)
+	}
+	return nil
+}
+
+// RetagAll tags each source image in mappings with its destination
+// reference, e.g. to mirror images to another registry, running at most
+// DefaultTagConcurrency tags at a time. Failed tags are returned by source
+// and also together, in source order, as an AggregateError.
+func RetagAll(d Driver, mappings map[string]string) (map[string]error, error) {
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	failed := map[string]error{}
+	sem := NewSemaphore(DefaultTagConcurrency)
+	for src, dst := range mappings {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(src, dst string) {
+			defer wg.Done()
+			defer sem.Release()
+			if err := d.ImageTag(src, dst); err != nil {
+				lock.Lock()
+				failed[src] = fmt.Errorf("Couldn't tag %s as %s: %w", src, dst, err)
+				lock.Unlock()
+			}
+		}(src, dst)
+	}
+	wg.Wait()
+	if len(failed) == 0 {
+		return failed, nil
+	}
+	srcs := make([]string, 0, len(failed))
+	for src := range failed {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+	errs := make([]error, 0, len(srcs))
+	for _, src := range srcs {
+		errs = append(errs, failed[src])
+	}
+	return failed, &AggregateError{Errors: errs}
+}
@@ -0,0 +1,171 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImageLabel(t *testing.T) {
+	m := newMockDriver()
+	ref := "quay.io/skupper/router:1.0"
+	m.addImage(ref, ImageInspect{Labels: map[string]string{"io.skupper.config-path": "/etc/skupper-router"}})
+
+	value, ok, err := ImageLabel(m, ref, "io.skupper.config-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || value != "/etc/skupper-router" {
+		t.Errorf("Expected /etc/skupper-router, got %q (set %v)", value, ok)
+	}
+	if _, ok, err := ImageLabel(m, ref, "io.skupper.missing"); err != nil || ok {
+		t.Errorf("Expected a missing label to be unset, got %v, %v", ok, err)
+	}
+	if _, _, err := ImageLabel(m, "quay.io/skupper/missing", "io.skupper.config-path"); err == nil {
+		t.Errorf("Expected an error for a missing image")
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	os, arch, variant, err := ParsePlatform("linux/arm64/v8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os != "linux" || arch != "arm64" || variant != "v8" {
+		t.Errorf("Expected linux, arm64 and v8, got %s, %s and %s", os, arch, variant)
+	}
+	for _, platform := range []string{"linux", "linux/", "/amd64", "linux/arm/v7/extra"} {
+		if _, _, _, err := ParsePlatform(platform); err == nil {
+			t.Errorf("Expected platform %q to be rejected", platform)
+		}
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	image := &ImageInspect{ID: "sha256:abc", Os: "linux", Architecture: "arm", Variant: "v7"}
+	for platform, matches := range map[string]bool{
+		"linux/arm":    true,
+		"linux/arm/v7": true,
+		"linux/arm/v6": false,
+		"linux/amd64":  false,
+	} {
+		if err := CheckPlatform(image, platform); (err == nil) != matches {
+			t.Errorf("Expected platform %s matches=%v, got %v", platform, matches, err)
+		}
+	}
+}
+
+func TestContainerCreateVerifiesDigest(t *testing.T) {
+	m := newMockDriver()
+	pinned := "quay.io/skupper/router@sha256:" + strings.Repeat("a", 64)
+	m.addImage("quay.io/skupper/router:1.0", ImageInspect{RepoDigests: []string{pinned}})
+	if _, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: pinned, PullPolicy: PullNever}); err != nil {
+		t.Errorf("Expected creating from the matching digest to succeed, got %v", err)
+	}
+
+	// a local image stored under the reference but with another digest
+	wrong := "quay.io/skupper/router@sha256:" + strings.Repeat("b", 64)
+	m.addImage(wrong, ImageInspect{ID: "sha256:other", RepoDigests: []string{pinned}})
+	_, err := m.ContainerCreate(ContainerSpec{Name: "other", Image: wrong, PullPolicy: PullNever})
+	var mismatch *DigestMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a DigestMismatchError, got %v", err)
+	}
+	if mismatch.Expected != "sha256:"+strings.Repeat("b", 64) {
+		t.Errorf("Expected the pinned digest to be reported, got %s", mismatch.Expected)
+	}
+}
+
+func TestCopyImage(t *testing.T) {
+	ref := "quay.io/skupper/router:1.0"
+	src := newMockDriver()
+	id := src.addImage(ref, ImageInspect{Labels: map[string]string{"io.skupper.config-path": "/etc/skupper-router"}})
+	dst := newMockDriver()
+
+	if err := CopyImage(context.Background(), src, dst, ref); err != nil {
+		t.Fatal(err)
+	}
+	image, err := dst.ImageInspect(ref, ImageInspectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.ID != id || image.Labels["io.skupper.config-path"] != "/etc/skupper-router" {
+		t.Errorf("Expected %s to be copied with its labels, got %s with %v", id, image.ID, image.Labels)
+	}
+}
+
+func TestCopyImageLoadFails(t *testing.T) {
+	ref := "quay.io/skupper/router:1.0"
+	src := newMockDriver()
+	src.addImage(ref, ImageInspect{})
+	dst := newMockDriver()
+	failed := errors.New("no space left on device")
+	dst.fail("ImageLoad", failed)
+
+	// a failed load must not leave the save side blocked on the pipe
+	if err := CopyImage(context.Background(), src, dst, ref); !errors.Is(err, failed) {
+		t.Errorf("Expected the load error, got %v", err)
+	}
+	if err := CopyImage(context.Background(), src, dst, "quay.io/skupper/missing:1.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing source image to be reported, got %v", err)
+	}
+}
+
+func TestImageUpToDate(t *testing.T) {
+	ref := "quay.io/skupper/router:1.0"
+	m := newMockDriver()
+	m.addImage(ref, ImageInspect{ID: "sha256:old", RepoDigests: []string{"quay.io/skupper/router@sha256:aaa"}})
+	id, err := m.runContainer(ContainerSpec{Name: "router", Image: ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := ContainerImageDigest(m, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "sha256:aaa" {
+		t.Errorf("Expected digest sha256:aaa, got %s", digest)
+	}
+	if upToDate, err := ImageUpToDate(m, id, ref); err != nil || !upToDate {
+		t.Errorf("Expected the container to be up to date, got %v, %v", upToDate, err)
+	}
+
+	// a pull moves the tag to a new image
+	m.addImage(ref, ImageInspect{ID: "sha256:new", RepoDigests: []string{"quay.io/skupper/router@sha256:bbb"}})
+	if upToDate, err := ImageUpToDate(m, id, ref); err != nil || upToDate {
+		t.Errorf("Expected the container to be out of date after the retag, got %v, %v", upToDate, err)
+	}
+	if digest, _ := ContainerImageDigest(m, id); digest != "sha256:aaa" {
+		t.Errorf("Expected the container to still run sha256:aaa, got %s", digest)
+	}
+}
+
+func TestRetagAll(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:1.0", ImageInspect{})
+	m.addImage("quay.io/skupper/controller:1.0", ImageInspect{})
+	mappings := map[string]string{
+		"quay.io/skupper/router:1.0":     "registry.internal/skupper/router:1.0",
+		"quay.io/skupper/controller:1.0": "registry.internal/skupper/controller:1.0",
+		"quay.io/skupper/missing:1.0":    "registry.internal/skupper/missing:1.0",
+	}
+
+	failed, err := RetagAll(m, mappings)
+	var aggregate *AggregateError
+	if !errors.As(err, &aggregate) || len(aggregate.Errors) != 1 {
+		t.Fatalf("Expected one aggregated failure, got %v", err)
+	}
+	if len(failed) != 1 || !errors.Is(failed["quay.io/skupper/missing:1.0"], ErrNotFound) {
+		t.Errorf("Expected only the missing source to fail, got %v", failed)
+	}
+	for src, dst := range mappings {
+		if _, missing := failed[src]; missing {
+			continue
+		}
+		if exists, err := m.ImageExists(dst); err != nil || !exists {
+			t.Errorf("Expected %s to be tagged as %s, got %v, %v", src, dst, exists, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrProcessNotLocal is returned by KillExecProcess when the pid an engine
+// reported does not name the exec's process as seen from the driver, so
+// the process was left running.
+var ErrProcessNotLocal = errors.New("exec process is not visible to the driver")
+
+// procRoot is where KillExecProcess looks up pids.
+var procRoot = "/proc"
+
+// KillExecProcess sends SIGKILL to pid, the host pid an engine reported for
+// an exec in container containerID. Engines without an API to kill an exec
+// leave the plugins no other way, but that pid is only meaningful here when
+// host is a local unix socket and the driver shares the engine's PID
+// namespace. Anything else, including a driver running in a container,
+// returns ErrProcessNotLocal: the process is only signalled when host is a
+// unix socket and pid's cgroup, as read here, names the container.
+func KillExecProcess(host string, containerID string, pid int) error {
+	if !strings.HasPrefix(host, "unix:") {
+		return fmt.Errorf("engine at %s is not local: %w", host, ErrProcessNotLocal)
+	}
+	if containerID == "" || pid <= 0 {
+		return ErrProcessNotLocal
+	}
+	cgroup, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cgroup"))
+	if err != nil || !strings.Contains(string(cgroup), containerID) {
+		return fmt.Errorf("pid %d is not in container %s: %w", pid, containerID, ErrProcessNotLocal)
+	}
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestKillExecProcess(t *testing.T) {
+	const containerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("Couldn't start sleep: %v", err)
+	}
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+
+	root, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	saved := procRoot
+	procRoot = root
+	defer func() { procRoot = saved }()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cgroup"), []byte("0::/system.slice/docker-"+containerID+".scope\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"tcp://10.0.0.1:2376", "ssh://core@10.0.0.1/run/podman/podman.sock", ""} {
+		if err := KillExecProcess(host, containerID, pid); !errors.Is(err, ErrProcessNotLocal) {
+			t.Errorf("Expected ErrProcessNotLocal for %q, got %v", host, err)
+		}
+	}
+	if err := KillExecProcess("unix:///var/run/docker.sock", "fedcba98", pid); !errors.Is(err, ErrProcessNotLocal) {
+		t.Errorf("Expected ErrProcessNotLocal for a pid in another container, got %v", err)
+	}
+	if err := KillExecProcess("unix:///var/run/docker.sock", containerID, pid+100000); !errors.Is(err, ErrProcessNotLocal) {
+		t.Errorf("Expected ErrProcessNotLocal for an unknown pid, got %v", err)
+	}
+
+	if err := KillExecProcess("unix:///var/run/docker.sock", containerID, pid); err != nil {
+		t.Fatalf("Expected the process to be killed, got %v", err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Errorf("Expected sleep to have been killed")
+	}
+}
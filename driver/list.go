@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultInspectConcurrency bounds the number of inspect calls issued in
+// parallel when back-filling a container list.
+const DefaultInspectConcurrency = 4
+
+// BackfillContainers populates the env, mounts and state of each container
+// in list from inspect, running at most DefaultInspectConcurrency inspects
+// at a time. The first inspect error is returned.
+func BackfillContainers(list []Container, inspect func(id string) (*InspectContainerData, error)) error {
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, DefaultInspectConcurrency)
+	for i := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(c *Container) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			icd, err := inspect(c.ID)
+			if err != nil {
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				lock.Unlock()
+				return
+			}
+			c.Env = icd.Env
+			c.Mounts = icd.Mounts
+			if icd.State != nil {
+				c.State = icd.State.Status
+			}
+		}(&list[i])
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// ResolveContainer returns the full ID of the container named ref or,
+// failing that, of the one container whose ID starts with ref. A prefix
+// shared by several containers returns an AmbiguousReferenceError, and a
+// ref matching none an error matching ErrNotFound.
+func ResolveContainer(d Driver, ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("No container reference specified")
+	}
+	list, err := d.ContainerList(ContainerListOptions{All: true})
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, c := range list {
+		if c.ID == ref {
+			return c.ID, nil
+		}
+		for _, name := range c.Names {
+			if strings.TrimPrefix(name, "/") == strings.TrimPrefix(ref, "/") {
+				return c.ID, nil
+			}
+		}
+		if strings.HasPrefix(c.ID, ref) {
+			matches = append(matches, c.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("Container %s %w", ref, ErrNotFound)
+	case 1:
+		return matches[0], nil
+	}
+	return "", &AmbiguousReferenceError{Ref: ref, Matches: matches}
+}
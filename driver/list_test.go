@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContainerListInspect(t *testing.T) {
+	m := newMockDriver()
+	env := []string{"QDROUTERD_CONF=/etc/qpid-dispatch/qdrouterd.json"}
+	if _, err := m.runContainer(ContainerSpec{Name: "router", Env: env}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := m.ContainerList(ContainerListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Env != nil {
+		t.Errorf("Expected the fast path to leave env empty, got %v", list)
+	}
+	if n := m.called("ContainerInspect"); n != 0 {
+		t.Errorf("Expected no inspects on the fast path, got %d", n)
+	}
+
+	list, err = m.ContainerList(ContainerListOptions{Inspect: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !reflect.DeepEqual(list[0].Env, env) {
+		t.Errorf("Expected env %v to be backfilled, got %v", env, list)
+	}
+	if list[0].State != "running" {
+		t.Errorf("Expected state running, got %q", list[0].State)
+	}
+}
+
+func TestBackfillContainersBoundsConcurrency(t *testing.T) {
+	list := make([]Container, 3*DefaultInspectConcurrency)
+	for i := range list {
+		list[i].ID = fmt.Sprintf("c%d", i)
+	}
+	var (
+		lock            sync.Mutex
+		running, maxRan int
+	)
+	err := BackfillContainers(list, func(id string) (*InspectContainerData, error) {
+		lock.Lock()
+		running++
+		if running > maxRan {
+			maxRan = running
+		}
+		lock.Unlock()
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if id == "c5" {
+			return nil, fmt.Errorf("No such container: %s", id)
+		}
+		return &InspectContainerData{Env: []string{"ID=" + id}}, nil
+	})
+	if err == nil {
+		t.Errorf("Expected the inspect error to be returned")
+	}
+	if maxRan > DefaultInspectConcurrency {
+		t.Errorf("Expected at most %d concurrent inspects, got %d", DefaultInspectConcurrency, maxRan)
+	}
+	if got := list[0].Env; len(got) != 1 || got[0] != "ID=c0" {
+		t.Errorf("Expected c0 to be backfilled, got %v", got)
+	}
+}
+
+func TestResolveContainer(t *testing.T) {
+	m := newMockDriver()
+	// run containers until two IDs share a first character, which takes at
+	// most 17 of them
+	byFirst := map[byte]string{}
+	var router, shared []string
+	for i := 0; shared == nil; i++ {
+		id, err := m.runContainer(ContainerSpec{Name: fmt.Sprintf("router-%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			router = []string{"router-0", id}
+		}
+		if other, ok := byFirst[id[0]]; ok {
+			shared = []string{other, id}
+		}
+		byFirst[id[0]] = id
+	}
+
+	for _, ref := range []string{router[0], "/" + router[0], router[1], router[1][:12]} {
+		id, err := ResolveContainer(m, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != router[1] {
+			t.Errorf("Expected %s to resolve to %s, got %s", ref, router[1], id)
+		}
+	}
+
+	_, err := ResolveContainer(m, shared[0][:1])
+	var ambiguous *AmbiguousReferenceError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Expected an AmbiguousReferenceError, got %v", err)
+	}
+	for _, id := range shared {
+		if !contains(ambiguous.Matches, id) {
+			t.Errorf("Expected %s among the matches, got %v", id, ambiguous.Matches)
+		}
+	}
+	if _, err := ResolveContainer(m, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an unknown reference to match ErrNotFound, got %v", err)
+	}
+	_, err = ResolveContainer(m, "missing")
+	if err := WrapOperationError("mock", "ResolveContainer", "missing", err, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound to survive a plugin's wrapping, got %v", err)
+	}
+}
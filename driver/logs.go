@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CollectLogs writes a tar archive to out holding one "<name>.log" file
+// per managed container matching filter, e.g. for a support bundle. A
+// container without output gets an empty file. Containers whose logs
+// cannot be read are left out and reported as an AggregateError once the
+// archive is complete.
+func CollectLogs(ctx context.Context, d Driver, filter Filters, out io.Writer) error {
+	list, err := d.ContainerList(ContainerListOptions{
+		All:     true,
+		Filters: ManagedFilter(filter),
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(out)
+	var errs []error
+	for _, c := range list {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := readLogs(ctx, d, c.ID, &buf); err != nil {
+			if errors.Is(err, ErrNotSupported) {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("Couldn't read logs of container %s: %w", c.ID, err))
+			continue
+		}
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		hdr := &tar.Header{
+			Name:    name + ".log",
+			Mode:    0644,
+			Size:    int64(buf.Len()),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &AggregateError{Errors: errs}
+	}
+	return nil
+}
+
+func readLogs(ctx context.Context, d Driver, id string, w io.Writer) error {
+	rc, err := d.ContainerLogs(ctx, id, LogOptions{})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
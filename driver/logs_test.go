@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// readTar returns the files in the archive by name.
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(data)
+	}
+}
+
+func TestCollectLogs(t *testing.T) {
+	m := newMockDriver()
+	labels := map[string]string{"application": "skupper"}
+	router, err := m.runContainer(ContainerSpec{Name: "router", Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.runContainer(ContainerSpec{Name: "controller", Labels: labels}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.runContainer(ContainerSpec{Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	m.lock.Lock()
+	m.containers[router].logs = "Router started in 12ms\n"
+	m.lock.Unlock()
+
+	var out bytes.Buffer
+	if err := CollectLogs(context.Background(), m, LabelFilter("application", "skupper"), &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"router.log":     "Router started in 12ms\n",
+		"controller.log": "",
+	}
+	if files := readTar(t, &out); !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+}
+
+func TestCollectLogsReportsFailures(t *testing.T) {
+	m := newMockDriver()
+	if _, err := m.runContainer(ContainerSpec{Name: "router"}); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("log driver none")
+	m.fail("ContainerLogs", failed)
+
+	var out bytes.Buffer
+	err := CollectLogs(context.Background(), m, nil, &out)
+	var aggregate *AggregateError
+	if !errors.As(err, &aggregate) || !errors.Is(aggregate.Errors[0], failed) {
+		t.Errorf("Expected the failure to be aggregated, got %v", err)
+	}
+	if files := readTar(t, &out); len(files) != 0 {
+		t.Errorf("Expected a complete but empty archive, got %v", files)
+	}
+}
@@ -0,0 +1,1295 @@
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockContainer is a container of a mockDriver.
+type mockContainer struct {
+	icd   InspectContainerData
+	seq   int
+	stats ContainerStats
+	logs  string
+	files map[string]PathStat
+}
+
+// mockDriver is an in-memory engine for tests. Errors set with fail are
+// returned by the named method before it does anything, and the hooks,
+// when set, stand in for the engine's side of a method.
+type mockDriver struct {
+	lock       sync.Mutex
+	seq        int
+	containers map[string]*mockContainer
+	images     map[string]*ImageInspect
+	networks   map[string]*NetworkResource
+	volumes    map[string]uint64
+	execs      map[string]*ExecInspect
+	features   map[Feature]bool
+	watchers   []mockWatcher
+	calls      map[string]int
+	errs       map[string]error
+
+	// pull is run by ImagesPull before the image is added.
+	pull func(ref string, options ImagePullOptions) error
+	// exec runs a command; nil exits zero without output.
+	exec func(id string, opts ExecOptions) (ExecResult, error)
+	// create adjusts a new container's inspect data and returns warnings.
+	create func(spec ContainerSpec, icd *InspectContainerData) []string
+}
+
+type mockWatcher struct {
+	ctx    context.Context
+	id     string
+	events chan Event
+}
+
+var _ Driver = (*mockDriver)(nil)
+
+func newMockDriver() *mockDriver {
+	return &mockDriver{
+		containers: map[string]*mockContainer{},
+		images:     map[string]*ImageInspect{},
+		networks:   map[string]*NetworkResource{},
+		volumes:    map[string]uint64{},
+		execs:      map[string]*ExecInspect{},
+		features:   map[Feature]bool{},
+		calls:      map[string]int{},
+		errs:       map[string]error{},
+	}
+}
+
+// fail makes method return err until cleared with a nil err.
+func (m *mockDriver) fail(method string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err == nil {
+		delete(m.errs, method)
+		return
+	}
+	m.errs[method] = err
+}
+
+// called returns how often method was called.
+func (m *mockDriver) called(method string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.calls[method]
+}
+
+// enter counts a call of method and returns the error set for it.
+func (m *mockDriver) enter(method string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls[method]++
+	return m.errs[method]
+}
+
+func (m *mockDriver) newID(kind string) string {
+	m.seq++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s-%d", kind, m.seq)))
+	return hex.EncodeToString(sum[:])
+}
+
+func notFound(op string, kind string, id string) error {
+	return WrapOperationError("mock", op, id, fmt.Errorf("No such %s: %s", kind, id), true)
+}
+
+// addImage stores an image under ref and returns its ID.
+func (m *mockDriver) addImage(ref string, image ImageInspect) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if image.ID == "" {
+		sum := sha256.Sum256([]byte(ref))
+		image.ID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	m.untag(ref)
+	if existing, ok := m.images[image.ID]; ok {
+		image.RepoTags = existing.RepoTags
+	}
+	image.RepoTags = append(image.RepoTags, ref)
+	m.images[image.ID] = &image
+	return image.ID
+}
+
+// untag removes ref from whichever image carries it. The caller holds the
+// lock.
+func (m *mockDriver) untag(ref string) {
+	for _, image := range m.images {
+		for i, tag := range image.RepoTags {
+			if tag == ref {
+				image.RepoTags = append(image.RepoTags[:i], image.RepoTags[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// image finds an image by ID, ID prefix or tag. The caller holds the lock.
+func (m *mockDriver) image(ref string) *ImageInspect {
+	if image, ok := m.images[ref]; ok {
+		return image
+	}
+	for _, image := range m.images {
+		if strings.HasPrefix(image.ID, "sha256:"+ref) && len(ref) >= 12 {
+			return image
+		}
+		for _, tag := range image.RepoTags {
+			if tag == ref {
+				return image
+			}
+		}
+		for _, digest := range image.RepoDigests {
+			if digest == ref {
+				return image
+			}
+		}
+	}
+	return nil
+}
+
+// container finds a container by ID, name or unique ID prefix. The caller
+// holds the lock.
+func (m *mockDriver) container(ref string) *mockContainer {
+	if c, ok := m.containers[ref]; ok {
+		return c
+	}
+	var match *mockContainer
+	for _, c := range m.containers {
+		if c.icd.Name == "/"+strings.TrimPrefix(ref, "/") {
+			return c
+		}
+		if strings.HasPrefix(c.icd.ID, ref) {
+			if match != nil {
+				return nil
+			}
+			match = c
+		}
+	}
+	return match
+}
+
+// network finds a network by ID or name. The caller holds the lock.
+func (m *mockDriver) network(ref string) *NetworkResource {
+	if n, ok := m.networks[ref]; ok {
+		return n
+	}
+	for _, n := range m.networks {
+		if n.Name == ref {
+			return n
+		}
+	}
+	return nil
+}
+
+// notify sends ev to the watchers of its container. The caller holds the
+// lock.
+func (m *mockDriver) notify(ev Event) {
+	for _, w := range m.watchers {
+		if w.id != ev.ID || w.ctx.Err() != nil {
+			continue
+		}
+		select {
+		case w.events <- ev:
+		default:
+		}
+	}
+}
+
+func (m *mockDriver) New(ctx context.Context, options ConnectOptions) error {
+	return m.enter("New")
+}
+
+func (m *mockDriver) Reconnect(options ConnectOptions) error {
+	return m.enter("Reconnect")
+}
+
+func (m *mockDriver) Close() error {
+	return m.enter("Close")
+}
+
+func (m *mockDriver) SupportsFeature(feature Feature) (bool, error) {
+	if err := m.enter("SupportsFeature"); err != nil {
+		return false, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.features[feature], nil
+}
+
+func (m *mockDriver) ImageInspect(id string, options ImageInspectOptions) (*ImageInspect, error) {
+	if err := m.enter("ImageInspect"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	image := m.image(id)
+	m.lock.Unlock()
+	if image == nil {
+		return nil, notFound("ImageInspect", "image", id)
+	}
+	res := *image
+	if options.Platform != "" {
+		if err := CheckPlatform(&res, options.Platform); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+func (m *mockDriver) ImagesList(options ImageListOptions) ([]ImageSummary, error) {
+	if err := m.enter("ImagesList"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var list []ImageSummary
+	for _, image := range m.images {
+		list = append(list, ImageSummary{
+			ID:          image.ID,
+			Created:     image.Created,
+			Labels:      image.Labels,
+			RepoTags:    image.RepoTags,
+			RepoDigests: image.RepoDigests,
+			Size:        image.Size,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (m *mockDriver) ImagesDiskUsage() (ImagesDiskReport, error) {
+	if err := m.enter("ImagesDiskUsage"); err != nil {
+		return ImagesDiskReport{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var report ImagesDiskReport
+	for _, image := range m.images {
+		report.Images = append(report.Images, ImageDiskUsage{
+			ID:            image.ID,
+			RepoTags:      image.RepoTags,
+			Size:          image.Size,
+			ExclusiveSize: image.Size,
+		})
+		report.TotalSize += image.Size
+	}
+	return report, nil
+}
+
+func (m *mockDriver) ImagesPull(refStr string, options ImagePullOptions) ([]string, error) {
+	if err := m.enter("ImagesPull"); err != nil {
+		return nil, err
+	}
+	if m.pull != nil {
+		if err := m.pull(refStr, options); err != nil {
+			return nil, err
+		}
+	}
+	m.addImage(refStr, ImageInspect{})
+	return []string{refStr}, nil
+}
+
+func (m *mockDriver) ImageExists(ref string) (bool, error) {
+	if err := m.enter("ImageExists"); err != nil {
+		return false, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.image(ref) != nil, nil
+}
+
+func (m *mockDriver) ImageWait(ctx context.Context, ref string, interval time.Duration) error {
+	if err := m.enter("ImageWait"); err != nil {
+		return err
+	}
+	return WaitForImage(ctx, m, ref, interval)
+}
+
+// mockManifest is an entry of a docker-archive's manifest.json. The mock
+// stores the image's inspect data as its config.
+type mockManifest struct {
+	Config   string
+	RepoTags []string
+}
+
+// ImageSave writes a docker-archive holding a manifest.json and each
+// image's inspect data, without layers.
+func (m *mockDriver) ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	if err := m.enter("ImageSave"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	var manifest []mockManifest
+	for _, ref := range refs {
+		image := m.image(ref)
+		if image == nil {
+			return nil, notFound("ImageSave", "image", ref)
+		}
+		config := strings.TrimPrefix(image.ID, "sha256:") + ".json"
+		var tags []string
+		if contains(image.RepoTags, ref) {
+			tags = []string{ref}
+		}
+		manifest = append(manifest, mockManifest{Config: config, RepoTags: tags})
+		if err := writeTarJSON(tw, config, image); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeTarJSON(tw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(&buf), nil
+}
+
+func writeTarJSON(tw *tar.Writer, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// ImageLoad reads an archive written by ImageSave.
+func (m *mockDriver) ImageLoad(ctx context.Context, r io.Reader) ([]string, error) {
+	if err := m.enter("ImageLoad"); err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read image archive: %w", err)
+		}
+		if files[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("Couldn't read image archive: %w", err)
+		}
+	}
+	var manifest []mockManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		return nil, fmt.Errorf("Couldn't decode image archive manifest: %w", err)
+	}
+	var loaded []string
+	for _, entry := range manifest {
+		var image ImageInspect
+		if err := json.Unmarshal(files[entry.Config], &image); err != nil {
+			return nil, fmt.Errorf("Couldn't decode image config: %w", err)
+		}
+		image.RepoTags = nil
+		if len(entry.RepoTags) == 0 {
+			m.lock.Lock()
+			if m.images[image.ID] == nil {
+				m.images[image.ID] = &image
+			}
+			m.lock.Unlock()
+			loaded = append(loaded, image.ID)
+		}
+		for _, ref := range entry.RepoTags {
+			m.addImage(ref, image)
+			loaded = append(loaded, ref)
+		}
+	}
+	return loaded, nil
+}
+
+func (m *mockDriver) ImageTag(src string, dst string) error {
+	if err := m.enter("ImageTag"); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	image := m.image(src)
+	if image == nil {
+		return notFound("ImageTag", "image", src)
+	}
+	m.untag(dst)
+	image.RepoTags = append(image.RepoTags, dst)
+	return nil
+}
+
+func (m *mockDriver) ContainerCreate(spec ContainerSpec) (ContainerCreateResponse, error) {
+	if err := m.enter("ContainerCreate"); err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	if err := spec.Validate(); err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	if err := PullForPolicy(m, spec.Image, spec.PullPolicy); err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	if err := VerifyDigest(m, spec.Image); err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	m.lock.Lock()
+	if existing := m.container(spec.Name); spec.Name != "" && existing != nil && existing.icd.Name == "/"+spec.Name {
+		m.lock.Unlock()
+		err := fmt.Errorf("Conflict. The container name \"/%s\" is already in use by container \"%s\"", spec.Name, existing.icd.ID)
+		if spec.ReuseExisting {
+			return ReuseExisting(m, spec)
+		}
+		return ContainerCreateResponse{}, err
+	}
+	image := m.image(spec.Image)
+	if image == nil {
+		m.lock.Unlock()
+		return ContainerCreateResponse{}, notFound("ContainerCreate", "image", spec.Image)
+	}
+	id := m.newID("container")
+	c := &mockContainer{
+		seq: m.seq,
+		icd: InspectContainerData{
+			ID:            id,
+			Created:       time.Now(),
+			Image:         image.ID,
+			ImageName:     spec.Image,
+			Name:          "/" + spec.Name,
+			State:         &ContainerState{Status: "created"},
+			Env:           spec.Env,
+			Labels:        spec.EngineLabels(),
+			Annotations:   spec.Annotations,
+			RestartPolicy: spec.RestartPolicy,
+			Runtime:       spec.Runtime,
+			PidMode:       spec.PidMode,
+			IpcMode:       spec.IpcMode,
+			UTSMode:       spec.UTSMode,
+			CgroupParent:  spec.CgroupParent,
+			CgroupnsMode:  spec.CgroupnsMode,
+			StopSignal:    spec.StopSignal,
+			StopTimeout:   spec.StopTimeout,
+			Resources:     spec.Resources,
+			NetworkConfig: spec.NetworkConfig,
+			Networks:      map[string]EndpointResource{},
+		},
+		files: map[string]PathStat{},
+	}
+	for _, mount := range spec.Mounts {
+		mp := MountPoint{
+			Type:        mount.Type,
+			Source:      mount.Source,
+			Destination: mount.Target,
+			RW:          !mount.ReadOnly,
+		}
+		if mount.Type == TypeVolume {
+			mp.Name = mount.Source
+			mp.Source = "/var/lib/mock/volumes/" + mount.Source + "/_data"
+		}
+		c.icd.Mounts = append(c.icd.Mounts, mp)
+	}
+	for _, p := range spec.Ports {
+		c.icd.PortBindings = append(c.icd.PortBindings, Port{
+			IP:            p.HostIP,
+			ContainerPort: p.ContainerPort,
+			HostPort:      p.HostPort,
+			Type:          p.Protocol,
+		})
+	}
+	if spec.Name == "" {
+		c.icd.Name = "/" + id[:12]
+	}
+	create := m.create
+	m.containers[id] = c
+	m.lock.Unlock()
+
+	var warnings []string
+	if create != nil {
+		m.lock.Lock()
+		warnings = create(spec, &c.icd)
+		m.lock.Unlock()
+	}
+	return ContainerCreateResponse{ID: id, Warnings: warnings}, nil
+}
+
+func (m *mockDriver) ContainerStart(id string) error {
+	if err := m.enter("ContainerStart"); err != nil {
+		return StartFailure(id, err)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return notFound("ContainerStart", "container", id)
+	}
+	state := c.icd.State
+	if !state.Running {
+		state.Running = true
+		state.Status = "running"
+		state.StartedAt = time.Now()
+		state.Pid = 1000 + c.seq
+		m.notify(Event{ID: c.icd.ID, Action: EventStart, Time: time.Now()})
+	}
+	return nil
+}
+
+func (m *mockDriver) ContainerWait(id string, state string, timeout time.Duration, interval time.Duration) error {
+	return m.ContainerWaitWithOptions(id, WaitOptions{State: state, Timeout: timeout, Interval: interval})
+}
+
+func (m *mockDriver) ContainerWaitWithOptions(id string, opts WaitOptions) error {
+	if err := m.enter("ContainerWaitWithOptions"); err != nil {
+		return err
+	}
+	return WaitForState(context.Background(), m, id, opts)
+}
+
+func (m *mockDriver) ContainerList(options ContainerListOptions) ([]Container, error) {
+	if err := m.enter("ContainerList"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	var all []*mockContainer
+	for _, c := range m.containers {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].seq > all[j].seq })
+	since, before := 0, -1
+	if options.Since != "" {
+		if c := m.container(options.Since); c != nil {
+			since = c.seq
+		}
+	}
+	if options.Before != "" {
+		if c := m.container(options.Before); c != nil {
+			before = c.seq
+		}
+	}
+	var list []Container
+	for _, c := range all {
+		if !options.All && options.Limit <= 0 && !c.icd.State.Running {
+			continue
+		}
+		if c.seq <= since || (before >= 0 && c.seq >= before) {
+			continue
+		}
+		if !MatchLabels(c.icd.Labels, options.Filters["label"]) {
+			continue
+		}
+		if names := options.Filters["name"]; len(names) > 0 && !contains(names, strings.TrimPrefix(c.icd.Name, "/")) {
+			continue
+		}
+		list = append(list, Container{
+			ID:      c.icd.ID,
+			Names:   []string{c.icd.Name},
+			Image:   c.icd.ImageName,
+			ImageID: c.icd.Image,
+			Created: c.icd.Created.Unix(),
+			Labels:  c.icd.Labels,
+			State:   c.icd.State.Status,
+		})
+		if options.Limit > 0 && len(list) == options.Limit {
+			break
+		}
+	}
+	m.lock.Unlock()
+	if options.Inspect {
+		if err := BackfillContainers(list, m.ContainerInspect); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockDriver) ContainerInspect(id string) (*InspectContainerData, error) {
+	if err := m.enter("ContainerInspect"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return nil, notFound("ContainerInspect", "container", id)
+	}
+	icd := c.icd
+	state := *c.icd.State
+	icd.State = &state
+	icd.Networks = map[string]EndpointResource{}
+	for name, ep := range c.icd.Networks {
+		icd.Networks[name] = ep
+	}
+	return &icd, nil
+}
+
+func (m *mockDriver) ContainerImageDigest(id string) (string, error) {
+	return ContainerImageDigest(m, id)
+}
+
+func (m *mockDriver) ImageUpToDate(id string, ref string) (bool, error) {
+	return ImageUpToDate(m, id, ref)
+}
+
+func (m *mockDriver) ResolveContainer(nameOrPrefix string) (string, error) {
+	return ResolveContainer(m, nameOrPrefix)
+}
+
+func (m *mockDriver) ContainerSpecOf(id string) (ContainerSpec, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return ContainerSpec{}, err
+	}
+	return SpecFromInspect(icd), nil
+}
+
+func (m *mockDriver) ContainerMatchesSpec(id string, spec ContainerSpec) (bool, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	hash, ok := icd.Labels[SpecHashLabel]
+	return ok && hash == SpecHash(spec), nil
+}
+
+func (m *mockDriver) IsManaged(id string) (bool, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	return icd.Labels[ManagedByLabel] == ManagedByValue, nil
+}
+
+func (m *mockDriver) ContainerStop(id string) error {
+	if err := m.enter("ContainerStop"); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return notFound("ContainerStop", "container", id)
+	}
+	m.stop(c, c.icd.State.ExitCode)
+	return nil
+}
+
+func (m *mockDriver) ContainerStopWithTimeout(id string, timeout time.Duration) error {
+	if err := m.enter("ContainerStopWithTimeout"); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return notFound("ContainerStopWithTimeout", "container", id)
+	}
+	m.stop(c, c.icd.State.ExitCode)
+	return nil
+}
+
+// stop moves the container to the exited state. The caller holds the lock.
+func (m *mockDriver) stop(c *mockContainer, exitCode int) {
+	state := c.icd.State
+	if !state.Running {
+		return
+	}
+	state.Running = false
+	state.Status = "exited"
+	state.ExitCode = exitCode
+	state.Pid = 0
+	state.FinishedAt = time.Now()
+	m.notify(Event{ID: c.icd.ID, Action: EventDie, Time: time.Now()})
+}
+
+// exit stops a running container with exitCode, as if its process ended.
+func (m *mockDriver) exit(id string, exitCode int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if c := m.container(id); c != nil {
+		m.stop(c, exitCode)
+	}
+}
+
+// update changes a container's inspect data.
+func (m *mockDriver) update(id string, fn func(c *mockContainer)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if c := m.container(id); c != nil {
+		fn(c)
+	}
+}
+
+func (m *mockDriver) ContainerExitCode(id string) (int, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return 0, err
+	}
+	if icd.State.Running {
+		return 0, &ContainerRunningError{ID: id}
+	}
+	return icd.State.ExitCode, nil
+}
+
+func (m *mockDriver) ContainerUptime(id string) (time.Duration, error) {
+	return ContainerUptime(m, id)
+}
+
+func (m *mockDriver) ContainerRestartCount(id string) (int, error) {
+	return ContainerRestartCount(m, id)
+}
+
+func (m *mockDriver) ContainerRemove(id string, options RemoveOptions) error {
+	if err := m.enter("ContainerRemove"); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return notFound("ContainerRemove", "container", id)
+	}
+	if c.icd.State.Running && !options.Force {
+		return fmt.Errorf("You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.icd.ID)
+	}
+	for _, n := range m.networks {
+		delete(n.Containers, c.icd.ID)
+	}
+	delete(m.containers, c.icd.ID)
+	return nil
+}
+
+func (m *mockDriver) ContainerExec(id string, cmd []string) (ExecResult, error) {
+	return m.ContainerExecWithOptions(id, ExecOptions{Cmd: cmd})
+}
+
+// runExec runs opts through the exec hook for a running container and
+// records the session.
+func (m *mockDriver) runExec(id string, opts ExecOptions) (ExecResult, error) {
+	m.lock.Lock()
+	c := m.container(id)
+	if c == nil {
+		m.lock.Unlock()
+		return ExecResult{}, notFound("ContainerExec", "container", id)
+	}
+	if !c.icd.State.Running {
+		m.lock.Unlock()
+		return ExecResult{}, fmt.Errorf("Container %s is not running", id)
+	}
+	execID := m.newID("exec")
+	m.execs[execID] = &ExecInspect{ID: execID, ContainerID: c.icd.ID, Running: true, Pid: 2000 + m.seq}
+	exec := m.exec
+	m.lock.Unlock()
+
+	startedAt := time.Now()
+	res := ExecResult{OutBuffer: &bytes.Buffer{}, ErrBuffer: &bytes.Buffer{}}
+	var err error
+	if exec != nil {
+		res, err = exec(c.icd.ID, opts)
+		if res.OutBuffer == nil {
+			res.OutBuffer = &bytes.Buffer{}
+		}
+		if res.ErrBuffer == nil {
+			res.ErrBuffer = &bytes.Buffer{}
+		}
+	}
+	res.ExecID = execID
+	res.Tty = opts.Tty
+	res.StartedAt = startedAt
+	res.Duration = time.Since(startedAt)
+
+	m.lock.Lock()
+	m.execs[execID].Running = false
+	m.execs[execID].ExitCode = res.ExitCode
+	m.lock.Unlock()
+	return res, err
+}
+
+func (m *mockDriver) ContainerExecStream(id string, opts ExecOptions, stdout io.Writer, stderr io.Writer) (int, error) {
+	if err := m.enter("ContainerExecStream"); err != nil {
+		return 0, err
+	}
+	res, err := m.runExec(id, opts)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(stdout, res.OutBuffer); err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(stderr, res.ErrBuffer); err != nil {
+		return 0, err
+	}
+	return res.ExitCode, nil
+}
+
+func (m *mockDriver) ContainerExecWithOptions(id string, opts ExecOptions) (ExecResult, error) {
+	if err := m.enter("ContainerExecWithOptions"); err != nil {
+		return ExecResult{}, err
+	}
+	res, err := m.runExec(id, opts)
+	if err != nil {
+		return res, err
+	}
+	if opts.MaxOutputBytes > 0 {
+		for _, buf := range []*bytes.Buffer{res.OutBuffer, res.ErrBuffer} {
+			if int64(buf.Len()) > opts.MaxOutputBytes {
+				buf.Truncate(int(opts.MaxOutputBytes))
+				res.Truncated = true
+			}
+		}
+	}
+	return res, nil
+}
+
+// ContainerExecDetached runs the exec hook in the background; the session
+// is running until the hook returns.
+func (m *mockDriver) ContainerExecDetached(id string, opts ExecOptions) (string, error) {
+	if err := m.enter("ContainerExecDetached"); err != nil {
+		return "", err
+	}
+	started := make(chan string, 1)
+	go func() {
+		m.lock.Lock()
+		exec := m.exec
+		m.exec = func(id string, opts ExecOptions) (ExecResult, error) {
+			m.lock.Lock()
+			var execID string
+			for _, e := range m.execs {
+				if e.ContainerID == id && e.Running {
+					execID = e.ID
+				}
+			}
+			m.lock.Unlock()
+			started <- execID
+			if exec == nil {
+				return ExecResult{}, nil
+			}
+			return exec(id, opts)
+		}
+		m.lock.Unlock()
+		m.runExec(id, opts)
+	}()
+	select {
+	case execID := <-started:
+		m.lock.Lock()
+		m.exec = nil
+		m.lock.Unlock()
+		return execID, nil
+	case <-time.After(time.Second):
+		return "", fmt.Errorf("Exec in container %s did not start", id)
+	}
+}
+
+func (m *mockDriver) WaitForPort(ctx context.Context, id string, port int, proto string) error {
+	return WaitForPort(ctx, m, id, port, proto)
+}
+
+func (m *mockDriver) ExecInspect(execID string) (ExecInspect, error) {
+	if err := m.enter("ExecInspect"); err != nil {
+		return ExecInspect{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	e, ok := m.execs[execID]
+	if !ok {
+		return ExecInspect{}, notFound("ExecInspect", "exec", execID)
+	}
+	return *e, nil
+}
+
+func (m *mockDriver) ContainerStatsSnapshot(id string) (ContainerStats, error) {
+	if err := m.enter("ContainerStatsSnapshot"); err != nil {
+		return ContainerStats{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return ContainerStats{}, notFound("ContainerStatsSnapshot", "container", id)
+	}
+	stats := c.stats
+	stats.ID = c.icd.ID
+	stats.Read = time.Now()
+	return stats, nil
+}
+
+func (m *mockDriver) ContainerSummary(id string) (ContainerSummary, error) {
+	return SummarizeContainer(m, id)
+}
+
+func (m *mockDriver) ContainerPorts(id string) ([]Port, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return nil, err
+	}
+	if !icd.State.Running {
+		return nil, nil
+	}
+	var ports []Port
+	for _, p := range icd.PortBindings {
+		if p.HostPort == 0 {
+			// the engine picks a free port
+			p.HostPort = 32768 + p.ContainerPort
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+func (m *mockDriver) ContainerNetworkConfig(id string) (NetworkConfig, error) {
+	return ContainerNetworkConfig(m, id)
+}
+
+func (m *mockDriver) ContainerStatPath(id string, path string) (PathStat, error) {
+	if err := m.enter("ContainerStatPath"); err != nil {
+		return PathStat{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := m.container(id)
+	if c == nil {
+		return PathStat{}, notFound("ContainerStatPath", "container", id)
+	}
+	stat, ok := c.files[path]
+	if !ok {
+		return PathStat{}, &PathNotFoundError{ID: id, Path: path}
+	}
+	return stat, nil
+}
+
+// writeFile creates or replaces a file of the container.
+func (m *mockDriver) writeFile(id string, path string, size int64) {
+	m.update(id, func(c *mockContainer) {
+		c.files[path] = PathStat{Name: path[strings.LastIndex(path, "/")+1:], Size: size, Mode: 0644, Mtime: time.Now()}
+	})
+}
+
+func (m *mockDriver) WatchPath(ctx context.Context, id string, path string, interval time.Duration) (<-chan PathEvent, error) {
+	return WatchPath(ctx, m, id, path, interval)
+}
+
+func (m *mockDriver) ContainerEvents(ctx context.Context, id string) (<-chan Event, <-chan error, error) {
+	if err := m.enter("ContainerEvents"); err != nil {
+		return nil, nil, err
+	}
+	m.lock.Lock()
+	c := m.container(id)
+	if c == nil {
+		m.lock.Unlock()
+		return nil, nil, notFound("ContainerEvents", "container", id)
+	}
+	events := make(chan Event, 16)
+	errs := make(chan error)
+	m.watchers = append(m.watchers, mockWatcher{ctx: ctx, id: c.icd.ID, events: events})
+	m.lock.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		for i, w := range m.watchers {
+			if w.events == events {
+				m.watchers = append(m.watchers[:i], m.watchers[i+1:]...)
+				break
+			}
+		}
+		close(events)
+		close(errs)
+	}()
+	return events, errs, nil
+}
+
+func (m *mockDriver) ContainerLogs(ctx context.Context, id string, options LogOptions) (io.ReadCloser, error) {
+	if err := m.enter("ContainerLogs"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	c := m.container(id)
+	m.lock.Unlock()
+	if c == nil {
+		return nil, notFound("ContainerLogs", "container", id)
+	}
+	rc := ioutil.NopCloser(strings.NewReader(c.logs))
+	if options.MaxOutputBytes > 0 {
+		rc = LimitReadCloser(rc, options.MaxOutputBytes)
+	}
+	return rc, nil
+}
+
+func (m *mockDriver) ContainerLogPath(id string) (string, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return "", err
+	}
+	return "/var/lib/mock/containers/" + icd.ID + "/" + icd.ID + "-json.log", nil
+}
+
+func (m *mockDriver) GenerateSystemdUnit(id string, opts SystemdOptions) (map[string]string, error) {
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(icd.Name, "/")
+	return map[string]string{
+		"container-" + name + ".service": "[Service]\nExecStart=/usr/bin/mock start " + icd.ID + "\n",
+	}, nil
+}
+
+func (m *mockDriver) NetworkCreate(name string, options NetworkCreateOptions) (NetworkCreateResponse, error) {
+	if err := m.enter("NetworkCreate"); err != nil {
+		return NetworkCreateResponse{}, err
+	}
+	if err := options.Validate(); err != nil {
+		return NetworkCreateResponse{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.network(name) != nil {
+		return NetworkCreateResponse{}, fmt.Errorf("network with name %s already exists", name)
+	}
+	id := m.newID("network")
+	m.networks[id] = &NetworkResource{
+		ID:         id,
+		Name:       name,
+		Driver:     options.Driver,
+		Labels:     options.EngineLabels(),
+		Options:    options.Options,
+		IPAM:       options.IPAM,
+		EnableIPv6: options.EnableIPv6,
+		Containers: map[string]EndpointResource{},
+	}
+	return NetworkCreateResponse{ID: id}, nil
+}
+
+func (m *mockDriver) NetworkInspect(id string) (NetworkResource, error) {
+	if err := m.enter("NetworkInspect"); err != nil {
+		return NetworkResource{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	n := m.network(id)
+	if n == nil {
+		return NetworkResource{}, notFound("NetworkInspect", "network", id)
+	}
+	return *n, nil
+}
+
+func (m *mockDriver) NetworkList(options NetworkListOptions) ([]NetworkResource, error) {
+	if err := m.enter("NetworkList"); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var list []NetworkResource
+	for _, n := range m.networks {
+		if !MatchLabels(n.Labels, options.Filters["label"]) {
+			continue
+		}
+		if names := options.Filters["name"]; len(names) > 0 && !contains(names, n.Name) {
+			continue
+		}
+		list = append(list, *n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (m *mockDriver) NetworkRemove(id string, force bool) error {
+	if err := m.enter("NetworkRemove"); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	n := m.network(id)
+	if n == nil {
+		return notFound("NetworkRemove", "network", id)
+	}
+	if len(n.Containers) > 0 && !force {
+		var endpoints []string
+		for _, ep := range n.Containers {
+			endpoints = append(endpoints, ep.Name)
+		}
+		sort.Strings(endpoints)
+		return &NetworkInUseError{Network: n.Name, Endpoints: endpoints}
+	}
+	delete(m.networks, n.ID)
+	return nil
+}
+
+func (m *mockDriver) NetworkConnect(id string, container string, aliases []string) (EndpointResource, error) {
+	if err := m.enter("NetworkConnect"); err != nil {
+		return EndpointResource{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	n := m.network(id)
+	if n == nil {
+		return EndpointResource{}, notFound("NetworkConnect", "network", id)
+	}
+	c := m.container(container)
+	if c == nil {
+		return EndpointResource{}, notFound("NetworkConnect", "container", container)
+	}
+	ep := EndpointResource{
+		Name:        strings.TrimPrefix(c.icd.Name, "/"),
+		EndpointID:  m.newID("endpoint"),
+		IPv4Address: fmt.Sprintf("172.18.0.%d/16", len(n.Containers)+2),
+		Gateway:     "172.18.0.1",
+	}
+	n.Containers[c.icd.ID] = ep
+	c.icd.Networks[n.Name] = ep
+	return ep, nil
+}
+
+func (m *mockDriver) NetworkDisconnect(id string, container string, force bool) error {
+	if err := m.enter("NetworkDisconnect"); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	n := m.network(id)
+	if n == nil {
+		return notFound("NetworkDisconnect", "network", id)
+	}
+	c := m.container(container)
+	if c == nil {
+		return notFound("NetworkDisconnect", "container", container)
+	}
+	if _, ok := n.Containers[c.icd.ID]; !ok {
+		return fmt.Errorf("container %s is not connected to network %s", container, id)
+	}
+	delete(n.Containers, c.icd.ID)
+	delete(c.icd.Networks, n.Name)
+	return nil
+}
+
+func (m *mockDriver) ContainerConnectNetworks(id string, endpoints map[string]EndpointConfig, opts ConnectNetworksOptions) error {
+	return ContainerConnectNetworks(m, id, endpoints, opts)
+}
+
+func (m *mockDriver) NetworkGateway(id string) (string, error) {
+	return NetworkGateway(m, id)
+}
+
+func (m *mockDriver) ContainersPrune(filters Filters) (PruneReport, error) {
+	if err := m.enter("ContainersPrune"); err != nil {
+		return PruneReport{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var report PruneReport
+	for id, c := range m.containers {
+		if c.icd.State.Running || !MatchLabels(c.icd.Labels, filters["label"]) {
+			continue
+		}
+		delete(m.containers, id)
+		report.Deleted = append(report.Deleted, id)
+		report.SpaceReclaimed += uint64(c.stats.BlockWrite)
+	}
+	sort.Strings(report.Deleted)
+	return report, nil
+}
+
+func (m *mockDriver) ImagesPrune(filters Filters) (PruneReport, error) {
+	if err := m.enter("ImagesPrune"); err != nil {
+		return PruneReport{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	used := map[string]bool{}
+	for _, c := range m.containers {
+		used[c.icd.Image] = true
+	}
+	var report PruneReport
+	for id, image := range m.images {
+		if used[id] || len(image.RepoTags) > 0 || !MatchLabels(image.Labels, filters["label"]) {
+			continue
+		}
+		delete(m.images, id)
+		report.Deleted = append(report.Deleted, id)
+		report.SpaceReclaimed += uint64(image.Size)
+	}
+	sort.Strings(report.Deleted)
+	return report, nil
+}
+
+func (m *mockDriver) NetworksPrune(filters Filters) (PruneReport, error) {
+	if err := m.enter("NetworksPrune"); err != nil {
+		return PruneReport{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var report PruneReport
+	for id, n := range m.networks {
+		if len(n.Containers) > 0 || !MatchLabels(n.Labels, filters["label"]) {
+			continue
+		}
+		delete(m.networks, id)
+		report.Deleted = append(report.Deleted, n.Name)
+	}
+	sort.Strings(report.Deleted)
+	return report, nil
+}
+
+func (m *mockDriver) VolumesPrune(filters Filters) (PruneReport, error) {
+	if err := m.enter("VolumesPrune"); err != nil {
+		return PruneReport{}, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	used := map[string]bool{}
+	for _, c := range m.containers {
+		for _, mp := range c.icd.Mounts {
+			if mp.Type == TypeVolume {
+				used[mp.Name] = true
+			}
+		}
+	}
+	var report PruneReport
+	for name, size := range m.volumes {
+		if used[name] {
+			continue
+		}
+		delete(m.volumes, name)
+		report.Deleted = append(report.Deleted, name)
+		report.SpaceReclaimed += size
+	}
+	sort.Strings(report.Deleted)
+	return report, nil
+}
+
+// runContainer creates and starts a container from spec, adding its image
+// first.
+func (m *mockDriver) runContainer(spec ContainerSpec) (string, error) {
+	if spec.Image == "" {
+		spec.Image = "quay.io/skupper/router:latest"
+	}
+	m.lock.Lock()
+	missing := m.image(spec.Image) == nil
+	m.lock.Unlock()
+	if missing {
+		m.addImage(spec.Image, ImageInspect{})
+	}
+	res, err := m.ContainerCreate(spec)
+	if err != nil {
+		return "", err
+	}
+	return res.ID, m.ContainerStart(res.ID)
+}
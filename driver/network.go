@@ -0,0 +1,203 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// NetworkLabel lists, comma separated, the networks a driver connected with
+// AutoAttachNetworks attaches a container to after creating it.
+const NetworkLabel = "io.skupper.network"
+
+// NetworkOwnerLabel holds the Owner a network was created with.
+const NetworkOwnerLabel = "io.skupper.network-owner"
+
+// EngineLabels returns a copy of options.Labels with NetworkOwnerLabel set
+// when the options have an Owner.
+func (options NetworkCreateOptions) EngineLabels() map[string]string {
+	if options.Owner == "" {
+		return options.Labels
+	}
+	labels := make(map[string]string, len(options.Labels)+1)
+	for k, v := range options.Labels {
+		labels[k] = v
+	}
+	labels[NetworkOwnerLabel] = options.Owner
+	return labels
+}
+
+// Validate checks that every IPAM subnet and range is a valid CIDR and that
+// gateways and auxiliary addresses fall within their subnet.
+func (options NetworkCreateOptions) Validate() error {
+	for _, pool := range options.IPAM.Config {
+		if pool.Subnet == "" {
+			if pool.Gateway != "" || pool.IPRange != "" {
+				return fmt.Errorf("IPAM gateway and range require a subnet")
+			}
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(pool.Subnet)
+		if err != nil {
+			return fmt.Errorf("Invalid subnet %s: %w", pool.Subnet, err)
+		}
+		if subnet.IP.To4() == nil && !options.EnableIPv6 {
+			return fmt.Errorf("IPv6 subnet %s requires EnableIPv6", pool.Subnet)
+		}
+		if pool.IPRange != "" {
+			ip, _, err := net.ParseCIDR(pool.IPRange)
+			if err != nil {
+				return fmt.Errorf("Invalid ip range %s: %w", pool.IPRange, err)
+			}
+			if !subnet.Contains(ip) {
+				return fmt.Errorf("Ip range %s is not within subnet %s", pool.IPRange, pool.Subnet)
+			}
+		}
+		if pool.Gateway != "" {
+			if err := checkAddress(subnet, pool.Gateway); err != nil {
+				return fmt.Errorf("Invalid gateway: %w", err)
+			}
+		}
+		for name, addr := range pool.AuxAddresses {
+			if err := checkAddress(subnet, addr); err != nil {
+				return fmt.Errorf("Invalid aux address %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func checkAddress(subnet *net.IPNet, addr string) error {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return fmt.Errorf("%s is not an ip address", addr)
+	}
+	if !subnet.Contains(ip) {
+		return fmt.Errorf("%s is not within subnet %s", addr, subnet)
+	}
+	return nil
+}
+
+// DisconnectAll disconnects the container from every network it is
+// attached to, e.g. before removing it. Failures do not stop the loop and
+// are returned as an AggregateError.
+func DisconnectAll(d Driver, containerID string, force bool) error {
+	icd, err := d.ContainerInspect(containerID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for name := range icd.Networks {
+		if err := d.NetworkDisconnect(name, containerID, force); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to disconnect container %s from network %s: %w", containerID, name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &AggregateError{Errors: errs}
+	}
+	return nil
+}
+
+// ContainerConnectNetworks connects the container to each network in
+// endpoints, in name order. Failures do not stop the loop and are returned
+// as an AggregateError, unless opts.Atomic is set: then the first failure
+// disconnects the networks connected so far and is returned together with
+// any failure to undo them.
+func ContainerConnectNetworks(d Driver, containerID string, endpoints map[string]EndpointConfig, opts ConnectNetworksOptions) error {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var connected []string
+	var errs []error
+	for _, name := range names {
+		_, err := d.NetworkConnect(name, containerID, endpoints[name].Aliases)
+		if err == nil {
+			connected = append(connected, name)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("Failed to connect container %s to network %s: %w", containerID, name, err))
+		if opts.Atomic {
+			for i := len(connected) - 1; i >= 0; i-- {
+				if err := d.NetworkDisconnect(connected[i], containerID, true); err != nil {
+					errs = append(errs, fmt.Errorf("Failed to roll back network %s of container %s: %w", connected[i], containerID, err))
+				}
+			}
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return &AggregateError{Errors: errs}
+	}
+	return nil
+}
+
+// NetworkGateway returns the IPv4 gateway of the network's first IPAM pool
+// that has one, e.g. for containers to reach the host through the bridge.
+func NetworkGateway(d Driver, id string) (string, error) {
+	network, err := d.NetworkInspect(id)
+	if err != nil {
+		return "", err
+	}
+	for _, pool := range network.IPAM.Config {
+		if ip := net.ParseIP(pool.Gateway); ip != nil && ip.To4() != nil {
+			return pool.Gateway, nil
+		}
+	}
+	return "", fmt.Errorf("Network %s reports no IPv4 gateway", id)
+}
+
+// EnsureNetwork returns the network called name, creating it with options
+// when it does not exist. When options has an Owner, an existing network
+// must carry the same owner, or a NetworkOwnedByOtherError is returned.
+func EnsureNetwork(d Driver, name string, options NetworkCreateOptions) (NetworkResource, error) {
+	list, err := d.NetworkList(NetworkListOptions{Filters: Filters{"name": {name}}})
+	if err != nil {
+		return NetworkResource{}, err
+	}
+	// the engine's name filter may also match on a substring
+	for _, network := range list {
+		if network.Name != name {
+			continue
+		}
+		if owner := network.Labels[NetworkOwnerLabel]; options.Owner != "" && owner != options.Owner {
+			return NetworkResource{}, &NetworkOwnedByOtherError{Network: name, Owner: owner, Want: options.Owner}
+		}
+		return network, nil
+	}
+	if _, err := d.NetworkCreate(name, options); err != nil {
+		return NetworkResource{}, fmt.Errorf("Couldn't create network %s: %w", name, err)
+	}
+	return d.NetworkInspect(name)
+}
+
+// AttachLabeledNetworks connects the container to every network named in
+// its NetworkLabel label, creating missing networks with default options.
+func AttachLabeledNetworks(d Driver, containerID string, labels map[string]string) error {
+	for _, name := range strings.Split(labels[NetworkLabel], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, err := EnsureNetwork(d, name, NetworkCreateOptions{}); err != nil {
+			return err
+		}
+		if _, err := d.NetworkConnect(name, containerID, nil); err != nil {
+			return fmt.Errorf("Couldn't connect container %s to network %s: %w", containerID, name, err)
+		}
+	}
+	return nil
+}
+
+// ContainerNetworkConfig returns the container's effective hostname and
+// DNS settings.
+func ContainerNetworkConfig(d Driver, id string) (NetworkConfig, error) {
+	icd, err := d.ContainerInspect(id)
+	if err != nil {
+		return NetworkConfig{}, err
+	}
+	return icd.NetworkConfig, nil
+}
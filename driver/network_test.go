@@ -0,0 +1,195 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNetworkCreateOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		pool  IPAMPool
+		valid bool
+	}{
+		{IPAMPool{Subnet: "10.212.42.0/24", Gateway: "10.212.42.1"}, true},
+		{IPAMPool{Subnet: "10.212.42.0/24", IPRange: "10.212.42.128/25", AuxAddresses: map[string]string{"router": "10.212.42.2"}}, true},
+		{IPAMPool{Subnet: "10.212.42.0"}, false},
+		{IPAMPool{Subnet: "10.212.42.0/24", Gateway: "10.212.43.1"}, false},
+		{IPAMPool{Subnet: "10.212.42.0/24", IPRange: "10.212.43.0/25"}, false},
+		{IPAMPool{Subnet: "10.212.42.0/24", AuxAddresses: map[string]string{"router": "router"}}, false},
+		{IPAMPool{Gateway: "10.212.42.1"}, false},
+	} {
+		options := NetworkCreateOptions{IPAM: IPAMConfig{Config: []IPAMPool{tc.pool}}}
+		if err := options.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Expected pool %+v valid=%v, got %v", tc.pool, tc.valid, err)
+		}
+	}
+}
+
+func TestNetworkCreateOptionsValidateIPv6(t *testing.T) {
+	options := NetworkCreateOptions{IPAM: IPAMConfig{Config: []IPAMPool{{Subnet: "fd00:ce:42::/64", Gateway: "fd00:ce:42::1"}}}}
+	if err := options.Validate(); err == nil {
+		t.Errorf("Expected an IPv6 subnet without EnableIPv6 to be rejected")
+	}
+	options.EnableIPv6 = true
+	if err := options.Validate(); err != nil {
+		t.Errorf("Expected an IPv6 subnet to be valid, got %v", err)
+	}
+}
+
+func TestDisconnectAll(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"skupper", "skupper-data"} {
+		if _, err := m.NetworkCreate(name, NetworkCreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.NetworkConnect(name, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DisconnectAll(m, id, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("NetworkDisconnect"); n != 2 {
+		t.Errorf("Expected 2 disconnects, got %d", n)
+	}
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icd.Networks) != 0 {
+		t.Errorf("Expected no networks left, got %v", icd.Networks)
+	}
+	for _, name := range []string{"skupper", "skupper-data"} {
+		network, err := m.NetworkInspect(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(network.Containers) != 0 {
+			t.Errorf("Expected network %s to have no containers, got %v", name, network.Containers)
+		}
+	}
+}
+
+func TestNetworkGateway(t *testing.T) {
+	m := newMockDriver()
+	options := NetworkCreateOptions{
+		EnableIPv6: true,
+		IPAM: IPAMConfig{Config: []IPAMPool{
+			{Subnet: "fd00:ce:42::/64", Gateway: "fd00:ce:42::1"},
+			{Subnet: "10.212.42.0/24", Gateway: "10.212.42.1"},
+		}},
+	}
+	if _, err := m.NetworkCreate("skupper", options); err != nil {
+		t.Fatal(err)
+	}
+	gateway, err := NetworkGateway(m, "skupper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway != "10.212.42.1" {
+		t.Errorf("Expected the IPv4 gateway 10.212.42.1, got %s", gateway)
+	}
+
+	if _, err := m.NetworkCreate("skupper-none", NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if gateway, err := NetworkGateway(m, "skupper-none"); err == nil {
+		t.Errorf("Expected an error for a network without a gateway, got %s", gateway)
+	}
+}
+
+func TestAttachLabeledNetworks(t *testing.T) {
+	m := newMockDriver()
+	if _, err := m.NetworkCreate("skupper", NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{NetworkLabel: "skupper, skupper-data"}
+	id, err := m.runContainer(ContainerSpec{Name: "router", Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AttachLabeledNetworks(m, id, labels); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("NetworkCreate"); n != 2 {
+		t.Errorf("Expected only the missing network to be created, got %d creates", n)
+	}
+	icd, err := m.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"skupper", "skupper-data"} {
+		if _, ok := icd.Networks[name]; !ok {
+			t.Errorf("Expected the container to be connected to %s, got %v", name, icd.Networks)
+		}
+	}
+}
+
+func TestContainerConnectNetworks(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		m := newMockDriver()
+		id, err := m.runContainer(ContainerSpec{Name: "router"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.NetworkCreate("skupper", NetworkCreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		// networks connect in name order, so the missing one fails second
+		endpoints := map[string]EndpointConfig{
+			"skupper":         {Aliases: []string{"router"}},
+			"skupper-missing": {},
+		}
+
+		err = ContainerConnectNetworks(m, id, endpoints, ConnectNetworksOptions{Atomic: atomic})
+		var aggregate *AggregateError
+		if !errors.As(err, &aggregate) || len(aggregate.Errors) != 1 || !errors.Is(aggregate.Errors[0], ErrNotFound) {
+			t.Fatalf("Expected the missing network to be reported, got %v", err)
+		}
+		icd, err := m.ContainerInspect(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, connected := icd.Networks["skupper"]; connected == atomic {
+			t.Errorf("Expected the first network to be connected %v with atomic %v, got %v", !atomic, atomic, icd.Networks)
+		}
+	}
+}
+
+func TestEnsureNetworkOwner(t *testing.T) {
+	m := newMockDriver()
+	west := NetworkCreateOptions{Owner: "site-west"}
+	created, err := EnsureNetwork(m, "skupper", west)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Labels[NetworkOwnerLabel] != "site-west" {
+		t.Errorf("Expected the owner to be recorded, got %v", created.Labels)
+	}
+
+	reused, err := EnsureNetwork(m, "skupper", west)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.ID != created.ID || m.called("NetworkCreate") != 1 {
+		t.Errorf("Expected the owner's network to be reused, got %s after %d creates", reused.ID, m.called("NetworkCreate"))
+	}
+
+	_, err = EnsureNetwork(m, "skupper", NetworkCreateOptions{Owner: "site-east"})
+	var owned *NetworkOwnedByOtherError
+	if !errors.As(err, &owned) || owned.Owner != "site-west" || owned.Want != "site-east" {
+		t.Errorf("Expected a NetworkOwnedByOtherError naming site-west, got %v", err)
+	}
+	if _, err := m.NetworkCreate("shared", NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EnsureNetwork(m, "shared", west); !errors.As(err, &owned) || owned.Owner != "" {
+		t.Errorf("Expected an unowned network to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+)
+
+// StopInOrder stops the containers one after the other in the order given,
+// e.g. dependents before the routers they use. Each container gets grace
+// to exit before the engine kills it, and the next one is only stopped
+// once it has. Failures do not stop the sequence and are returned as an
+// AggregateError.
+func StopInOrder(d Driver, ids []string, grace time.Duration) error {
+	var errs []error
+	for _, id := range ids {
+		if err := d.ContainerStopWithTimeout(id, grace); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to stop container %s: %w", id, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &AggregateError{Errors: errs}
+	}
+	return nil
+}
+
+// StartInOrder starts the containers one after the other in the order
+// given, e.g. routers before their dependents. Unlike StopInOrder it stops
+// at the first failure, since later containers may depend on it.
+func StartInOrder(d Driver, ids []string) error {
+	for _, id := range ids {
+		if err := d.ContainerStart(id); err != nil {
+			return fmt.Errorf("Failed to start container %s: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// orderedDriver records the order containers are stopped and started in.
+type orderedDriver struct {
+	*mockDriver
+	order []string
+	// slow containers take this long to stop
+	slow  map[string]time.Duration
+	lock  sync.Mutex
+	grace []time.Duration
+}
+
+func (d *orderedDriver) ContainerStopWithTimeout(id string, timeout time.Duration) error {
+	d.lock.Lock()
+	d.order = append(d.order, "stop "+id)
+	d.grace = append(d.grace, timeout)
+	d.lock.Unlock()
+	time.Sleep(d.slow[id])
+	err := d.mockDriver.ContainerStopWithTimeout(id, timeout)
+	d.lock.Lock()
+	d.order = append(d.order, "stopped "+id)
+	d.lock.Unlock()
+	return err
+}
+
+func (d *orderedDriver) ContainerStart(id string) error {
+	d.order = append(d.order, "start "+id)
+	return d.mockDriver.ContainerStart(id)
+}
+
+func TestStopInOrder(t *testing.T) {
+	m := newMockDriver()
+	var ids []string
+	for _, name := range []string{"service", "router", "gateway"} {
+		id, err := m.runContainer(ContainerSpec{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	d := &orderedDriver{mockDriver: m}
+
+	if err := StopInOrder(d, ids, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	for _, id := range ids {
+		expected = append(expected, "stop "+id, "stopped "+id)
+	}
+	if !reflect.DeepEqual(d.order, expected) {
+		t.Errorf("Expected %v, got %v", expected, d.order)
+	}
+	for _, grace := range d.grace {
+		if grace != time.Second {
+			t.Errorf("Expected the engine to get the grace period, got %v", grace)
+		}
+	}
+	for _, id := range ids {
+		icd, err := m.ContainerInspect(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if icd.State.Running {
+			t.Errorf("Expected %s to be stopped", id)
+		}
+	}
+}
+
+func TestStopInOrderContinuesPastFailures(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &orderedDriver{mockDriver: m}
+
+	err = StopInOrder(d, []string{"missing", id}, time.Second)
+	var aggregate *AggregateError
+	if !errors.As(err, &aggregate) || len(aggregate.Errors) != 1 {
+		t.Fatalf("Expected one aggregated failure, got %v", err)
+	}
+	if !errors.Is(aggregate.Errors[0], ErrNotFound) {
+		t.Errorf("Expected the missing container to be reported, got %v", aggregate.Errors[0])
+	}
+	if len(d.order) != 4 {
+		t.Errorf("Expected both containers to be stopped, got %v", d.order)
+	}
+}
+
+func TestStopInOrderWaitsForSlowStops(t *testing.T) {
+	m := newMockDriver()
+	var ids []string
+	for _, name := range []string{"service", "router"} {
+		id, err := m.runContainer(ContainerSpec{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// the first stop runs past the grace period, as an engine killing
+	// the container would
+	d := &orderedDriver{mockDriver: m, slow: map[string]time.Duration{ids[0]: 50 * time.Millisecond}}
+
+	if err := StopInOrder(d, ids, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"stop " + ids[0], "stopped " + ids[0], "stop " + ids[1], "stopped " + ids[1]}
+	if !reflect.DeepEqual(d.order, expected) {
+		t.Errorf("Expected each stop to finish before the next, got %v", d.order)
+	}
+}
+
+func TestStartInOrder(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:latest", ImageInspect{})
+	var ids []string
+	for _, name := range []string{"router", "service"} {
+		res, err := m.ContainerCreate(ContainerSpec{Name: name, Image: "quay.io/skupper/router:latest"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, res.ID)
+	}
+	d := &orderedDriver{mockDriver: m}
+
+	if err := StartInOrder(d, append([]string{ids[0], "missing"}, ids[1])); err == nil {
+		t.Fatal("Expected starting a missing container to fail")
+	}
+	expected := []string{"start " + ids[0], "start missing"}
+	if !reflect.DeepEqual(d.order, expected) {
+		t.Errorf("Expected starting to stop at the failure, got %v", d.order)
+	}
+}
@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"io"
+)
+
+// CappedWriter passes at most Max bytes on to W and discards the rest, so
+// that a copy into it neither fails nor blocks on excess output.
+type CappedWriter struct {
+	W         io.Writer
+	Max       int64
+	written   int64
+	truncated bool
+}
+
+func (w *CappedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if remaining := w.Max - w.written; int64(len(p)) > remaining {
+		p = p[:remaining]
+		w.truncated = true
+	}
+	if len(p) > 0 {
+		m, err := w.W.Write(p)
+		w.written += int64(m)
+		if err != nil {
+			return m, err
+		}
+	}
+	return n, nil
+}
+
+// Truncated reports whether output was discarded.
+func (w *CappedWriter) Truncated() bool {
+	return w.truncated
+}
+
+// CapWriter returns w capped at max bytes, or w itself when max is not
+// positive.
+func CapWriter(w io.Writer, max int64) io.Writer {
+	if max <= 0 {
+		return w
+	}
+	return &CappedWriter{W: w, Max: max}
+}
+
+// LimitedReadCloser ends a stream after Max bytes, closing the underlying
+// stream so that the engine stops sending.
+type LimitedReadCloser struct {
+	R         io.ReadCloser
+	Max       int64
+	read      int64
+	truncated bool
+}
+
+func (l *LimitedReadCloser) Read(p []byte) (int, error) {
+	remaining := l.Max - l.read
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+	// read one byte past the limit to tell a stream that ends right at
+	// it from one that is truncated
+	if int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := l.R.Read(p)
+	if int64(n) > remaining {
+		l.read = l.Max
+		l.truncated = true
+		l.R.Close()
+		return int(remaining), io.EOF
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+func (l *LimitedReadCloser) Close() error {
+	return l.R.Close()
+}
+
+// Truncated reports whether the stream was cut short.
+func (l *LimitedReadCloser) Truncated() bool {
+	return l.truncated
+}
+
+// LimitReadCloser returns rc ending after max bytes as a
+// *LimitedReadCloser, or rc itself when max is not positive.
+func LimitReadCloser(rc io.ReadCloser, max int64) io.ReadCloser {
+	if max <= 0 {
+		return rc
+	}
+	return &LimitedReadCloser{R: rc, Max: max}
+}
+
+// IsTruncated reports whether v is a CappedWriter or LimitedReadCloser
+// that discarded output.
+func IsTruncated(v interface{}) bool {
+	t, ok := v.(interface{ Truncated() bool })
+	return ok && t.Truncated()
+}
@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCapWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := CapWriter(&buf, 4)
+	for _, chunk := range []string{"ab", "cdef", "gh"} {
+		n, err := w.Write([]byte(chunk))
+		if err != nil || n != len(chunk) {
+			t.Fatalf("Expected the whole of %q to be accepted, got %d, %v", chunk, n, err)
+		}
+	}
+	if buf.String() != "abcd" {
+		t.Errorf("Expected abcd, got %q", buf.String())
+	}
+	if !IsTruncated(w) {
+		t.Errorf("Expected the writer to report truncation")
+	}
+	if w := CapWriter(&buf, 0); w != io.Writer(&buf) {
+		t.Errorf("Expected no cap to return the writer itself")
+	}
+}
+
+func TestLimitReadCloser(t *testing.T) {
+	rc := LimitReadCloser(ioutil.NopCloser(strings.NewReader("abcdefgh")), 4)
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abcd" || !IsTruncated(rc) {
+		t.Errorf("Expected abcd and truncation, got %q and %v", data, IsTruncated(rc))
+	}
+
+	rc = LimitReadCloser(ioutil.NopCloser(strings.NewReader("abcd")), 4)
+	data, err = ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abcd" || IsTruncated(rc) {
+		t.Errorf("Expected abcd without truncation, got %q and %v", data, IsTruncated(rc))
+	}
+}
@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Middleware decorates a Driver with additional behavior.
+type Middleware func(Driver) Driver
+
+// ImagePolicy decides whether an image reference may be used.
+type ImagePolicy interface {
+	// Allow returns an error describing why ref is rejected, or nil.
+	Allow(ref string) error
+}
+
+// PolicyViolationError is returned when an ImagePolicy rejects a reference.
+type PolicyViolationError struct {
+	Ref string
+	Err error
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("image %s rejected by policy: %v", e.Ref, e.Err)
+}
+
+func (e *PolicyViolationError) Unwrap() error {
+	return e.Err
+}
+
+// RegistryAllowlist is an ImagePolicy accepting only images hosted on the
+// listed registries, e.g. "quay.io" or "docker.io".
+type RegistryAllowlist []string
+
+func (l RegistryAllowlist) Allow(ref string) error {
+	registry := ImageRegistry(ref)
+	for _, allowed := range l {
+		if registry == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("registry %s is not allowed", registry)
+}
+
+// ImageRegistry returns the registry host of an image reference, following
+// docker's rule that the first path component is a registry only when it
+// contains a '.' or ':' or is "localhost"; otherwise it is docker.io.
+func ImageRegistry(ref string) string {
+	i := strings.IndexRune(ref, '/')
+	if i == -1 {
+		return "docker.io"
+	}
+	first := ref[:i]
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return "docker.io"
+}
+
+type policyDriver struct {
+	Driver
+	policy ImagePolicy
+}
+
+// PolicyMiddleware returns a Middleware that checks every image reference
+// against policy before it is pulled, loaded, tagged or used to create a
+// container. Rejected references fail with a PolicyViolationError without
+// reaching the engine.
+func PolicyMiddleware(policy ImagePolicy) Middleware {
+	return func(d Driver) Driver {
+		return &policyDriver{Driver: d, policy: policy}
+	}
+}
+
+func (d *policyDriver) allow(ref string) error {
+	if err := d.policy.Allow(ref); err != nil {
+		return &PolicyViolationError{Ref: ref, Err: err}
+	}
+	return nil
+}
+
+func (d *policyDriver) ImagesPull(refStr string, options ImagePullOptions) ([]string, error) {
+	if err := d.allow(refStr); err != nil {
+		return nil, err
+	}
+	return d.Driver.ImagesPull(refStr, options)
+}
+
+func (d *policyDriver) ContainerCreate(spec ContainerSpec) (ContainerCreateResponse, error) {
+	if err := d.allow(spec.Image); err != nil {
+		return ContainerCreateResponse{}, err
+	}
+	return d.Driver.ContainerCreate(spec)
+}
+
+func (d *policyDriver) ImageTag(src string, dst string) error {
+	if err := d.allow(dst); err != nil {
+		return err
+	}
+	return d.Driver.ImageTag(src, dst)
+}
+
+// ImageLoad checks the references an archive is tagged with before loading
+// it. The archive is spooled to a temporary file, as the engine can only
+// be handed it once it has been read.
+func (d *policyDriver) ImageLoad(ctx context.Context, r io.Reader) ([]string, error) {
+	f, err := ioutil.TempFile("", "image-load")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, fmt.Errorf("Couldn't read image archive: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	refs, err := archiveRefs(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if err := d.allow(ref); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return d.Driver.ImageLoad(ctx, f)
+}
+
+// archiveRefs returns the references a docker-archive, optionally gzip
+// compressed, is tagged with, from its manifest.json.
+func archiveRefs(r io.Reader) ([]string, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read image archive: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("Image archive has no manifest.json")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read image archive: %w", err)
+		}
+		if hdr.Name != "manifest.json" {
+			continue
+		}
+		var manifest []struct {
+			RepoTags []string
+		}
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("Couldn't decode image archive manifest: %w", err)
+		}
+		var refs []string
+		for _, m := range manifest {
+			refs = append(refs, m.RepoTags...)
+		}
+		return refs, nil
+	}
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestImageRegistry(t *testing.T) {
+	for ref, want := range map[string]string{
+		"nginx":                         "docker.io",
+		"library/nginx:alpine":          "docker.io",
+		"quay.io/skupper/router":        "quay.io",
+		"localhost/router":              "localhost",
+		"registry.local:5000/router:v1": "registry.local:5000",
+	} {
+		if got := ImageRegistry(ref); got != want {
+			t.Errorf("%s: expected %s, got %s", ref, want, got)
+		}
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	m := newMockDriver()
+	d := PolicyMiddleware(RegistryAllowlist{"quay.io"})(m)
+
+	_, err := d.ImagesPull("docker.io/library/nginx", ImagePullOptions{})
+	var violation *PolicyViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("Expected a policy violation, got %v", err)
+	}
+	if n := m.called("ImagesPull"); n != 0 {
+		t.Errorf("Expected the rejected pull not to reach the engine, got %d calls", n)
+	}
+	if _, err := d.ContainerCreate(ContainerSpec{Name: "web", Image: "nginx"}); !errors.As(err, &violation) {
+		t.Errorf("Expected a policy violation, got %v", err)
+	}
+
+	if _, err := d.ImagesPull("quay.io/skupper/router", ImagePullOptions{}); err != nil {
+		t.Errorf("Expected the allowed pull to pass through, got %v", err)
+	}
+	if n := m.called("ImagesPull"); n != 1 {
+		t.Errorf("Expected the allowed pull to reach the engine, got %d calls", n)
+	}
+}
+
+func TestPolicyMiddlewareTagAndLoad(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:1.0", ImageInspect{})
+	d := PolicyMiddleware(RegistryAllowlist{"quay.io"})(m)
+
+	var violation *PolicyViolationError
+	if err := d.ImageTag("quay.io/skupper/router:1.0", "docker.io/skupper/router:1.0"); !errors.As(err, &violation) {
+		t.Errorf("Expected tagging into a disallowed registry to be rejected, got %v", err)
+	}
+	if err := d.ImageTag("quay.io/skupper/router:1.0", "quay.io/skupper/router:latest"); err != nil {
+		t.Errorf("Expected tagging within an allowed registry to pass, got %v", err)
+	}
+
+	// an archive of an image tagged for a disallowed registry
+	untrusted := newMockDriver()
+	untrusted.addImage("docker.io/evil/router:1.0", ImageInspect{})
+	archive, err := untrusted.ImageSave(context.Background(), []string{"docker.io/evil/router:1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ImageLoad(context.Background(), archive); !errors.As(err, &violation) {
+		t.Errorf("Expected loading a disallowed image to be rejected, got %v", err)
+	}
+	if n := m.called("ImageLoad"); n != 0 {
+		t.Errorf("Expected the rejected load not to reach the engine, got %d calls", n)
+	}
+
+	archive, err = m.ImageSave(context.Background(), []string{"quay.io/skupper/router:1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := d.ImageLoad(context.Background(), archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0] != "quay.io/skupper/router:1.0" {
+		t.Errorf("Expected the allowed image to load, got %v", loaded)
+	}
+}
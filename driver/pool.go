@@ -0,0 +1,220 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type poolEntry struct {
+	driver   Driver
+	lastUsed time.Time
+	// users counts the Acquire callers that have not released the driver;
+	// an evicted driver in use is closed once the last one releases it
+	users   int
+	evicted bool
+}
+
+// Pool keeps one connected driver per set of ConnectOptions, e.g. one per
+// remote host, so that repeated operations against an endpoint reuse its
+// connection. Drivers unused for the idle timeout are closed, as is the
+// least recently used one when a new endpoint would exceed the size limit.
+// A driver held through Acquire is not closed before it is released. One
+// returned by Get may be closed once the caller has not asked for it for a
+// while; call Get for each batch of operations, and again after an error,
+// rather than keeping the driver.
+type Pool struct {
+	ctx     context.Context
+	factory func() Driver
+	maxSize int
+	idle    time.Duration
+
+	lock    sync.Mutex
+	entries map[ConnectOptions]*poolEntry
+	closed  bool
+	stop    chan struct{}
+}
+
+// NewPool returns a Pool creating drivers with factory, such as a plugin's
+// NewDriver, and connecting them with ctx. maxSize bounds the drivers kept
+// and idle how long an unused one is kept; zero disables either limit.
+func NewPool(ctx context.Context, factory func() Driver, maxSize int, idle time.Duration) *Pool {
+	p := &Pool{
+		ctx:     ctx,
+		factory: factory,
+		maxSize: maxSize,
+		idle:    idle,
+		entries: map[ConnectOptions]*poolEntry{},
+		stop:    make(chan struct{}),
+	}
+	if idle > 0 {
+		go p.evictIdle()
+	}
+	return p
+}
+
+// Get returns the pooled driver for options, connecting a new one when
+// there is none yet.
+func (p *Pool) Get(options ConnectOptions) (Driver, error) {
+	entry, err := p.entry(options, false)
+	if err != nil {
+		return nil, err
+	}
+	return entry.driver, nil
+}
+
+// Acquire returns the pooled driver for options like Get, along with a
+// function to call once done with it. Until then the pool does not close
+// the driver, except in Close. Calling release more than once is harmless.
+func (p *Pool) Acquire(options ConnectOptions) (Driver, func(), error) {
+	entry, err := p.entry(options, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var once sync.Once
+	return entry.driver, func() { once.Do(func() { p.release(entry) }) }, nil
+}
+
+// entry returns the pooled entry for options, connecting a new driver when
+// there is none yet. The connection is made without holding the lock, so
+// that a slow endpoint does not hold up the others; when two callers
+// connect the same options at once, the loser's driver is closed. acquire
+// counts the caller as a user of the entry.
+func (p *Pool) entry(options ConnectOptions, acquire bool) (*poolEntry, error) {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil, fmt.Errorf("Pool is closed")
+	}
+	if entry, ok := p.entries[options]; ok {
+		p.use(entry, acquire)
+		p.lock.Unlock()
+		return entry, nil
+	}
+	p.lock.Unlock()
+
+	d := p.factory()
+	if err := d.New(p.ctx, options); err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		d.Close()
+		return nil, fmt.Errorf("Pool is closed")
+	}
+	if entry, ok := p.entries[options]; ok {
+		d.Close()
+		p.use(entry, acquire)
+		return entry, nil
+	}
+	if p.maxSize > 0 && len(p.entries) >= p.maxSize {
+		p.evictOldest()
+	}
+	entry := &poolEntry{driver: d}
+	p.entries[options] = entry
+	p.use(entry, acquire)
+	return entry, nil
+}
+
+// use marks entry as just used, and as in use when acquire is set. The
+// caller holds the lock.
+func (p *Pool) use(entry *poolEntry, acquire bool) {
+	entry.lastUsed = time.Now()
+	if acquire {
+		entry.users++
+	}
+}
+
+func (p *Pool) release(entry *poolEntry) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry.users--
+	entry.lastUsed = time.Now()
+	if entry.users == 0 && entry.evicted {
+		entry.driver.Close()
+	}
+}
+
+// evict removes the driver for options from the pool, closing it unless it
+// is in use, in which case its last release closes it. The caller holds
+// the lock.
+func (p *Pool) evict(options ConnectOptions) {
+	entry := p.entries[options]
+	delete(p.entries, options)
+	entry.evicted = true
+	if entry.users == 0 {
+		entry.driver.Close()
+	}
+}
+
+// Len returns the number of drivers in the pool.
+func (p *Pool) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.entries)
+}
+
+// Close closes every pooled driver, including those held through Acquire;
+// later calls to Get and Acquire fail.
+func (p *Pool) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.stop)
+	var errs []error
+	for options, entry := range p.entries {
+		if err := entry.driver.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(p.entries, options)
+	}
+	if len(errs) > 0 {
+		return &AggregateError{Errors: errs}
+	}
+	return nil
+}
+
+// evictOldest evicts the least recently used driver. The caller holds the
+// lock.
+func (p *Pool) evictOldest() {
+	var (
+		oldest  ConnectOptions
+		lastUse time.Time
+		found   bool
+	)
+	for options, entry := range p.entries {
+		if !found || entry.lastUsed.Before(lastUse) {
+			oldest, lastUse, found = options, entry.lastUsed, true
+		}
+	}
+	if found {
+		p.evict(oldest)
+	}
+}
+
+// evictIdle evicts drivers unused for the idle timeout until the pool is
+// closed. Drivers held through Acquire are in use and kept.
+func (p *Pool) evictIdle() {
+	ticker := time.NewTicker(p.idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		p.lock.Lock()
+		for options, entry := range p.entries {
+			if entry.users == 0 && time.Since(entry.lastUsed) > p.idle {
+				p.evict(options)
+			}
+		}
+		p.lock.Unlock()
+	}
+}
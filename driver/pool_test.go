@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolFactory hands out mock drivers and keeps them for inspection.
+type poolFactory struct {
+	lock    sync.Mutex
+	drivers []*mockDriver
+}
+
+func (f *poolFactory) new() Driver {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	m := newMockDriver()
+	f.drivers = append(f.drivers, m)
+	return m
+}
+
+func (f *poolFactory) created() []*mockDriver {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]*mockDriver(nil), f.drivers...)
+}
+
+func TestPoolReusesDrivers(t *testing.T) {
+	f := &poolFactory{}
+	p := NewPool(context.Background(), f.new, 0, 0)
+	defer p.Close()
+
+	hostA := ConnectOptions{Host: "tcp://10.0.0.1:2376"}
+	first, err := p.Get(hostA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.Get(hostA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("Expected the driver for %s to be reused", hostA.Host)
+	}
+	other, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.2:2376"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Errorf("Expected a separate driver for another host")
+	}
+	if drivers := f.created(); len(drivers) != 2 || drivers[0].called("New") != 1 {
+		t.Errorf("Expected two drivers connected once each, got %d", len(drivers))
+	}
+}
+
+func TestPoolEvictsIdleDrivers(t *testing.T) {
+	f := &poolFactory{}
+	p := NewPool(context.Background(), f.new, 0, 50*time.Millisecond)
+	defer p.Close()
+
+	if _, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.1:2376"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.Len() != 0 {
+		t.Fatalf("Expected the idle driver to be evicted")
+	}
+	if f.created()[0].called("Close") != 1 {
+		t.Errorf("Expected the evicted driver to be closed")
+	}
+}
+
+func TestPoolMaxSize(t *testing.T) {
+	f := &poolFactory{}
+	p := NewPool(context.Background(), f.new, 1, 0)
+
+	if _, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.1:2376"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.2:2376"}); err != nil {
+		t.Fatal(err)
+	}
+	drivers := f.created()
+	if p.Len() != 1 || drivers[0].called("Close") != 1 {
+		t.Errorf("Expected the least recently used driver to be closed to make room")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if drivers[1].called("Close") != 1 {
+		t.Errorf("Expected Close to close the remaining driver")
+	}
+	if _, err := p.Get(ConnectOptions{}); err == nil {
+		t.Errorf("Expected Get on a closed pool to fail")
+	}
+}
+
+// blockingDriver blocks in New until connect is closed.
+type blockingDriver struct {
+	*mockDriver
+	started chan struct{}
+	connect chan struct{}
+}
+
+func (s *blockingDriver) New(ctx context.Context, options ConnectOptions) error {
+	close(s.started)
+	<-s.connect
+	return s.mockDriver.New(ctx, options)
+}
+
+func TestPoolConnectsOutsideTheLock(t *testing.T) {
+	slow := &blockingDriver{mockDriver: newMockDriver(), started: make(chan struct{}), connect: make(chan struct{})}
+	f := &poolFactory{}
+	var once sync.Once
+	p := NewPool(context.Background(), func() Driver {
+		var d Driver
+		once.Do(func() { d = slow })
+		if d != nil {
+			return d
+		}
+		return f.new()
+	}, 0, 0)
+	defer p.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.9:2376"})
+		done <- err
+	}()
+	<-slow.started
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.1:2376"})
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Get for another host not to wait on the slow connect")
+	}
+	if p.Len() != 1 {
+		t.Errorf("Expected only the connected driver in the pool, got %d", p.Len())
+	}
+	close(slow.connect)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 2 {
+		t.Errorf("Expected both drivers in the pool, got %d", p.Len())
+	}
+}
+
+func TestPoolKeepsAcquiredDrivers(t *testing.T) {
+	f := &poolFactory{}
+	p := NewPool(context.Background(), f.new, 1, 0)
+	defer p.Close()
+
+	held, release, err := p.Acquire(ConnectOptions{Host: "tcp://10.0.0.1:2376"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(ConnectOptions{Host: "tcp://10.0.0.2:2376"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 1 {
+		t.Errorf("Expected the held driver to be evicted from the pool, got %d", p.Len())
+	}
+	if n := held.(*mockDriver).called("Close"); n != 0 {
+		t.Fatalf("Expected the held driver to stay open, closed %d times", n)
+	}
+	release()
+	release()
+	if n := held.(*mockDriver).called("Close"); n != 1 {
+		t.Errorf("Expected the released driver to be closed once, got %d", n)
+	}
+}
@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortMapping publishes a single container port on the host.
+type PortMapping struct {
+	HostIP string
+	// HostPort is the host port to bind; zero lets the engine pick one.
+	HostPort uint16
+	// HostPortEnd, when set, makes the engine allocate the host port from
+	// the range HostPort-HostPortEnd.
+	HostPortEnd   uint16
+	ContainerPort uint16
+	Protocol      string
+}
+
+// ParsePortSpec parses a docker CLI style publish spec such as "80",
+// "8080:80/udp", "127.0.0.1:8080:80" or "8000-8001:80-81" into one
+// PortMapping per container port, following the semantics of docker's
+// nat.ParsePortSpec.
+func ParsePortSpec(spec string) ([]PortMapping, error) {
+	rawPort, proto := splitProtoPort(spec)
+	if proto != "tcp" && proto != "udp" && proto != "sctp" {
+		return nil, fmt.Errorf("Invalid proto: %s", proto)
+	}
+
+	ip, hostPort, containerPort := splitParts(rawPort)
+	if ip != "" && ip[0] == '[' {
+		// Strip [] from IPv6 addresses
+		if len(ip) < 2 || ip[len(ip)-1] != ']' {
+			return nil, fmt.Errorf("Invalid ip address: %s", ip)
+		}
+		ip = ip[1 : len(ip)-1]
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("Invalid ip address: %s", ip)
+	}
+	if containerPort == "" {
+		return nil, fmt.Errorf("No port specified: %s", spec)
+	}
+
+	startPort, endPort, err := parsePortRange(containerPort)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid containerPort: %s", containerPort)
+	}
+	var startHostPort, endHostPort uint16
+	if hostPort != "" {
+		startHostPort, endHostPort, err = parsePortRange(hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid hostPort: %s", hostPort)
+		}
+		// A host port range is only allowed with a matching container range,
+		// or with a single container port in which case it is the range
+		// the host port is allocated from.
+		if endPort-startPort != endHostPort-startHostPort && endPort != startPort {
+			return nil, fmt.Errorf("Invalid ranges specified for container and host Ports: %s and %s", containerPort, hostPort)
+		}
+	}
+
+	var mappings []PortMapping
+	for n := 0; n <= int(endPort-startPort); n++ {
+		i := uint16(n)
+		mapping := PortMapping{
+			HostIP:        ip,
+			ContainerPort: startPort + i,
+			Protocol:      proto,
+		}
+		if hostPort != "" {
+			mapping.HostPort = startHostPort + i
+			if endPort == startPort && endHostPort != startHostPort {
+				mapping.HostPort = startHostPort
+				mapping.HostPortEnd = endHostPort
+			}
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// ParsePortBinding builds a Port from an engine's "80/tcp" port key and
+// the host address and port it is bound to.
+func ParsePortBinding(key string, hostIP string, hostPort string) (Port, error) {
+	rawPort, proto := splitProtoPort(key)
+	containerPort, err := strconv.ParseUint(rawPort, 10, 16)
+	if err != nil {
+		return Port{}, fmt.Errorf("Invalid container port %s: %w", key, err)
+	}
+	port := Port{
+		IP:            hostIP,
+		ContainerPort: uint16(containerPort),
+		Type:          proto,
+	}
+	if hostPort != "" {
+		p, err := strconv.ParseUint(hostPort, 10, 16)
+		if err != nil {
+			return Port{}, fmt.Errorf("Invalid host port %s: %w", hostPort, err)
+		}
+		port.HostPort = uint16(p)
+	}
+	return port, nil
+}
+
+// splitProtoPort splits "80/udp" into its port and protocol, defaulting
+// the protocol to tcp.
+func splitProtoPort(rawPort string) (string, string) {
+	parts := strings.Split(rawPort, "/")
+	if len(parts) == 1 || parts[1] == "" {
+		return parts[0], "tcp"
+	}
+	return parts[0], strings.ToLower(parts[1])
+}
+
+// splitParts splits "ip:hostPort:containerPort", where the ip may itself
+// contain colons.
+func splitParts(rawPort string) (string, string, string) {
+	parts := strings.Split(rawPort, ":")
+	n := len(parts)
+	containerPort := parts[n-1]
+
+	switch n {
+	case 1:
+		return "", "", containerPort
+	case 2:
+		return "", parts[0], containerPort
+	case 3:
+		return parts[0], parts[1], containerPort
+	default:
+		return strings.Join(parts[:n-2], ":"), parts[n-2], containerPort
+	}
+}
+
+// parsePortRange parses "80" or "80-90" into its first and last port.
+func parsePortRange(ports string) (uint16, uint16, error) {
+	if !strings.Contains(ports, "-") {
+		port, err := strconv.ParseUint(ports, 10, 16)
+		if err != nil {
+			return 0, 0, err
+		}
+		return uint16(port), uint16(port), nil
+	}
+	parts := strings.SplitN(ports, "-", 2)
+	start, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("Invalid range specified for port: %s", ports)
+	}
+	return uint16(start), uint16(end), nil
+}
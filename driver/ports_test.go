@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []PortMapping
+	}{
+		{"80", []PortMapping{{ContainerPort: 80, Protocol: "tcp"}}},
+		{"8080:80", []PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"8080:80/udp", []PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "udp"}}},
+		{"53/SCTP", []PortMapping{{ContainerPort: 53, Protocol: "sctp"}}},
+		{"127.0.0.1:8080:80", []PortMapping{{HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"127.0.0.1::80", []PortMapping{{HostIP: "127.0.0.1", ContainerPort: 80, Protocol: "tcp"}}},
+		{"[::1]:8080:80", []PortMapping{{HostIP: "::1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"::1:8080:80", []PortMapping{{HostIP: "::1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"8000-8001:80-81", []PortMapping{
+			{HostPort: 8000, ContainerPort: 80, Protocol: "tcp"},
+			{HostPort: 8001, ContainerPort: 81, Protocol: "tcp"},
+		}},
+		{"80-81", []PortMapping{
+			{ContainerPort: 80, Protocol: "tcp"},
+			{ContainerPort: 81, Protocol: "tcp"},
+		}},
+		{"8000-8010:80", []PortMapping{{HostPort: 8000, HostPortEnd: 8010, ContainerPort: 80, Protocol: "tcp"}}},
+	}
+	for _, test := range tests {
+		got, err := ParsePortSpec(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %+v, got %+v", test.spec, test.want, got)
+		}
+	}
+}
+
+func TestParsePortSpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"80/icmp",
+		"8080:",
+		"abc",
+		"90-80",
+		"8000-8001:80-82",
+		"localhost:8080:80",
+		"[:80:80",
+		"[::1:80:80",
+		"70000",
+	} {
+		if _, err := ParsePortSpec(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// PullWithProgressBar pulls ref and renders its progress to out for a
+// terminal or log: a line whenever a layer changes status, and the overall
+// download percentage in steps of ten. Engines that do not stream pull
+// progress only get the completion line.
+func PullWithProgressBar(ctx context.Context, d Driver, ref string, out io.Writer) error {
+	bar := &progressBar{
+		out:    out,
+		layers: map[string]*layerProgress{},
+	}
+	_, err := d.ImagesPull(ref, ImagePullOptions{
+		Context:  ctx,
+		Progress: bar.update,
+	})
+	if err != nil {
+		fmt.Fprintf(out, "%s: pull failed: %v\n", ref, err)
+		return err
+	}
+	fmt.Fprintf(out, "%s: pull complete\n", ref)
+	return nil
+}
+
+type layerProgress struct {
+	status  string
+	current int64
+	total   int64
+}
+
+// progressBar turns PullProgress updates into lines of text.
+type progressBar struct {
+	out io.Writer
+
+	lock   sync.Mutex
+	layers map[string]*layerProgress
+	// tenths is the last overall percentage printed, divided by ten
+	tenths int
+}
+
+func (b *progressBar) update(p PullProgress) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if p.Layer == "" {
+		// image wide messages, e.g. the digest once the pull is done
+		fmt.Fprintf(b.out, "%s: %s\n", p.Ref, p.Status)
+		return
+	}
+	l, ok := b.layers[p.Layer]
+	if !ok {
+		l = &layerProgress{}
+		b.layers[p.Layer] = l
+	}
+	// only the download counts towards the percentage; extraction reports
+	// the same layer's bytes again
+	switch p.Status {
+	case "Downloading":
+		l.current, l.total = p.Current, p.Total
+	case "Download complete", "Pull complete", "Already exists":
+		l.current = l.total
+	}
+	if p.Status != l.status {
+		l.status = p.Status
+		fmt.Fprintf(b.out, "%s: %s\n", p.Layer, p.Status)
+	}
+	var current, total int64
+	for _, layer := range b.layers {
+		current += layer.current
+		total += layer.total
+	}
+	if total == 0 {
+		return
+	}
+	percent := int(current * 100 / total)
+	if percent/10 > b.tenths {
+		b.tenths = percent / 10
+		fmt.Fprintf(b.out, "%s: %d%% downloaded\n", p.Ref, percent)
+	}
+}
@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPullWithProgressBar(t *testing.T) {
+	ref := "quay.io/skupper/router:1.0"
+	m := newMockDriver()
+	m.pull = func(ref string, options ImagePullOptions) error {
+		for _, p := range []PullProgress{
+			{Layer: "a1b2c3", Status: "Pulling fs layer"},
+			{Layer: "d4e5f6", Status: "Pulling fs layer"},
+			{Layer: "a1b2c3", Status: "Downloading", Current: 50, Total: 100},
+			{Layer: "d4e5f6", Status: "Downloading", Current: 20, Total: 100},
+			{Layer: "a1b2c3", Status: "Download complete"},
+			{Layer: "d4e5f6", Status: "Download complete"},
+			{Layer: "a1b2c3", Status: "Pull complete"},
+			{Layer: "d4e5f6", Status: "Pull complete"},
+			{Status: "Digest: sha256:abc"},
+		} {
+			p.Ref = ref
+			options.Progress(p)
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	if err := PullWithProgressBar(context.Background(), m, ref, &out); err != nil {
+		t.Fatal(err)
+	}
+	rendered := out.String()
+	for _, line := range []string{
+		"a1b2c3: Pulling fs layer\n",
+		"d4e5f6: Downloading\n",
+		ref + ": 50% downloaded\n",
+		ref + ": 60% downloaded\n",
+		ref + ": 100% downloaded\n",
+		ref + ": Digest: sha256:abc\n",
+	} {
+		if !strings.Contains(rendered, line) {
+			t.Errorf("Expected %q in the output, got:\n%s", line, rendered)
+		}
+	}
+	if !strings.HasSuffix(rendered, ref+": pull complete\n") {
+		t.Errorf("Expected a completion line at the end, got:\n%s", rendered)
+	}
+	if n := strings.Count(rendered, "a1b2c3: Downloading"); n != 1 {
+		t.Errorf("Expected one line per status change, got %d for the download", n)
+	}
+}
+
+func TestPullWithProgressBarFails(t *testing.T) {
+	m := newMockDriver()
+	failed := errors.New("manifest unknown")
+	m.fail("ImagesPull", failed)
+
+	var out bytes.Buffer
+	if err := PullWithProgressBar(context.Background(), m, "quay.io/skupper/router:1.0", &out); !errors.Is(err, failed) {
+		t.Errorf("Expected the pull error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "pull failed: manifest unknown") {
+		t.Errorf("Expected the failure to be rendered, got %q", out.String())
+	}
+}
@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"errors"
+)
+
+// PruneReport lists the resources a prune removed.
+type PruneReport struct {
+	Deleted []string
+	// SpaceReclaimed is in bytes; engines report none for networks.
+	SpaceReclaimed uint64
+}
+
+type SystemPruneOptions struct {
+	// Filters restrict every category, e.g. to a label.
+	Filters Filters
+	// Volumes also prunes unused volumes, which may hold data.
+	Volumes bool
+	// IncludeUnmanaged also prunes containers that ContainerCreate did not
+	// make. By default only containers carrying ManagedByLabel are pruned.
+	IncludeUnmanaged bool
+}
+
+// SystemPruneReport combines the reports of each prune SystemPrune ran.
+type SystemPruneReport struct {
+	Containers     PruneReport
+	Networks       PruneReport
+	Images         PruneReport
+	Volumes        PruneReport
+	SpaceReclaimed uint64
+}
+
+// ManagedFilter returns a copy of filters that also requires the
+// ManagedByLabel stamped by ContainerCreate.
+func ManagedFilter(filters Filters) Filters {
+	res := make(Filters, len(filters)+1)
+	for k, v := range filters {
+		res[k] = append([]string(nil), v...)
+	}
+	res["label"] = append(res["label"], ManagedByLabel+"="+ManagedByValue)
+	return res
+}
+
+// SystemPrune removes stopped containers, then the networks and images
+// they no longer hold, then, when opts.Volumes is set, unused volumes.
+// Only managed containers are pruned unless opts.IncludeUnmanaged is set. A
+// category the engine cannot prune is skipped; other failures do not stop
+// the remaining categories and are returned as an AggregateError.
+func SystemPrune(d Driver, opts SystemPruneOptions) (SystemPruneReport, error) {
+	var (
+		report SystemPruneReport
+		errs   []error
+	)
+	prune := func(fn func(Filters) (PruneReport, error), filters Filters, into *PruneReport) {
+		r, err := fn(filters)
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			errs = append(errs, err)
+		}
+		*into = r
+		report.SpaceReclaimed += r.SpaceReclaimed
+	}
+	containerFilters := opts.Filters
+	if !opts.IncludeUnmanaged {
+		containerFilters = ManagedFilter(opts.Filters)
+	}
+	prune(d.ContainersPrune, containerFilters, &report.Containers)
+	prune(d.NetworksPrune, opts.Filters, &report.Networks)
+	prune(d.ImagesPrune, opts.Filters, &report.Images)
+	if opts.Volumes {
+		prune(d.VolumesPrune, opts.Filters, &report.Volumes)
+	}
+	if len(errs) > 0 {
+		return report, &AggregateError{Errors: errs}
+	}
+	return report, nil
+}
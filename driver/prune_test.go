@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSystemPruneSumsReports(t *testing.T) {
+	m := newMockDriver()
+	stopped, err := m.runContainer(ContainerSpec{Name: "stopped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.update(stopped, func(c *mockContainer) { c.stats.BlockWrite = 1000 })
+	m.exit(stopped, 0)
+	if _, err := m.runContainer(ContainerSpec{
+		Name:   "router",
+		Mounts: []Mount{{Type: TypeVolume, Source: "skupper-internal", Target: "/etc/qpid-dispatch"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.NetworkCreate("unused", NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// retagging leaves the old image dangling
+	old := m.addImage("quay.io/skupper/router:1.0", ImageInspect{ID: "sha256:old", Size: 5000})
+	m.addImage("quay.io/skupper/router:1.0", ImageInspect{ID: "sha256:new"})
+	m.lock.Lock()
+	m.volumes["skupper-internal"] = 200
+	m.volumes["scratch"] = 300
+	m.lock.Unlock()
+
+	report, err := SystemPrune(m, SystemPruneOptions{Volumes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		category string
+		got      []string
+		want     []string
+	}{
+		{"containers", report.Containers.Deleted, []string{stopped}},
+		{"networks", report.Networks.Deleted, []string{"unused"}},
+		{"images", report.Images.Deleted, []string{old}},
+		{"volumes", report.Volumes.Deleted, []string{"scratch"}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("Expected %s %v to be pruned, got %v", tc.category, tc.want, tc.got)
+		}
+	}
+	if report.SpaceReclaimed != 1000+5000+300 {
+		t.Errorf("Expected the per-category space to add up to 6300, got %d", report.SpaceReclaimed)
+	}
+}
+
+func TestSystemPruneSkipsVolumesByDefault(t *testing.T) {
+	m := newMockDriver()
+	m.lock.Lock()
+	m.volumes["scratch"] = 300
+	m.lock.Unlock()
+	report, err := SystemPrune(m, SystemPruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("VolumesPrune"); n != 0 || len(report.Volumes.Deleted) != 0 {
+		t.Errorf("Expected volumes to be kept, got %d prunes of %v", n, report.Volumes.Deleted)
+	}
+}
+
+func TestSystemPruneSkipsUnmanaged(t *testing.T) {
+	m := newMockDriver()
+	var ids []string
+	for _, name := range []string{"managed", "external"} {
+		id, err := m.runContainer(ContainerSpec{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.exit(id, 0)
+		ids = append(ids, id)
+	}
+	// as if created outside this driver
+	m.update(ids[1], func(c *mockContainer) { delete(c.icd.Labels, ManagedByLabel) })
+	if managed, err := m.IsManaged(ids[1]); err != nil || managed {
+		t.Fatalf("Expected the external container to be unmanaged, got %v, %v", managed, err)
+	}
+
+	report, err := SystemPrune(m, SystemPruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Containers.Deleted, ids[:1]) {
+		t.Errorf("Expected only %s to be pruned, got %v", ids[0], report.Containers.Deleted)
+	}
+
+	report, err = SystemPrune(m, SystemPruneOptions{IncludeUnmanaged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Containers.Deleted, ids[1:]) {
+		t.Errorf("Expected IncludeUnmanaged to prune %s, got %v", ids[1], report.Containers.Deleted)
+	}
+}
@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// PullForPolicy makes ref available according to policy: PullAlways pulls
+// it, PullMissing pulls it unless it exists locally and PullNever fails if
+// it does not exist locally.
+func PullForPolicy(d Driver, ref string, policy PullPolicy) error {
+	switch policy {
+	case PullAlways:
+		_, err := d.ImagesPull(ref, ImagePullOptions{})
+		return err
+	case PullMissing, PullNever:
+		exists, err := d.ImageExists(ref)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		if policy == PullNever {
+			return fmt.Errorf("Image %s is not present and pull policy is %s", ref, policy)
+		}
+		_, err = d.ImagesPull(ref, ImagePullOptions{})
+		return err
+	}
+	return nil
+}
+
+// LinkContext returns a copy of ctx that is also cancelled once other is
+// done. Its watcher goroutine exits as soon as either context is done, so
+// calling cancel never leaks it. A nil other is ignored.
+func LinkContext(ctx context.Context, other context.Context) (context.Context, context.CancelFunc) {
+	linked, cancel := context.WithCancel(ctx)
+	if other == nil {
+		return linked, cancel
+	}
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-linked.Done():
+		}
+	}()
+	return linked, cancel
+}
+
+// ContextErr returns the error of the first done context in ctxs, skipping
+// nil ones, so a caller's cancellation is reported ahead of the derived
+// context it caused.
+func ContextErr(ctxs ...context.Context) error {
+	for _, ctx := range ctxs {
+		if ctx != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// PullAll pulls refs with at most concurrency pulls in flight and returns
+// the error for each reference (nil on success). When any pull failed, the
+// failures are also returned as an AggregateError, in the order of refs.
+// ctx bounds the pulls unless opts carries a context of its own, and
+// references not yet started when ctx is done are reported with its error.
+func PullAll(ctx context.Context, d Driver, refs []string, opts ImagePullOptions, concurrency int) (map[string]error, error) {
+	if concurrency <= 0 {
+		concurrency = len(refs)
+	}
+	if opts.Context == nil {
+		opts.Context = ctx
+	}
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	results := make(map[string]error, len(refs))
+	sem := NewSemaphore(concurrency)
+	for _, ref := range refs {
+		if err := sem.Acquire(ctx); err != nil {
+			lock.Lock()
+			results[ref] = err
+			lock.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(ref string) {
+			defer wg.Done()
+			defer sem.Release()
+			_, err := d.ImagesPull(ref, opts)
+			lock.Lock()
+			results[ref] = err
+			lock.Unlock()
+		}(ref)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, ref := range refs {
+		if err := results[ref]; err != nil {
+			errs = append(errs, fmt.Errorf("Failed to pull image %s: %w", ref, err))
+		}
+	}
+	if len(errs) > 0 {
+		return results, &AggregateError{Errors: errs}
+	}
+	return results, nil
+}
@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPullAll(t *testing.T) {
+	m := newMockDriver()
+	var (
+		lock            sync.Mutex
+		running, maxRan int
+	)
+	m.pull = func(ref string, options ImagePullOptions) error {
+		lock.Lock()
+		running++
+		if running > maxRan {
+			maxRan = running
+		}
+		lock.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if options.Context == nil {
+			return fmt.Errorf("No context")
+		}
+		if strings.Contains(ref, "invalid") {
+			return fmt.Errorf("manifest for %s not found", ref)
+		}
+		return nil
+	}
+	refs := []string{
+		"quay.io/skupper/router:1.0",
+		"quay.io/skupper/invalid:1.0",
+		"quay.io/skupper/service-controller:1.0",
+		"quay.io/skupper/invalid:2.0",
+		"quay.io/skupper/config-sync:1.0",
+	}
+
+	results, err := PullAll(context.Background(), m, refs, ImagePullOptions{}, 2)
+	var agg *AggregateError
+	if !errors.As(err, &agg) || len(agg.Errors) != 2 {
+		t.Fatalf("Expected an aggregate of 2 failures, got %v", err)
+	}
+	if !strings.Contains(agg.Errors[0].Error(), "invalid:1.0") || !strings.Contains(agg.Errors[1].Error(), "invalid:2.0") {
+		t.Errorf("Expected the failures in order, got %v", agg.Errors)
+	}
+	for _, ref := range refs {
+		failed := strings.Contains(ref, "invalid")
+		if got, ok := results[ref]; !ok || (got != nil) != failed {
+			t.Errorf("%s: unexpected result %v", ref, got)
+		}
+	}
+	if maxRan > 2 {
+		t.Errorf("Expected at most 2 pulls in flight, got %d", maxRan)
+	}
+}
+
+func TestPullAllCancelled(t *testing.T) {
+	m := newMockDriver()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := PullAll(ctx, m, []string{"quay.io/skupper/router:1.0"}, ImagePullOptions{}, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled error, got %v", err)
+	}
+	if !errors.Is(results["quay.io/skupper/router:1.0"], context.Canceled) {
+		t.Errorf("Expected the unstarted pull to be cancelled, got %v", results)
+	}
+	if n := m.called("ImagesPull"); n != 0 {
+		t.Errorf("Expected no pulls after cancellation, got %d", n)
+	}
+}
+
+func TestPullForPolicy(t *testing.T) {
+	ref := "quay.io/skupper/router:1.0"
+	for _, tc := range []struct {
+		policy  PullPolicy
+		present bool
+		pulls   int
+		fails   bool
+	}{
+		{PullAlways, true, 1, false},
+		{PullAlways, false, 1, false},
+		{PullMissing, true, 0, false},
+		{PullMissing, false, 1, false},
+		{PullNever, true, 0, false},
+		{PullNever, false, 0, true},
+	} {
+		m := newMockDriver()
+		if tc.present {
+			m.addImage(ref, ImageInspect{})
+		}
+		_, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: ref, PullPolicy: tc.policy})
+		if (err != nil) != tc.fails {
+			t.Errorf("%s with image present=%v: expected failure %v, got %v", tc.policy, tc.present, tc.fails, err)
+		}
+		if tc.fails && err != nil && !strings.Contains(err.Error(), "not present") {
+			t.Errorf("%s: expected a clear error, got %v", tc.policy, err)
+		}
+		if n := m.called("ImagesPull"); n != tc.pulls {
+			t.Errorf("%s with image present=%v: expected %d pulls, got %d", tc.policy, tc.present, tc.pulls, n)
+		}
+	}
+}
+
+func TestLinkContext(t *testing.T) {
+	other, cancelOther := context.WithCancel(context.Background())
+	linked, cancel := LinkContext(context.Background(), other)
+	defer cancel()
+	cancelOther()
+	select {
+	case <-linked.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the linked context to be cancelled with the other")
+	}
+	if err := ContextErr(other, linked); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		_, cancel := LinkContext(context.Background(), context.Background())
+		cancel()
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected cancel to stop the watchers, had %d goroutines, now %d", before, n)
+	}
+}
@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"context"
+	"sync"
+)
+
+// pullCall is a pull in flight whose result is shared by every caller
+// that asked for the same reference while it ran.
+type pullCall struct {
+	done chan struct{}
+	res  []string
+	err  error
+
+	// cancel aborts the pull once every caller waiting on it has gone.
+	// waiters and progress are guarded by the cache's lock.
+	cancel   context.CancelFunc
+	waiters  int
+	progress map[int]func(PullProgress)
+}
+
+// PullCache is a Driver whose ImagesPull collapses concurrent pulls of the
+// same reference into a single call to the wrapped driver. Results are not
+// kept once the pull completes; a later pull reaches the engine again.
+//
+// The shared pull is not bound to any one caller's context: a caller whose
+// context is done stops waiting on its own, and the pull is only cancelled
+// once no caller is left waiting. Progress goes to every waiting caller.
+type PullCache struct {
+	Driver
+
+	lock     sync.Mutex
+	inflight map[string]*pullCall
+	nextID   int
+}
+
+// NewPullCache wraps d with pull deduplication.
+func NewPullCache(d Driver) *PullCache {
+	return &PullCache{
+		Driver:   d,
+		inflight: map[string]*pullCall{},
+	}
+}
+
+// PullCacheMiddleware returns a Middleware wrapping drivers with NewPullCache.
+func PullCacheMiddleware() Middleware {
+	return func(d Driver) Driver {
+		return NewPullCache(d)
+	}
+}
+
+func (p *PullCache) ImagesPull(refStr string, options ImagePullOptions) ([]string, error) {
+	key := refStr
+	if options.All {
+		key += " all"
+	}
+
+	p.lock.Lock()
+	call, ok := p.inflight[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		call = &pullCall{
+			done:     make(chan struct{}),
+			cancel:   cancel,
+			progress: map[int]func(PullProgress){},
+		}
+		p.inflight[key] = call
+		go p.run(ctx, key, refStr, options.All, call)
+	}
+	id := p.nextID
+	p.nextID++
+	call.waiters++
+	if options.Progress != nil {
+		call.progress[id] = options.Progress
+	}
+	p.lock.Unlock()
+
+	var cancelled <-chan struct{}
+	if options.Context != nil {
+		cancelled = options.Context.Done()
+	}
+	select {
+	case <-call.done:
+		return call.res, call.err
+	case <-cancelled:
+		p.leave(key, id, call)
+		return nil, &PullInterruptedError{Ref: refStr, Err: options.Context.Err()}
+	}
+}
+
+// run performs the shared pull and publishes its result to the waiters.
+func (p *PullCache) run(ctx context.Context, key string, refStr string, all bool, call *pullCall) {
+	res, err := p.Driver.ImagesPull(refStr, ImagePullOptions{
+		All:      all,
+		Context:  ctx,
+		Progress: func(progress PullProgress) { p.forward(call, progress) },
+	})
+
+	p.lock.Lock()
+	if p.inflight[key] == call {
+		delete(p.inflight, key)
+	}
+	p.lock.Unlock()
+	call.cancel()
+	call.res, call.err = res, err
+	close(call.done)
+}
+
+// forward passes a progress update of the shared pull to the callers
+// still waiting on it.
+func (p *PullCache) forward(call *pullCall, progress PullProgress) {
+	p.lock.Lock()
+	var listeners []func(PullProgress)
+	for _, fn := range call.progress {
+		listeners = append(listeners, fn)
+	}
+	p.lock.Unlock()
+	for _, fn := range listeners {
+		fn(progress)
+	}
+}
+
+// leave removes a caller that stopped waiting on call, cancelling the pull
+// when it was the last one. A pull nobody waits for is forgotten at once so
+// that a later caller starts afresh instead of joining a cancelled pull.
+func (p *PullCache) leave(key string, id int, call *pullCall) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(call.progress, id)
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	if p.inflight[key] == call {
+		delete(p.inflight, key)
+	}
+	call.cancel()
+}
@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPullCacheDeduplicatesConcurrentPulls(t *testing.T) {
+	m := newMockDriver()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m.pull = func(ref string, options ImagePullOptions) error {
+		close(started)
+		<-release
+		return nil
+	}
+	p := NewPullCache(m)
+
+	ref := "quay.io/skupper/router:1.0"
+	results := make([][]string, 5)
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	pull := func(i int) {
+		defer wg.Done()
+		results[i], errs[i] = p.ImagesPull(ref, ImagePullOptions{})
+	}
+	wg.Add(1)
+	go pull(0)
+	<-started
+	for i := 1; i < 5; i++ {
+		wg.Add(1)
+		go pull(i)
+	}
+	// let the others join the pull in flight before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := m.called("ImagesPull"); n != 1 {
+		t.Errorf("Expected one backend pull, got %d", n)
+	}
+	for i := range results {
+		if errs[i] != nil {
+			t.Errorf("Pull %d failed: %v", i, errs[i])
+		}
+		if !reflect.DeepEqual(results[i], []string{ref}) {
+			t.Errorf("Expected pull %d to share the result, got %v", i, results[i])
+		}
+	}
+
+	// a completed pull is not cached
+	m.pull = nil
+	if _, err := p.ImagesPull(ref, ImagePullOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ImagesPull"); n != 2 {
+		t.Errorf("Expected a later pull to reach the backend, got %d pulls", n)
+	}
+}
+
+func TestPullCacheLeaderCancelled(t *testing.T) {
+	m := newMockDriver()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m.pull = func(ref string, options ImagePullOptions) error {
+		close(started)
+		<-release
+		options.Progress(PullProgress{Ref: ref, Layer: "layer1", Status: "Downloading"})
+		return nil
+	}
+	p := NewPullCache(m)
+	ref := "quay.io/skupper/router:1.0"
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := p.ImagesPull(ref, ImagePullOptions{Context: leaderCtx})
+		leaderErr <- err
+	}()
+	<-started
+
+	var progress []PullProgress
+	followerDone := make(chan struct{})
+	var res []string
+	var err error
+	go func() {
+		defer close(followerDone)
+		res, err = p.ImagesPull(ref, ImagePullOptions{
+			Context:  context.Background(),
+			Progress: func(pp PullProgress) { progress = append(progress, pp) },
+		})
+	}()
+	// let the follower join the pull in flight before the leader goes
+	time.Sleep(50 * time.Millisecond)
+	cancelLeader()
+
+	var interrupted *PullInterruptedError
+	if err := <-leaderErr; !errors.As(err, &interrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the leader to be interrupted, got %v", err)
+	}
+	close(release)
+	<-followerDone
+	if err != nil {
+		t.Errorf("Expected the follower's pull to complete, got %v", err)
+	}
+	if !reflect.DeepEqual(res, []string{ref}) {
+		t.Errorf("Expected the follower to get the pull's result, got %v", res)
+	}
+	if len(progress) != 1 || progress[0].Layer != "layer1" {
+		t.Errorf("Expected the follower to receive the pull's progress, got %v", progress)
+	}
+	if n := m.called("ImagesPull"); n != 1 {
+		t.Errorf("Expected one backend pull, got %d", n)
+	}
+}
+
+func TestPullCacheCancelsWhenAllWaitersLeave(t *testing.T) {
+	m := newMockDriver()
+	aborted := make(chan error, 1)
+	m.pull = func(ref string, options ImagePullOptions) error {
+		<-options.Context.Done()
+		aborted <- options.Context.Err()
+		return options.Context.Err()
+	}
+	p := NewPullCache(m)
+	ref := "quay.io/skupper/router:1.0"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ImagesPull(ref, ImagePullOptions{Context: ctx})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	select {
+	case err := <-aborted:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the backend pull to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the backend pull to be cancelled once no caller waits")
+	}
+}
@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RemoveByLabel removes every container whose label is set to value and
+// returns the IDs that were removed. Unless opts.IncludeUnmanaged is set,
+// containers without the ManagedByLabel are left alone. Failures are
+// collected into an AggregateError; the loop stops early once the driver's
+// context has been cancelled.
+func RemoveByLabel(d Driver, label string, value string, opts RemoveOptions) ([]string, error) {
+	filters := LabelFilter(label, value)
+	if !opts.IncludeUnmanaged {
+		filters = ManagedFilter(filters)
+	}
+	list, err := d.ContainerList(ContainerListOptions{
+		All:     true,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	var errs []error
+	for _, c := range list {
+		if err := d.ContainerRemove(c.ID, opts); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to remove container %s: %w", c.ID, err))
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			continue
+		}
+		removed = append(removed, c.ID)
+	}
+	if len(errs) > 0 {
+		return removed, &AggregateError{Errors: errs}
+	}
+	return removed, nil
+}
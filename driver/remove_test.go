@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestRemoveByLabel(t *testing.T) {
+	m := newMockDriver()
+	var labeled []string
+	for i := 0; i < 3; i++ {
+		id, err := m.runContainer(ContainerSpec{
+			Name:   fmt.Sprintf("router-%d", i),
+			Labels: map[string]string{"application": "skupper"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		labeled = append(labeled, id)
+	}
+	other, err := m.runContainer(ContainerSpec{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := RemoveByLabel(m, "application", "skupper", RemoveOptions{Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(removed)
+	sort.Strings(labeled)
+	if fmt.Sprint(removed) != fmt.Sprint(labeled) {
+		t.Errorf("Expected %v to be removed, got %v", labeled, removed)
+	}
+	if _, err := m.ContainerInspect(other); err != nil {
+		t.Errorf("Expected the unlabeled container to remain, got %v", err)
+	}
+	for _, id := range labeled {
+		if _, err := m.ContainerInspect(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %s to be removed, got %v", id, err)
+		}
+	}
+}
+
+func TestRemoveByLabelStopsOnDeadline(t *testing.T) {
+	m := newMockDriver()
+	for i := 0; i < 3; i++ {
+		if _, err := m.runContainer(ContainerSpec{
+			Name:   fmt.Sprintf("router-%d", i),
+			Labels: map[string]string{"application": "skupper"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.fail("ContainerRemove", fmt.Errorf("operation timeout: %w", context.DeadlineExceeded))
+
+	removed, err := RemoveByLabel(m, "application", "skupper", RemoveOptions{Force: true})
+	if len(removed) != 0 {
+		t.Errorf("Expected nothing to be removed, got %v", removed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the aggregate error to match the deadline, got %v", err)
+	}
+	var agg *AggregateError
+	if !errors.As(err, &agg) || len(agg.Errors) != 1 {
+		t.Errorf("Expected a single failure before stopping, got %v", err)
+	}
+	if n := m.called("ContainerRemove"); n != 1 {
+		t.Errorf("Expected the loop to stop after the deadline, got %d removes", n)
+	}
+}
+
+func TestRemoveByLabelSkipsUnmanaged(t *testing.T) {
+	m := newMockDriver()
+	labels := map[string]string{"application": "skupper"}
+	managed, err := m.runContainer(ContainerSpec{Name: "router", Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	external, err := m.runContainer(ContainerSpec{Name: "external", Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as if created outside this driver
+	m.update(external, func(c *mockContainer) { delete(c.icd.Labels, ManagedByLabel) })
+
+	removed, err := RemoveByLabel(m, "application", "skupper", RemoveOptions{Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(removed) != fmt.Sprint([]string{managed}) {
+		t.Errorf("Expected only %s to be removed, got %v", managed, removed)
+	}
+	if _, err := m.ContainerInspect(external); err != nil {
+		t.Errorf("Expected the unmanaged container to remain, got %v", err)
+	}
+
+	removed, err = RemoveByLabel(m, "application", "skupper", RemoveOptions{Force: true, IncludeUnmanaged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(removed) != fmt.Sprint([]string{external}) {
+		t.Errorf("Expected IncludeUnmanaged to remove %s, got %v", external, removed)
+	}
+}
@@ -0,0 +1,30 @@
+package driver
+
+import (
+	"context"
+)
+
+// Semaphore bounds the number of concurrent operations.
+type Semaphore chan struct{}
+
+func NewSemaphore(n int) Semaphore {
+	return make(Semaphore, n)
+}
+
+// Acquire blocks until a slot is free or ctx is done. A ctx that is
+// already done always fails.
+func (s Semaphore) Acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s Semaphore) Release() {
+	<-s
+}
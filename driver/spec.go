@@ -0,0 +1,293 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+const (
+	// SpecHashLabel is the label ContainerCreate stamps with the SpecHash
+	// of the spec a container was created from.
+	SpecHashLabel = "io.skupper.spec-hash"
+	// ReadinessProbeLabel holds the spec's ReadinessProbe as JSON.
+	ReadinessProbeLabel = "io.skupper.readiness-probe"
+	// ManagedByLabel marks containers ContainerCreate made, so bulk
+	// operations leave other containers on the host alone.
+	ManagedByLabel = "io.skupper.managed-by"
+	// ManagedByValue is the value ManagedByLabel is stamped with.
+	ManagedByValue = "ce-drivers"
+)
+
+// Validate checks spec for settings that no engine would accept.
+func (spec ContainerSpec) Validate() error {
+	if spec.Image == "" {
+		return fmt.Errorf("No image specified")
+	}
+	if spec.Platform != "" {
+		if _, _, _, err := ParsePlatform(spec.Platform); err != nil {
+			return err
+		}
+	}
+	switch spec.PullPolicy {
+	case "", PullAlways, PullMissing, PullNever:
+	default:
+		return fmt.Errorf("Invalid pull policy %s", spec.PullPolicy)
+	}
+	switch spec.CgroupnsMode {
+	case "", CgroupnsModeHost, CgroupnsModePrivate:
+	default:
+		return fmt.Errorf("Invalid cgroupns mode %s", spec.CgroupnsMode)
+	}
+	for _, ns := range spec.namespaceModes() {
+		if err := validateNamespaceMode(ns[0], ns[1]); err != nil {
+			return err
+		}
+	}
+	if spec.WorkingDir != "" && !path.IsAbs(spec.WorkingDir) {
+		return fmt.Errorf("Working directory %s must be an absolute path", spec.WorkingDir)
+	}
+	if spec.Runtime != "" && strings.TrimSpace(spec.Runtime) != spec.Runtime {
+		return fmt.Errorf("Invalid runtime %q", spec.Runtime)
+	}
+	if spec.StopTimeout != nil && *spec.StopTimeout < 0 {
+		return fmt.Errorf("Invalid stop timeout %d", *spec.StopTimeout)
+	}
+	if err := spec.Resources.Validate(); err != nil {
+		return err
+	}
+	if err := spec.NetworkConfig.Validate(); err != nil {
+		return err
+	}
+	if spec.ShmSize < 0 {
+		return fmt.Errorf("Invalid shm size %d", spec.ShmSize)
+	}
+	for _, u := range spec.Ulimits {
+		if u.Name == "" {
+			return fmt.Errorf("Ulimit name is required")
+		}
+		if u.Hard < u.Soft {
+			return fmt.Errorf("Ulimit %s: hard limit %d is lower than soft limit %d", u.Name, u.Hard, u.Soft)
+		}
+	}
+	for _, d := range spec.Devices {
+		if !path.IsAbs(d.PathOnHost) {
+			return fmt.Errorf("Device path %s must be an absolute path", d.PathOnHost)
+		}
+		if d.PathInContainer != "" && !path.IsAbs(d.PathInContainer) {
+			return fmt.Errorf("Device path %s must be an absolute path", d.PathInContainer)
+		}
+	}
+	for _, m := range spec.Mounts {
+		if !path.IsAbs(m.Target) {
+			return fmt.Errorf("Mount target %s must be an absolute path", m.Target)
+		}
+	}
+	for target := range spec.Tmpfs {
+		if !path.IsAbs(target) {
+			return fmt.Errorf("Tmpfs target %s must be an absolute path", target)
+		}
+	}
+	if p := spec.ReadinessProbe; p != nil {
+		if len(p.Exec) == 0 {
+			return fmt.Errorf("Readiness probe command is required")
+		}
+		if p.Interval < 0 || p.Timeout < 0 || p.Retries < 0 {
+			return fmt.Errorf("Invalid readiness probe settings")
+		}
+	}
+	for _, secret := range spec.Secrets {
+		if secret.Source == "" {
+			return fmt.Errorf("Secret source is required")
+		}
+		if !path.IsAbs(secret.Target) {
+			return fmt.Errorf("Secret target %s must be an absolute path", secret.Target)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the limits are in range and consistent.
+func (r Resources) Validate() error {
+	if r.Memory < 0 || r.MemoryReservation < 0 || r.NanoCPUs < 0 || r.CPUShares < 0 {
+		return fmt.Errorf("Resource limits must not be negative")
+	}
+	if r.Memory > 0 && r.MemoryReservation > r.Memory {
+		return fmt.Errorf("Memory reservation %d is above the memory limit %d", r.MemoryReservation, r.Memory)
+	}
+	if r.OOMScoreAdj < -1000 || r.OOMScoreAdj > 1000 {
+		return fmt.Errorf("Invalid OOM score adjustment %d, must be between -1000 and 1000", r.OOMScoreAdj)
+	}
+	return nil
+}
+
+// namespaceModes pairs each namespace kind with the spec's mode for it.
+func (spec ContainerSpec) namespaceModes() [][2]string {
+	return [][2]string{{"pid", spec.PidMode}, {"ipc", spec.IpcMode}, {"uts", spec.UTSMode}}
+}
+
+func validateNamespaceMode(kind string, mode string) error {
+	switch mode {
+	case "", "host", "private":
+		return nil
+	case "shareable", "none":
+		if kind == "ipc" {
+			return nil
+		}
+	}
+	if target, ok := NamespaceTarget(mode); ok && target != "" {
+		return nil
+	}
+	return fmt.Errorf("Invalid %s namespace mode %s", kind, mode)
+}
+
+// NamespaceTarget returns the container a "container:<name or id>"
+// namespace mode shares with, and false for any other mode.
+func NamespaceTarget(mode string) (string, bool) {
+	if !strings.HasPrefix(mode, NamespaceModeContainer) {
+		return "", false
+	}
+	return strings.TrimPrefix(mode, NamespaceModeContainer), true
+}
+
+// CheckNamespaceTargets checks that the containers whose namespaces spec
+// shares exist, for a clearer error than the engine's at start time.
+func CheckNamespaceTargets(d Driver, spec ContainerSpec) error {
+	for _, ns := range spec.namespaceModes() {
+		target, ok := NamespaceTarget(ns[1])
+		if !ok {
+			continue
+		}
+		if _, err := d.ContainerInspect(target); err != nil {
+			return fmt.Errorf("Couldn't find container %s to share the %s namespace of: %w", target, ns[0], err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the DNS servers are ip addresses and the extra
+// hosts are "host:ip" entries.
+func (n NetworkConfig) Validate() error {
+	for _, server := range n.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("Invalid DNS server %s", server)
+		}
+	}
+	for _, entry := range n.ExtraHosts {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+			return fmt.Errorf("Invalid extra host %s, must be host:ip", entry)
+		}
+	}
+	return nil
+}
+
+// SpecHash returns a stable hash of the settings spec creates a container
+// with. PullPolicy, ReuseExisting and the SpecHashLabel and ManagedByLabel
+// labels are ignored, and EnvFiles count by path, not content.
+func SpecHash(spec ContainerSpec) string {
+	spec.PullPolicy = ""
+	spec.ReuseExisting = false
+	_, hashed := spec.Labels[SpecHashLabel]
+	_, managed := spec.Labels[ManagedByLabel]
+	if hashed || managed {
+		labels := make(map[string]string, len(spec.Labels))
+		for k, v := range spec.Labels {
+			if k != SpecHashLabel && k != ManagedByLabel {
+				labels[k] = v
+			}
+		}
+		spec.Labels = labels
+	}
+	// json sorts map keys, so equal specs always encode the same
+	data, err := json.Marshal(spec)
+	if err != nil {
+		// a ContainerSpec only holds plain data, so this cannot happen
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// EngineLabels returns a copy of spec.Labels with the labels drivers stamp
+// at create time: SpecHashLabel, ManagedByLabel and, when the spec has a
+// readiness probe, ReadinessProbeLabel.
+func (spec ContainerSpec) EngineLabels() map[string]string {
+	labels := make(map[string]string, len(spec.Labels)+3)
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	labels[SpecHashLabel] = SpecHash(spec)
+	labels[ManagedByLabel] = ManagedByValue
+	if spec.ReadinessProbe != nil {
+		data, err := json.Marshal(spec.ReadinessProbe)
+		if err != nil {
+			// a Probe only holds plain data, so this cannot happen
+			panic(err)
+		}
+		labels[ReadinessProbeLabel] = string(data)
+	}
+	return labels
+}
+
+// SpecFromInspect reconstructs the spec a container was created from.
+// Settings the engine does not report are left zero.
+func SpecFromInspect(icd *InspectContainerData) ContainerSpec {
+	spec := ContainerSpec{
+		Name:          strings.TrimPrefix(icd.Name, "/"),
+		Image:         icd.ImageName,
+		Env:           icd.Env,
+		RestartPolicy: icd.RestartPolicy,
+		Runtime:       icd.Runtime,
+		PidMode:       icd.PidMode,
+		IpcMode:       icd.IpcMode,
+		UTSMode:       icd.UTSMode,
+		CgroupParent:  icd.CgroupParent,
+		StopSignal:    icd.StopSignal,
+		StopTimeout:   icd.StopTimeout,
+		Annotations:   icd.Annotations,
+		Resources:     icd.Resources,
+	}
+	switch icd.CgroupnsMode {
+	case CgroupnsModeHost, CgroupnsModePrivate:
+		spec.CgroupnsMode = icd.CgroupnsMode
+	}
+	for k, v := range icd.Labels {
+		if k == ReadinessProbeLabel {
+			var probe Probe
+			if err := json.Unmarshal([]byte(v), &probe); err == nil {
+				spec.ReadinessProbe = &probe
+				continue
+			}
+		}
+		if spec.Labels == nil {
+			spec.Labels = map[string]string{}
+		}
+		spec.Labels[k] = v
+	}
+	for _, mp := range icd.Mounts {
+		m := Mount{
+			Type:     mp.Type,
+			Source:   mp.Source,
+			Target:   mp.Destination,
+			ReadOnly: !mp.RW,
+		}
+		if mp.Type == TypeVolume {
+			m.Source = mp.Name
+		}
+		spec.Mounts = append(spec.Mounts, m)
+	}
+	for _, p := range icd.PortBindings {
+		spec.Ports = append(spec.Ports, PortMapping{
+			HostIP:        p.IP,
+			HostPort:      p.HostPort,
+			ContainerPort: p.ContainerPort,
+			Protocol:      p.Type,
+		})
+	}
+	return spec
+}
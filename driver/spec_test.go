@@ -0,0 +1,231 @@
+package driver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateUlimits(t *testing.T) {
+	spec := ContainerSpec{Image: "quay.io/skupper/router"}
+	spec.Ulimits = []Ulimit{{Name: "nofile", Soft: 1024, Hard: 65536}}
+	if err := spec.Validate(); err != nil {
+		t.Errorf("Expected a valid ulimit, got %v", err)
+	}
+	spec.Ulimits = []Ulimit{{Name: "nofile", Soft: 65536, Hard: 1024}}
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected a hard limit below the soft limit to be rejected")
+	}
+	spec.Ulimits = []Ulimit{{Soft: 1, Hard: 1}}
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected a ulimit without a name to be rejected")
+	}
+}
+
+func TestValidateCgroupnsMode(t *testing.T) {
+	for mode, valid := range map[string]bool{"": true, CgroupnsModeHost: true, CgroupnsModePrivate: true, "shared": false} {
+		spec := ContainerSpec{Image: "quay.io/skupper/router", CgroupnsMode: mode}
+		if err := spec.Validate(); (err == nil) != valid {
+			t.Errorf("Expected cgroupns mode %q valid=%v, got %v", mode, valid, err)
+		}
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	for _, r := range []Resources{
+		{OOMScoreAdj: -1000},
+		{OOMScoreAdj: 1000},
+		{Memory: 256 << 20, MemoryReservation: 128 << 20},
+		{MemoryReservation: 128 << 20},
+	} {
+		spec := ContainerSpec{Image: "quay.io/skupper/router", Resources: r}
+		if err := spec.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", r, err)
+		}
+	}
+	for _, r := range []Resources{
+		{OOMScoreAdj: -1001},
+		{OOMScoreAdj: 1001},
+		{Memory: 128 << 20, MemoryReservation: 256 << 20},
+		{MemoryReservation: -1},
+	} {
+		spec := ContainerSpec{Image: "quay.io/skupper/router", Resources: r}
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", r)
+		}
+	}
+}
+
+func TestContainerSpecOfRoundTrip(t *testing.T) {
+	m := newMockDriver()
+	spec := ContainerSpec{
+		Name:          "router",
+		Env:           []string{"QDROUTERD_CONF=/etc/qpid-dispatch/qdrouterd.json"},
+		Labels:        map[string]string{"application": "skupper-router"},
+		RestartPolicy: RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+		Mounts: []Mount{
+			{Type: TypeVolume, Source: "skupper-internal", Target: "/etc/qpid-dispatch"},
+			{Type: TypeBind, Source: "/etc/skupper", Target: "/etc/skupper", ReadOnly: true},
+		},
+		Ports:          []PortMapping{{HostIP: "127.0.0.1", HostPort: 5671, ContainerPort: 5671, Protocol: "tcp"}},
+		ReadinessProbe: &Probe{Exec: []string{"qdstat", "-g"}},
+	}
+	id, err := m.runContainer(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.ContainerSpecOf(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Image = "quay.io/skupper/router:latest"
+	if got.Name != spec.Name || got.Image != spec.Image {
+		t.Errorf("Expected %s from %s, got %s from %s", spec.Name, spec.Image, got.Name, got.Image)
+	}
+	if !reflect.DeepEqual(got.Env, spec.Env) {
+		t.Errorf("Expected env %v, got %v", spec.Env, got.Env)
+	}
+	if got.Labels["application"] != "skupper-router" {
+		t.Errorf("Expected the application label, got %v", got.Labels)
+	}
+	if got.RestartPolicy != spec.RestartPolicy {
+		t.Errorf("Expected restart policy %v, got %v", spec.RestartPolicy, got.RestartPolicy)
+	}
+	if !reflect.DeepEqual(got.Mounts, spec.Mounts) {
+		t.Errorf("Expected mounts %v, got %v", spec.Mounts, got.Mounts)
+	}
+	if !reflect.DeepEqual(got.Ports, spec.Ports) {
+		t.Errorf("Expected ports %v, got %v", spec.Ports, got.Ports)
+	}
+	if !reflect.DeepEqual(got.ReadinessProbe, spec.ReadinessProbe) {
+		t.Errorf("Expected probe %v, got %v", spec.ReadinessProbe, got.ReadinessProbe)
+	}
+}
+
+func TestValidateDevices(t *testing.T) {
+	spec := ContainerSpec{Image: "quay.io/skupper/router"}
+	spec.Devices = []DeviceMapping{{PathOnHost: "/dev/null", PathInContainer: "/dev/telemetry"}}
+	if err := spec.Validate(); err != nil {
+		t.Errorf("Expected a valid device, got %v", err)
+	}
+	spec.Devices = []DeviceMapping{{PathOnHost: "dev/null"}}
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected a relative host path to be rejected")
+	}
+	spec.Devices = []DeviceMapping{{PathOnHost: "/dev/null", PathInContainer: "telemetry"}}
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected a relative container path to be rejected")
+	}
+}
+
+func TestValidateWorkingDir(t *testing.T) {
+	spec := ContainerSpec{Image: "quay.io/skupper/router", WorkingDir: "/var/lib/skupper"}
+	if err := spec.Validate(); err != nil {
+		t.Errorf("Expected an absolute working directory to be valid, got %v", err)
+	}
+	spec.WorkingDir = "skupper"
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected a relative working directory to be rejected")
+	}
+}
+
+func TestSpecHash(t *testing.T) {
+	spec := ContainerSpec{
+		Name:   "router",
+		Image:  "quay.io/skupper/router:1.0",
+		Env:    []string{"QDROUTERD_CONF=/etc/qpid-dispatch/qdrouterd.json"},
+		Labels: map[string]string{"application": "skupper-router", "version": "1.0"},
+	}
+	same := spec
+	same.Labels = map[string]string{"version": "1.0", "application": "skupper-router"}
+	same.PullPolicy = PullAlways
+	if SpecHash(spec) != SpecHash(same) {
+		t.Errorf("Expected identical specs to hash the same")
+	}
+	changed := spec
+	changed.Env = []string{"QDROUTERD_CONF=/etc/skupper-router/skrouterd.json"}
+	if SpecHash(spec) == SpecHash(changed) {
+		t.Errorf("Expected a changed env to change the hash")
+	}
+}
+
+func TestContainerMatchesSpec(t *testing.T) {
+	m := newMockDriver()
+	spec := ContainerSpec{Name: "router", Env: []string{"QDROUTERD_CONF=/etc/qpid-dispatch/qdrouterd.json"}}
+	id, err := m.runContainer(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Image = "quay.io/skupper/router:latest"
+	if match, err := m.ContainerMatchesSpec(id, spec); err != nil || !match {
+		t.Errorf("Expected the creating spec to match, got %v, %v", match, err)
+	}
+	spec.Env = []string{"QDROUTERD_CONF=/etc/skupper-router/skrouterd.json"}
+	if match, err := m.ContainerMatchesSpec(id, spec); err != nil || match {
+		t.Errorf("Expected a changed env to mismatch, got %v, %v", match, err)
+	}
+}
+
+func TestValidateTmpfs(t *testing.T) {
+	spec := ContainerSpec{Image: "quay.io/skupper/router", Tmpfs: map[string]string{"/scratch": "size=64m"}}
+	if err := spec.Validate(); err != nil {
+		t.Errorf("Expected an absolute tmpfs target to be valid, got %v", err)
+	}
+	spec.Tmpfs = map[string]string{"scratch": ""}
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected a relative tmpfs target to be rejected")
+	}
+}
+
+func TestValidateNetworkConfig(t *testing.T) {
+	config := NetworkConfig{DNS: []string{"10.0.0.53", "fd00::53"}, ExtraHosts: []string{"router:10.0.0.7"}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected ip nameservers and host:ip entries to be valid, got %v", err)
+	}
+	for _, invalid := range []NetworkConfig{
+		{DNS: []string{"dns.example.com"}},
+		{ExtraHosts: []string{"router"}},
+		{ExtraHosts: []string{"router:example.com"}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestValidateNamespaceModes(t *testing.T) {
+	for _, spec := range []ContainerSpec{
+		{PidMode: "host"},
+		{PidMode: "container:router", UTSMode: "container:router"},
+		{IpcMode: "shareable"},
+	} {
+		spec.Image = "quay.io/skupper/router"
+		if err := spec.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", spec, err)
+		}
+	}
+	for _, spec := range []ContainerSpec{
+		{PidMode: "container:"},
+		{PidMode: "shareable"},
+		{UTSMode: "sideways"},
+	} {
+		spec.Image = "quay.io/skupper/router"
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", spec)
+		}
+	}
+}
+
+func TestCheckNamespaceTargets(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckNamespaceTargets(m, ContainerSpec{PidMode: NamespaceModeContainer + id}); err != nil {
+		t.Errorf("Expected an existing target to pass, got %v", err)
+	}
+	if err := CheckNamespaceTargets(m, ContainerSpec{IpcMode: "container:missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing target to be reported, got %v", err)
+	}
+}
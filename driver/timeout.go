@@ -0,0 +1,621 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultOperation is the TimeoutMiddleware key whose timeout applies to
+// every method without a timeout of its own.
+const DefaultOperation = "default"
+
+// OperationTimeoutError is returned by a driver wrapped with
+// TimeoutMiddleware when a method did not return within its timeout. It
+// matches context.DeadlineExceeded.
+type OperationTimeoutError struct {
+	Method  string
+	Timeout time.Duration
+}
+
+func (e *OperationTimeoutError) Error() string {
+	return fmt.Sprintf("%s did not complete within %v", e.Method, e.Timeout)
+}
+
+func (e *OperationTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+type timeoutDriver struct {
+	Driver
+	timeouts map[string]time.Duration
+}
+
+// TimeoutMiddleware returns a Middleware bounding each call by the timeout
+// stored under its method name in timeouts, e.g. "ImagesPull", or else
+// under DefaultOperation. A missing or zero timeout leaves the call
+// unbounded.
+//
+// ImagesPull, ImageWait and WaitForPort are cancelled through their
+// context. Other calls cannot be cancelled: the wrapper stops waiting and
+// returns an OperationTimeoutError while the engine finishes the request
+// in the background. New, Reconnect, Close, the streaming methods
+// ContainerExecStream, ContainerEvents, ContainerLogs and WatchPath, and
+// ImageSave and ImageLoad, which stream an archive for as long as the
+// caller reads or writes it, are not bounded; pass the latter two a
+// context with a deadline instead.
+func TimeoutMiddleware(timeouts map[string]time.Duration) Middleware {
+	return func(d Driver) Driver {
+		return &timeoutDriver{Driver: d, timeouts: timeouts}
+	}
+}
+
+func (t *timeoutDriver) timeout(method string) time.Duration {
+	if timeout, ok := t.timeouts[method]; ok {
+		return timeout
+	}
+	return t.timeouts[DefaultOperation]
+}
+
+// run calls fn and waits for it at most method's timeout. Callers must only
+// read what fn sets when run did not time out.
+func (t *timeoutDriver) run(method string, fn func() error) error {
+	timeout := t.timeout(method)
+	if timeout <= 0 {
+		return fn()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return &OperationTimeoutError{Method: method, Timeout: timeout}
+	}
+}
+
+func timedOut(err error) bool {
+	_, ok := err.(*OperationTimeoutError)
+	return ok
+}
+
+func (t *timeoutDriver) ImagesPull(refStr string, options ImagePullOptions) ([]string, error) {
+	timeout := t.timeout("ImagesPull")
+	if timeout <= 0 {
+		return t.Driver.ImagesPull(refStr, options)
+	}
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	options.Context = ctx
+	return t.Driver.ImagesPull(refStr, options)
+}
+
+func (t *timeoutDriver) ImageWait(ctx context.Context, ref string, interval time.Duration) error {
+	if timeout := t.timeout("ImageWait"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return t.Driver.ImageWait(ctx, ref, interval)
+}
+
+func (t *timeoutDriver) ImageTag(src string, dst string) error {
+	return t.run("ImageTag", func() error {
+		return t.Driver.ImageTag(src, dst)
+	})
+}
+
+func (t *timeoutDriver) WaitForPort(ctx context.Context, id string, port int, proto string) error {
+	if timeout := t.timeout("WaitForPort"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return t.Driver.WaitForPort(ctx, id, port, proto)
+}
+
+func (t *timeoutDriver) SupportsFeature(feature Feature) (bool, error) {
+	var res bool
+	err := t.run("SupportsFeature", func() (err error) {
+		res, err = t.Driver.SupportsFeature(feature)
+		return err
+	})
+	if timedOut(err) {
+		return false, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ImageInspect(id string, options ImageInspectOptions) (*ImageInspect, error) {
+	var res *ImageInspect
+	err := t.run("ImageInspect", func() (err error) {
+		res, err = t.Driver.ImageInspect(id, options)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ImagesList(options ImageListOptions) ([]ImageSummary, error) {
+	var res []ImageSummary
+	err := t.run("ImagesList", func() (err error) {
+		res, err = t.Driver.ImagesList(options)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ImagesDiskUsage() (ImagesDiskReport, error) {
+	var res ImagesDiskReport
+	err := t.run("ImagesDiskUsage", func() (err error) {
+		res, err = t.Driver.ImagesDiskUsage()
+		return err
+	})
+	if timedOut(err) {
+		return ImagesDiskReport{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ImageExists(ref string) (bool, error) {
+	var res bool
+	err := t.run("ImageExists", func() (err error) {
+		res, err = t.Driver.ImageExists(ref)
+		return err
+	})
+	if timedOut(err) {
+		return false, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerCreate(spec ContainerSpec) (ContainerCreateResponse, error) {
+	var res ContainerCreateResponse
+	err := t.run("ContainerCreate", func() (err error) {
+		res, err = t.Driver.ContainerCreate(spec)
+		return err
+	})
+	if timedOut(err) {
+		return ContainerCreateResponse{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerStart(id string) error {
+	return t.run("ContainerStart", func() error {
+		return t.Driver.ContainerStart(id)
+	})
+}
+
+func (t *timeoutDriver) ContainerWait(id string, state string, timeout time.Duration, interval time.Duration) error {
+	return t.run("ContainerWait", func() error {
+		return t.Driver.ContainerWait(id, state, timeout, interval)
+	})
+}
+
+func (t *timeoutDriver) ContainerWaitWithOptions(id string, opts WaitOptions) error {
+	return t.run("ContainerWaitWithOptions", func() error {
+		return t.Driver.ContainerWaitWithOptions(id, opts)
+	})
+}
+
+func (t *timeoutDriver) ContainerList(options ContainerListOptions) ([]Container, error) {
+	var res []Container
+	err := t.run("ContainerList", func() (err error) {
+		res, err = t.Driver.ContainerList(options)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerInspect(id string) (*InspectContainerData, error) {
+	var res *InspectContainerData
+	err := t.run("ContainerInspect", func() (err error) {
+		res, err = t.Driver.ContainerInspect(id)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerImageDigest(id string) (string, error) {
+	var res string
+	err := t.run("ContainerImageDigest", func() (err error) {
+		res, err = t.Driver.ContainerImageDigest(id)
+		return err
+	})
+	if timedOut(err) {
+		return "", err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ImageUpToDate(id string, ref string) (bool, error) {
+	var res bool
+	err := t.run("ImageUpToDate", func() (err error) {
+		res, err = t.Driver.ImageUpToDate(id, ref)
+		return err
+	})
+	if timedOut(err) {
+		return false, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ResolveContainer(nameOrPrefix string) (string, error) {
+	var res string
+	err := t.run("ResolveContainer", func() (err error) {
+		res, err = t.Driver.ResolveContainer(nameOrPrefix)
+		return err
+	})
+	if timedOut(err) {
+		return "", err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerSpecOf(id string) (ContainerSpec, error) {
+	var res ContainerSpec
+	err := t.run("ContainerSpecOf", func() (err error) {
+		res, err = t.Driver.ContainerSpecOf(id)
+		return err
+	})
+	if timedOut(err) {
+		return ContainerSpec{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerMatchesSpec(id string, spec ContainerSpec) (bool, error) {
+	var res bool
+	err := t.run("ContainerMatchesSpec", func() (err error) {
+		res, err = t.Driver.ContainerMatchesSpec(id, spec)
+		return err
+	})
+	if timedOut(err) {
+		return false, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) IsManaged(id string) (bool, error) {
+	var res bool
+	err := t.run("IsManaged", func() (err error) {
+		res, err = t.Driver.IsManaged(id)
+		return err
+	})
+	if timedOut(err) {
+		return false, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerStop(id string) error {
+	return t.run("ContainerStop", func() error {
+		return t.Driver.ContainerStop(id)
+	})
+}
+
+func (t *timeoutDriver) ContainerStopWithTimeout(id string, timeout time.Duration) error {
+	return t.run("ContainerStopWithTimeout", func() error {
+		return t.Driver.ContainerStopWithTimeout(id, timeout)
+	})
+}
+
+func (t *timeoutDriver) ContainerExitCode(id string) (int, error) {
+	var res int
+	err := t.run("ContainerExitCode", func() (err error) {
+		res, err = t.Driver.ContainerExitCode(id)
+		return err
+	})
+	if timedOut(err) {
+		return 0, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerUptime(id string) (time.Duration, error) {
+	var res time.Duration
+	err := t.run("ContainerUptime", func() (err error) {
+		res, err = t.Driver.ContainerUptime(id)
+		return err
+	})
+	if timedOut(err) {
+		return 0, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerRestartCount(id string) (int, error) {
+	var res int
+	err := t.run("ContainerRestartCount", func() (err error) {
+		res, err = t.Driver.ContainerRestartCount(id)
+		return err
+	})
+	if timedOut(err) {
+		return 0, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerRemove(id string, options RemoveOptions) error {
+	return t.run("ContainerRemove", func() error {
+		return t.Driver.ContainerRemove(id, options)
+	})
+}
+
+func (t *timeoutDriver) ContainerExec(id string, cmd []string) (ExecResult, error) {
+	var res ExecResult
+	err := t.run("ContainerExec", func() (err error) {
+		res, err = t.Driver.ContainerExec(id, cmd)
+		return err
+	})
+	if timedOut(err) {
+		return ExecResult{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerExecWithOptions(id string, opts ExecOptions) (ExecResult, error) {
+	var res ExecResult
+	err := t.run("ContainerExecWithOptions", func() (err error) {
+		res, err = t.Driver.ContainerExecWithOptions(id, opts)
+		return err
+	})
+	if timedOut(err) {
+		return ExecResult{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerExecDetached(id string, opts ExecOptions) (string, error) {
+	var res string
+	err := t.run("ContainerExecDetached", func() (err error) {
+		res, err = t.Driver.ContainerExecDetached(id, opts)
+		return err
+	})
+	if timedOut(err) {
+		return "", err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ExecInspect(execID string) (ExecInspect, error) {
+	var res ExecInspect
+	err := t.run("ExecInspect", func() (err error) {
+		res, err = t.Driver.ExecInspect(execID)
+		return err
+	})
+	if timedOut(err) {
+		return ExecInspect{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerStatsSnapshot(id string) (ContainerStats, error) {
+	var res ContainerStats
+	err := t.run("ContainerStatsSnapshot", func() (err error) {
+		res, err = t.Driver.ContainerStatsSnapshot(id)
+		return err
+	})
+	if timedOut(err) {
+		return ContainerStats{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerSummary(id string) (ContainerSummary, error) {
+	var res ContainerSummary
+	err := t.run("ContainerSummary", func() (err error) {
+		res, err = t.Driver.ContainerSummary(id)
+		return err
+	})
+	if timedOut(err) {
+		return ContainerSummary{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerPorts(id string) ([]Port, error) {
+	var res []Port
+	err := t.run("ContainerPorts", func() (err error) {
+		res, err = t.Driver.ContainerPorts(id)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerNetworkConfig(id string) (NetworkConfig, error) {
+	var res NetworkConfig
+	err := t.run("ContainerNetworkConfig", func() (err error) {
+		res, err = t.Driver.ContainerNetworkConfig(id)
+		return err
+	})
+	if timedOut(err) {
+		return NetworkConfig{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerStatPath(id string, path string) (PathStat, error) {
+	var res PathStat
+	err := t.run("ContainerStatPath", func() (err error) {
+		res, err = t.Driver.ContainerStatPath(id, path)
+		return err
+	})
+	if timedOut(err) {
+		return PathStat{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainerLogPath(id string) (string, error) {
+	var res string
+	err := t.run("ContainerLogPath", func() (err error) {
+		res, err = t.Driver.ContainerLogPath(id)
+		return err
+	})
+	if timedOut(err) {
+		return "", err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) GenerateSystemdUnit(id string, opts SystemdOptions) (map[string]string, error) {
+	var res map[string]string
+	err := t.run("GenerateSystemdUnit", func() (err error) {
+		res, err = t.Driver.GenerateSystemdUnit(id, opts)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) NetworkCreate(name string, options NetworkCreateOptions) (NetworkCreateResponse, error) {
+	var res NetworkCreateResponse
+	err := t.run("NetworkCreate", func() (err error) {
+		res, err = t.Driver.NetworkCreate(name, options)
+		return err
+	})
+	if timedOut(err) {
+		return NetworkCreateResponse{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) NetworkInspect(id string) (NetworkResource, error) {
+	var res NetworkResource
+	err := t.run("NetworkInspect", func() (err error) {
+		res, err = t.Driver.NetworkInspect(id)
+		return err
+	})
+	if timedOut(err) {
+		return NetworkResource{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) NetworkList(options NetworkListOptions) ([]NetworkResource, error) {
+	var res []NetworkResource
+	err := t.run("NetworkList", func() (err error) {
+		res, err = t.Driver.NetworkList(options)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) NetworkRemove(id string, force bool) error {
+	return t.run("NetworkRemove", func() error {
+		return t.Driver.NetworkRemove(id, force)
+	})
+}
+
+func (t *timeoutDriver) NetworkConnect(id string, container string, aliases []string) (EndpointResource, error) {
+	var res EndpointResource
+	err := t.run("NetworkConnect", func() (err error) {
+		res, err = t.Driver.NetworkConnect(id, container, aliases)
+		return err
+	})
+	if timedOut(err) {
+		return EndpointResource{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) NetworkDisconnect(id string, container string, force bool) error {
+	return t.run("NetworkDisconnect", func() error {
+		return t.Driver.NetworkDisconnect(id, container, force)
+	})
+}
+
+func (t *timeoutDriver) ContainerConnectNetworks(id string, endpoints map[string]EndpointConfig, opts ConnectNetworksOptions) error {
+	return t.run("ContainerConnectNetworks", func() error {
+		return t.Driver.ContainerConnectNetworks(id, endpoints, opts)
+	})
+}
+
+func (t *timeoutDriver) NetworkGateway(id string) (string, error) {
+	var res string
+	err := t.run("NetworkGateway", func() (err error) {
+		res, err = t.Driver.NetworkGateway(id)
+		return err
+	})
+	if timedOut(err) {
+		return "", err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ContainersPrune(filters Filters) (PruneReport, error) {
+	var res PruneReport
+	err := t.run("ContainersPrune", func() (err error) {
+		res, err = t.Driver.ContainersPrune(filters)
+		return err
+	})
+	if timedOut(err) {
+		return PruneReport{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) ImagesPrune(filters Filters) (PruneReport, error) {
+	var res PruneReport
+	err := t.run("ImagesPrune", func() (err error) {
+		res, err = t.Driver.ImagesPrune(filters)
+		return err
+	})
+	if timedOut(err) {
+		return PruneReport{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) NetworksPrune(filters Filters) (PruneReport, error) {
+	var res PruneReport
+	err := t.run("NetworksPrune", func() (err error) {
+		res, err = t.Driver.NetworksPrune(filters)
+		return err
+	})
+	if timedOut(err) {
+		return PruneReport{}, err
+	}
+	return res, err
+}
+
+func (t *timeoutDriver) VolumesPrune(filters Filters) (PruneReport, error) {
+	var res PruneReport
+	err := t.run("VolumesPrune", func() (err error) {
+		res, err = t.Driver.VolumesPrune(filters)
+		return err
+	})
+	if timedOut(err) {
+		return PruneReport{}, err
+	}
+	return res, err
+}
@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowDriver takes delay to answer inspects and pulls.
+type slowDriver struct {
+	*mockDriver
+	delay time.Duration
+}
+
+func (d *slowDriver) ContainerInspect(id string) (*InspectContainerData, error) {
+	time.Sleep(d.delay)
+	return d.mockDriver.ContainerInspect(id)
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.pull = func(ref string, options ImagePullOptions) error {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		case <-options.Context.Done():
+			return options.Context.Err()
+		}
+	}
+	d := TimeoutMiddleware(map[string]time.Duration{
+		"ContainerInspect": 20 * time.Millisecond,
+		"ImagesPull":       5 * time.Second,
+		DefaultOperation:   time.Second,
+	})(&slowDriver{mockDriver: m, delay: 100 * time.Millisecond})
+
+	start := time.Now()
+	_, err = d.ContainerInspect(id)
+	var timeoutErr *OperationTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Method != "ContainerInspect" {
+		t.Errorf("Expected the inspect to time out, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v to match context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("Expected the inspect to give up after its timeout, took %v", elapsed)
+	}
+
+	if _, err := d.ImagesPull("quay.io/skupper/router:1.0", ImagePullOptions{}); err != nil {
+		t.Errorf("Expected the pull to complete within its timeout, got %v", err)
+	}
+}
+
+func TestTimeoutMiddlewareCancelsPull(t *testing.T) {
+	m := newMockDriver()
+	m.pull = func(ref string, options ImagePullOptions) error {
+		<-options.Context.Done()
+		return options.Context.Err()
+	}
+	d := TimeoutMiddleware(map[string]time.Duration{"ImagesPull": 20 * time.Millisecond})(m)
+	_, err := d.ImagesPull("quay.io/skupper/router:1.0", ImagePullOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the pull to be cancelled at its timeout, got %v", err)
+	}
+}
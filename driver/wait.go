@@ -0,0 +1,365 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WaitForImage polls d.ImageExists every interval, or every
+// DefaultReadinessInterval when interval is not positive, until ref is
+// present locally or ctx is done.
+func WaitForImage(ctx context.Context, d Driver, ref string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultReadinessInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		exists, err := d.ImageExists(ref)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForPort polls every DefaultReadinessInterval until a socket in the
+// container is listening on port, or ctx is done. proto is "tcp" (the
+// default) or "udp". The check reads the kernel's socket tables with cat,
+// so it works in any image that ships a shell toolbox. A failed exec, e.g.
+// in a container still starting, is retried; the last failure is reported
+// with ctx's error.
+func WaitForPort(ctx context.Context, d Driver, id string, port int, proto string) error {
+	if proto == "" {
+		proto = "tcp"
+	}
+	if proto != "tcp" && proto != "udp" {
+		return fmt.Errorf("Invalid proto: %s", proto)
+	}
+	// cat exits non-zero when the kernel has no IPv6 table but still
+	// prints the IPv4 one, so only the output matters
+	cmd := []string{"cat", "/proc/net/" + proto, "/proc/net/" + proto + "6"}
+	ticker := time.NewTicker(DefaultReadinessInterval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		res, err := d.ContainerExec(id, cmd)
+		if err == nil && isListening(res.Stdout(), port, proto) {
+			return nil
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("Port %d/%s in container %s: %w (last exec error: %v)", port, proto, id, ctx.Err(), lastErr)
+			}
+			return fmt.Errorf("Port %d/%s in container %s: %w", port, proto, id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// isListening scans /proc/net/{tcp,udp}[6] content for a socket bound to
+// port, in the listen state for tcp and the unconnected state for udp.
+func isListening(table string, port int, proto string) bool {
+	state := "0A" // TCP_LISTEN
+	if proto == "udp" {
+		state = "07" // TCP_CLOSE, i.e. bound but unconnected
+	}
+	for _, line := range strings.Split(table, "\n") {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != state {
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i == -1 {
+			continue
+		}
+		p, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+		if err == nil && int(p) == port {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// DefaultReadinessInterval is how often StartAndWaitReady polls the
+	// container state.
+	DefaultReadinessInterval = time.Second
+	// DefaultWaitMaxInterval caps the poll interval grown by a
+	// WaitOptions Backoff.
+	DefaultWaitMaxInterval = 10 * time.Second
+	// DefaultProbeRetries is how often a readiness probe is attempted
+	// when it does not say.
+	DefaultProbeRetries = 3
+)
+
+// NotReadyError is returned by StartAndWaitReady when the container did not
+// become ready. State is the last state observed, if any, and Probe the
+// error of the last readiness probe attempt.
+type NotReadyError struct {
+	ID    string
+	State *ContainerState
+	Probe error
+}
+
+func (e *NotReadyError) Error() string {
+	if e.Probe != nil {
+		return fmt.Sprintf("container %s not ready: readiness probe failed: %v", e.ID, e.Probe)
+	}
+	if e.State == nil {
+		return fmt.Sprintf("container %s not ready: state unknown", e.ID)
+	}
+	msg := fmt.Sprintf("container %s not ready: status %s", e.ID, e.State.Status)
+	if h := e.State.Health; h != nil {
+		msg += fmt.Sprintf(", health %s", h.Status)
+		if len(h.Log) > 0 {
+			last := h.Log[len(h.Log)-1]
+			msg += fmt.Sprintf(", last probe exited %d: %s", last.ExitCode, strings.TrimSpace(last.Output))
+		}
+	}
+	return msg
+}
+
+// isReady reports whether a container is running and, when it has a
+// healthcheck, healthy.
+func isReady(state *ContainerState) bool {
+	if !state.Running {
+		return false
+	}
+	return state.Health == nil || state.Health.Status == HealthHealthy
+}
+
+// runProbe execs probe in container id until it exits zero or its retries
+// are exhausted, returning the last attempt's failure. Attempts are not
+// started, nor allowed to run, past deadline.
+func runProbe(d Driver, id string, probe *Probe, deadline time.Time) error {
+	retries := probe.Retries
+	if retries == 0 {
+		retries = DefaultProbeRetries
+	}
+	interval := probe.Interval
+	if interval == 0 {
+		interval = DefaultReadinessInterval
+	}
+	var err error
+	for attempt := 0; attempt < retries; attempt++ {
+		if attempt > 0 {
+			if time.Until(deadline) <= interval {
+				break
+			}
+			time.Sleep(interval)
+		}
+		if err = probeOnce(d, id, probe, deadline); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// probeOnce runs a single attempt of probe, bounded by its Timeout and by
+// deadline. The exec is given that bound as its ExecOptions.Timeout, so the
+// backend kills a hung probe rather than leaving it running.
+func probeOnce(d Driver, id string, probe *Probe, deadline time.Time) error {
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return fmt.Errorf("%s not run: %w", strings.Join(probe.Exec, " "), context.DeadlineExceeded)
+	}
+	if probe.Timeout > 0 && probe.Timeout < timeout {
+		timeout = probe.Timeout
+	}
+	res, err := d.ContainerExecWithOptions(id, ExecOptions{Cmd: probe.Exec, Timeout: timeout})
+	var timeoutErr *ExecTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return fmt.Errorf("%s timed out after %v: %w", strings.Join(probe.Exec, " "), timeout, err)
+	}
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("%s exited %d: %s", strings.Join(probe.Exec, " "), res.ExitCode, strings.TrimSpace(res.Combined()))
+	}
+	return nil
+}
+
+// StartAndWaitReady starts the container and blocks until it is running,
+// or healthy when it has a healthcheck, and then until its readiness probe
+// passes when it was created with one. It fails early with a NotReadyError
+// if the container exits, and with one carrying the last known state and
+// health log once timeout elapses. The probe's attempts count against
+// timeout too.
+func StartAndWaitReady(d Driver, id string, timeout time.Duration) error {
+	if err := d.ContainerStart(id); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	var last *ContainerState
+	for {
+		icd, err := d.ContainerInspect(id)
+		if err == nil && icd.State != nil {
+			last = icd.State
+			if isReady(last) {
+				data, ok := icd.Labels[ReadinessProbeLabel]
+				if !ok {
+					return nil
+				}
+				var probe Probe
+				if err := json.Unmarshal([]byte(data), &probe); err != nil {
+					return fmt.Errorf("Couldn't decode readiness probe of container %s: %w", id, err)
+				}
+				if err := runProbe(d, id, &probe, deadline); err != nil {
+					return &NotReadyError{ID: id, State: last, Probe: err}
+				}
+				return nil
+			}
+			if last.Status == "exited" || last.Dead {
+				return &NotReadyError{ID: id, State: last}
+			}
+		}
+		if time.Now().After(deadline) {
+			return &NotReadyError{ID: id, State: last}
+		}
+		time.Sleep(DefaultReadinessInterval)
+	}
+}
+
+// WaitForLogPattern follows the container's logs until a line matches
+// pattern and returns that line. It fails with ctx's error once ctx is
+// done, or when the log ends, e.g. because the container exited, without
+// a match. The log stream is closed before it returns.
+func WaitForLogPattern(ctx context.Context, d Driver, id string, pattern *regexp.Regexp) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	logs, err := d.ContainerLogs(ctx, id, LogOptions{Follow: true})
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(logs)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+	for {
+		select {
+		case line := <-lines:
+			if pattern.MatchString(line) {
+				return line, nil
+			}
+		case err := <-scanErr:
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			if err != nil {
+				return "", fmt.Errorf("Couldn't read logs of container %s: %w", id, err)
+			}
+			return "", fmt.Errorf("Logs of container %s ended without matching %s", id, pattern)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// WaitOptions configures ContainerWaitWithOptions.
+type WaitOptions struct {
+	// State is the container status waited for, e.g. "running".
+	State string
+	// Timeout bounds the wait; zero waits until the driver is closed.
+	Timeout time.Duration
+	// Interval is the first poll interval; zero uses
+	// DefaultReadinessInterval.
+	Interval time.Duration
+	// Backoff multiplies the interval after every poll; values up to 1
+	// poll at a fixed Interval.
+	Backoff float64
+	// MaxInterval caps the interval grown by Backoff; zero uses
+	// DefaultWaitMaxInterval.
+	MaxInterval time.Duration
+}
+
+// NextInterval returns the poll interval that follows interval.
+func (opts WaitOptions) NextInterval(interval time.Duration) time.Duration {
+	if opts.Backoff <= 1 {
+		return interval
+	}
+	max := opts.MaxInterval
+	if max <= 0 {
+		max = DefaultWaitMaxInterval
+	}
+	next := time.Duration(float64(interval) * opts.Backoff)
+	// a negative result means the multiplication overflowed
+	if next > max || next < 0 {
+		return max
+	}
+	return next
+}
+
+// PollWithBackoff calls fn until it reports done or fails, or ctx is done,
+// sleeping between calls for opts' Interval grown by its Backoff.
+func PollWithBackoff(ctx context.Context, opts WaitOptions, fn func() (bool, error)) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultReadinessInterval
+	}
+	for {
+		done, err := fn()
+		if err != nil || done {
+			return err
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		interval = opts.NextInterval(interval)
+	}
+}
+
+// WaitForState polls the container until its status is opts.State, ctx is
+// done or opts.Timeout passes. Failed inspects are polled again, as the
+// container may not exist yet.
+func WaitForState(ctx context.Context, d Driver, id string, opts WaitOptions) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	err := PollWithBackoff(ctx, opts, func() (bool, error) {
+		icd, err := d.ContainerInspect(id)
+		if err != nil || icd.State == nil {
+			return false, nil
+		}
+		return icd.State.Status == opts.State, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Container %s did not reach state %s: %w", id, opts.State, err)
+	}
+	return nil
+}
@@ -0,0 +1,399 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pollingDriver makes ref appear on the third ImageExists poll.
+type pollingDriver struct {
+	*mockDriver
+	ref   string
+	polls int
+}
+
+func (p *pollingDriver) ImageExists(ref string) (bool, error) {
+	p.polls++
+	if p.polls == 3 {
+		p.addImage(p.ref, ImageInspect{})
+	}
+	return p.mockDriver.ImageExists(ref)
+}
+
+func TestWaitForImageAppearsAfterPolls(t *testing.T) {
+	ref := "quay.io/skupper/router:1.0"
+	p := &pollingDriver{mockDriver: newMockDriver(), ref: ref}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WaitForImage(ctx, p, ref, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if p.polls != 3 {
+		t.Errorf("Expected 3 polls, got %d", p.polls)
+	}
+}
+
+func TestWaitForImageDefaultsInterval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := WaitForImage(ctx, newMockDriver(), "quay.io/skupper/router:1.0", 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+}
+
+func TestStartAndWaitReadyWithoutHealthcheck(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:latest", ImageInspect{})
+	res, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: "quay.io/skupper/router:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StartAndWaitReady(m, res.ID, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ContainerInspect"); n != 1 {
+		t.Errorf("Expected a running container to be ready at once, got %d inspects", n)
+	}
+}
+
+func TestStartAndWaitReadyWaitsForHealthy(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:latest", ImageInspect{})
+	res, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: "quay.io/skupper/router:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.update(res.ID, func(c *mockContainer) {
+		c.icd.State.Health = &Health{Status: HealthStarting}
+	})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.update(res.ID, func(c *mockContainer) {
+			c.icd.State.Health = &Health{Status: HealthHealthy}
+		})
+	}()
+	if err := StartAndWaitReady(m, res.ID, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.called("ContainerInspect"); n < 2 {
+		t.Errorf("Expected to poll until healthy, got %d inspects", n)
+	}
+}
+
+func TestStartAndWaitReadyTimeoutReportsHealth(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:latest", ImageInspect{})
+	res, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: "quay.io/skupper/router:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.update(res.ID, func(c *mockContainer) {
+		c.icd.State.Health = &Health{
+			Status:        HealthUnhealthy,
+			FailingStreak: 1,
+			Log:           []HealthLog{{ExitCode: 1, Output: "connection refused"}},
+		}
+	})
+	err = StartAndWaitReady(m, res.ID, 0)
+	var notReady *NotReadyError
+	if !errors.As(err, &notReady) {
+		t.Fatalf("Expected a NotReadyError, got %v", err)
+	}
+	if notReady.State == nil || notReady.State.Health.Status != HealthUnhealthy {
+		t.Errorf("Expected the last state to be unhealthy, got %+v", notReady.State)
+	}
+	if !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the health log in the error, got %q", err)
+	}
+}
+
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1627 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:A1B2 01 00000000:00000000 00:00000000 00000000     0        0 12346 1 0000000000000000 20 4 30 10 -1
+`
+
+func TestIsListening(t *testing.T) {
+	for _, tc := range []struct {
+		port  int
+		proto string
+		want  bool
+	}{
+		{5671, "tcp", true},
+		{8080, "tcp", false}, // established, not listening
+		{5672, "tcp", false},
+		{5671, "udp", false},
+	} {
+		if got := isListening(procNetTCP, tc.port, tc.proto); got != tc.want {
+			t.Errorf("Expected %s port %d listening=%v, got %v", tc.proto, tc.port, tc.want, got)
+		}
+	}
+}
+
+func TestWaitForPort(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	polls := 0
+	m.exec = func(id string, opts ExecOptions) (ExecResult, error) {
+		polls++
+		out := &bytes.Buffer{}
+		if polls > 1 {
+			out.WriteString(procNetTCP)
+		}
+		return ExecResult{OutBuffer: out}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := WaitForPort(ctx, m, id, 5671, "tcp"); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 {
+		t.Errorf("Expected the port to open on the second poll, got %d polls", polls)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := WaitForPort(ctx, m, id, 5672, "tcp"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error for a closed port, got %v", err)
+	}
+}
+
+func TestWaitForPortRetriesFailedExecs(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := errors.New("container is not running")
+	polls := 0
+	m.exec = func(id string, opts ExecOptions) (ExecResult, error) {
+		polls++
+		if polls == 1 {
+			return ExecResult{}, refused
+		}
+		return ExecResult{OutBuffer: bytes.NewBufferString(procNetTCP)}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := WaitForPort(ctx, m, id, 5671, "tcp"); err != nil {
+		t.Fatalf("Expected the wait to outlast a refused exec, got %v", err)
+	}
+	if polls != 2 {
+		t.Errorf("Expected the port to open on the second poll, got %d polls", polls)
+	}
+
+	m.exec = func(id string, opts ExecOptions) (ExecResult, error) {
+		return ExecResult{}, refused
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = WaitForPort(ctx, m, id, 5671, "tcp")
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), refused.Error()) {
+		t.Errorf("Expected a deadline error carrying the last exec error, got %v", err)
+	}
+}
+
+func TestStartAndWaitReadyRunsProbe(t *testing.T) {
+	for _, tc := range []struct {
+		retries int
+		ready   bool
+	}{
+		{3, true},
+		{2, false},
+	} {
+		m := newMockDriver()
+		m.addImage("quay.io/skupper/router:latest", ImageInspect{})
+		probe := &Probe{Exec: []string{"qdstat", "-g"}, Interval: time.Millisecond, Retries: tc.retries}
+		res, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: "quay.io/skupper/router:latest", ReadinessProbe: probe})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempts := 0
+		m.exec = func(id string, opts ExecOptions) (ExecResult, error) {
+			attempts++
+			if attempts < 3 {
+				return ExecResult{ExitCode: 1, ErrBuffer: bytes.NewBufferString("connection refused")}, nil
+			}
+			return ExecResult{}, nil
+		}
+		err = StartAndWaitReady(m, res.ID, time.Second)
+		if tc.ready && err != nil {
+			t.Errorf("Expected the probe to pass on the third attempt, got %v", err)
+		}
+		var notReady *NotReadyError
+		if !tc.ready && (!errors.As(err, &notReady) || notReady.Probe == nil) {
+			t.Errorf("Expected a probe failure after %d attempts, got %v", tc.retries, err)
+		}
+		if attempts != tc.retries {
+			t.Errorf("Expected %d attempts, got %d", tc.retries, attempts)
+		}
+	}
+}
+
+func TestStartAndWaitReadyBoundsProbe(t *testing.T) {
+	m := newMockDriver()
+	m.addImage("quay.io/skupper/router:latest", ImageInspect{})
+	probe := &Probe{Exec: []string{"qdstat", "-g"}, Interval: 20 * time.Millisecond, Timeout: 50 * time.Millisecond, Retries: 1000}
+	res, err := m.ContainerCreate(ContainerSpec{Name: "router", Image: "quay.io/skupper/router:latest", ReadinessProbe: probe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock sync.Mutex
+	var timeouts []time.Duration
+	m.exec = func(id string, opts ExecOptions) (ExecResult, error) {
+		lock.Lock()
+		timeouts = append(timeouts, opts.Timeout)
+		lock.Unlock()
+		// a hung probe, killed by the backend once opts.Timeout elapses
+		time.Sleep(opts.Timeout)
+		return ExecResult{}, &ExecTimeoutError{ContainerID: id, Timeout: opts.Timeout}
+	}
+	start := time.Now()
+	err = StartAndWaitReady(m, res.ID, 300*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the probe to stop at the timeout, took %v", elapsed)
+	}
+	var notReady *NotReadyError
+	if !errors.As(err, &notReady) || !errors.Is(notReady.Probe, context.DeadlineExceeded) {
+		t.Errorf("Expected a probe timeout, got %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(timeouts) == 0 {
+		t.Fatal("Expected the probe to run")
+	}
+	for _, timeout := range timeouts {
+		if timeout <= 0 || timeout > probe.Timeout {
+			t.Errorf("Expected each attempt to be bounded by the probe timeout, got %v", timeout)
+		}
+	}
+}
+
+// closedLogs records whether the log streams it hands out were closed.
+type closedLogs struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (l *closedLogs) Close() error {
+	l.closed = true
+	return l.ReadCloser.Close()
+}
+
+type logsDriver struct {
+	*mockDriver
+	logs *closedLogs
+}
+
+func (d *logsDriver) ContainerLogs(ctx context.Context, id string, options LogOptions) (io.ReadCloser, error) {
+	rc, err := d.mockDriver.ContainerLogs(ctx, id, options)
+	if err != nil {
+		return nil, err
+	}
+	d.logs = &closedLogs{ReadCloser: rc}
+	return d.logs, nil
+}
+
+func TestWaitForLogPattern(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.containers[id].logs = "starting\nRouter started in 12ms\nlistening\n"
+	d := &logsDriver{mockDriver: m}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	line, err := WaitForLogPattern(ctx, d, id, regexp.MustCompile(`Router started`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "Router started in 12ms" {
+		t.Errorf("Expected the matching line, got %q", line)
+	}
+	if !d.logs.closed {
+		t.Errorf("Expected the log stream to be closed")
+	}
+
+	_, err = WaitForLogPattern(ctx, d, id, regexp.MustCompile(`Router stopped`))
+	if err == nil || !strings.Contains(err.Error(), "ended without matching") {
+		t.Errorf("Expected the logs to end without a match, got %v", err)
+	}
+	if !d.logs.closed {
+		t.Errorf("Expected the log stream to be closed")
+	}
+}
+
+func TestWaitOptionsNextInterval(t *testing.T) {
+	opts := WaitOptions{Backoff: 2, MaxInterval: 300 * time.Millisecond}
+	interval := 50 * time.Millisecond
+	var intervals []time.Duration
+	for i := 0; i < 5; i++ {
+		intervals = append(intervals, interval)
+		interval = opts.NextInterval(interval)
+	}
+	expected := []time.Duration{50, 100, 200, 300, 300}
+	for i := range expected {
+		expected[i] *= time.Millisecond
+	}
+	if !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Expected intervals %v, got %v", expected, intervals)
+	}
+	if next := (WaitOptions{}).NextInterval(interval); next != interval {
+		t.Errorf("Expected a fixed interval without backoff, got %v", next)
+	}
+}
+
+func TestPollWithBackoffGrowsInterval(t *testing.T) {
+	var polls []time.Time
+	err := PollWithBackoff(context.Background(), WaitOptions{Interval: 10 * time.Millisecond, Backoff: 3}, func() (bool, error) {
+		polls = append(polls, time.Now())
+		return len(polls) == 4, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gaps []time.Duration
+	for i := 1; i < len(polls); i++ {
+		gaps = append(gaps, polls[i].Sub(polls[i-1]))
+	}
+	// 10ms, 30ms and 90ms apart
+	for i := 1; i < len(gaps); i++ {
+		if gaps[i] <= gaps[i-1] {
+			t.Errorf("Expected the poll interval to grow, got %v", gaps)
+			break
+		}
+	}
+}
+
+func TestWaitForState(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.exit(id, 0)
+	}()
+	opts := WaitOptions{State: "exited", Timeout: 5 * time.Second, Interval: 5 * time.Millisecond, Backoff: 2}
+	if err := WaitForState(context.Background(), m, id, opts); err != nil {
+		t.Fatal(err)
+	}
+	opts = WaitOptions{State: "running", Timeout: 50 * time.Millisecond, Interval: 5 * time.Millisecond}
+	if err := WaitForState(context.Background(), m, id, opts); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to time out, got %v", err)
+	}
+}
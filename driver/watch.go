@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Path event operations reported by WatchPath.
+const (
+	PathCreated  = "created"
+	PathModified = "modified"
+	PathRemoved  = "removed"
+)
+
+// PathEvent is a change to a watched path in a container. Stat is the
+// path's new state, zero for PathRemoved.
+type PathEvent struct {
+	Path string
+	Op   string
+	Stat PathStat
+	Time time.Time
+}
+
+// WatchPath polls the path in the container every interval, or every
+// DefaultReadinessInterval when interval is not positive, and sends an
+// event whenever it appears, disappears or changes size, mode or mtime.
+// Stat failures other than the path not existing are skipped, e.g. while
+// the container restarts. The channel is closed once ctx is done.
+func WatchPath(ctx context.Context, d Driver, id string, path string, interval time.Duration) (<-chan PathEvent, error) {
+	if interval <= 0 {
+		interval = DefaultReadinessInterval
+	}
+	last, exists, err := statPath(d, id, path)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan PathEvent)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			stat, found, err := statPath(d, id, path)
+			if err != nil {
+				continue
+			}
+			ev := PathEvent{Path: path, Stat: stat, Time: time.Now()}
+			switch {
+			case found && !exists:
+				ev.Op = PathCreated
+			case !found && exists:
+				ev.Op = PathRemoved
+			case found && (stat.Size != last.Size || stat.Mode != last.Mode || !stat.Mtime.Equal(last.Mtime)):
+				ev.Op = PathModified
+			default:
+				continue
+			}
+			last, exists = stat, found
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// statPath stats the path and reports whether it exists.
+func statPath(d Driver, id string, path string) (PathStat, bool, error) {
+	stat, err := d.ContainerStatPath(id, path)
+	var notFound *PathNotFoundError
+	if errors.As(err, &notFound) {
+		return PathStat{}, false, nil
+	}
+	if err != nil {
+		return PathStat{}, false, err
+	}
+	return stat, true, nil
+}
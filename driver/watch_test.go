@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event, failing the test if none arrives soon.
+func nextEvent(t *testing.T, events <-chan PathEvent) PathEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("Expected an event, the channel was closed")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an event, got none")
+	}
+	return PathEvent{}
+}
+
+func TestWatchPath(t *testing.T) {
+	m := newMockDriver()
+	id, err := m.runContainer(ContainerSpec{Name: "router"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/etc/skupper-router/skrouterd.json"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := WatchPath(ctx, m, id, path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.writeFile(id, path, 100)
+	if ev := nextEvent(t, events); ev.Op != PathCreated || ev.Stat.Size != 100 {
+		t.Errorf("Expected the file to be created with 100 bytes, got %+v", ev)
+	}
+	m.writeFile(id, path, 120)
+	if ev := nextEvent(t, events); ev.Op != PathModified || ev.Stat.Size != 120 {
+		t.Errorf("Expected the file to be modified to 120 bytes, got %+v", ev)
+	}
+	m.update(id, func(c *mockContainer) { delete(c.files, path) })
+	if ev := nextEvent(t, events); ev.Op != PathRemoved || ev.Path != path {
+		t.Errorf("Expected the file to be removed, got %+v", ev)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchPathMissingContainer(t *testing.T) {
+	m := newMockDriver()
+	if _, err := WatchPath(context.Background(), m, "missing", "/etc/hosts", time.Second); err == nil {
+		t.Errorf("Expected watching a missing container to fail")
+	}
+}
@@ -5,5 +5,12 @@ go 1.14
 require (
 	github.com/containers/podman/v2 v2.2.1
 	github.com/docker/docker v17.12.0-ce-rc1.0.20201020191947-73dc6a680cdd+incompatible
-	github.com/skupperproject/skupper v0.0.0-20201230152546-bc753101fa58
+	github.com/docker/go-connections v0.4.0
+	github.com/docker/go-units v0.4.0
+	github.com/opencontainers/image-spec v1.0.2-0.20190823105129-775207bd45b6
+	github.com/opencontainers/runtime-spec v1.0.3-0.20200817204227-f9c09b4ea1df
+	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b
+	google.golang.org/grpc v1.29.1
+	k8s.io/client-go v0.17.0 // indirect
+	k8s.io/cri-api v0.20.1
 )
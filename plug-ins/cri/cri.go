@@ -0,0 +1,1255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/ajssmith/ce-drivers/driver"
+)
+
+const (
+	defaultEndpoint = "unix:///run/containerd/containerd.sock"
+	// sandboxNamespace is the pod namespace of the sandboxes created for
+	// containers, as shown by crictl pods.
+	sandboxNamespace = "skupper"
+	// defaultStopTimeout is the grace period in seconds before a stopped
+	// container is killed.
+	defaultStopTimeout = 10
+)
+
+// criClient drives a container runtime through the kubelet's CRI API. CRI
+// runs every container in a pod sandbox, so each container created here
+// gets a sandbox of its own, which is removed along with it. Networking is
+// owned by the sandbox's CNI configuration, so the network calls are not
+// supported.
+type criClient struct {
+	// ctx derives from the caller supplied context and is cancelled by
+	// Close; all operations derive from it
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	pulls   driver.Semaphore
+
+	// reconnectLock serializes Reconnect
+	reconnectLock sync.Mutex
+	// connLock guards conn, which Reconnect replaces; use acquire
+	connLock sync.RWMutex
+	conn     *connRef
+}
+
+// connRef counts the calls using a connection, so that Reconnect can
+// close it once they have finished.
+type connRef struct {
+	conn     *grpc.ClientConn
+	runtime  runtimeapi.RuntimeServiceClient
+	images   runtimeapi.ImageServiceClient
+	endpoint string
+	users    sync.WaitGroup
+}
+
+func newConnRef(conn *grpc.ClientConn, endpoint string) *connRef {
+	return &connRef{
+		conn:     conn,
+		runtime:  runtimeapi.NewRuntimeServiceClient(conn),
+		images:   runtimeapi.NewImageServiceClient(conn),
+		endpoint: endpoint,
+	}
+}
+
+var Driver criClient
+
+// NewDriver returns a driver of its own, for callers such as driver.Pool
+// that connect to several runtimes. Driver is the shared one.
+func NewDriver() driver.Driver {
+	return &criClient{}
+}
+
+func getTimeoutContext(d *criClient) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(d.ctx, d.timeout)
+}
+
+// dial connects to the CRI socket at endpoint, e.g.
+// "unix:///run/containerd/containerd.sock" or "unix:///run/crio/crio.sock".
+func dial(ctx context.Context, endpoint string) (*grpc.ClientConn, error) {
+	if !strings.HasPrefix(endpoint, "unix://") {
+		return nil, fmt.Errorf("Unsupported CRI endpoint %s, only unix sockets are supported", endpoint)
+	}
+	socket := strings.TrimPrefix(endpoint, "unix://")
+	dctx, cancel := context.WithTimeout(ctx, driver.DefaultTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dctx, socket,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't connect to CRI runtime at %s: %w", endpoint, err)
+	}
+	return conn, nil
+}
+
+// New connects to the CRI runtime. Cancelling ctx closes the connection.
+func (c *criClient) New(ctx context.Context, options driver.ConnectOptions) (err error) {
+	fmt.Println("Inside cri plugin new")
+	defer c.wrapErr(&err, "New", "")
+	if options.APIVersion != "" {
+		return fmt.Errorf("API version pinning: %w by the cri driver", driver.ErrNotSupported)
+	}
+	if options.AutoAttachNetworks {
+		return fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+	}
+	endpoint := defaultEndpoint
+	if options.Host != "" {
+		endpoint = options.Host
+	}
+	conn, err := dial(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.connLock.Lock()
+	c.conn = newConnRef(conn, endpoint)
+	c.connLock.Unlock()
+	c.timeout = driver.DefaultTimeout
+	maxPulls := options.MaxConcurrentPulls
+	if maxPulls <= 0 {
+		maxPulls = driver.DefaultMaxConcurrentPulls
+	}
+	c.pulls = driver.NewSemaphore(maxPulls)
+
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		// a Reconnect in progress swaps in its connection first
+		c.reconnectLock.Lock()
+		defer c.reconnectLock.Unlock()
+		c.connLock.RLock()
+		current := c.conn
+		c.connLock.RUnlock()
+		current.conn.Close()
+	}(c.ctx)
+	return nil
+}
+
+// Close cancels the driver's context, failing any operation in progress,
+// which closes the connection.
+func (c *criClient) Close() (err error) {
+	fmt.Println("Inside cri plugin close")
+	defer c.wrapErr(&err, "Close", "")
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// Reconnect replaces the connection with one to the endpoint in options.
+// The grpc connection already re-dials a dropped socket on its own. The
+// replaced connection is closed once the calls using it have finished.
+func (c *criClient) Reconnect(options driver.ConnectOptions) (err error) {
+	fmt.Println("Inside cri plugin reconnect")
+	defer c.wrapErr(&err, "Reconnect", "")
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+	c.connLock.RLock()
+	connected := c.conn != nil
+	c.connLock.RUnlock()
+	if !connected {
+		return fmt.Errorf("Driver is not connected, call New first")
+	}
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	endpoint := defaultEndpoint
+	if options.Host != "" {
+		endpoint = options.Host
+	}
+	conn, err := dial(c.ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	c.connLock.Lock()
+	old := c.conn
+	c.conn = newConnRef(conn, endpoint)
+	c.connLock.Unlock()
+	go func() {
+		old.users.Wait()
+		old.conn.Close()
+	}()
+	return nil
+}
+
+// acquire returns the current connection and a function to call once done
+// with it, which Reconnect waits for before closing a replaced connection.
+// Calling release more than once is harmless. It fails when New has not
+// connected the driver yet.
+func (c *criClient) acquire() (*connRef, func(), error) {
+	c.connLock.RLock()
+	defer c.connLock.RUnlock()
+	ref := c.conn
+	if ref == nil {
+		return nil, nil, fmt.Errorf("CRI driver is not connected")
+	}
+	ref.users.Add(1)
+	var once sync.Once
+	return ref, func() { once.Do(ref.users.Done) }, nil
+}
+
+// wrapErr names the failed operation and its resource in *err. The
+// runtime reports a missing resource with the NotFound status code, and
+// lookups that come back empty return one too.
+func (c *criClient) wrapErr(err *error, op string, resource string) {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	notFound := errors.As(*err, &grpcErr) && grpcErr.GRPCStatus().Code() == codes.NotFound
+	*err = driver.WrapOperationError("cri", op, resource, *err, notFound)
+}
+
+func (c *criClient) imageStatus(ref string) (*runtimeapi.Image, error) {
+	conn, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := conn.images.ImageStatus(ctx, &runtimeapi.ImageStatusRequest{
+		Image: &runtimeapi.ImageSpec{Image: ref},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Image, nil
+}
+
+// SupportsFeature reports no swarm secrets or BuildKit, which CRI
+// runtimes do not offer. CRI does not report how the runtime is set up, so
+// the other features cannot be probed.
+func (c *criClient) SupportsFeature(feature driver.Feature) (_ bool, err error) {
+	fmt.Println("Inside cri supports feature: ", feature)
+	defer c.wrapErr(&err, "SupportsFeature", "")
+	switch feature {
+	case driver.SwarmSecrets, driver.BuildKit:
+		return false, nil
+	case driver.CgroupV2, driver.RootlessNetworking:
+		return false, fmt.Errorf("Feature probe %s: %w by the cri driver", feature, driver.ErrNotSupported)
+	}
+	return false, fmt.Errorf("Unknown feature %s", feature)
+}
+
+func (c *criClient) ImageInspect(id string, options driver.ImageInspectOptions) (_ *driver.ImageInspect, err error) {
+	fmt.Println("In cri inspect image")
+	defer c.wrapErr(&err, "ImageInspect", id)
+	if options.Platform != "" {
+		return nil, fmt.Errorf("Image platforms: %w by the cri driver", driver.ErrNotSupported)
+	}
+	image, err := c.imageStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, status.Errorf(codes.NotFound, "Image %s not found", id)
+	}
+	return &driver.ImageInspect{
+		ID:          image.Id,
+		RepoTags:    image.RepoTags,
+		RepoDigests: image.RepoDigests,
+		Size:        int64(image.Size_),
+	}, nil
+}
+
+func (c *criClient) ImagesList(options driver.ImageListOptions) (_ []driver.ImageSummary, err error) {
+	fmt.Println("In cri list images")
+	defer c.wrapErr(&err, "ImagesList", "")
+	conn, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := conn.images.ListImages(ctx, &runtimeapi.ListImagesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var summary []driver.ImageSummary
+	for _, image := range resp.Images {
+		summary = append(summary, driver.ImageSummary{
+			ID:          image.Id,
+			RepoTags:    image.RepoTags,
+			RepoDigests: image.RepoDigests,
+			Size:        int64(image.Size_),
+		})
+	}
+	return summary, nil
+}
+
+// ImagesDiskUsage takes TotalSize from the image filesystem's usage. CRI
+// does not report which layers images share, so each image's SharedSize
+// and ExclusiveSize are left zero.
+func (c *criClient) ImagesDiskUsage() (_ driver.ImagesDiskReport, err error) {
+	fmt.Println("In cri images disk usage")
+	defer c.wrapErr(&err, "ImagesDiskUsage", "")
+	conn, release, err := c.acquire()
+	if err != nil {
+		return driver.ImagesDiskReport{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	fs, err := conn.images.ImageFsInfo(ctx, &runtimeapi.ImageFsInfoRequest{})
+	if err != nil {
+		return driver.ImagesDiskReport{}, err
+	}
+	list, err := conn.images.ListImages(ctx, &runtimeapi.ListImagesRequest{})
+	if err != nil {
+		return driver.ImagesDiskReport{}, err
+	}
+	var report driver.ImagesDiskReport
+	for _, usage := range fs.ImageFilesystems {
+		report.TotalSize += int64(usage.GetUsedBytes().GetValue())
+	}
+	for _, image := range list.Images {
+		report.Images = append(report.Images, driver.ImageDiskUsage{
+			ID:       image.Id,
+			RepoTags: image.RepoTags,
+			Size:     int64(image.Size_),
+		})
+	}
+	return report, nil
+}
+
+// ImagesPull pulls refStr. CRI reports no progress, so options.Progress is
+// never called.
+func (c *criClient) ImagesPull(refStr string, options driver.ImagePullOptions) (_ []string, err error) {
+	fmt.Println("In cri pull images")
+	defer c.wrapErr(&err, "ImagesPull", refStr)
+	ctx, cancel := driver.LinkContext(c.ctx, options.Context)
+	defer cancel()
+	if err := c.pulls.Acquire(ctx); err != nil {
+		return nil, driver.ContextErr(options.Context, ctx)
+	}
+	defer c.pulls.Release()
+	conn, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := conn.images.PullImage(ctx, &runtimeapi.PullImageRequest{
+		Image: &runtimeapi.ImageSpec{Image: refStr},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, &driver.PullInterruptedError{Ref: refStr, Err: driver.ContextErr(options.Context, ctx)}
+		}
+		return nil, fmt.Errorf("Could not pull image: %w", err)
+	}
+	return []string{resp.ImageRef}, nil
+}
+
+func (c *criClient) ImageExists(ref string) (_ bool, err error) {
+	fmt.Println("In cri image exists")
+	defer c.wrapErr(&err, "ImageExists", ref)
+	image, err := c.imageStatus(ref)
+	if err != nil {
+		return false, err
+	}
+	return image != nil, nil
+}
+
+// ImageWait blocks until ref is present locally, e.g. pulled by another
+// process, or ctx is done.
+func (c *criClient) ImageWait(ctx context.Context, ref string, interval time.Duration) (err error) {
+	defer c.wrapErr(&err, "ImageWait", ref)
+	return driver.WaitForImage(ctx, c, ref, interval)
+}
+
+func (c *criClient) ImageSave(ctx context.Context, refs []string) (_ io.ReadCloser, err error) {
+	defer c.wrapErr(&err, "ImageSave", "")
+	return nil, fmt.Errorf("Image save: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ImageLoad(ctx context.Context, r io.Reader) (_ []string, err error) {
+	defer c.wrapErr(&err, "ImageLoad", "")
+	return nil, fmt.Errorf("Image load: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ImageTag(src string, dst string) (err error) {
+	defer c.wrapErr(&err, "ImageTag", src)
+	return fmt.Errorf("Image tag: %w by the cri driver", driver.ErrNotSupported)
+}
+
+// securityContext maps the spec's user and groups onto CRI, which only
+// takes numeric groups.
+func securityContext(spec driver.ContainerSpec) (*runtimeapi.LinuxContainerSecurityContext, error) {
+	sc := &runtimeapi.LinuxContainerSecurityContext{}
+	if spec.User != "" {
+		parts := strings.SplitN(spec.User, ":", 2)
+		if uid, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+			sc.RunAsUser = &runtimeapi.Int64Value{Value: uid}
+		} else {
+			sc.RunAsUsername = parts[0]
+		}
+		if len(parts) == 2 {
+			gid, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Group %s must be numeric for cri", parts[1])
+			}
+			sc.RunAsGroup = &runtimeapi.Int64Value{Value: gid}
+		}
+	}
+	for _, group := range spec.GroupAdd {
+		gid, err := strconv.ParseInt(group, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Group %s must be numeric for cri", group)
+		}
+		sc.SupplementalGroups = append(sc.SupplementalGroups, gid)
+	}
+	ns, err := namespaceOptions(spec)
+	if err != nil {
+		return nil, err
+	}
+	sc.NamespaceOptions = ns
+	return sc, nil
+}
+
+// namespaceOptions maps the spec's namespace modes onto CRI, which can
+// only share the host's pid and ipc namespaces; sharing with a container
+// is limited to containers of the same pod. It returns nil when the spec
+// sets no mode. Each container has a pod of its own, so the pod's
+// namespaces, the CRI default, are the container's.
+func namespaceOptions(spec driver.ContainerSpec) (*runtimeapi.NamespaceOption, error) {
+	if spec.PidMode == "" && spec.IpcMode == "" && spec.UTSMode == "" {
+		return nil, nil
+	}
+	ns := &runtimeapi.NamespaceOption{}
+	if spec.UTSMode != "" {
+		return nil, fmt.Errorf("UTS namespace modes: %w by the cri driver", driver.ErrNotSupported)
+	}
+	for _, n := range []struct {
+		kind string
+		mode string
+		into *runtimeapi.NamespaceMode
+	}{
+		{"PID", spec.PidMode, &ns.Pid},
+		{"IPC", spec.IpcMode, &ns.Ipc},
+	} {
+		switch n.mode {
+		case "", "private":
+			*n.into = runtimeapi.NamespaceMode_POD
+		case "host":
+			*n.into = runtimeapi.NamespaceMode_NODE
+		default:
+			return nil, fmt.Errorf("%s namespace mode %s: %w by the cri driver", n.kind, n.mode, driver.ErrNotSupported)
+		}
+	}
+	return ns, nil
+}
+
+func protocol(proto string) runtimeapi.Protocol {
+	switch proto {
+	case "udp":
+		return runtimeapi.Protocol_UDP
+	case "sctp":
+		return runtimeapi.Protocol_SCTP
+	}
+	return runtimeapi.Protocol_TCP
+}
+
+// ContainerCreate creates a pod sandbox named after the container and the
+// container inside it. Volumes, tmpfs mounts, secrets, device requests and
+// restart policies have no CRI equivalent and are rejected.
+func (c *criClient) ContainerCreate(spec driver.ContainerSpec) (_ driver.ContainerCreateResponse, err error) {
+	fmt.Println("Inside cri container create")
+	defer c.wrapErr(&err, "ContainerCreate", spec.Name)
+	if err := spec.Validate(); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	switch {
+	case len(spec.Secrets) > 0:
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Secret mounts: %w by the cri driver", driver.ErrNotSupported)
+	case len(spec.DeviceRequests) > 0:
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Device requests: %w by the cri driver", driver.ErrNotSupported)
+	case len(spec.Tmpfs) > 0:
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Tmpfs mounts: %w by the cri driver", driver.ErrNotSupported)
+	case spec.RestartPolicy.Name != "" && spec.RestartPolicy.Name != "no":
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Restart policies: %w by the cri driver", driver.ErrNotSupported)
+	case spec.Platform != "":
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Platforms: %w by the cri driver", driver.ErrNotSupported)
+	case spec.Resources.MemoryReservation != 0:
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Memory reservation: %w by the cri driver", driver.ErrNotSupported)
+	case spec.NetworkConfig.DomainName != "" || len(spec.NetworkConfig.ExtraHosts) > 0:
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Domain names and extra hosts: %w by the cri driver", driver.ErrNotSupported)
+	}
+	if err := driver.PullForPolicy(c, spec.Image, spec.PullPolicy); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if err := driver.VerifyDigest(c, spec.Image); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	env, err := spec.ResolveEnv()
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	sc, err := securityContext(spec)
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+
+	labels := spec.EngineLabels()
+	hostname := spec.NetworkConfig.Hostname
+	if hostname == "" {
+		hostname = spec.Name
+	}
+	sandboxConfig := &runtimeapi.PodSandboxConfig{
+		Metadata: &runtimeapi.PodSandboxMetadata{
+			Name:      spec.Name,
+			Uid:       spec.Name,
+			Namespace: sandboxNamespace,
+		},
+		Hostname: hostname,
+		Labels:   labels,
+		Linux: &runtimeapi.LinuxPodSandboxConfig{
+			CgroupParent: spec.CgroupParent,
+			// host namespaces have to be set on the pod as well
+			SecurityContext: &runtimeapi.LinuxSandboxSecurityContext{
+				NamespaceOptions: sc.NamespaceOptions,
+			},
+		},
+	}
+	if len(spec.NetworkConfig.DNS) > 0 {
+		sandboxConfig.DnsConfig = &runtimeapi.DNSConfig{Servers: spec.NetworkConfig.DNS}
+	}
+	for _, p := range spec.Ports {
+		if p.HostPortEnd != 0 {
+			return driver.ContainerCreateResponse{}, fmt.Errorf("Host port ranges: %w by the cri driver", driver.ErrNotSupported)
+		}
+		sandboxConfig.PortMappings = append(sandboxConfig.PortMappings, &runtimeapi.PortMapping{
+			Protocol:      protocol(p.Protocol),
+			ContainerPort: int32(p.ContainerPort),
+			HostPort:      int32(p.HostPort),
+			HostIp:        p.HostIP,
+		})
+	}
+
+	config := &runtimeapi.ContainerConfig{
+		Metadata:    &runtimeapi.ContainerMetadata{Name: spec.Name},
+		Image:       &runtimeapi.ImageSpec{Image: spec.Image},
+		WorkingDir:  spec.WorkingDir,
+		Labels:      labels,
+		Annotations: spec.Annotations,
+		Linux: &runtimeapi.LinuxContainerConfig{
+			SecurityContext: sc,
+			Resources: &runtimeapi.LinuxContainerResources{
+				MemoryLimitInBytes: spec.Resources.Memory,
+				CpuShares:          spec.Resources.CPUShares,
+				OomScoreAdj:        int64(spec.Resources.OOMScoreAdj),
+			},
+		},
+	}
+	if spec.Resources.NanoCPUs != 0 {
+		config.Linux.Resources.CpuPeriod = 100000
+		config.Linux.Resources.CpuQuota = spec.Resources.NanoCPUs * 100000 / 1e9
+	}
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		config.Envs = append(config.Envs, &runtimeapi.KeyValue{Key: kv[0], Value: value})
+	}
+	for _, m := range spec.Mounts {
+		if m.Type != driver.TypeBind {
+			return driver.ContainerCreateResponse{}, fmt.Errorf("%s mounts: %w by the cri driver", m.Type, driver.ErrNotSupported)
+		}
+		config.Mounts = append(config.Mounts, &runtimeapi.Mount{
+			ContainerPath: m.Target,
+			HostPath:      m.Source,
+			Readonly:      m.ReadOnly,
+		})
+	}
+	for _, d := range spec.Devices {
+		device := &runtimeapi.Device{
+			HostPath:      d.PathOnHost,
+			ContainerPath: d.PathInContainer,
+			Permissions:   d.CgroupPermissions,
+		}
+		if device.ContainerPath == "" {
+			device.ContainerPath = d.PathOnHost
+		}
+		if device.Permissions == "" {
+			device.Permissions = "rwm"
+		}
+		config.Devices = append(config.Devices, device)
+	}
+
+	conn, release, err := c.acquire()
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	sandbox, err := conn.runtime.RunPodSandbox(ctx, &runtimeapi.RunPodSandboxRequest{
+		Config:         sandboxConfig,
+		RuntimeHandler: spec.Runtime,
+	})
+	if err != nil && spec.ReuseExisting && driver.IsNameConflict(err) {
+		return driver.ReuseExisting(c, spec)
+	}
+	if err != nil {
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Couldn't create pod sandbox: %w", err)
+	}
+	resp, err := conn.runtime.CreateContainer(ctx, &runtimeapi.CreateContainerRequest{
+		PodSandboxId:  sandbox.PodSandboxId,
+		Config:        config,
+		SandboxConfig: sandboxConfig,
+	})
+	if err != nil {
+		c.removeSandbox(sandbox.PodSandboxId)
+		return driver.ContainerCreateResponse{}, err
+	}
+	return driver.ContainerCreateResponse{ID: resp.ContainerId}, nil
+}
+
+func (c *criClient) removeSandbox(id string) error {
+	conn, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	if _, err := conn.runtime.StopPodSandbox(ctx, &runtimeapi.StopPodSandboxRequest{PodSandboxId: id}); err != nil {
+		return err
+	}
+	_, err = conn.runtime.RemovePodSandbox(ctx, &runtimeapi.RemovePodSandboxRequest{PodSandboxId: id})
+	return err
+}
+
+func (c *criClient) ContainerStart(id string) (err error) {
+	fmt.Println("Inside cri start container")
+	defer c.wrapErr(&err, "ContainerStart", id)
+	conn, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	_, err = conn.runtime.StartContainer(ctx, &runtimeapi.StartContainerRequest{ContainerId: id})
+	return driver.StartFailure(id, err)
+}
+
+// containerState maps a CRI container state onto the docker status names
+// the other drivers report.
+func containerState(state runtimeapi.ContainerState) string {
+	switch state {
+	case runtimeapi.ContainerState_CONTAINER_CREATED:
+		return "created"
+	case runtimeapi.ContainerState_CONTAINER_RUNNING:
+		return "running"
+	case runtimeapi.ContainerState_CONTAINER_EXITED:
+		return "exited"
+	}
+	return "unknown"
+}
+
+func (c *criClient) containerStatus(id string) (*runtimeapi.ContainerStatus, error) {
+	conn, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := conn.runtime.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: id})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == nil {
+		return nil, fmt.Errorf("No status reported for container %s", id)
+	}
+	return resp.Status, nil
+}
+
+func (c *criClient) ContainerWait(id string, state string, timeout time.Duration, interval time.Duration) (err error) {
+	fmt.Println("Inside cri container wait")
+	defer c.wrapErr(&err, "ContainerWait", id)
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := c.containerStatus(id)
+		if err == nil && containerState(status.State) == state {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Container %s did not reach state %s within %v", id, state, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ContainerWaitWithOptions polls the container until it reaches opts.State.
+func (c *criClient) ContainerWaitWithOptions(id string, opts driver.WaitOptions) (err error) {
+	fmt.Println("Inside cri container wait with options")
+	defer c.wrapErr(&err, "ContainerWaitWithOptions", id)
+	return driver.WaitForState(c.ctx, c, id, opts)
+}
+
+// labelSelector converts "key=value" label filters into a CRI label
+// selector. CRI has no other container filters.
+func labelSelector(filters driver.Filters) (map[string]string, error) {
+	selector := map[string]string{}
+	for key, values := range filters {
+		if key != "label" {
+			return nil, fmt.Errorf("Filter %s: %w by the cri driver", key, driver.ErrNotSupported)
+		}
+		for _, value := range values {
+			kv := strings.SplitN(value, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("Label filter %s without a value: %w by the cri driver", value, driver.ErrNotSupported)
+			}
+			selector[kv[0]] = kv[1]
+		}
+	}
+	return selector, nil
+}
+
+// ContainerList lists containers matching the label filters. CRI cannot
+// page, so Limit, Since and Before are not supported.
+func (c *criClient) ContainerList(options driver.ContainerListOptions) (_ []driver.Container, err error) {
+	fmt.Println("Inside cri container list")
+	defer c.wrapErr(&err, "ContainerList", "")
+	if options.Limit != 0 || options.Since != "" || options.Before != "" {
+		return nil, fmt.Errorf("Paging: %w by the cri driver", driver.ErrNotSupported)
+	}
+	selector, err := labelSelector(options.Filters)
+	if err != nil {
+		return nil, err
+	}
+	filter := &runtimeapi.ContainerFilter{LabelSelector: selector}
+	if !options.All {
+		filter.State = &runtimeapi.ContainerStateValue{State: runtimeapi.ContainerState_CONTAINER_RUNNING}
+	}
+
+	conn, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := conn.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	var dc []driver.Container
+	for _, container := range resp.Containers {
+		dc = append(dc, driver.Container{
+			ID:      container.Id,
+			Names:   []string{container.GetMetadata().GetName()},
+			Image:   container.GetImage().GetImage(),
+			ImageID: container.ImageRef,
+			Created: time.Unix(0, container.CreatedAt).Unix(),
+			Labels:  container.Labels,
+			State:   containerState(container.State),
+		})
+	}
+	if options.Inspect {
+		err = driver.BackfillContainers(dc, c.ContainerInspect)
+	}
+	return dc, err
+}
+
+func (c *criClient) ContainerInspect(id string) (_ *driver.InspectContainerData, err error) {
+	fmt.Println("Inside cri container inspect")
+	defer c.wrapErr(&err, "ContainerInspect", id)
+	status, err := c.containerStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	state := &driver.ContainerState{
+		Status:   containerState(status.State),
+		Running:  status.State == runtimeapi.ContainerState_CONTAINER_RUNNING,
+		ExitCode: int(status.ExitCode),
+		Error:    status.Message,
+	}
+	if status.StartedAt != 0 {
+		state.StartedAt = time.Unix(0, status.StartedAt)
+	}
+	if status.FinishedAt != 0 {
+		state.FinishedAt = time.Unix(0, status.FinishedAt)
+	}
+	icd := &driver.InspectContainerData{
+		ID:          status.Id,
+		Created:     time.Unix(0, status.CreatedAt),
+		Image:       status.ImageRef,
+		ImageName:   status.GetImage().GetImage(),
+		Name:        status.GetMetadata().GetName(),
+		Labels:      status.Labels,
+		Annotations: status.Annotations,
+		State:       state,
+	}
+	for _, m := range status.Mounts {
+		icd.Mounts = append(icd.Mounts, driver.MountPoint{
+			Type:        driver.TypeBind,
+			Source:      m.HostPath,
+			Destination: m.ContainerPath,
+			RW:          !m.Readonly,
+		})
+	}
+	return icd, nil
+}
+
+// ContainerImageDigest returns the digest of the image the container runs.
+func (c *criClient) ContainerImageDigest(id string) (_ string, err error) {
+	fmt.Println("Inside cri container image digest: ", id)
+	defer c.wrapErr(&err, "ContainerImageDigest", id)
+	return driver.ContainerImageDigest(c, id)
+}
+
+// ImageUpToDate reports whether the container runs the image ref points
+// to locally; CRI cannot query the registry without pulling.
+func (c *criClient) ImageUpToDate(id string, ref string) (_ bool, err error) {
+	fmt.Println("Inside cri image up to date: ", id)
+	defer c.wrapErr(&err, "ImageUpToDate", id)
+	return driver.ImageUpToDate(c, id, ref)
+}
+
+// ResolveContainer returns the full ID of the container with the given
+// name or unique ID prefix.
+func (c *criClient) ResolveContainer(nameOrPrefix string) (_ string, err error) {
+	fmt.Println("Inside cri resolve container: ", nameOrPrefix)
+	defer c.wrapErr(&err, "ResolveContainer", nameOrPrefix)
+	return driver.ResolveContainer(c, nameOrPrefix)
+}
+
+// ContainerSpecOf reconstructs the spec the container was created from,
+// for image, labels and mounts. CRI does not report env or ports.
+func (c *criClient) ContainerSpecOf(id string) (_ driver.ContainerSpec, err error) {
+	defer c.wrapErr(&err, "ContainerSpecOf", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return driver.ContainerSpec{}, err
+	}
+	return driver.SpecFromInspect(icd), nil
+}
+
+// ContainerMatchesSpec reports whether the container was created from a
+// spec equal to spec, going by the SpecHashLabel stamped at create time.
+func (c *criClient) ContainerMatchesSpec(id string, spec driver.ContainerSpec) (_ bool, err error) {
+	fmt.Println("Inside cri container matches spec")
+	defer c.wrapErr(&err, "ContainerMatchesSpec", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	hash, ok := icd.Labels[driver.SpecHashLabel]
+	return ok && hash == driver.SpecHash(spec), nil
+}
+
+// IsManaged reports whether ContainerCreate made the container, going by
+// the ManagedByLabel stamped at create time.
+func (c *criClient) IsManaged(id string) (_ bool, err error) {
+	fmt.Println("Inside cri container is managed")
+	defer c.wrapErr(&err, "IsManaged", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	return icd.Labels[driver.ManagedByLabel] == driver.ManagedByValue, nil
+}
+
+func (c *criClient) ContainerStop(id string) (err error) {
+	fmt.Println("Inside cri stop container")
+	defer c.wrapErr(&err, "ContainerStop", id)
+	conn, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout+defaultStopTimeout*time.Second)
+	defer cancel()
+
+	_, err = conn.runtime.StopContainer(ctx, &runtimeapi.StopContainerRequest{
+		ContainerId: id,
+		Timeout:     defaultStopTimeout,
+	})
+	return err
+}
+
+// ContainerStopWithTimeout stops the container, giving it timeout, in
+// whole seconds, to exit before the runtime kills it.
+func (c *criClient) ContainerStopWithTimeout(id string, timeout time.Duration) (err error) {
+	fmt.Println("Inside cri stop container with timeout")
+	defer c.wrapErr(&err, "ContainerStopWithTimeout", id)
+	if timeout < 0 {
+		return fmt.Errorf("Invalid stop timeout %v", timeout)
+	}
+	conn, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout+timeout)
+	defer cancel()
+
+	_, err = conn.runtime.StopContainer(ctx, &runtimeapi.StopContainerRequest{
+		ContainerId: id,
+		Timeout:     int64(timeout / time.Second),
+	})
+	return err
+}
+
+// ContainerExitCode returns the exit code of a stopped container.
+func (c *criClient) ContainerExitCode(id string) (_ int, err error) {
+	fmt.Println("Inside cri container exit code")
+	defer c.wrapErr(&err, "ContainerExitCode", id)
+	status, err := c.containerStatus(id)
+	if err != nil {
+		return 0, err
+	}
+	if status.State == runtimeapi.ContainerState_CONTAINER_RUNNING {
+		return 0, &driver.ContainerRunningError{ID: id}
+	}
+	return int(status.ExitCode), nil
+}
+
+// ContainerUptime returns how long the running container has been up.
+func (c *criClient) ContainerUptime(id string) (_ time.Duration, err error) {
+	fmt.Println("Inside cri container uptime")
+	defer c.wrapErr(&err, "ContainerUptime", id)
+	return driver.ContainerUptime(c, id)
+}
+
+// ContainerRestartCount returns how many times the container was restarted.
+func (c *criClient) ContainerRestartCount(id string) (_ int, err error) {
+	fmt.Println("Inside cri container restart count")
+	defer c.wrapErr(&err, "ContainerRestartCount", id)
+	return driver.ContainerRestartCount(c, id)
+}
+
+// ContainerRemove removes the container and the pod sandbox it runs in.
+func (c *criClient) ContainerRemove(id string, options driver.RemoveOptions) (err error) {
+	fmt.Println("Inside cri remove container")
+	defer c.wrapErr(&err, "ContainerRemove", id)
+	conn, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	list, err := conn.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{
+		Filter: &runtimeapi.ContainerFilter{Id: id},
+	})
+	if err != nil {
+		return err
+	}
+	if len(list.Containers) == 0 {
+		return status.Errorf(codes.NotFound, "Container %s not found", id)
+	}
+	container := list.Containers[0]
+	if container.State == runtimeapi.ContainerState_CONTAINER_RUNNING {
+		if !options.Force {
+			return &driver.ContainerRunningError{ID: id}
+		}
+		if err := c.ContainerStop(id); err != nil {
+			return err
+		}
+	}
+	// stopping may have used up ctx's deadline
+	rctx, rcancel := getTimeoutContext(c)
+	defer rcancel()
+	if _, err := conn.runtime.RemoveContainer(rctx, &runtimeapi.RemoveContainerRequest{ContainerId: id}); err != nil {
+		return err
+	}
+	return c.removeSandbox(container.PodSandboxId)
+}
+
+func (c *criClient) ContainerExec(id string, cmd []string) (_ driver.ExecResult, err error) {
+	fmt.Println("Inside cri container exec")
+	defer c.wrapErr(&err, "ContainerExec", id)
+	return c.execSync(id, cmd, c.timeout)
+}
+
+// execSync runs cmd to completion through ExecSync, which has the runtime
+// kill it once timeout elapses.
+func (c *criClient) execSync(id string, cmd []string, timeout time.Duration) (driver.ExecResult, error) {
+	conn, release, err := c.acquire()
+	if err != nil {
+		return driver.ExecResult{}, err
+	}
+	defer release()
+	// leave the runtime time to report its own timeout
+	ctx, cancel := context.WithTimeout(c.ctx, timeout+c.timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	resp, err := conn.runtime.ExecSync(ctx, &runtimeapi.ExecSyncRequest{
+		ContainerId: id,
+		Cmd:         cmd,
+		Timeout:     execTimeoutSeconds(timeout),
+	})
+	if err != nil {
+		return driver.ExecResult{}, err
+	}
+	return driver.ExecResult{
+		ExitCode:  int(resp.ExitCode),
+		OutBuffer: bytes.NewBuffer(resp.Stdout),
+		ErrBuffer: bytes.NewBuffer(resp.Stderr),
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}, nil
+}
+
+// execTimeoutSeconds rounds timeout up to the whole seconds ExecSync takes,
+// as a zero timeout would leave the exec unbounded.
+func execTimeoutSeconds(timeout time.Duration) int64 {
+	return int64((timeout + time.Second - 1) / time.Second)
+}
+
+// isExecTimeout reports whether err is the runtime, or the call's own
+// deadline, ending an exec that ran out of time. containerd reports it as
+// "timeout ... exceeded" and CRI-O as "command timed out".
+func isExecTimeout(err error) bool {
+	if status.Code(err) == codes.DeadlineExceeded {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "timed out") || (strings.Contains(msg, "timeout") && strings.Contains(msg, "exceeded"))
+}
+
+// ContainerExecWithOptions runs opts.Cmd in the container through
+// ExecSync, which kills it once opts.Timeout, or else the driver timeout,
+// elapses; ExecSync counts in whole seconds, so opts.Timeout is rounded
+// up. CRI returns no output for a timed out exec. Env, WorkingDir, User
+// and Tty cannot be set through ExecSync.
+func (c *criClient) ContainerExecWithOptions(id string, opts driver.ExecOptions) (_ driver.ExecResult, err error) {
+	fmt.Println("Inside cri container exec with options")
+	defer c.wrapErr(&err, "ContainerExecWithOptions", id)
+	if len(opts.Env) > 0 || opts.WorkingDir != "" || opts.User != "" || opts.Tty {
+		return driver.ExecResult{}, fmt.Errorf("Exec options: %w by the cri driver", driver.ErrNotSupported)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+	res, err := c.execSync(id, opts.Cmd, timeout)
+	if err != nil && opts.Timeout > 0 && isExecTimeout(err) {
+		return res, &driver.ExecTimeoutError{ContainerID: id, Timeout: opts.Timeout, Result: res}
+	}
+	if err != nil {
+		return res, err
+	}
+	if max := opts.MaxOutputBytes; max > 0 {
+		// ExecSync has already buffered it all, only bound what is kept
+		for _, buf := range []*bytes.Buffer{res.OutBuffer, res.ErrBuffer} {
+			if int64(buf.Len()) > max {
+				buf.Truncate(int(max))
+				res.Truncated = true
+			}
+		}
+	}
+	return res, nil
+}
+
+// ContainerExecStream runs opts.Cmd in the container and copies its output
+// to stdout and stderr. CRI only streams execs through the kubelet's
+// streaming server, so the output is written once the command exits.
+func (c *criClient) ContainerExecStream(id string, opts driver.ExecOptions, stdout io.Writer, stderr io.Writer) (_ int, err error) {
+	fmt.Println("Inside cri container exec stream")
+	defer c.wrapErr(&err, "ContainerExecStream", id)
+	res, err := c.ContainerExecWithOptions(id, opts)
+	if err != nil {
+		return 0, err
+	}
+	if stdout != nil {
+		if _, err := io.Copy(stdout, res.OutBuffer); err != nil {
+			return 0, err
+		}
+	}
+	if stderr != nil {
+		if _, err := io.Copy(stderr, res.ErrBuffer); err != nil {
+			return 0, err
+		}
+	}
+	return res.ExitCode, nil
+}
+
+// NetworkGateway returns the IPv4 gateway of the network.
+func (c *criClient) NetworkGateway(id string) (_ string, err error) {
+	defer c.wrapErr(&err, "NetworkGateway", id)
+	return driver.NetworkGateway(c, id)
+}
+
+// WaitForPort blocks until something in the container listens on port,
+// or ctx is done.
+func (c *criClient) WaitForPort(ctx context.Context, id string, port int, proto string) (err error) {
+	defer c.wrapErr(&err, "WaitForPort", id)
+	return driver.WaitForPort(ctx, c, id, port, proto)
+}
+
+func (c *criClient) ContainerExecDetached(id string, opts driver.ExecOptions) (_ string, err error) {
+	defer c.wrapErr(&err, "ContainerExecDetached", id)
+	return "", fmt.Errorf("Detached exec: %w by the cri driver", driver.ErrNotSupported)
+}
+
+// ExecInspect is not supported: ExecSync runs to completion and leaves no
+// session behind.
+func (c *criClient) ExecInspect(execID string) (_ driver.ExecInspect, err error) {
+	defer c.wrapErr(&err, "ExecInspect", execID)
+	return driver.ExecInspect{}, fmt.Errorf("Exec sessions: %w by the cri driver", driver.ErrNotSupported)
+}
+
+// ContainerStatsSnapshot reports memory usage only; CRI reports cumulative
+// CPU time, which needs two samples to turn into a percentage.
+func (c *criClient) ContainerStatsSnapshot(id string) (_ driver.ContainerStats, err error) {
+	fmt.Println("Inside cri container stats snapshot")
+	defer c.wrapErr(&err, "ContainerStatsSnapshot", id)
+	conn, release, err := c.acquire()
+	if err != nil {
+		return driver.ContainerStats{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := conn.runtime.ContainerStats(ctx, &runtimeapi.ContainerStatsRequest{ContainerId: id})
+	if err != nil {
+		return driver.ContainerStats{}, err
+	}
+	stats := driver.ContainerStats{ID: id, Read: time.Now()}
+	if mem := resp.GetStats().GetMemory(); mem != nil && mem.WorkingSetBytes != nil {
+		stats.MemoryUsage = mem.WorkingSetBytes.Value
+	}
+	return stats, nil
+}
+
+// ContainerSummary returns the container's state, uptime, restart count,
+// stats and health together.
+func (c *criClient) ContainerSummary(id string) (_ driver.ContainerSummary, err error) {
+	fmt.Println("Inside cri container summary")
+	defer c.wrapErr(&err, "ContainerSummary", id)
+	return driver.SummarizeContainer(c, id)
+}
+
+func (c *criClient) ContainerPorts(id string) (_ []driver.Port, err error) {
+	defer c.wrapErr(&err, "ContainerPorts", id)
+	return nil, fmt.Errorf("Container ports: %w by the cri driver", driver.ErrNotSupported)
+}
+
+// ContainerNetworkConfig is not supported as CRI does not report the
+// sandbox's hostname and DNS settings.
+func (c *criClient) ContainerNetworkConfig(id string) (_ driver.NetworkConfig, err error) {
+	defer c.wrapErr(&err, "ContainerNetworkConfig", id)
+	return driver.NetworkConfig{}, fmt.Errorf("Network config: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ContainerStatPath(id string, path string) (_ driver.PathStat, err error) {
+	defer c.wrapErr(&err, "ContainerStatPath", id)
+	return driver.PathStat{}, fmt.Errorf("Stat path: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) WatchPath(ctx context.Context, id string, path string, interval time.Duration) (_ <-chan driver.PathEvent, err error) {
+	defer c.wrapErr(&err, "WatchPath", id)
+	return nil, fmt.Errorf("Watch path: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ContainerEvents(ctx context.Context, id string) (_ <-chan driver.Event, _ <-chan error, err error) {
+	defer c.wrapErr(&err, "ContainerEvents", id)
+	return nil, nil, fmt.Errorf("Container events: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ContainerLogs(ctx context.Context, id string, options driver.LogOptions) (_ io.ReadCloser, err error) {
+	defer c.wrapErr(&err, "ContainerLogs", id)
+	return nil, fmt.Errorf("Container logs: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ContainerLogPath(id string) (_ string, err error) {
+	defer c.wrapErr(&err, "ContainerLogPath", id)
+	return "", fmt.Errorf("Container log paths: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) GenerateSystemdUnit(id string, opts driver.SystemdOptions) (_ map[string]string, err error) {
+	defer c.wrapErr(&err, "GenerateSystemdUnit", id)
+	return nil, fmt.Errorf("Systemd units: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworkCreate(name string, options driver.NetworkCreateOptions) (_ driver.NetworkCreateResponse, err error) {
+	defer c.wrapErr(&err, "NetworkCreate", name)
+	return driver.NetworkCreateResponse{}, fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworkInspect(id string) (_ driver.NetworkResource, err error) {
+	defer c.wrapErr(&err, "NetworkInspect", id)
+	return driver.NetworkResource{}, fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworkList(options driver.NetworkListOptions) (_ []driver.NetworkResource, err error) {
+	defer c.wrapErr(&err, "NetworkList", "")
+	return nil, fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworkRemove(id string, force bool) (err error) {
+	defer c.wrapErr(&err, "NetworkRemove", id)
+	return fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworkConnect(id string, container string, aliases []string) (_ driver.EndpointResource, err error) {
+	defer c.wrapErr(&err, "NetworkConnect", id)
+	return driver.EndpointResource{}, fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworkDisconnect(id string, container string, force bool) (err error) {
+	defer c.wrapErr(&err, "NetworkDisconnect", id)
+	return fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ContainerConnectNetworks(id string, endpoints map[string]driver.EndpointConfig, opts driver.ConnectNetworksOptions) (err error) {
+	defer c.wrapErr(&err, "ContainerConnectNetworks", id)
+	return fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ContainersPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	defer c.wrapErr(&err, "ContainersPrune", "")
+	return driver.PruneReport{}, fmt.Errorf("Container prune: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) ImagesPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	defer c.wrapErr(&err, "ImagesPrune", "")
+	return driver.PruneReport{}, fmt.Errorf("Image prune: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) NetworksPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	defer c.wrapErr(&err, "NetworksPrune", "")
+	return driver.PruneReport{}, fmt.Errorf("Networks: %w by the cri driver", driver.ErrNotSupported)
+}
+
+func (c *criClient) VolumesPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	defer c.wrapErr(&err, "VolumesPrune", "")
+	return driver.PruneReport{}, fmt.Errorf("Volumes: %w by the cri driver", driver.ErrNotSupported)
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/ajssmith/ce-drivers/driver"
+)
+
+// fakeCRI serves the runtime and image services on a unix socket, keeping
+// sandboxes, containers and images in memory and recording the calls made.
+type fakeCRI struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	runtimeapi.UnimplementedImageServiceServer
+
+	endpoint   string
+	lock       sync.Mutex
+	calls      []string
+	next       int
+	images     map[string]bool
+	sandboxes  map[string]bool
+	containers map[string]*runtimeapi.Container
+	// handlers records the runtime handler each sandbox was run with
+	handlers map[string]string
+	// stopTimeouts records the timeout each container was stopped with
+	stopTimeouts map[string]int64
+	// execTimeouts records the timeout of each ExecSync, in seconds
+	execTimeouts []int64
+	// hangExec makes ExecSync run until its timeout kills it
+	hangExec bool
+}
+
+func newFakeCRI(t *testing.T, dir string) *fakeCRI {
+	t.Helper()
+	socket := filepath.Join(dir, "cri.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeCRI{
+		endpoint:     "unix://" + socket,
+		images:       map[string]bool{},
+		sandboxes:    map[string]bool{},
+		containers:   map[string]*runtimeapi.Container{},
+		handlers:     map[string]string{},
+		stopTimeouts: map[string]int64{},
+	}
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, f)
+	runtimeapi.RegisterImageServiceServer(server, f)
+	go server.Serve(l)
+	t.Cleanup(server.Stop)
+	return f
+}
+
+// record notes the call and returns a fresh ID; callers hold f.lock.
+func (f *fakeCRI) record(call string) string {
+	f.calls = append(f.calls, call)
+	f.next++
+	return fmt.Sprintf("%s-%d", strings.ToLower(call), f.next)
+}
+
+func (f *fakeCRI) container(id string) (*runtimeapi.Container, error) {
+	container, ok := f.containers[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "container %s not found", id)
+	}
+	return container, nil
+}
+
+func (f *fakeCRI) ImageStatus(ctx context.Context, req *runtimeapi.ImageStatusRequest) (*runtimeapi.ImageStatusResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("ImageStatus")
+	ref := req.Image.Image
+	if !f.images[ref] {
+		return &runtimeapi.ImageStatusResponse{}, nil
+	}
+	return &runtimeapi.ImageStatusResponse{Image: &runtimeapi.Image{Id: "sha256:abc", RepoTags: []string{ref}}}, nil
+}
+
+func (f *fakeCRI) PullImage(ctx context.Context, req *runtimeapi.PullImageRequest) (*runtimeapi.PullImageResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("PullImage")
+	f.images[req.Image.Image] = true
+	return &runtimeapi.PullImageResponse{ImageRef: "sha256:abc"}, nil
+}
+
+func (f *fakeCRI) RunPodSandbox(ctx context.Context, req *runtimeapi.RunPodSandboxRequest) (*runtimeapi.RunPodSandboxResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	id := f.record("RunPodSandbox")
+	f.sandboxes[id] = true
+	f.handlers[id] = req.RuntimeHandler
+	return &runtimeapi.RunPodSandboxResponse{PodSandboxId: id}, nil
+}
+
+func (f *fakeCRI) StopPodSandbox(ctx context.Context, req *runtimeapi.StopPodSandboxRequest) (*runtimeapi.StopPodSandboxResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("StopPodSandbox")
+	return &runtimeapi.StopPodSandboxResponse{}, nil
+}
+
+func (f *fakeCRI) RemovePodSandbox(ctx context.Context, req *runtimeapi.RemovePodSandboxRequest) (*runtimeapi.RemovePodSandboxResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("RemovePodSandbox")
+	delete(f.sandboxes, req.PodSandboxId)
+	return &runtimeapi.RemovePodSandboxResponse{}, nil
+}
+
+func (f *fakeCRI) CreateContainer(ctx context.Context, req *runtimeapi.CreateContainerRequest) (*runtimeapi.CreateContainerResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	id := f.record("CreateContainer")
+	if !f.sandboxes[req.PodSandboxId] {
+		return nil, status.Errorf(codes.NotFound, "sandbox %s not found", req.PodSandboxId)
+	}
+	f.containers[id] = &runtimeapi.Container{
+		Id:           id,
+		PodSandboxId: req.PodSandboxId,
+		Metadata:     req.Config.Metadata,
+		Image:        req.Config.Image,
+		Labels:       req.Config.Labels,
+		Annotations:  req.Config.Annotations,
+		State:        runtimeapi.ContainerState_CONTAINER_CREATED,
+	}
+	return &runtimeapi.CreateContainerResponse{ContainerId: id}, nil
+}
+
+func (f *fakeCRI) StartContainer(ctx context.Context, req *runtimeapi.StartContainerRequest) (*runtimeapi.StartContainerResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("StartContainer")
+	container, err := f.container(req.ContainerId)
+	if err != nil {
+		return nil, err
+	}
+	container.State = runtimeapi.ContainerState_CONTAINER_RUNNING
+	return &runtimeapi.StartContainerResponse{}, nil
+}
+
+func (f *fakeCRI) StopContainer(ctx context.Context, req *runtimeapi.StopContainerRequest) (*runtimeapi.StopContainerResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("StopContainer")
+	container, err := f.container(req.ContainerId)
+	if err != nil {
+		return nil, err
+	}
+	container.State = runtimeapi.ContainerState_CONTAINER_EXITED
+	f.stopTimeouts[req.ContainerId] = req.Timeout
+	return &runtimeapi.StopContainerResponse{}, nil
+}
+
+func (f *fakeCRI) RemoveContainer(ctx context.Context, req *runtimeapi.RemoveContainerRequest) (*runtimeapi.RemoveContainerResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("RemoveContainer")
+	delete(f.containers, req.ContainerId)
+	return &runtimeapi.RemoveContainerResponse{}, nil
+}
+
+func (f *fakeCRI) ListContainers(ctx context.Context, req *runtimeapi.ListContainersRequest) (*runtimeapi.ListContainersResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("ListContainers")
+	resp := &runtimeapi.ListContainersResponse{}
+	for id, container := range f.containers {
+		if req.Filter.GetId() == "" || req.Filter.GetId() == id {
+			resp.Containers = append(resp.Containers, container)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeCRI) ContainerStatus(ctx context.Context, req *runtimeapi.ContainerStatusRequest) (*runtimeapi.ContainerStatusResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.record("ContainerStatus")
+	container, err := f.container(req.ContainerId)
+	if err != nil {
+		return nil, err
+	}
+	return &runtimeapi.ContainerStatusResponse{Status: &runtimeapi.ContainerStatus{
+		Id:          container.Id,
+		Metadata:    container.Metadata,
+		Image:       container.Image,
+		Labels:      container.Labels,
+		Annotations: container.Annotations,
+		State:       container.State,
+	}}, nil
+}
+
+func (f *fakeCRI) ExecSync(ctx context.Context, req *runtimeapi.ExecSyncRequest) (*runtimeapi.ExecSyncResponse, error) {
+	f.lock.Lock()
+	f.record("ExecSync")
+	f.execTimeouts = append(f.execTimeouts, req.Timeout)
+	hang := f.hangExec
+	_, err := f.container(req.ContainerId)
+	f.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if hang {
+		// kill the exec once its timeout elapses, as containerd does
+		select {
+		case <-time.After(time.Duration(req.Timeout) * time.Second):
+			return nil, status.Errorf(codes.Unknown, "failed to exec in container: timeout %ds exceeded: context deadline exceeded", req.Timeout)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &runtimeapi.ExecSyncResponse{
+		Stdout:   []byte(strings.Join(req.Cmd, " ") + "\n"),
+		ExitCode: 3,
+	}, nil
+}
+
+func (f *fakeCRI) Calls() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// newTestClient connects a client to a fake CRI server.
+func newTestClient(t *testing.T) (*criClient, *fakeCRI) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "cri-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	f := newFakeCRI(t, dir)
+	c := &criClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: f.endpoint}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, f
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	c, f := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       "router",
+		Image:      "quay.io/skupper/router:1.0",
+		PullPolicy: driver.PullMissing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	icd, err := c.ContainerInspect(res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Name != "router" || !icd.State.Running {
+		t.Errorf("Expected router to be running, got %s in state %+v", icd.Name, icd.State)
+	}
+	exec, err := c.ContainerExec(res.ID, []string{"echo", "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exec.ExitCode != 3 || exec.Stdout() != "echo hello\n" {
+		t.Errorf("Expected the exec's output and exit code, got %q and %d", exec.Stdout(), exec.ExitCode)
+	}
+	if err := c.ContainerRemove(res.ID, driver.RemoveOptions{}); !errors.As(err, new(*driver.ContainerRunningError)) {
+		t.Errorf("Expected a ContainerRunningError removing a running container, got %v", err)
+	}
+	if err := c.ContainerStop(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerRemove(res.ID, driver.RemoveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lifecycle []string
+	for _, call := range f.Calls() {
+		if call != "ImageStatus" && call != "ContainerStatus" && call != "ListContainers" {
+			lifecycle = append(lifecycle, call)
+		}
+	}
+	expected := []string{
+		"PullImage", "RunPodSandbox", "CreateContainer", "StartContainer", "ExecSync",
+		"StopContainer", "RemoveContainer", "StopPodSandbox", "RemovePodSandbox",
+	}
+	if !reflect.DeepEqual(lifecycle, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, lifecycle)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.sandboxes) != 0 {
+		t.Errorf("Expected the sandbox to be removed, got %v", f.sandboxes)
+	}
+}
+
+func TestContainerStopWithTimeout(t *testing.T) {
+	c, f := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       "router",
+		Image:      "quay.io/skupper/router:1.0",
+		PullPolicy: driver.PullMissing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStopWithTimeout(res.ID, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if timeout := f.stopTimeouts[res.ID]; timeout != 3 {
+		t.Errorf("Expected the runtime to get a 3 second timeout, got %d", timeout)
+	}
+}
+
+func TestContainerExecWithOptionsTimeout(t *testing.T) {
+	c, f := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       "router",
+		Image:      "quay.io/skupper/router:1.0",
+		PullPolicy: driver.PullMissing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ContainerExecWithOptions(res.ID, driver.ExecOptions{Cmd: []string{"true"}, Timeout: 1500 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.lock.Lock()
+	f.hangExec = true
+	f.lock.Unlock()
+	_, err = c.ContainerExecWithOptions(res.ID, driver.ExecOptions{Cmd: []string{"sleep", "60"}, Timeout: 300 * time.Millisecond})
+	var timeout *driver.ExecTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("Expected an ExecTimeoutError, got %v", err)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !reflect.DeepEqual(f.execTimeouts, []int64{2, 1}) {
+		t.Errorf("Expected the timeouts to be rounded up to 2 and 1 seconds, got %v", f.execTimeouts)
+	}
+}
+
+func TestImageInspectNotFound(t *testing.T) {
+	c, _ := newTestClient(t)
+	_, err := c.ImageInspect("quay.io/skupper/missing:1.0", driver.ImageInspectOptions{})
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestReconnectDuringCalls(t *testing.T) {
+	c, f := newTestClient(t)
+	f.lock.Lock()
+	f.images["quay.io/skupper/router:1.0"] = true
+	f.lock.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := c.ImageExists("quay.io/skupper/router:1.0"); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := c.Reconnect(driver.ConnectOptions{Host: f.endpoint}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Expected calls to survive a reconnect, got %v", err)
+	}
+}
+
+func TestContainerRemoveNotFound(t *testing.T) {
+	c, _ := newTestClient(t)
+	err := c.ContainerRemove("missing", driver.RemoveOptions{})
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestContainerInspectNotFound(t *testing.T) {
+	c, _ := newTestClient(t)
+	_, err := c.ContainerInspect("missing")
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	var opErr *driver.OperationError
+	if !errors.As(err, &opErr) || opErr.Engine != "cri" || opErr.Op != "ContainerInspect" || opErr.Resource != "missing" {
+		t.Errorf("Expected an OperationError naming the op and container, got %v", err)
+	}
+}
+
+func TestNetworksNotSupported(t *testing.T) {
+	c, f := newTestClient(t)
+	if _, err := c.NetworkCreate("skupper", driver.NetworkCreateOptions{}); !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected NetworkCreate to be unsupported, got %v", err)
+	}
+	if _, err := c.NetworkList(driver.NetworkListOptions{}); !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected NetworkList to be unsupported, got %v", err)
+	}
+	if calls := f.Calls(); len(calls) != 0 {
+		t.Errorf("Expected no calls to the runtime, got %v", calls)
+	}
+}
+
+func TestContainerCreateRuntimeHandler(t *testing.T) {
+	c, f := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       "router",
+		Image:      "quay.io/skupper/router:1.0",
+		PullPolicy: driver.PullMissing,
+		Runtime:    "kata",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sandbox := f.containers[res.ID].PodSandboxId
+	if handler := f.handlers[sandbox]; handler != "kata" {
+		t.Errorf("Expected the sandbox to run with handler kata, got %q", handler)
+	}
+}
+
+func TestSupportsFeature(t *testing.T) {
+	c, f := newTestClient(t)
+	for _, feature := range []driver.Feature{driver.SwarmSecrets, driver.BuildKit} {
+		if supported, err := c.SupportsFeature(feature); err != nil || supported {
+			t.Errorf("Expected %s to be unsupported, got %v, %v", feature, supported, err)
+		}
+	}
+	for _, feature := range []driver.Feature{driver.CgroupV2, driver.RootlessNetworking} {
+		if _, err := c.SupportsFeature(feature); !errors.Is(err, driver.ErrNotSupported) {
+			t.Errorf("Expected probing %s to be unsupported, got %v", feature, err)
+		}
+	}
+	if calls := f.Calls(); len(calls) != 0 {
+		t.Errorf("Expected no calls to the runtime, got %v", calls)
+	}
+}
+
+func TestContainerLabelsAndAnnotations(t *testing.T) {
+	c, _ := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:        "router",
+		Image:       "quay.io/skupper/router:1.0",
+		PullPolicy:  driver.PullMissing,
+		Labels:      map[string]string{"application": "skupper"},
+		Annotations: map[string]string{"io.skupper.site": "west"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	icd, err := c.ContainerInspect(res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Labels["application"] != "skupper" || icd.Annotations["io.skupper.site"] != "west" {
+		t.Errorf("Expected the label and annotation to round-trip, got %v and %v", icd.Labels, icd.Annotations)
+	}
+}
@@ -1,24 +1,38 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	dockertypes "github.com/docker/docker/api/types"
 	dockercontainer "github.com/docker/docker/api/types/container"
+	dockerfilters "github.com/docker/docker/api/types/filters"
+	dockermount "github.com/docker/docker/api/types/mount"
 	dockernetworktypes "github.com/docker/docker/api/types/network"
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+	dockerversions "github.com/docker/docker/api/types/versions"
 	dockerapi "github.com/docker/docker/client"
 	dockermessage "github.com/docker/docker/pkg/jsonmessage"
 	dockerstdcopy "github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/ajssmith/ce-drivers/driver"
-	skupperutils "github.com/skupperproject/skupper/pkg/utils"
+	"golang.org/x/net/http/httpproxy"
 )
 
 const (
@@ -32,12 +46,39 @@ const (
 
 	// defaultImagePullingProgressReportInterval is the default interval of image pulling progress reporting.
 	defaultImagePullingProgressReportInterval = 10 * time.Second
+
+	// skupperNetwork is created with the bridge name skupper0 unless the
+	// caller passes its own options.
+	skupperNetwork = "skupper"
 )
 
 type dockerClient struct {
-	client                   *dockerapi.Client
+	// ctx derives from the caller supplied context and is cancelled by
+	// Close; all operations derive from it
+	ctx                      context.Context
+	cancel                   context.CancelFunc
 	timeout                  time.Duration
 	imagePullProgessDeadline time.Duration
+	pulls                    driver.Semaphore
+	autoAttach               bool
+
+	// reconnectLock serializes Reconnect
+	reconnectLock sync.Mutex
+	// clientLock guards client, which Reconnect replaces; use acquire
+	clientLock sync.RWMutex
+	client     *clientRef
+
+	// partialPulls holds the layers completed by interrupted pulls, by
+	// reference, so a retry does not report them again
+	partialLock  sync.Mutex
+	partialPulls map[string]map[string]bool
+}
+
+// clientRef counts the calls using a client, so that Reconnect can close
+// it once they have finished.
+type clientRef struct {
+	client *dockerapi.Client
+	users  sync.WaitGroup
 }
 
 type ImageNotFoundError struct {
@@ -46,8 +87,19 @@ type ImageNotFoundError struct {
 
 var Driver dockerClient
 
+// NewDriver returns a driver of its own, for callers such as driver.Pool
+// that connect to several daemons. Driver is the shared one.
+func NewDriver() driver.Driver {
+	return &dockerClient{}
+}
+
 func getTimeoutContext(d *dockerClient) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), d.timeout)
+	return context.WithTimeout(d.ctx, d.timeout)
+}
+
+// wrapErr names the failed operation and its resource in *err.
+func (c *dockerClient) wrapErr(err *error, op string, resource string) {
+	*err = driver.WrapOperationError("docker", op, resource, *err, dockerapi.IsErrNotFound(*err))
 }
 
 func newContainerSpec(name string) *dockertypes.ContainerCreateConfig {
@@ -65,26 +117,202 @@ func newContainerSpec(name string) *dockertypes.ContainerCreateConfig {
 	return opts
 }
 
-func (c *dockerClient) New() error {
+// New connects to the docker daemon. Cancelling ctx closes the client and
+// fails any operation in progress. The docker client dials a fresh
+// connection whenever a pooled one is closed, so options.Reconnect needs
+// no extra handling here.
+func (c *dockerClient) New(ctx context.Context, options driver.ConnectOptions) (err error) {
 	fmt.Println("Inside docker plugin new")
-	client, err := dockerapi.NewClientWithOpts(dockerapi.FromEnv, dockerapi.WithAPIVersionNegotiation())
+	defer c.wrapErr(&err, "New", "")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.timeout = driver.DefaultTimeout
+	c.imagePullProgessDeadline = driver.DefaultImagePullingProgressReportInterval
+	client, err := c.connect(options)
 	if err != nil {
-		return fmt.Errorf("Couldn't connect to docker: %w", err)
+		c.cancel()
+		return err
 	}
 
-	Driver.client = client
-	Driver.timeout = driver.DefaultTimeout
-	Driver.imagePullProgessDeadline = driver.DefaultImagePullingProgressReportInterval
+	c.clientLock.Lock()
+	c.client = &clientRef{client: client}
+	c.clientLock.Unlock()
+	maxPulls := options.MaxConcurrentPulls
+	if maxPulls <= 0 {
+		maxPulls = driver.DefaultMaxConcurrentPulls
+	}
+	c.pulls = driver.NewSemaphore(maxPulls)
+	c.autoAttach = options.AutoAttachNetworks
+
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		// a Reconnect in progress swaps in its client first
+		c.reconnectLock.Lock()
+		defer c.reconnectLock.Unlock()
+		c.clientLock.RLock()
+		current := c.client
+		c.clientLock.RUnlock()
+		current.client.Close()
+	}(c.ctx)
+	return nil
+}
+
+// Close cancels the driver's context, failing any operation in progress,
+// which closes the client.
+func (c *dockerClient) Close() (err error) {
+	fmt.Println("Inside docker plugin close")
+	defer c.wrapErr(&err, "Close", "")
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
 
-	ctx, cancel := getTimeoutContext(&Driver)
+// SupportsFeature probes the daemon's info for features that depend on
+// its configuration.
+func (c *dockerClient) SupportsFeature(feature driver.Feature) (_ bool, err error) {
+	fmt.Println("Inside docker supports feature: ", feature)
+	defer c.wrapErr(&err, "SupportsFeature", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
-	Driver.client.NegotiateAPIVersion(ctx)
 
+	info, err := client.Info(ctx)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return false, ctxErr
+	}
+	if err != nil {
+		return false, err
+	}
+	switch feature {
+	case driver.SwarmSecrets:
+		return info.Swarm.LocalNodeState == dockerswarm.LocalNodeStateActive, nil
+	case driver.CgroupV2:
+		return info.CgroupVersion == "2", nil
+	case driver.BuildKit:
+		// the daemon serves BuildKit builds from API 1.39, on linux only
+		return info.OSType == "linux" && dockerversions.GreaterThanOrEqualTo(client.ClientVersion(), "1.39"), nil
+	case driver.RootlessNetworking:
+		for _, opt := range info.SecurityOptions {
+			if opt == "name=rootless" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("Unknown feature %s", feature)
+}
+
+// connect creates a client for the endpoint in options, reading the
+// remaining settings (TLS certificates, API version) from the environment.
+func (c *dockerClient) connect(options driver.ConnectOptions) (*dockerapi.Client, error) {
+	clientOpts := []dockerapi.Opt{dockerapi.FromEnv}
+	if options.APIVersion != "" {
+		clientOpts = append(clientOpts, dockerapi.WithVersion(options.APIVersion))
+	} else {
+		clientOpts = append(clientOpts, dockerapi.WithAPIVersionNegotiation())
+	}
+	if options.Host != "" {
+		clientOpts = append(clientOpts, dockerapi.WithHost(options.Host))
+	}
+	if !options.Proxy.IsZero() {
+		// must come after the host, which resets the transport's proxy
+		clientOpts = append(clientOpts, withProxy(options.Proxy))
+	}
+	client, err := dockerapi.NewClientWithOpts(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't connect to docker: %w", err)
+	}
+
+	if options.APIVersion == "" {
+		nctx, cancel := getTimeoutContext(c)
+		defer cancel()
+		client.NegotiateAPIVersion(nctx)
+	}
+
+	return client, nil
+}
+
+// withProxy routes the client's requests to a tcp daemon through the
+// configured proxy. Other hosts, such as unix sockets, are left without
+// one.
+func withProxy(proxy driver.ProxyConfig) dockerapi.Opt {
+	config := httpproxy.Config{
+		HTTPProxy:  proxy.HTTPProxy,
+		HTTPSProxy: proxy.HTTPSProxy,
+		NoProxy:    proxy.NoProxy,
+	}
+	proxyFunc := config.ProxyFunc()
+	return func(c *dockerapi.Client) error {
+		if !strings.HasPrefix(c.DaemonHost(), "tcp://") {
+			return nil
+		}
+		// HTTPClient returns a copy sharing the client's transport
+		transport, ok := c.HTTPClient().Transport.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("Couldn't configure proxy: unexpected transport %T", c.HTTPClient().Transport)
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+		return nil
+	}
+}
+
+// Reconnect replaces the client with one connected per options, e.g. after
+// rotating TLS certificates. Timeouts and the pull limit set by New are
+// kept. Calls already in flight finish, or fail, on the old client, which
+// is closed once the last of them returns or its stream is closed.
+func (c *dockerClient) Reconnect(options driver.ConnectOptions) (err error) {
+	fmt.Println("Inside docker plugin reconnect")
+	defer c.wrapErr(&err, "Reconnect", "")
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+	c.clientLock.RLock()
+	connected := c.client != nil
+	c.clientLock.RUnlock()
+	if !connected {
+		return fmt.Errorf("Driver is not connected, call New first")
+	}
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	client, err := c.connect(options)
+	if err != nil {
+		return err
+	}
+	c.clientLock.Lock()
+	old := c.client
+	c.client = &clientRef{client: client}
+	c.clientLock.Unlock()
+	go func() {
+		old.users.Wait()
+		old.client.Close()
+	}()
 	return nil
 }
 
-func getCancelableContext() (context.Context, context.CancelFunc) {
-	return context.WithCancel(context.Background())
+// acquire returns the current client and a function to call once done
+// with it, which Reconnect waits for before closing a replaced client.
+// Calling release more than once is harmless. It fails when New has not
+// connected the driver yet.
+func (c *dockerClient) acquire() (*dockerapi.Client, func(), error) {
+	c.clientLock.RLock()
+	defer c.clientLock.RUnlock()
+	ref := c.client
+	if ref == nil {
+		return nil, nil, fmt.Errorf("Docker driver is not connected")
+	}
+	ref.users.Add(1)
+	var once sync.Once
+	return ref.client, func() { once.Do(ref.users.Done) }, nil
+}
+
+func getCancelableContext(d *dockerClient) (context.Context, context.CancelFunc) {
+	return context.WithCancel(d.ctx)
 }
 
 func contextError(ctx context.Context) error {
@@ -102,6 +330,10 @@ func (e operationTimeout) Error() string {
 	return fmt.Sprintf("operation timeout: %v", e.err)
 }
 
+func (e operationTimeout) Unwrap() error {
+	return e.err
+}
+
 func base64EncodeAuth(auth dockertypes.AuthConfig) (string, error) {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(auth); err != nil {
@@ -117,17 +349,39 @@ type progress struct {
 	message *dockermessage.JSONMessage
 	// timestamp of the latest update.
 	timestamp time.Time
+	// layers holds the latest byte count of each layer, to compute speed.
+	layers map[string]layerSample
+}
+
+type layerSample struct {
+	current int64
+	at      time.Time
 }
 
 func newProgress() *progress {
-	return &progress{timestamp: time.Now()}
+	return &progress{timestamp: time.Now(), layers: map[string]layerSample{}}
 }
 
-func (p *progress) set(msg *dockermessage.JSONMessage) {
+// set records msg as the latest progress and returns it as a PullProgress,
+// with the layer's speed computed from its previous update.
+func (p *progress) set(msg *dockermessage.JSONMessage) driver.PullProgress {
 	p.Lock()
 	defer p.Unlock()
+	now := time.Now()
 	p.message = msg
-	p.timestamp = time.Now()
+	p.timestamp = now
+
+	update := driver.PullProgress{Layer: msg.ID, Status: msg.Status}
+	if msg.Progress != nil {
+		update.Current = msg.Progress.Current
+		update.Total = msg.Progress.Total
+		prev, ok := p.layers[msg.ID]
+		if ok && now.After(prev.at) && update.Current >= prev.current {
+			update.SpeedBytesPerSec = float64(update.Current-prev.current) / now.Sub(prev.at).Seconds()
+		}
+		p.layers[msg.ID] = layerSample{current: update.Current, at: now}
+	}
+	return update
 }
 
 func (p *progress) get() (string, time.Time) {
@@ -152,6 +406,7 @@ type progressReporter struct {
 	image                     string
 	cancel                    context.CancelFunc
 	stopCh                    chan struct{}
+	stalledCh                 chan struct{}
 	imagePullProgressDeadline time.Duration
 }
 
@@ -161,13 +416,19 @@ func newProgressReporter(image string, cancel context.CancelFunc, imagePullProgr
 		image:                     image,
 		cancel:                    cancel,
 		stopCh:                    make(chan struct{}),
+		stalledCh:                 make(chan struct{}),
 		imagePullProgressDeadline: imagePullProgressDeadline,
 	}
 }
 
 func (p *progressReporter) start() {
+	// check at least as often as the deadline, so a short one is honored
+	interval := defaultImagePullingProgressReportInterval
+	if p.imagePullProgressDeadline < interval {
+		interval = p.imagePullProgressDeadline
+	}
 	go func() {
-		ticker := time.NewTicker(defaultImagePullingProgressReportInterval)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
@@ -177,6 +438,7 @@ func (p *progressReporter) start() {
 				if time.Since(timestamp) > p.imagePullProgressDeadline {
 					//log.Printf("Cancel pulling image %q because of no progress for %v, latest progress: %q", p.image, p.imagePullProgressDeadline, progress)
 					//log.Println()
+					close(p.stalledCh)
 					p.cancel()
 					return
 				}
@@ -196,9 +458,23 @@ func (p *progressReporter) stop() {
 	close(p.stopCh)
 }
 
-func (c *dockerClient) ImagesPull(refStr string, options driver.ImagePullOptions) ([]string, error) {
+// stalled reports whether the pull was cancelled for lack of progress.
+func (p *progressReporter) stalled() bool {
+	select {
+	case <-p.stalledCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *dockerClient) ImagesPull(refStr string, options driver.ImagePullOptions) (_ []string, err error) {
 	// TODO: return common []string
 	fmt.Println("In docker pull images")
+	defer c.wrapErr(&err, "ImagesPull", refStr)
+	if c.pulls == nil {
+		return nil, fmt.Errorf("Docker driver is not connected")
+	}
 	// RegistryAuth is the base64 encoded credentials for the registry
 	auth := dockertypes.AuthConfig{}
 	base64Auth, err := base64EncodeAuth(auth)
@@ -208,14 +484,34 @@ func (c *dockerClient) ImagesPull(refStr string, options driver.ImagePullOptions
 	opts := dockertypes.ImagePullOptions{}
 	opts.RegistryAuth = base64Auth
 
-	ctx, cancel := getCancelableContext()
+	ctx, cancel := driver.LinkContext(c.ctx, options.Context)
 	defer cancel()
-	resp, err := c.client.ImagePull(ctx, refStr, opts)
+	if err := c.pulls.Acquire(ctx); err != nil {
+		return nil, driver.ContextErr(options.Context, ctx)
+	}
+	defer c.pulls.Release()
+	// acquired once the pull has a slot, so a queued pull does not keep a
+	// replaced client open
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	completed := c.completedLayers(refStr)
+	resp, err := client.ImagePull(ctx, refStr, opts)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, c.pullInterrupted(refStr, completed, driver.ContextErr(options.Context, ctx))
+		}
 		return nil, err
 	}
 	defer resp.Close()
-	reporter := newProgressReporter(refStr, cancel, 10*time.Second)
+	// closing the body unblocks a decode stuck waiting on the daemon
+	go func() {
+		<-ctx.Done()
+		resp.Close()
+	}()
+	reporter := newProgressReporter(refStr, cancel, c.imagePullProgessDeadline)
 	reporter.start()
 	defer reporter.stop()
 	decoder := json.NewDecoder(resp)
@@ -226,23 +522,79 @@ func (c *dockerClient) ImagesPull(refStr string, options driver.ImagePullOptions
 			break
 		}
 		if err != nil {
+			if reporter.stalled() {
+				return nil, c.pullInterrupted(refStr, completed, &driver.PullStalledError{Ref: refStr, Timeout: c.imagePullProgessDeadline})
+			}
+			if ctx.Err() != nil {
+				return nil, c.pullInterrupted(refStr, completed, driver.ContextErr(options.Context, ctx))
+			}
 			return nil, err
 		}
 		if msg.Error != nil {
 			return nil, msg.Error
 		}
-		reporter.set(&msg)
+		if msg.ID != "" && completed[msg.ID] {
+			continue
+		}
+		switch msg.Status {
+		case "Pull complete", "Already exists":
+			completed[msg.ID] = true
+		}
+		update := reporter.set(&msg)
+		if options.Progress != nil {
+			update.Ref = refStr
+			options.Progress(update)
+		}
 	}
+	c.partialLock.Lock()
+	delete(c.partialPulls, refStr)
+	c.partialLock.Unlock()
 	return nil, nil
 }
 
-func (c *dockerClient) ImageInspect(id string) (*driver.ImageInspect, error) {
+// completedLayers returns a copy of the layers an earlier, interrupted pull
+// of ref completed.
+func (c *dockerClient) completedLayers(ref string) map[string]bool {
+	c.partialLock.Lock()
+	defer c.partialLock.Unlock()
+	completed := map[string]bool{}
+	for id := range c.partialPulls[ref] {
+		completed[id] = true
+	}
+	return completed
+}
+
+// pullInterrupted records the layers completed before a pull of ref was
+// interrupted and returns the matching PullInterruptedError.
+func (c *dockerClient) pullInterrupted(ref string, completed map[string]bool, err error) error {
+	c.partialLock.Lock()
+	if c.partialPulls == nil {
+		c.partialPulls = map[string]map[string]bool{}
+	}
+	c.partialPulls[ref] = completed
+	c.partialLock.Unlock()
+
+	var layers []string
+	for id := range completed {
+		layers = append(layers, id)
+	}
+	sort.Strings(layers)
+	return &driver.PullInterruptedError{Ref: ref, CompletedLayers: layers, Err: err}
+}
+
+func (c *dockerClient) ImageInspect(id string, options driver.ImageInspectOptions) (_ *driver.ImageInspect, err error) {
 	fmt.Println("In docker inspect image")
+	defer c.wrapErr(&err, "ImageInspect", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	data, _, err := c.client.ImageInspectWithRaw(ctx, id)
+	data, _, err := client.ImageInspectWithRaw(ctx, id)
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return nil, ctxErr
 	}
@@ -251,18 +603,149 @@ func (c *dockerClient) ImageInspect(id string) (*driver.ImageInspect, error) {
 	}
 
 	image := &driver.ImageInspect{
-		ID:       data.ID,
-		Size:     data.Size,
-		RepoTags: data.RepoTags,
+		ID:           data.ID,
+		Size:         data.Size,
+		RepoTags:     data.RepoTags,
+		RepoDigests:  data.RepoDigests,
+		Os:           data.Os,
+		Architecture: data.Architecture,
+		Variant:      data.Variant,
+	}
+	if data.Config != nil {
+		image.Labels = data.Config.Labels
+	}
+	if options.Platform != "" {
+		if err := driver.CheckPlatform(image, options.Platform); err != nil {
+			return nil, err
+		}
 	}
 	return image, nil
 }
 
-func (c *dockerClient) ImagesList(options driver.ImageListOptions) ([]driver.ImageSummary, error) {
+func (c *dockerClient) ImageExists(ref string) (_ bool, err error) {
+	fmt.Println("In docker image exists")
+	defer c.wrapErr(&err, "ImageExists", ref)
+	client, release, err := c.acquire()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	_, _, err = client.ImageInspectWithRaw(ctx, ref)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return false, ctxErr
+	}
+	if dockerapi.IsErrNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImageTag adds dst as a reference to the image src.
+func (c *dockerClient) ImageTag(src string, dst string) (err error) {
+	fmt.Println("In docker image tag")
+	defer c.wrapErr(&err, "ImageTag", src)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	err = client.ImageTag(ctx, src, dst)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// ImageWait blocks until ref is present locally, e.g. pulled by another
+// process, or ctx is done.
+func (c *dockerClient) ImageWait(ctx context.Context, ref string, interval time.Duration) (err error) {
+	defer c.wrapErr(&err, "ImageWait", ref)
+	return driver.WaitForImage(ctx, c, ref, interval)
+}
+
+// ImageSave returns a docker-archive tarball of the images in refs.
+func (c *dockerClient) ImageSave(ctx context.Context, refs []string) (_ io.ReadCloser, err error) {
+	fmt.Println("In docker image save")
+	defer c.wrapErr(&err, "ImageSave", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := driver.LinkContext(c.ctx, ctx)
+	rc, err := client.ImageSave(ctx, refs)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	return &logStream{ReadCloser: rc, cancel: cancel, release: release}, nil
+}
+
+// ImageLoad loads a docker-archive tarball and returns the names of the
+// images it held.
+func (c *dockerClient) ImageLoad(ctx context.Context, r io.Reader) (_ []string, err error) {
+	fmt.Println("In docker image load")
+	defer c.wrapErr(&err, "ImageLoad", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	linked, cancel := driver.LinkContext(c.ctx, ctx)
+	defer cancel()
+	resp, err := client.ImageLoad(linked, r, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var loaded []string
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg dockermessage.JSONMessage
+		err := decoder.Decode(&msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ctxErr := driver.ContextErr(ctx, linked); ctxErr != nil {
+				return loaded, ctxErr
+			}
+			return loaded, err
+		}
+		if msg.Error != nil {
+			return loaded, msg.Error
+		}
+		for _, prefix := range []string{"Loaded image: ", "Loaded image ID: "} {
+			if strings.HasPrefix(msg.Stream, prefix) {
+				loaded = append(loaded, strings.TrimSpace(strings.TrimPrefix(msg.Stream, prefix)))
+			}
+		}
+	}
+	return loaded, nil
+}
+
+func (c *dockerClient) ImagesList(options driver.ImageListOptions) (_ []driver.ImageSummary, err error) {
 	fmt.Println("In docker list images")
-	ctx, cancel := getTimeoutContext(&Driver)
+	defer c.wrapErr(&err, "ImagesList", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
-	images, err := c.client.ImageList(ctx, dockertypes.ImageListOptions{})
+	images, err := client.ImageList(ctx, dockertypes.ImageListOptions{})
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return nil, ctxErr
 	}
@@ -281,62 +764,296 @@ func (c *dockerClient) ImagesList(options driver.ImageListOptions) ([]driver.Ima
 	return summary, nil
 }
 
-func (c *dockerClient) ContainerCreate(image string) (driver.ContainerCreateResponse, error) {
+// ImagesDiskUsage sizes the local images from the daemon's disk usage
+// report, which accounts for shared layers.
+func (c *dockerClient) ImagesDiskUsage() (_ driver.ImagesDiskReport, err error) {
+	fmt.Println("In docker images disk usage")
+	defer c.wrapErr(&err, "ImagesDiskUsage", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.ImagesDiskReport{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+	du, err := client.DiskUsage(ctx)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return driver.ImagesDiskReport{}, ctxErr
+	}
+	if err != nil {
+		return driver.ImagesDiskReport{}, err
+	}
+	report := driver.ImagesDiskReport{TotalSize: du.LayersSize}
+	for _, image := range du.Images {
+		usage := driver.ImageDiskUsage{
+			ID:            image.ID,
+			RepoTags:      image.RepoTags,
+			Size:          image.Size,
+			ExclusiveSize: image.Size,
+		}
+		// -1 means the daemon did not compute it
+		if image.SharedSize > 0 {
+			usage.SharedSize = image.SharedSize
+			usage.ExclusiveSize = image.Size - image.SharedSize
+		}
+		report.Images = append(report.Images, usage)
+	}
+	return report, nil
+}
+
+// portBindings converts the driver port mappings into docker's exposed
+// port set and host port bindings.
+func portBindings(ports []driver.PortMapping) (nat.PortSet, nat.PortMap) {
+	exposed := nat.PortSet{}
+	bindings := nat.PortMap{}
+	for _, p := range ports {
+		port := nat.Port(fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol))
+		exposed[port] = struct{}{}
+		var hostPort string
+		if p.HostPortEnd != 0 {
+			hostPort = fmt.Sprintf("%d-%d", p.HostPort, p.HostPortEnd)
+		} else if p.HostPort != 0 {
+			hostPort = strconv.Itoa(int(p.HostPort))
+		}
+		bindings[port] = append(bindings[port], nat.PortBinding{HostIP: p.HostIP, HostPort: hostPort})
+	}
+	return exposed, bindings
+}
+
+func (c *dockerClient) ContainerCreate(spec driver.ContainerSpec) (_ driver.ContainerCreateResponse, err error) {
 	fmt.Println("Inside docker container create")
+	defer c.wrapErr(&err, "ContainerCreate", spec.Name)
+	if err := spec.Validate(); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if len(spec.Annotations) > 0 {
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Annotations: %w by docker", driver.ErrNotSupported)
+	}
+	if len(spec.Secrets) > 0 {
+		// docker secrets need swarm services, and copying the files in
+		// would leave them in the container's writable layer
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Secret mounts: %w by standalone docker containers", driver.ErrNotSupported)
+	}
+	if err := driver.PullForPolicy(c, spec.Image, spec.PullPolicy); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if err := driver.VerifyDigest(c, spec.Image); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if err := driver.CheckNamespaceTargets(c, spec); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	env, err := spec.ResolveEnv()
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	opts := newContainerSpec("skupper-router")
-	opts.Config.Image = image
+	if spec.Runtime != "" {
+		info, err := client.Info(ctx)
+		if err != nil {
+			return driver.ContainerCreateResponse{}, err
+		}
+		if _, ok := info.Runtimes[spec.Runtime]; !ok {
+			return driver.ContainerCreateResponse{}, fmt.Errorf("Unknown runtime %s", spec.Runtime)
+		}
+	}
+	opts := newContainerSpec(spec.Name)
+	opts.Config.Image = spec.Image
+	opts.Config.Env = env
+	opts.Config.Labels = spec.EngineLabels()
+	opts.Config.User = spec.User
+	opts.Config.WorkingDir = spec.WorkingDir
+	opts.Config.StopSignal = spec.StopSignal
+	opts.Config.StopTimeout = spec.StopTimeout
+	opts.Config.Hostname = spec.NetworkConfig.Hostname
+	opts.Config.Domainname = spec.NetworkConfig.DomainName
+	opts.HostConfig.DNS = spec.NetworkConfig.DNS
+	opts.HostConfig.ExtraHosts = spec.NetworkConfig.ExtraHosts
+	opts.HostConfig.GroupAdd = spec.GroupAdd
+	opts.HostConfig.Runtime = spec.Runtime
+	for _, m := range spec.Mounts {
+		opts.HostConfig.Mounts = append(opts.HostConfig.Mounts, dockermount.Mount{
+			Type:     dockermount.Type(m.Type),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	opts.HostConfig.Tmpfs = spec.Tmpfs
+	opts.HostConfig.Memory = spec.Resources.Memory
+	opts.HostConfig.MemoryReservation = spec.Resources.MemoryReservation
+	opts.HostConfig.NanoCPUs = spec.Resources.NanoCPUs
+	opts.HostConfig.CPUShares = spec.Resources.CPUShares
+	opts.HostConfig.OomScoreAdj = spec.Resources.OOMScoreAdj
+	opts.HostConfig.RestartPolicy = dockercontainer.RestartPolicy{
+		Name:              spec.RestartPolicy.Name,
+		MaximumRetryCount: spec.RestartPolicy.MaximumRetryCount,
+	}
+	opts.Config.ExposedPorts, opts.HostConfig.PortBindings = portBindings(spec.Ports)
+	opts.HostConfig.ShmSize = spec.ShmSize
+	if opts.HostConfig.ShmSize == 0 {
+		opts.HostConfig.ShmSize = defaultShmSize
+	}
+	if spec.Init {
+		opts.HostConfig.Init = &spec.Init
+	}
+	opts.HostConfig.CgroupParent = spec.CgroupParent
+	opts.HostConfig.CgroupnsMode = dockercontainer.CgroupnsMode(spec.CgroupnsMode)
+	opts.HostConfig.PidMode = dockercontainer.PidMode(spec.PidMode)
+	opts.HostConfig.IpcMode = dockercontainer.IpcMode(spec.IpcMode)
+	opts.HostConfig.UTSMode = dockercontainer.UTSMode(spec.UTSMode)
+	for _, d := range spec.Devices {
+		mapping := dockercontainer.DeviceMapping{
+			PathOnHost:        d.PathOnHost,
+			PathInContainer:   d.PathInContainer,
+			CgroupPermissions: d.CgroupPermissions,
+		}
+		if mapping.PathInContainer == "" {
+			mapping.PathInContainer = d.PathOnHost
+		}
+		if mapping.CgroupPermissions == "" {
+			mapping.CgroupPermissions = "rwm"
+		}
+		opts.HostConfig.Devices = append(opts.HostConfig.Devices, mapping)
+	}
+	for _, r := range spec.DeviceRequests {
+		opts.HostConfig.DeviceRequests = append(opts.HostConfig.DeviceRequests, dockercontainer.DeviceRequest{
+			Driver:       r.Driver,
+			Count:        r.Count,
+			DeviceIDs:    r.DeviceIDs,
+			Capabilities: r.Capabilities,
+			Options:      r.Options,
+		})
+	}
+	for _, u := range spec.Ulimits {
+		opts.HostConfig.Ulimits = append(opts.HostConfig.Ulimits, &units.Ulimit{
+			Name: u.Name,
+			Soft: u.Soft,
+			Hard: u.Hard,
+		})
+	}
 
-	ccb, err := c.client.ContainerCreate(ctx, opts.Config, opts.HostConfig, opts.NetworkingConfig, nil, opts.Name)
+	var platform *ocispec.Platform
+	if spec.Platform != "" {
+		os, arch, variant, _ := driver.ParsePlatform(spec.Platform)
+		platform = &ocispec.Platform{OS: os, Architecture: arch, Variant: variant}
+	}
+	ccb, err := client.ContainerCreate(ctx, opts.Config, opts.HostConfig, opts.NetworkingConfig, platform, opts.Name)
+	if err != nil && spec.ReuseExisting && driver.IsNameConflict(err) {
+		return driver.ReuseExisting(c, spec)
+	}
 	if err != nil {
 		return driver.ContainerCreateResponse{}, err
 	}
-	return driver.ContainerCreateResponse{ID: ccb.ID}, nil
+	if c.autoAttach {
+		if err := driver.AttachLabeledNetworks(c, ccb.ID, spec.Labels); err != nil {
+			client.ContainerRemove(ctx, ccb.ID, dockertypes.ContainerRemoveOptions{Force: true})
+			return driver.ContainerCreateResponse{}, err
+		}
+	}
+	return driver.ContainerCreateResponse{ID: ccb.ID, Warnings: ccb.Warnings}, nil
 }
 
-func (c *dockerClient) ContainerStart(id string) error {
+func (c *dockerClient) ContainerStart(id string) (err error) {
 	fmt.Println("Inside docker start container")
+	defer c.wrapErr(&err, "ContainerStart", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	err := c.client.ContainerStart(ctx, id, dockertypes.ContainerStartOptions{})
+	err = client.ContainerStart(ctx, id, dockertypes.ContainerStartOptions{})
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return ctxErr
 	}
 
-	return err
+	return driver.StartFailure(id, err)
 }
 
-func (c *dockerClient) ContainerWait(id string, status string, timeout time.Duration, interval time.Duration) error {
+// ContainerWait polls at a fixed interval; see ContainerWaitWithOptions.
+func (c *dockerClient) ContainerWait(id string, status string, timeout time.Duration, interval time.Duration) (err error) {
 	fmt.Println("Inside docker container wait")
-	var container dockertypes.ContainerJSON
-	var err error
+	defer c.wrapErr(&err, "ContainerWait", id)
+	return c.ContainerWaitWithOptions(id, driver.WaitOptions{State: status, Timeout: timeout, Interval: interval})
+}
 
-	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+// ContainerWaitWithOptions waits for the container to reach opts.State.
+// For "exited" it first blocks on docker's own wait, which returns as the
+// container stops; other states, or a wait that ends in another state, are
+// polled per opts.
+func (c *dockerClient) ContainerWaitWithOptions(id string, opts driver.WaitOptions) (err error) {
+	fmt.Println("Inside docker container wait with options")
+	defer c.wrapErr(&err, "ContainerWaitWithOptions", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(c.ctx, opts.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(c.ctx)
+	}
 	defer cancel()
 
-	err = skupperutils.RetryWithContext(ctx, interval, func() (bool, error) {
-		container, err = c.client.ContainerInspect(ctx, id)
-		if err != nil {
-			return false, nil
+	if opts.State == "exited" {
+		// a failed wait, e.g. for a container not created yet, is left
+		// to the polling below
+		waitCh, errCh := client.ContainerWait(ctx, id, dockercontainer.WaitConditionNotRunning)
+		select {
+		case <-waitCh:
+		case <-errCh:
 		}
-		return container.State.Status == status, nil
-	})
-	return err
+	}
+	return driver.WaitForState(ctx, c, id, opts)
+}
+
+func convertFilters(filters driver.Filters) dockerfilters.Args {
+	args := dockerfilters.NewArgs()
+	for key, values := range filters {
+		for _, value := range values {
+			args.Add(key, value)
+		}
+	}
+	return args
 }
 
-func (c *dockerClient) ContainerList(driver.ContainerListOptions) ([]driver.Container, error) {
+func (c *dockerClient) ContainerList(options driver.ContainerListOptions) (_ []driver.Container, err error) {
 	fmt.Println("Inside docker container list")
+	defer c.wrapErr(&err, "ContainerList", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	// TODO: convert options
-	containers, err := c.client.ContainerList(ctx, dockertypes.ContainerListOptions{})
+	containers, err := client.ContainerList(ctx, dockertypes.ContainerListOptions{
+		All:     options.All,
+		Limit:   options.Limit,
+		Since:   options.Since,
+		Before:  options.Before,
+		Filters: convertFilters(options.Filters),
+	})
 	var dc []driver.Container
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return dc, ctxErr
@@ -359,130 +1076,805 @@ func (c *dockerClient) ContainerList(driver.ContainerListOptions) ([]driver.Cont
 			//Mounts:  container.Mounts,
 		})
 	}
+	if options.Inspect {
+		if err := driver.BackfillContainers(dc, c.ContainerInspect); err != nil {
+			return dc, err
+		}
+	}
 	return dc, nil
 }
 
-func (c *dockerClient) ContainerInspect(id string) (*driver.InspectContainerData, error) {
+func (c *dockerClient) ContainerInspect(id string) (_ *driver.InspectContainerData, err error) {
 	fmt.Println("Inside docker container inspect")
+	defer c.wrapErr(&err, "ContainerInspect", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	container, err := c.client.ContainerInspect(ctx, id)
+	container, err := client.ContainerInspect(ctx, id)
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return nil, ctxErr
 	}
 	if err != nil {
 		return nil, err
 	}
+	// docker reports the creation time as an RFC3339 string
+	created, err := time.Parse(time.RFC3339Nano, container.Created)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't parse container created time %q: %w", container.Created, err)
+	}
 	icd := &driver.InspectContainerData{
-		ID: container.ID,
-		//		Created: container.Created,
-		Path: container.Path,
-		Args: container.Args,
-		// State: container.State,
-		Image: container.Image,
-		//ImageName: container.ImageName,
-		Name: container.Name,
+		ID:           container.ID,
+		Created:      created,
+		Path:         container.Path,
+		Args:         container.Args,
+		Image:        container.Image,
+		Name:         container.Name,
+		Mounts:       convertMounts(container.Mounts),
+		RestartCount: container.RestartCount,
+	}
+	if container.State != nil {
+		icd.State = convertState(container.State)
+	}
+	if container.Config != nil {
+		icd.ImageName = container.Config.Image
+		icd.Env = container.Config.Env
+		icd.Labels = container.Config.Labels
+		icd.Entrypoint = container.Config.Entrypoint
+		icd.Cmd = container.Config.Cmd
+		icd.StopSignal = container.Config.StopSignal
+		icd.StopTimeout = container.Config.StopTimeout
+		icd.NetworkConfig.Hostname = container.Config.Hostname
+		icd.NetworkConfig.DomainName = container.Config.Domainname
+	}
+	if container.HostConfig != nil {
+		icd.RestartPolicy = driver.RestartPolicy{
+			Name:              container.HostConfig.RestartPolicy.Name,
+			MaximumRetryCount: container.HostConfig.RestartPolicy.MaximumRetryCount,
+		}
+		icd.Resources = driver.Resources{
+			Memory:            container.HostConfig.Memory,
+			MemoryReservation: container.HostConfig.MemoryReservation,
+			NanoCPUs:          container.HostConfig.NanoCPUs,
+			CPUShares:         container.HostConfig.CPUShares,
+			OOMScoreAdj:       container.HostConfig.OomScoreAdj,
+		}
+		icd.Runtime = container.HostConfig.Runtime
+		icd.PidMode = string(container.HostConfig.PidMode)
+		icd.IpcMode = string(container.HostConfig.IpcMode)
+		icd.UTSMode = string(container.HostConfig.UTSMode)
+		icd.CgroupParent = container.HostConfig.CgroupParent
+		icd.CgroupnsMode = string(container.HostConfig.CgroupnsMode)
+		icd.NetworkConfig.DNS = container.HostConfig.DNS
+		icd.NetworkConfig.ExtraHosts = container.HostConfig.ExtraHosts
+		for key, bindings := range container.HostConfig.PortBindings {
+			for _, binding := range bindings {
+				port, err := driver.ParsePortBinding(string(key), binding.HostIP, binding.HostPort)
+				if err != nil {
+					return nil, err
+				}
+				icd.PortBindings = append(icd.PortBindings, port)
+			}
+		}
+	}
+	if container.NetworkSettings != nil {
+		icd.Networks = make(map[string]driver.EndpointResource)
+		for name, es := range container.NetworkSettings.Networks {
+			if es != nil {
+				icd.Networks[name] = convertEndpoint(strings.TrimPrefix(container.Name, "/"), es)
+			}
+		}
 	}
 
 	return icd, err
 }
 
-func (c *dockerClient) ContainerStop(id string) error {
-	fmt.Println("Inside docker stop container")
+// ContainerImageDigest returns the digest of the image the container runs.
+func (c *dockerClient) ContainerImageDigest(id string) (_ string, err error) {
+	fmt.Println("Inside docker container image digest: ", id)
+	defer c.wrapErr(&err, "ContainerImageDigest", id)
+	return driver.ContainerImageDigest(c, id)
+}
 
-	ctx, cancel := getTimeoutContext(&Driver)
+// ImageUpToDate reports whether the container runs the image ref points
+// to in its registry, going by digest. When the registry cannot be reached
+// the local image ref points to is compared instead.
+func (c *dockerClient) ImageUpToDate(id string, ref string) (_ bool, err error) {
+	fmt.Println("Inside docker image up to date: ", id)
+	defer c.wrapErr(&err, "ImageUpToDate", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	running, err := c.ContainerImageDigest(id)
+	if err != nil {
+		return false, err
+	}
+	auth, err := base64EncodeAuth(dockertypes.AuthConfig{})
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
-
-	err := c.client.ContainerStop(ctx, id, nil)
+	remote, err := client.DistributionInspect(ctx, ref, auth)
 	if ctxErr := contextError(ctx); ctxErr != nil {
-		return ctxErr
+		return false, ctxErr
 	}
-	return err
+	if err != nil {
+		return driver.ImageUpToDate(c, id, ref)
+	}
+	return remote.Descriptor.Digest.String() == running, nil
+}
+
+// ResolveContainer returns the full ID of the container with the given
+// name or unique ID prefix.
+func (c *dockerClient) ResolveContainer(nameOrPrefix string) (_ string, err error) {
+	fmt.Println("Inside docker resolve container: ", nameOrPrefix)
+	defer c.wrapErr(&err, "ResolveContainer", nameOrPrefix)
+	return driver.ResolveContainer(c, nameOrPrefix)
+}
+
+// ContainerSpecOf reconstructs the spec the container was created from,
+// for image, env, labels, mounts, ports and restart policy.
+func (c *dockerClient) ContainerSpecOf(id string) (_ driver.ContainerSpec, err error) {
+	defer c.wrapErr(&err, "ContainerSpecOf", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return driver.ContainerSpec{}, err
+	}
+	return driver.SpecFromInspect(icd), nil
+}
+
+func convertState(state *dockertypes.ContainerState) *driver.ContainerState {
+	cs := &driver.ContainerState{
+		Status:     state.Status,
+		Running:    state.Running,
+		Paused:     state.Paused,
+		Restarting: state.Restarting,
+		OOMKilled:  state.OOMKilled,
+		Dead:       state.Dead,
+		Pid:        state.Pid,
+		ExitCode:   state.ExitCode,
+		Error:      state.Error,
+	}
+	// docker reports zero times as "0001-01-01T00:00:00Z", which parses
+	// to the zero time.Time
+	cs.StartedAt, _ = time.Parse(time.RFC3339Nano, state.StartedAt)
+	cs.FinishedAt, _ = time.Parse(time.RFC3339Nano, state.FinishedAt)
+	if state.Health != nil {
+		cs.Health = &driver.Health{
+			Status:        state.Health.Status,
+			FailingStreak: state.Health.FailingStreak,
+		}
+		for _, l := range state.Health.Log {
+			cs.Health.Log = append(cs.Health.Log, driver.HealthLog{
+				Start:    l.Start,
+				End:      l.End,
+				ExitCode: l.ExitCode,
+				Output:   l.Output,
+			})
+		}
+	}
+	return cs
+}
+
+func convertMounts(mounts []dockertypes.MountPoint) []driver.MountPoint {
+	var mps []driver.MountPoint
+	for _, m := range mounts {
+		mps = append(mps, driver.MountPoint{
+			Type:        driver.MountType(m.Type),
+			Name:        m.Name,
+			Source:      m.Source,
+			Destination: m.Destination,
+			Driver:      m.Driver,
+			Mode:        m.Mode,
+			RW:          m.RW,
+			Propagation: driver.MountPropagation(m.Propagation),
+		})
+	}
+	return mps
+}
+
+func (c *dockerClient) ContainerPorts(id string) (_ []driver.Port, err error) {
+	fmt.Println("Inside docker container ports")
+	defer c.wrapErr(&err, "ContainerPorts", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	container, err := client.ContainerInspect(ctx, id)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ports []driver.Port
+	if container.NetworkSettings == nil {
+		return ports, nil
+	}
+	for key, bindings := range container.NetworkSettings.Ports {
+		for _, binding := range bindings {
+			port, err := driver.ParsePortBinding(string(key), binding.HostIP, binding.HostPort)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
 }
 
-func (c *dockerClient) ContainerRemove(id string) error {
+// ContainerNetworkConfig returns the container's effective hostname and
+// DNS settings.
+func (c *dockerClient) ContainerNetworkConfig(id string) (_ driver.NetworkConfig, err error) {
+	fmt.Println("Inside docker container network config: ", id)
+	defer c.wrapErr(&err, "ContainerNetworkConfig", id)
+	return driver.ContainerNetworkConfig(c, id)
+}
+
+func (c *dockerClient) ContainerStatPath(id string, path string) (_ driver.PathStat, err error) {
+	fmt.Println("Inside docker container stat path")
+	defer c.wrapErr(&err, "ContainerStatPath", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.PathStat{}, err
+	}
+	defer release()
+
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	stat, err := client.ContainerStatPath(ctx, id, path)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return driver.PathStat{}, ctxErr
+	}
+	if dockerapi.IsErrNotFound(err) {
+		return driver.PathStat{}, &driver.PathNotFoundError{ID: id, Path: path}
+	}
+	if err != nil {
+		return driver.PathStat{}, err
+	}
+	return driver.PathStat{
+		Name:       stat.Name,
+		Size:       stat.Size,
+		Mode:       stat.Mode,
+		Mtime:      stat.Mtime,
+		LinkTarget: stat.LinkTarget,
+	}, nil
+}
+
+// WatchPath polls the path in the container for changes until ctx is done.
+func (c *dockerClient) WatchPath(ctx context.Context, id string, path string, interval time.Duration) (_ <-chan driver.PathEvent, err error) {
+	fmt.Println("Inside docker watch path")
+	defer c.wrapErr(&err, "WatchPath", id)
+	return driver.WatchPath(ctx, c, id, path, interval)
+}
+
+// ContainerEvents follows the start, die and health_status events of one
+// container until ctx is done, when both channels are closed. A failure of
+// the event stream is sent on the error channel before it is closed.
+func (c *dockerClient) ContainerEvents(ctx context.Context, id string) (_ <-chan driver.Event, _ <-chan error, err error) {
+	fmt.Println("Inside docker container events: ", id)
+	defer c.wrapErr(&err, "ContainerEvents", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := driver.LinkContext(c.ctx, ctx)
+	msgs, errs := client.Events(ctx, dockertypes.EventsOptions{
+		Filters: dockerfilters.NewArgs(
+			dockerfilters.Arg("type", "container"),
+			dockerfilters.Arg("container", id),
+		),
+	})
+	events := make(chan driver.Event)
+	errCh := make(chan error, 1)
+	go func() {
+		defer release()
+		defer cancel()
+		defer close(errCh)
+		defer close(events)
+		for {
+			select {
+			case msg := <-msgs:
+				ev, ok := driver.NewContainerEvent(msg.Actor.ID, msg.Action, msg.Actor.Attributes, time.Unix(0, msg.TimeNano))
+				if !ok {
+					continue
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				if err != nil && ctx.Err() == nil {
+					errCh <- err
+				}
+				return
+			}
+		}
+	}()
+	return events, errCh, nil
+}
+
+// ContainerLogs returns the container's stdout and stderr interleaved.
+// Closing the stream ends the request to the daemon.
+func (c *dockerClient) ContainerLogs(ctx context.Context, id string, options driver.LogOptions) (_ io.ReadCloser, err error) {
+	fmt.Println("Inside docker container logs: ", id)
+	defer c.wrapErr(&err, "ContainerLogs", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := driver.LinkContext(c.ctx, ctx)
+	container, err := client.ContainerInspect(ctx, id)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	opts := dockertypes.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     options.Follow,
+		Timestamps: options.Timestamps,
+		Tail:       "all",
+	}
+	if options.Tail > 0 {
+		opts.Tail = strconv.Itoa(options.Tail)
+	}
+	if !options.Since.IsZero() {
+		opts.Since = options.Since.Format(time.RFC3339Nano)
+	}
+	rc, err := client.ContainerLogs(ctx, id, opts)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	if container.Config != nil && container.Config.Tty {
+		return driver.LimitReadCloser(&logStream{ReadCloser: rc, cancel: cancel, release: release}, options.MaxOutputBytes), nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := demuxStream(pw, pw, rc)
+		rc.Close()
+		pw.CloseWithError(err)
+	}()
+	return driver.LimitReadCloser(&logStream{ReadCloser: pr, cancel: cancel, release: release}, options.MaxOutputBytes), nil
+}
+
+// ContainerLogPath returns the host path of the container's json-file
+// log, for tailing it directly on the docker host.
+func (c *dockerClient) ContainerLogPath(id string) (_ string, err error) {
+	fmt.Println("Inside docker container log path: ", id)
+	defer c.wrapErr(&err, "ContainerLogPath", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	container, err := client.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if container.HostConfig == nil || container.HostConfig.LogConfig.Type != "json-file" {
+		logType := ""
+		if container.HostConfig != nil {
+			logType = container.HostConfig.LogConfig.Type
+		}
+		return "", fmt.Errorf("Log path of %s log driver: %w", logType, driver.ErrNotSupported)
+	}
+	return container.LogPath, nil
+}
+
+func (c *dockerClient) GenerateSystemdUnit(id string, opts driver.SystemdOptions) (_ map[string]string, err error) {
+	defer c.wrapErr(&err, "GenerateSystemdUnit", id)
+	return nil, fmt.Errorf("Systemd units: %w by docker, use a restart policy instead", driver.ErrNotSupported)
+}
+
+// logStream is a log or image archive reader that also cancels the
+// request it reads from when closed.
+type logStream struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	release func()
+}
+
+func (l *logStream) Close() error {
+	l.cancel()
+	err := l.ReadCloser.Close()
+	if l.release != nil {
+		l.release()
+	}
+	return err
+}
+
+// ContainerMatchesSpec reports whether the container was created from a
+// spec equal to spec, going by the SpecHashLabel stamped at create time.
+// Containers created without the label never match.
+func (c *dockerClient) ContainerMatchesSpec(id string, spec driver.ContainerSpec) (_ bool, err error) {
+	fmt.Println("Inside docker container matches spec")
+	defer c.wrapErr(&err, "ContainerMatchesSpec", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	hash, ok := icd.Labels[driver.SpecHashLabel]
+	return ok && hash == driver.SpecHash(spec), nil
+}
+
+// IsManaged reports whether ContainerCreate made the container, going by
+// the ManagedByLabel stamped at create time.
+func (c *dockerClient) IsManaged(id string) (_ bool, err error) {
+	fmt.Println("Inside docker container is managed")
+	defer c.wrapErr(&err, "IsManaged", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	return icd.Labels[driver.ManagedByLabel] == driver.ManagedByValue, nil
+}
+
+// ContainerExitCode returns the exit code of a stopped container.
+func (c *dockerClient) ContainerExitCode(id string) (_ int, err error) {
+	fmt.Println("Inside docker container exit code")
+	defer c.wrapErr(&err, "ContainerExitCode", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	container, err := client.ContainerInspect(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if container.State == nil {
+		return 0, fmt.Errorf("No state reported for container %s", id)
+	}
+	if container.State.Running {
+		return 0, &driver.ContainerRunningError{ID: id}
+	}
+	return container.State.ExitCode, nil
+}
+
+// ContainerUptime returns how long the running container has been up.
+func (c *dockerClient) ContainerUptime(id string) (_ time.Duration, err error) {
+	fmt.Println("Inside docker container uptime")
+	defer c.wrapErr(&err, "ContainerUptime", id)
+	return driver.ContainerUptime(c, id)
+}
+
+// ContainerRestartCount returns how many times the container was restarted.
+func (c *dockerClient) ContainerRestartCount(id string) (_ int, err error) {
+	fmt.Println("Inside docker container restart count")
+	defer c.wrapErr(&err, "ContainerRestartCount", id)
+	return driver.ContainerRestartCount(c, id)
+}
+
+func (c *dockerClient) ContainerStop(id string) (err error) {
+	fmt.Println("Inside docker stop container")
+	defer c.wrapErr(&err, "ContainerStop", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	err = client.ContainerStop(ctx, id, nil)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// ContainerStopWithTimeout stops the container, giving it timeout to exit
+// before the engine kills it.
+func (c *dockerClient) ContainerStopWithTimeout(id string, timeout time.Duration) (err error) {
+	fmt.Println("Inside docker stop container with timeout")
+	defer c.wrapErr(&err, "ContainerStopWithTimeout", id)
+	if timeout < 0 {
+		return fmt.Errorf("Invalid stop timeout %v", timeout)
+	}
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// the request lasts as long as the container takes to stop
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout+timeout)
+	defer cancel()
+
+	err = client.ContainerStop(ctx, id, &timeout)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (c *dockerClient) ContainerRemove(id string, options driver.RemoveOptions) (err error) {
 	fmt.Println("Inside docker container remove")
-	ctx, cancel := getTimeoutContext(&Driver)
+	defer c.wrapErr(&err, "ContainerRemove", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	err := c.client.ContainerRemove(ctx, id, dockertypes.ContainerRemoveOptions{})
+	err = client.ContainerRemove(ctx, id, dockertypes.ContainerRemoveOptions{
+		Force:         options.Force,
+		RemoveVolumes: options.RemoveVolumes,
+	})
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return ctxErr
 	}
 	return err
 }
 
-func (c *dockerClient) NetworkCreate(name string, options driver.NetworkCreateOptions) (driver.NetworkCreateResponse, error) {
+func (c *dockerClient) NetworkCreate(name string, options driver.NetworkCreateOptions) (_ driver.NetworkCreateResponse, err error) {
 	fmt.Println("Inside docker network create")
+	defer c.wrapErr(&err, "NetworkCreate", name)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.NetworkCreateResponse{}, err
+	}
+	defer release()
+	if err := options.Validate(); err != nil {
+		return driver.NetworkCreateResponse{}, err
+	}
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	ncr, err := c.client.NetworkCreate(ctx, name, dockertypes.NetworkCreate{
+	nc := dockertypes.NetworkCreate{
 		CheckDuplicate: true,
-		Driver:         "bridge",
-		Options: map[string]string{
+		Driver:         options.Driver,
+		Options:        options.Options,
+		Labels:         options.EngineLabels(),
+		EnableIPv6:     options.EnableIPv6,
+	}
+	if options.IPAM.Driver != "" || len(options.IPAM.Config) > 0 {
+		nc.IPAM = &dockernetworktypes.IPAM{Driver: options.IPAM.Driver}
+		for _, pool := range options.IPAM.Config {
+			nc.IPAM.Config = append(nc.IPAM.Config, dockernetworktypes.IPAMConfig{
+				Subnet:     pool.Subnet,
+				IPRange:    pool.IPRange,
+				Gateway:    pool.Gateway,
+				AuxAddress: pool.AuxAddresses,
+			})
+		}
+	}
+	if nc.Driver == "" {
+		nc.Driver = "bridge"
+	}
+	if nc.Options == nil && name == skupperNetwork {
+		nc.Options = map[string]string{
 			"com.docker.network.bridge.name":                 "skupper0",
 			"com.docker.network.bridge.enable_icc":           "true",
 			"com.docker.network.bridge.enable_ip_masquerade": "true",
-		},
-	})
+		}
+	}
+	ncr, err := client.NetworkCreate(ctx, name, nc)
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return driver.NetworkCreateResponse{}, ctxErr
 	}
 	return driver.NetworkCreateResponse{ID: ncr.ID, Warning: ncr.Warning}, err
 }
 
-func (c *dockerClient) NetworkInspect(id string) (driver.NetworkResource, error) {
+func (c *dockerClient) NetworkInspect(id string) (_ driver.NetworkResource, err error) {
 	fmt.Println("Inside docker network inspect")
-	ctx, cancel := getTimeoutContext(&Driver)
+	defer c.wrapErr(&err, "NetworkInspect", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.NetworkResource{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	nr, err := c.client.NetworkInspect(ctx, id, dockertypes.NetworkInspectOptions{})
+	nr, err := client.NetworkInspect(ctx, id, dockertypes.NetworkInspectOptions{})
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return driver.NetworkResource{}, ctxErr
 	}
-	return driver.NetworkResource{Name: nr.Name}, err
+	return convertNetwork(nr), err
+}
+
+func convertNetwork(nr dockertypes.NetworkResource) driver.NetworkResource {
+	dnr := driver.NetworkResource{
+		ID:         nr.ID,
+		Name:       nr.Name,
+		Driver:     nr.Driver,
+		Labels:     nr.Labels,
+		Options:    nr.Options,
+		IPAM:       driver.IPAMConfig{Driver: nr.IPAM.Driver},
+		EnableIPv6: nr.EnableIPv6,
+		Containers: map[string]driver.EndpointResource{},
+	}
+	for _, pool := range nr.IPAM.Config {
+		dnr.IPAM.Config = append(dnr.IPAM.Config, driver.IPAMPool{
+			Subnet:       pool.Subnet,
+			IPRange:      pool.IPRange,
+			Gateway:      pool.Gateway,
+			AuxAddresses: pool.AuxAddress,
+		})
+	}
+	for id, ep := range nr.Containers {
+		dnr.Containers[id] = driver.EndpointResource{
+			Name:        ep.Name,
+			EndpointID:  ep.EndpointID,
+			MacAddress:  ep.MacAddress,
+			IPv4Address: ep.IPv4Address,
+			IPv6Address: ep.IPv6Address,
+		}
+	}
+	return dnr
 }
 
-func (c *dockerClient) NetworkRemove(id string) error {
-	//	force := true
+func (c *dockerClient) NetworkList(options driver.NetworkListOptions) (_ []driver.NetworkResource, err error) {
+	fmt.Println("Inside docker network list")
+	defer c.wrapErr(&err, "NetworkList", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	networks, err := client.NetworkList(ctx, dockertypes.NetworkListOptions{
+		Filters: convertFilters(options.Filters),
+	})
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nrs []driver.NetworkResource
+	for _, nr := range networks {
+		nrs = append(nrs, convertNetwork(nr))
+	}
+	return nrs, nil
+}
+
+// NetworkRemove removes the network. When force is set, attached containers
+// are disconnected first; otherwise a network with attached containers
+// yields a driver.NetworkInUseError.
+func (c *dockerClient) NetworkRemove(id string, force bool) (err error) {
 	fmt.Println("Inside docker network remove for: ", id)
-	ctx, cancel := getTimeoutContext(&Driver)
+	defer c.wrapErr(&err, "NetworkRemove", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	if force {
+		nr, err := c.NetworkInspect(id)
+		if err != nil {
+			return err
+		}
+		for container := range nr.Containers {
+			if err := c.NetworkDisconnect(id, container, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	err := c.client.NetworkRemove(ctx, id)
+	err = client.NetworkRemove(ctx, id)
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return ctxErr
 	}
+	if err != nil && strings.Contains(err.Error(), "active endpoints") {
+		nr, ierr := c.NetworkInspect(id)
+		if ierr != nil {
+			return err
+		}
+		inUse := &driver.NetworkInUseError{Network: id}
+		for container := range nr.Containers {
+			inUse.Endpoints = append(inUse.Endpoints, container)
+		}
+		return inUse
+	}
 	return err
 }
 
-func (c *dockerClient) NetworkConnect(id string, container string, aliases []string) error {
+// NetworkConnect attaches container to the network and returns the
+// endpoint docker assigned, read back from the container's settings.
+func (c *dockerClient) NetworkConnect(id string, container string, aliases []string) (_ driver.EndpointResource, err error) {
 	fmt.Println("Inside docker network connect: ", id, container)
+	defer c.wrapErr(&err, "NetworkConnect", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.EndpointResource{}, err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	err := c.client.NetworkConnect(ctx, id, container, &dockernetworktypes.EndpointSettings{})
+	err = client.NetworkConnect(ctx, id, container, &dockernetworktypes.EndpointSettings{
+		Aliases: aliases,
+	})
 	if ctxErr := contextError(ctx); ctxErr != nil {
-		return ctxErr
+		return driver.EndpointResource{}, ctxErr
 	}
 	if err != nil {
-		return err
+		return driver.EndpointResource{}, err
 	}
-	return nil
+
+	cj, err := client.ContainerInspect(ctx, container)
+	if err != nil {
+		return driver.EndpointResource{}, fmt.Errorf("Couldn't read endpoint of container %s: %w", container, err)
+	}
+	if cj.NetworkSettings != nil {
+		for name, es := range cj.NetworkSettings.Networks {
+			if es == nil || (name != id && !strings.HasPrefix(es.NetworkID, id)) {
+				continue
+			}
+			return convertEndpoint(strings.TrimPrefix(cj.Name, "/"), es), nil
+		}
+	}
+	return driver.EndpointResource{}, fmt.Errorf("Container %s has no endpoint on network %s", container, id)
+}
+
+func convertEndpoint(name string, es *dockernetworktypes.EndpointSettings) driver.EndpointResource {
+	endpoint := driver.EndpointResource{
+		Name:       name,
+		EndpointID: es.EndpointID,
+		MacAddress: es.MacAddress,
+		Gateway:    es.Gateway,
+	}
+	if es.IPAddress != "" {
+		endpoint.IPv4Address = fmt.Sprintf("%s/%d", es.IPAddress, es.IPPrefixLen)
+	}
+	if es.GlobalIPv6Address != "" {
+		endpoint.IPv6Address = fmt.Sprintf("%s/%d", es.GlobalIPv6Address, es.GlobalIPv6PrefixLen)
+	}
+	return endpoint
 }
 
-func (c *dockerClient) NetworkDisconnect(id string, container string, force bool) error {
+func (c *dockerClient) NetworkDisconnect(id string, container string, force bool) (err error) {
 	fmt.Println("Inside docker network disconnect: ", id, container)
+	defer c.wrapErr(&err, "NetworkDisconnect", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	ctx, cancel := getTimeoutContext(&Driver)
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
-	err := c.client.NetworkDisconnect(ctx, id, container, force)
+	err = client.NetworkDisconnect(ctx, id, container, force)
 	if ctxErr := contextError(ctx); ctxErr != nil {
 		return ctxErr
 	}
@@ -492,35 +1884,235 @@ func (c *dockerClient) NetworkDisconnect(id string, container string, force bool
 	return nil
 }
 
-func (c *dockerClient) ContainerExec(id string, cmd []string) (driver.ExecResult, error) {
+// ContainerConnectNetworks connects the container to several networks.
+func (c *dockerClient) ContainerConnectNetworks(id string, endpoints map[string]driver.EndpointConfig, opts driver.ConnectNetworksOptions) (err error) {
+	fmt.Println("Inside docker container connect networks: ", id)
+	defer c.wrapErr(&err, "ContainerConnectNetworks", id)
+	return driver.ContainerConnectNetworks(c, id, endpoints, opts)
+}
+
+// ContainersPrune removes the stopped containers matching filters.
+func (c *dockerClient) ContainersPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside docker containers prune")
+	defer c.wrapErr(&err, "ContainersPrune", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	report, err := client.ContainersPrune(ctx, convertFilters(filters))
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	return driver.PruneReport{
+		Deleted:        report.ContainersDeleted,
+		SpaceReclaimed: report.SpaceReclaimed,
+	}, nil
+}
+
+// ImagesPrune removes the dangling images matching filters, or all unused
+// ones with the "dangling=false" filter.
+func (c *dockerClient) ImagesPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside docker images prune")
+	defer c.wrapErr(&err, "ImagesPrune", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	report, err := client.ImagesPrune(ctx, convertFilters(filters))
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	pr := driver.PruneReport{SpaceReclaimed: report.SpaceReclaimed}
+	for _, item := range report.ImagesDeleted {
+		if item.Deleted != "" {
+			pr.Deleted = append(pr.Deleted, item.Deleted)
+		}
+	}
+	return pr, nil
+}
+
+// NetworksPrune removes the networks matching filters that no container
+// uses.
+func (c *dockerClient) NetworksPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside docker networks prune")
+	defer c.wrapErr(&err, "NetworksPrune", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	report, err := client.NetworksPrune(ctx, convertFilters(filters))
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	return driver.PruneReport{Deleted: report.NetworksDeleted}, nil
+}
+
+// VolumesPrune removes the volumes matching filters that no container
+// uses.
+func (c *dockerClient) VolumesPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside docker volumes prune")
+	defer c.wrapErr(&err, "VolumesPrune", "")
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	report, err := client.VolumesPrune(ctx, convertFilters(filters))
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	return driver.PruneReport{
+		Deleted:        report.VolumesDeleted,
+		SpaceReclaimed: report.SpaceReclaimed,
+	}, nil
+}
+
+// cpuPercent computes the CPU usage between the previous and current
+// samples in stats. Without a previous sample, as in a one-shot read, there
+// is no interval to measure and the result is 0.
+func cpuPercent(stats *dockertypes.StatsJSON) float64 {
+	if stats.PreCPUStats.SystemUsage == 0 {
+		return 0
+	}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return (cpuDelta / systemDelta) * onlineCPUs * 100
+}
+
+// memoryUsage returns the memory in use excluding the page cache, matching
+// what the docker cli reports for cgroup v1 and v2 hosts.
+func memoryUsage(mem dockertypes.MemoryStats) uint64 {
+	if v, ok := mem.Stats["total_inactive_file"]; ok && v < mem.Usage {
+		return mem.Usage - v
+	}
+	if v, ok := mem.Stats["inactive_file"]; ok && v < mem.Usage {
+		return mem.Usage - v
+	}
+	return mem.Usage
+}
+
+// ContainerStatsSnapshot reads a single stats sample. The daemon takes it
+// about a second after the previous one, which it includes, so CPUPercent
+// is the usage over that second.
+func (c *dockerClient) ContainerStatsSnapshot(id string) (_ driver.ContainerStats, err error) {
+	fmt.Println("Inside docker container stats snapshot")
+	defer c.wrapErr(&err, "ContainerStatsSnapshot", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.ContainerStats{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := client.ContainerStats(ctx, id, false)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return driver.ContainerStats{}, ctxErr
+	}
+	if err != nil {
+		return driver.ContainerStats{}, err
+	}
+	defer resp.Body.Close()
+
+	var stats dockertypes.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return driver.ContainerStats{}, err
+	}
+
+	cs := driver.ContainerStats{
+		ID:          id,
+		Read:        stats.Read,
+		CPUPercent:  cpuPercent(&stats),
+		MemoryUsage: memoryUsage(stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+		PIDs:        stats.PidsStats.Current,
+	}
+	if cs.MemoryLimit != 0 {
+		cs.MemoryPercent = float64(cs.MemoryUsage) / float64(cs.MemoryLimit) * 100
+	}
+	for _, network := range stats.Networks {
+		cs.NetworkRx += network.RxBytes
+		cs.NetworkTx += network.TxBytes
+	}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			cs.BlockRead += entry.Value
+		case "write":
+			cs.BlockWrite += entry.Value
+		}
+	}
+	return cs, nil
+}
+
+// ContainerSummary returns the container's state, uptime, restart count,
+// stats and health together.
+func (c *dockerClient) ContainerSummary(id string) (_ driver.ContainerSummary, err error) {
+	fmt.Println("Inside docker container summary")
+	defer c.wrapErr(&err, "ContainerSummary", id)
+	return driver.SummarizeContainer(c, id)
+}
+
+func (c *dockerClient) ContainerExec(id string, cmd []string) (_ driver.ExecResult, err error) {
 	fmt.Println("Inside docker container exec")
-	ctx, cancel := getTimeoutContext(&Driver)
+	defer c.wrapErr(&err, "ContainerExec", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.ExecResult{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
 	defer cancel()
 
+	startedAt := time.Now()
 	execConfig := dockertypes.ExecConfig{
 		AttachStdout: true,
 		AttachStderr: true,
 		Cmd:          cmd,
 	}
 
-	createResponse, err := c.client.ContainerExecCreate(ctx, id, execConfig)
+	createResponse, err := client.ContainerExecCreate(ctx, id, execConfig)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
 	execID := createResponse.ID
 
 	// run with stdout and stderr attached
-	attachResponse, err := c.client.ContainerExecAttach(ctx, execID, dockertypes.ExecStartCheck{})
+	attachResponse, err := client.ContainerExecAttach(ctx, execID, dockertypes.ExecStartCheck{})
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
 	defer attachResponse.Close()
 
 	var outBuf, errBuf bytes.Buffer
+	var rawStream bool
 	outputDone := make(chan error, 1)
 
 	go func() {
-		_, err = dockerstdcopy.StdCopy(&outBuf, &errBuf, attachResponse.Reader)
+		var err error
+		rawStream, err = demuxStream(&outBuf, &errBuf, attachResponse.Reader)
 		outputDone <- err
 	}()
 
@@ -529,13 +2121,270 @@ func (c *dockerClient) ContainerExec(id string, cmd []string) (driver.ExecResult
 		if err != nil {
 			return driver.ExecResult{}, err
 		}
-		break
+	case <-ctx.Done():
+		// Closing the attach connection unblocks the copy, after which the
+		// buffers hold whatever was captured before the deadline.
+		attachResponse.Close()
+		<-outputDone
+		return driver.ExecResult{
+			ExecID:    execID,
+			OutBuffer: &outBuf,
+			ErrBuffer: &errBuf,
+			RawStream: rawStream,
+			StartedAt: startedAt,
+			Duration:  time.Since(startedAt),
+		}, fmt.Errorf("exec in container %s: %w", id, ctx.Err())
 	}
 
-	inspectResponse, err := c.client.ContainerExecInspect(ctx, execID)
+	inspectResponse, err := client.ContainerExecInspect(ctx, execID)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
 
-	return driver.ExecResult{ExitCode: inspectResponse.ExitCode, OutBuffer: &outBuf, ErrBuffer: &errBuf}, nil
+	return driver.ExecResult{
+		ExecID:    execID,
+		ExitCode:  inspectResponse.ExitCode,
+		OutBuffer: &outBuf,
+		ErrBuffer: &errBuf,
+		RawStream: rawStream,
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}, nil
+}
+
+// demuxStream splits a multiplexed attach stream into stdout and stderr.
+// StdCopy fails on the first header of a stream that is not multiplexed,
+// e.g. because the container allocated a TTY after all, having already
+// consumed part of it. Checking the first header up front instead lets
+// such a stream be copied to stdout unchanged, which is reported as raw.
+func demuxStream(stdout io.Writer, stderr io.Writer, r io.Reader) (bool, error) {
+	br := bufio.NewReaderSize(r, 32*1024)
+	header, _ := br.Peek(8)
+	if len(header) == 0 || isStdcopyHeader(header) {
+		_, err := dockerstdcopy.StdCopy(stdout, stderr, br)
+		return false, err
+	}
+	_, err := io.Copy(stdout, br)
+	return true, err
+}
+
+// isStdcopyHeader reports whether header is a stdcopy frame header: a
+// stream id of stdin, stdout, stderr or systemerr, three zero bytes and
+// the big endian frame size.
+func isStdcopyHeader(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	return header[0] <= byte(dockerstdcopy.Systemerr) && header[1] == 0 && header[2] == 0 && header[3] == 0
+}
+
+// ContainerExecStream runs opts.Cmd in the container, copying its output
+// to stdout and stderr as it is produced, and returns its exit code. Unlike
+// ContainerExec it is not bound by the driver timeout, only by
+// opts.Timeout.
+func (c *dockerClient) ContainerExecStream(id string, opts driver.ExecOptions, stdout io.Writer, stderr io.Writer) (_ int, err error) {
+	fmt.Println("Inside docker container exec stream")
+	defer c.wrapErr(&err, "ContainerExecStream", id)
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	_, exitCode, _, err := c.execAttached(id, opts, stdout, stderr)
+	return exitCode, err
+}
+
+// ContainerExecWithOptions runs opts.Cmd in the container and returns its
+// buffered output. It is bound by opts.Timeout rather than the driver
+// timeout; on timeout the ExecTimeoutError carries the partial output.
+func (c *dockerClient) ContainerExecWithOptions(id string, opts driver.ExecOptions) (_ driver.ExecResult, err error) {
+	fmt.Println("Inside docker container exec with options")
+	defer c.wrapErr(&err, "ContainerExecWithOptions", id)
+	var outBuf, errBuf bytes.Buffer
+	stdout := driver.CapWriter(&outBuf, opts.MaxOutputBytes)
+	stderr := driver.CapWriter(&errBuf, opts.MaxOutputBytes)
+	startedAt := time.Now()
+	execID, exitCode, rawStream, err := c.execAttached(id, opts, stdout, stderr)
+	res := driver.ExecResult{
+		ExecID:    execID,
+		ExitCode:  exitCode,
+		OutBuffer: &outBuf,
+		ErrBuffer: &errBuf,
+		Tty:       opts.Tty,
+		RawStream: rawStream,
+		Truncated: driver.IsTruncated(stdout) || driver.IsTruncated(stderr),
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}
+	if timeoutErr, ok := err.(*driver.ExecTimeoutError); ok {
+		timeoutErr.Result = res
+	}
+	return res, err
+}
+
+// execAttached runs an exec with its output attached to stdout and stderr
+// and returns its exec ID, exit code and whether the output arrived as a
+// raw stream. When opts.Timeout elapses the attach is closed, the process
+// is killed and an ExecTimeoutError is returned.
+func (c *dockerClient) execAttached(id string, opts driver.ExecOptions, stdout io.Writer, stderr io.Writer) (string, int, bool, error) {
+	client, release, err := c.acquire()
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer release()
+	ctx, cancel := getCancelableContext(c)
+	defer cancel()
+	if opts.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, opts.Timeout)
+		defer cancelTimeout()
+	}
+
+	createResponse, err := client.ContainerExecCreate(ctx, id, dockertypes.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          opts.Tty,
+		Env:          opts.Env,
+		WorkingDir:   opts.WorkingDir,
+		User:         opts.User,
+		Cmd:          opts.Cmd,
+	})
+	if err != nil {
+		return "", 0, false, err
+	}
+	execID := createResponse.ID
+	attachResponse, err := client.ContainerExecAttach(ctx, execID, dockertypes.ExecStartCheck{Tty: opts.Tty})
+	if err != nil {
+		return execID, 0, false, err
+	}
+	defer attachResponse.Close()
+
+	var rawStream bool
+	outputDone := make(chan error, 1)
+	go func() {
+		var err error
+		if opts.Tty {
+			_, err = io.Copy(stdout, attachResponse.Reader)
+		} else {
+			rawStream, err = demuxStream(stdout, stderr, attachResponse.Reader)
+		}
+		outputDone <- err
+	}()
+
+	select {
+	case err := <-outputDone:
+		if err != nil {
+			return execID, 0, rawStream, err
+		}
+	case <-ctx.Done():
+		attachResponse.Close()
+		<-outputDone
+		if opts.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+			killErr := c.killExec(execID)
+			return execID, 0, rawStream, &driver.ExecTimeoutError{ContainerID: id, ExecID: execID, Timeout: opts.Timeout, KillErr: killErr}
+		}
+		return execID, 0, rawStream, ctx.Err()
+	}
+
+	ictx, icancel := getTimeoutContext(c)
+	defer icancel()
+	inspectResponse, err := client.ContainerExecInspect(ictx, execID)
+	if err != nil {
+		return execID, 0, rawStream, err
+	}
+	return execID, inspectResponse.ExitCode, rawStream, nil
+}
+
+// killExec kills a timed out exec's process. Docker has no API for it, so
+// this signals the pid docker reports, which driver.KillExecProcess only
+// does when the daemon is local to the driver.
+func (c *dockerClient) killExec(execID string) error {
+	client, release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+	inspect, err := client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return err
+	}
+	if !inspect.Running {
+		return nil
+	}
+	return driver.KillExecProcess(client.DaemonHost(), inspect.ContainerID, inspect.Pid)
+}
+
+// NetworkGateway returns the IPv4 gateway of the network.
+func (c *dockerClient) NetworkGateway(id string) (_ string, err error) {
+	defer c.wrapErr(&err, "NetworkGateway", id)
+	return driver.NetworkGateway(c, id)
+}
+
+// WaitForPort blocks until something in the container listens on port,
+// or ctx is done.
+func (c *dockerClient) WaitForPort(ctx context.Context, id string, port int, proto string) (err error) {
+	defer c.wrapErr(&err, "WaitForPort", id)
+	return driver.WaitForPort(ctx, c, id, port, proto)
+}
+
+// ContainerExecDetached starts opts.Cmd in the container without waiting
+// for it and returns the exec ID for ExecInspect. Its output is discarded;
+// opts.Timeout and opts.MaxOutputBytes do not apply.
+func (c *dockerClient) ContainerExecDetached(id string, opts driver.ExecOptions) (_ string, err error) {
+	fmt.Println("Inside docker container exec detached: ", id)
+	defer c.wrapErr(&err, "ContainerExecDetached", id)
+	client, release, err := c.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	createResponse, err := client.ContainerExecCreate(ctx, id, dockertypes.ExecConfig{
+		Detach:     true,
+		Tty:        opts.Tty,
+		Env:        opts.Env,
+		WorkingDir: opts.WorkingDir,
+		User:       opts.User,
+		Cmd:        opts.Cmd,
+	})
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return "", ctxErr
+	}
+	if err != nil {
+		return "", err
+	}
+	err = client.ContainerExecStart(ctx, createResponse.ID, dockertypes.ExecStartCheck{Detach: true, Tty: opts.Tty})
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return createResponse.ID, ctxErr
+	}
+	return createResponse.ID, err
+}
+
+func (c *dockerClient) ExecInspect(execID string) (_ driver.ExecInspect, err error) {
+	fmt.Println("Inside docker exec inspect")
+	defer c.wrapErr(&err, "ExecInspect", execID)
+	client, release, err := c.acquire()
+	if err != nil {
+		return driver.ExecInspect{}, err
+	}
+	defer release()
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+
+	resp, err := client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return driver.ExecInspect{}, err
+	}
+	return driver.ExecInspect{
+		ID:          resp.ExecID,
+		ContainerID: resp.ContainerID,
+		Running:     resp.Running,
+		ExitCode:    resp.ExitCode,
+		Pid:         resp.Pid,
+	}, nil
 }
@@ -0,0 +1,1614 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockermessage "github.com/docker/docker/pkg/jsonmessage"
+	dockerstdcopy "github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/ajssmith/ce-drivers/driver"
+)
+
+// testImage keeps running with its default command and has a shell.
+const testImage = "docker.io/library/nginx:alpine"
+
+// newTestClient connects to the local docker daemon, skipping the test
+// when none is reachable.
+func newTestClient(t *testing.T) *dockerClient {
+	t.Helper()
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{}); err != nil {
+		t.Skipf("No docker daemon: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	ctx, cancel := getTimeoutContext(c)
+	defer cancel()
+	client, release, err := c.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if _, err := client.Ping(ctx); err != nil {
+		t.Skipf("No docker daemon: %v", err)
+	}
+	return c
+}
+
+// runTestContainer creates and starts a container of testImage from spec,
+// removing it when the test ends.
+func runTestContainer(t *testing.T, c *dockerClient, spec driver.ContainerSpec) string {
+	t.Helper()
+	if spec.Name == "" {
+		spec.Name = fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	}
+	if spec.Image == "" {
+		spec.Image = testImage
+	}
+	if spec.PullPolicy == "" {
+		spec.PullPolicy = driver.PullMissing
+	}
+	res, err := c.ContainerCreate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true}) })
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	return res.ID
+}
+
+// execOutput runs cmd in the container and returns its trimmed stdout.
+func execOutput(t *testing.T, c *dockerClient, id string, cmd ...string) string {
+	t.Helper()
+	res, err := c.ContainerExec(id, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 0 {
+		t.Fatalf("%v exited %d: %s", cmd, res.ExitCode, res.Combined())
+	}
+	return strings.TrimSpace(res.Stdout())
+}
+
+// entrypointMount replaces testImage's entrypoint with a shell script, as
+// specs have no command.
+func entrypointMount(t *testing.T, script string) driver.Mount {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "entrypoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "entrypoint.sh")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return driver.Mount{Type: driver.TypeBind, Source: path, Target: "/docker-entrypoint.sh", ReadOnly: true}
+}
+
+func TestContextErrorMatchesDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	err := contextError(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v to match context.DeadlineExceeded", err)
+	}
+}
+
+func TestContainerInspectCreated(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age := time.Since(icd.Created); age < -time.Minute || age > 5*time.Minute {
+		t.Errorf("Expected the container to have been created just now, got %v", icd.Created)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	stats := dockertypes.StatsJSON{Stats: dockertypes.Stats{
+		CPUStats: dockertypes.CPUStats{
+			CPUUsage:    dockertypes.CPUUsage{TotalUsage: 400},
+			SystemUsage: 2000,
+			OnlineCPUs:  2,
+		},
+		PreCPUStats: dockertypes.CPUStats{
+			CPUUsage:    dockertypes.CPUUsage{TotalUsage: 200},
+			SystemUsage: 1000,
+		},
+	}}
+	if got := cpuPercent(&stats); got != 40 {
+		t.Errorf("Expected 40%%, got %v", got)
+	}
+
+	// a one-shot read has no previous sample
+	stats.PreCPUStats = dockertypes.CPUStats{}
+	if got := cpuPercent(&stats); got != 0 {
+		t.Errorf("Expected 0%% without a previous sample, got %v", got)
+	}
+}
+
+func TestMemoryUsageExcludesCache(t *testing.T) {
+	mem := dockertypes.MemoryStats{Usage: 1000, Stats: map[string]uint64{"inactive_file": 300}}
+	if got := memoryUsage(mem); got != 700 {
+		t.Errorf("Expected 700, got %d", got)
+	}
+}
+
+func TestContainerStatsSnapshot(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	stats, err := c.ContainerStatsSnapshot(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.MemoryUsage == 0 {
+		t.Errorf("Expected nonzero memory usage for a running container")
+	}
+}
+
+func TestContainerCreateUlimits(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Ulimits: []driver.Ulimit{{Name: "nofile", Soft: 65536, Hard: 65536}},
+	})
+	if got := execOutput(t, c, id, "sh", "-c", "ulimit -n"); got != "65536" {
+		t.Errorf("Expected nofile 65536, got %s", got)
+	}
+}
+
+func TestContainerCreateShmSize(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{ShmSize: 128 * 1024 * 1024})
+	if got := execOutput(t, c, id, "sh", "-c", "df -k /dev/shm | tail -1 | awk '{print $2}'"); got != "131072" {
+		t.Errorf("Expected a 131072k /dev/shm, got %s", got)
+	}
+}
+
+func TestContainerCreateInit(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{Init: true})
+	if got := execOutput(t, c, id, "cat", "/proc/1/comm"); got != "docker-init" {
+		t.Errorf("Expected docker-init as pid 1, got %s", got)
+	}
+}
+
+func TestContainerPortsAssigned(t *testing.T) {
+	c := newTestClient(t)
+	mappings, err := driver.ParsePortSpec("0:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := runTestContainer(t, c, driver.ContainerSpec{Ports: mappings})
+	ports, err := c.ContainerPorts(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) == 0 || ports[0].ContainerPort != 80 || ports[0].HostPort == 0 {
+		t.Errorf("Expected port 80 bound to an assigned host port, got %+v", ports)
+	}
+}
+
+func TestNetworkLabels(t *testing.T) {
+	c := newTestClient(t)
+	value := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	name := "ce-drivers-" + value
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{Labels: map[string]string{"ce-drivers-test": value}}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	list, err := c.NetworkList(driver.NetworkListOptions{Filters: driver.LabelFilter("ce-drivers-test", value)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != name {
+		t.Fatalf("Expected only network %s, got %+v", name, list)
+	}
+	// only the skupper network gets the skupper0 bridge
+	if bridge := list[0].Options["com.docker.network.bridge.name"]; bridge != "" {
+		t.Errorf("Expected no bridge name, got %s", bridge)
+	}
+}
+
+func TestNetworkRemoveInUse(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	if _, err := c.NetworkConnect(name, id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.NetworkRemove(name, false)
+	var inUse *driver.NetworkInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("Expected a NetworkInUseError, got %v", err)
+	}
+	if len(inUse.Endpoints) != 1 || inUse.Endpoints[0] != id {
+		t.Errorf("Expected endpoint %s, got %v", id, inUse.Endpoints)
+	}
+	if err := c.NetworkRemove(name, true); err != nil {
+		t.Errorf("Expected a forced remove to disconnect and remove, got %v", err)
+	}
+}
+
+func TestContainerExecDeadline(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	start := time.Now()
+	res, err := c.ContainerExecWithOptions(id, driver.ExecOptions{
+		Cmd:     []string{"sh", "-c", "echo started; sleep 30"},
+		Timeout: time.Second,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the copy to stop at the deadline, took %v", elapsed)
+	}
+	var timeout *driver.ExecTimeoutError
+	if errors.As(err, &timeout) {
+		res = timeout.Result
+	}
+	if got := strings.TrimSpace(res.Stdout()); got != "started" {
+		t.Errorf("Expected the output captured before the deadline, got %q", got)
+	}
+}
+
+func TestContainerCreateSecretsNotSupported(t *testing.T) {
+	c := &dockerClient{}
+	_, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:    "router",
+		Image:   testImage,
+		Secrets: []driver.SecretMount{{Source: "/etc/skupper/tls.key", Target: "/etc/skupper-router-certs/tls.key"}},
+	})
+	if !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestContainerExecDuration(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	before := time.Now()
+	res, err := c.ContainerExec(id, []string{"sleep", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StartedAt.Before(before) || res.StartedAt.After(time.Now()) {
+		t.Errorf("Expected the start time around %v, got %v", before, res.StartedAt)
+	}
+	if res.Duration < time.Second || res.Duration > 10*time.Second {
+		t.Errorf("Expected a duration of about a second, got %v", res.Duration)
+	}
+}
+
+func TestContainerCreateCgroupParent(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{CgroupParent: "cedriverstest.slice"})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.CgroupParent != "cedriverstest.slice" {
+		t.Errorf("Expected cgroup parent cedriverstest.slice, got %q", icd.CgroupParent)
+	}
+}
+
+func TestNetworkCreateSubnet(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	pool := driver.IPAMPool{Subnet: "10.212.42.0/24", Gateway: "10.212.42.1"}
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{IPAM: driver.IPAMConfig{Config: []driver.IPAMPool{pool}}}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	network, err := c.NetworkInspect(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(network.IPAM.Config) != 1 {
+		t.Fatalf("Expected one IPAM pool, got %+v", network.IPAM.Config)
+	}
+	if got := network.IPAM.Config[0]; got.Subnet != pool.Subnet || got.Gateway != pool.Gateway {
+		t.Errorf("Expected subnet %s gateway %s, got %s gateway %s", pool.Subnet, pool.Gateway, got.Subnet, got.Gateway)
+	}
+}
+
+func TestNetworkCreateIPv6(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	pool := driver.IPAMPool{Subnet: "fd00:ce:42::/64", Gateway: "fd00:ce:42::1"}
+	options := driver.NetworkCreateOptions{
+		EnableIPv6: true,
+		IPAM:       driver.IPAMConfig{Config: []driver.IPAMPool{pool}},
+	}
+	if _, err := c.NetworkCreate(name, options); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	network, err := c.NetworkInspect(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, got := range network.IPAM.Config {
+		found = found || got.Subnet == pool.Subnet
+	}
+	if !found {
+		t.Errorf("Expected the IPv6 subnet %s, got %+v", pool.Subnet, network.IPAM.Config)
+	}
+}
+
+func TestContainerCreateDevices(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Devices: []driver.DeviceMapping{{PathOnHost: "/dev/null", PathInContainer: "/dev/telemetry"}},
+	})
+	if got := execOutput(t, c, id, "sh", "-c", "test -c /dev/telemetry && head -c 16 /dev/telemetry | wc -c"); got != "0" {
+		t.Errorf("Expected /dev/telemetry to read as /dev/null, got %q", got)
+	}
+}
+
+func TestExecInspect(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	res, err := c.ContainerExec(id, []string{"sh", "-c", "exit 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExecID == "" {
+		t.Fatal("Expected the exec session ID")
+	}
+	ei, err := c.ExecInspect(res.ExecID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ei.ID != res.ExecID || ei.Running || ei.ExitCode != 3 {
+		t.Errorf("Expected finished session %s exited 3, got %+v", res.ExecID, ei)
+	}
+}
+
+func TestContainerExitCode(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Mounts: []driver.Mount{entrypointMount(t, "sleep 1\nexit 42")},
+	})
+	if _, err := c.ContainerExitCode(id); !errors.As(err, new(*driver.ContainerRunningError)) {
+		t.Errorf("Expected a ContainerRunningError while running, got %v", err)
+	}
+	if err := c.ContainerWait(id, "exited", 30*time.Second, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	code, err := c.ContainerExitCode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 42 {
+		t.Errorf("Expected exit code 42, got %d", code)
+	}
+}
+
+// fakeDaemon answers requests on a unix socket, recording their paths.
+// Pulls and container creates are passed to pull and create when set;
+// anything else is a missing image.
+type fakeDaemon struct {
+	host   string
+	pull   http.HandlerFunc
+	create http.HandlerFunc
+	lock   sync.Mutex
+	paths  []string
+}
+
+func newFakeDaemon(t *testing.T, dir, name string) *fakeDaemon {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeDaemon{host: "unix://" + path}
+	srv := &http.Server{Handler: f}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return f
+}
+
+func (f *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	f.paths = append(f.paths, r.URL.Path)
+	f.lock.Unlock()
+	if f.pull != nil && strings.HasSuffix(r.URL.Path, "/images/create") {
+		f.pull(w, r)
+		return
+	}
+	if f.create != nil && strings.HasSuffix(r.URL.Path, "/containers/create") {
+		f.create(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `{"message":"No such image"}`)
+}
+
+func (f *fakeDaemon) count() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.paths)
+}
+
+func TestReconnectUsesNewEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := newFakeDaemon(t, dir, "first.sock")
+	second := newFakeDaemon(t, dir, "second.sock")
+
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: first.host, APIVersion: "1.41"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.ImageExists("quay.io/skupper/router"); err != nil {
+		t.Fatal(err)
+	}
+
+	// calls racing the reconnect use one client or the other, never none
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ImageExists("quay.io/skupper/router"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	if err := c.Reconnect(driver.ConnectOptions{Host: second.host, APIVersion: "1.41"}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	before := first.count()
+	if _, err := c.ImageExists("quay.io/skupper/router"); err != nil {
+		t.Fatal(err)
+	}
+	if n := first.count(); n != before {
+		t.Errorf("Expected no more requests to the old endpoint, got %d", n-before)
+	}
+	if second.count() == 0 {
+		t.Errorf("Expected the new endpoint to be used")
+	}
+}
+
+func TestContainerCreateUser(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		User:     "1000:1000",
+		GroupAdd: []string{"2000"},
+		// nginx needs root, so just keep the container running
+		Mounts: []driver.Mount{entrypointMount(t, "exec sleep 300")},
+	})
+	if got := execOutput(t, c, id, "id", "-u"); got != "1000" {
+		t.Errorf("Expected uid 1000, got %q", got)
+	}
+	if got := strings.Fields(execOutput(t, c, id, "id", "-G")); !reflect.DeepEqual(got, []string{"1000", "2000"}) {
+		t.Errorf("Expected groups 1000 and 2000, got %q", got)
+	}
+}
+
+func TestContainerCreateWorkingDir(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{WorkingDir: "/usr/share/nginx/html"})
+	if got := execOutput(t, c, id, "pwd"); got != "/usr/share/nginx/html" {
+		t.Errorf("Expected working directory /usr/share/nginx/html, got %q", got)
+	}
+}
+
+func TestNetworkConnectReturnsEndpoint(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+
+	ep, err := c.NetworkConnect(name, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.ParseIP(strings.Split(ep.IPv4Address, "/")[0]) == nil {
+		t.Errorf("Expected an IPv4 address, got %+v", ep)
+	}
+}
+
+func TestWaitForPort(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.WaitForPort(ctx, id, 80, "tcp"); err != nil {
+		t.Errorf("Expected nginx to listen on port 80, got %v", err)
+	}
+}
+
+func TestContainerCreateTmpfs(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{Tmpfs: map[string]string{"/scratch": "size=64m"}})
+	mounts := execOutput(t, c, id, "mount")
+	found := false
+	for _, line := range strings.Split(mounts, "\n") {
+		// tmpfs on /scratch type tmpfs (rw,...)
+		fields := strings.Fields(line)
+		found = found || len(fields) >= 5 && fields[2] == "/scratch" && fields[4] == "tmpfs"
+	}
+	if !found {
+		t.Errorf("Expected a tmpfs at /scratch, got:\n%s", mounts)
+	}
+}
+
+func TestAPIVersionPinSkipsNegotiation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeDaemon(t, dir, "docker.sock")
+
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: f.host, APIVersion: "1.40"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.ImageExists("quay.io/skupper/router"); err != nil {
+		t.Fatal(err)
+	}
+	client, release, err := c.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if v := client.ClientVersion(); v != "1.40" {
+		t.Errorf("Expected the pinned version 1.40, got %s", v)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, path := range f.paths {
+		if !strings.HasPrefix(path, "/v1.40/") {
+			t.Errorf("Expected only requests to the pinned version, got %s", path)
+		}
+	}
+}
+
+func TestContainerCreateStopSignal(t *testing.T) {
+	c := newTestClient(t)
+	timeout := 5
+	id := runTestContainer(t, c, driver.ContainerSpec{StopSignal: "SIGQUIT", StopTimeout: &timeout})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.StopSignal != "SIGQUIT" {
+		t.Errorf("Expected stop signal SIGQUIT, got %q", icd.StopSignal)
+	}
+	if icd.StopTimeout == nil || *icd.StopTimeout != timeout {
+		t.Errorf("Expected stop timeout %d, got %v", timeout, icd.StopTimeout)
+	}
+}
+
+func TestImageLabel(t *testing.T) {
+	c := newTestClient(t)
+	if err := driver.PullForPolicy(c, testImage, driver.PullMissing); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := driver.ImageLabel(c, testImage, "maintainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !strings.Contains(value, "nginx") {
+		t.Errorf("Expected the nginx maintainer label, got %q (set %v)", value, ok)
+	}
+}
+
+func TestPullInterruptedResumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeDaemon(t, dir, "docker.sock")
+	attempts := 0
+	f.pull = func(w http.ResponseWriter, r *http.Request) {
+		f.lock.Lock()
+		attempts++
+		first := attempts == 1
+		f.lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"id":"aaa","status":"Pull complete"}`)
+		fmt.Fprintln(w, `{"id":"bbb","status":"Downloading","progressDetail":{"current":10,"total":100}}`)
+		w.(http.Flusher).Flush()
+		if first {
+			// stall until the client gives up
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintln(w, `{"id":"bbb","status":"Pull complete"}`)
+	}
+
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: f.host, APIVersion: "1.41"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = c.ImagesPull("quay.io/skupper/router:1.0", driver.ImagePullOptions{
+		Context: ctx,
+		Progress: func(p driver.PullProgress) {
+			if p.Layer == "bbb" {
+				cancel()
+			}
+		},
+	})
+	var interrupted *driver.PullInterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected a PullInterruptedError, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the caller's cancellation to be reported, got %v", err)
+	}
+	if !reflect.DeepEqual(interrupted.CompletedLayers, []string{"aaa"}) {
+		t.Errorf("Expected layer aaa to be completed, got %v", interrupted.CompletedLayers)
+	}
+
+	var layers []string
+	_, err = c.ImagesPull("quay.io/skupper/router:1.0", driver.ImagePullOptions{
+		Progress: func(p driver.PullProgress) {
+			layers = append(layers, p.Layer)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range layers {
+		if layer == "aaa" {
+			t.Errorf("Expected the completed layer not to be reported again, got %v", layers)
+			break
+		}
+	}
+	if len(layers) == 0 {
+		t.Errorf("Expected progress for layer bbb")
+	}
+}
+
+func TestPullStalled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeDaemon(t, dir, "docker.sock")
+	f.pull = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"id":"aaa","status":"Pull complete"}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: f.host, APIVersion: "1.41"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.imagePullProgessDeadline = 200 * time.Millisecond
+
+	_, err = c.ImagesPull("quay.io/skupper/router:1.0", driver.ImagePullOptions{})
+	var interrupted *driver.PullInterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected a PullInterruptedError, got %v", err)
+	}
+	var stalled *driver.PullStalledError
+	if !errors.As(err, &stalled) || stalled.Timeout != c.imagePullProgessDeadline {
+		t.Errorf("Expected a PullStalledError after %v, got %v", c.imagePullProgessDeadline, err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a stall not to look like a cancellation, got %v", err)
+	}
+}
+
+func TestContainerListPages(t *testing.T) {
+	c := newTestClient(t)
+	if err := driver.PullForPolicy(c, testImage, driver.PullMissing); err != nil {
+		t.Fatal(err)
+	}
+	value := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	var created []string
+	for i := 0; i < 5; i++ {
+		res, err := c.ContainerCreate(driver.ContainerSpec{
+			Name:   fmt.Sprintf("ce-drivers-%s-%d", value, i),
+			Image:  testImage,
+			Labels: map[string]string{"ce-drivers-test": value},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+		created = append(created, res.ID)
+	}
+
+	var listed []string
+	options := driver.ContainerListOptions{Limit: 2, Filters: driver.LabelFilter("ce-drivers-test", value)}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages, got more: %v", listed)
+		}
+		page, err := c.ContainerList(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("Expected at most 2 containers per page, got %d", len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, container := range page {
+			listed = append(listed, container.ID)
+		}
+		options.Before = page[len(page)-1].ID
+	}
+	// newest first
+	for i, j := 0, len(created)-1; i < j; i, j = i+1, j-1 {
+		created[i], created[j] = created[j], created[i]
+	}
+	if !reflect.DeepEqual(listed, created) {
+		t.Errorf("Expected %v, got %v", created, listed)
+	}
+}
+
+func TestProgressSpeed(t *testing.T) {
+	p := newProgress()
+	msg := func(current int64) *dockermessage.JSONMessage {
+		return &dockermessage.JSONMessage{
+			ID:       "aaa",
+			Status:   "Downloading",
+			Progress: &dockermessage.JSONProgress{Current: current, Total: 100000},
+		}
+	}
+	if update := p.set(msg(1000)); update.SpeedBytesPerSec != 0 {
+		t.Errorf("Expected no speed for the first update, got %v", update.SpeedBytesPerSec)
+	}
+	time.Sleep(100 * time.Millisecond)
+	update := p.set(msg(11000))
+	// 10000 bytes in about 100ms, allowing for a slow scheduler
+	if update.SpeedBytesPerSec < 20000 || update.SpeedBytesPerSec > 110000 {
+		t.Errorf("Expected about 100000 bytes/s, got %v", update.SpeedBytesPerSec)
+	}
+	if other := p.set(&dockermessage.JSONMessage{ID: "bbb", Status: "Downloading", Progress: &dockermessage.JSONProgress{Current: 500}}); other.SpeedBytesPerSec != 0 {
+		t.Errorf("Expected layers to be timed separately, got %v", other.SpeedBytesPerSec)
+	}
+}
+
+func TestContainerStatPath(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	stat, err := c.ContainerStatPath(id, "/etc/nginx/nginx.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Name != "nginx.conf" || stat.Size == 0 || stat.Mode.IsDir() || stat.Mtime.IsZero() {
+		t.Errorf("Expected a non-empty regular file, got %+v", stat)
+	}
+	dir, err := c.ContainerStatPath(id, "/etc/nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dir.Mode.IsDir() {
+		t.Errorf("Expected /etc/nginx to be a directory, got %v", dir.Mode)
+	}
+	_, err = c.ContainerStatPath(id, "/etc/nginx/missing.conf")
+	if !errors.As(err, new(*driver.PathNotFoundError)) {
+		t.Errorf("Expected a PathNotFoundError, got %v", err)
+	}
+}
+
+// countingWriter counts the bytes written to it and keeps none.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestContainerExecStream(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	const size = 16 << 20
+	var stdout, stderr countingWriter
+	exitCode, err := c.ContainerExecStream(id, driver.ExecOptions{
+		Cmd: []string{"sh", "-c", fmt.Sprintf("head -c %d /dev/zero; echo oops >&2; exit 3", size)},
+	}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", exitCode)
+	}
+	if stdout.n != size {
+		t.Errorf("Expected %d bytes on stdout, got %d", size, stdout.n)
+	}
+	if stderr.n != int64(len("oops\n")) {
+		t.Errorf("Expected 5 bytes on stderr, got %d", stderr.n)
+	}
+}
+
+func TestContainerCreateOOMScoreAdj(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Resources: driver.Resources{OOMScoreAdj: 500, MemoryReservation: 64 << 20},
+	})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Resources.OOMScoreAdj != 500 || icd.Resources.MemoryReservation != 64<<20 {
+		t.Errorf("Expected OOM score adjustment 500 and a 64MiB reservation, got %+v", icd.Resources)
+	}
+	if got := execOutput(t, c, id, "cat", "/proc/1/oom_score_adj"); got != "500" {
+		t.Errorf("Expected oom_score_adj 500, got %s", got)
+	}
+}
+
+func TestContainerInspectResources(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Resources: driver.Resources{Memory: 256 << 20},
+	})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Resources.Memory != 256<<20 {
+		t.Errorf("Expected a 256MiB memory limit, got %+v", icd.Resources)
+	}
+}
+
+func TestPullCancelledByCaller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeDaemon(t, dir, "docker.sock")
+	f.pull = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"id":"aaa","status":"Downloading","progressDetail":{"current":10,"total":100}}`)
+		w.(http.Flusher).Flush()
+		// stall mid-stream until the client goes away
+		<-r.Context().Done()
+	}
+
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: f.host, APIVersion: "1.41"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	streaming := make(chan struct{})
+	var once sync.Once
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ImagesPull("quay.io/skupper/router:1.0", driver.ImagePullOptions{
+			Context: ctx,
+			Progress: func(p driver.PullProgress) {
+				once.Do(func() { close(streaming) })
+			},
+		})
+		done <- err
+	}()
+	<-streaming
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the pull to fail with context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the pull to return promptly once cancelled")
+	}
+
+	// the reporter and body watcher exit once the pull returns
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected no goroutines left behind by the pull, had %d, now %d", before, n)
+	}
+}
+
+func TestContainerCreatePlatform(t *testing.T) {
+	c := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		Platform:   "linux/arm64",
+		PullPolicy: driver.PullAlways,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+	icd, err := c.ContainerInspect(res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := c.ImageInspect(icd.Image, driver.ImageInspectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Architecture != "arm64" {
+		t.Errorf("Expected an arm64 container, got %s", image.Architecture)
+	}
+	if _, err := c.ImageInspect(icd.Image, driver.ImageInspectOptions{Platform: "linux/amd64"}); err == nil {
+		t.Errorf("Expected inspecting the arm64 image as linux/amd64 to fail")
+	}
+}
+
+func TestContainerCreateWarnings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeDaemon(t, dir, "docker.sock")
+	f.create = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"Id":"abc123","Warnings":["Your kernel does not support swap limit capabilities"]}`)
+	}
+
+	c := &dockerClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{Host: f.host, APIVersion: "1.41"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:      "router",
+		Image:     testImage,
+		Resources: driver.Resources{Memory: 256 << 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Your kernel does not support swap limit capabilities"}
+	if res.ID != "abc123" || !reflect.DeepEqual(res.Warnings, expected) {
+		t.Errorf("Expected container abc123 with warnings %v, got %+v", expected, res)
+	}
+}
+
+func TestContainerEvents(t *testing.T) {
+	c := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		PullPolicy: driver.PullMissing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	events, errs, err := c.ContainerEvents(ctx, res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStop(res.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var actions []string
+	for len(actions) == 0 || actions[len(actions)-1] != driver.EventDie {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("Events closed after %v: %v", actions, <-errs)
+			}
+			if ev.ID != res.ID {
+				t.Errorf("Expected events of %s only, got %+v", res.ID, ev)
+			}
+			actions = append(actions, ev.Action)
+		case <-ctx.Done():
+			t.Fatalf("Expected start and die events, got %v", actions)
+		}
+	}
+	if actions[0] != driver.EventStart {
+		t.Errorf("Expected start before die, got %v", actions)
+	}
+}
+
+func TestWaitForLogPattern(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Mounts: []driver.Mount{entrypointMount(t, "echo starting\nsleep 2\necho 'Router started'\nexec sleep 300")},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	line, err := driver.WaitForLogPattern(ctx, c, id, regexp.MustCompile(`Router started`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "Router started" {
+		t.Errorf("Expected the matching line, got %q", line)
+	}
+}
+
+func TestConnectThroughProxy(t *testing.T) {
+	var lock sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hosts = append(hosts, r.URL.Host)
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such image"}`)
+	}))
+	defer proxy.Close()
+
+	c := &dockerClient{}
+	err := c.New(context.Background(), driver.ConnectOptions{
+		Host:       "tcp://docker.example.com:2375",
+		APIVersion: "1.41",
+		Proxy:      driver.ProxyConfig{HTTPProxy: proxy.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if exists, err := c.ImageExists(testImage); err != nil || exists {
+		t.Fatalf("Expected the image to be missing, got %v, %v", exists, err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(hosts) == 0 || hosts[len(hosts)-1] != "docker.example.com:2375" {
+		t.Errorf("Expected the request to the daemon to go through the proxy, got %v", hosts)
+	}
+}
+
+func TestUnixSocketSkipsProxy(t *testing.T) {
+	var lock sync.Mutex
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		proxied = true
+		lock.Unlock()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+	dir, err := ioutil.TempDir("", "docker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeDaemon(t, dir, "docker.sock")
+
+	c := &dockerClient{}
+	err = c.New(context.Background(), driver.ConnectOptions{
+		Host:       f.host,
+		APIVersion: "1.41",
+		Proxy:      driver.ProxyConfig{HTTPProxy: proxy.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if exists, err := c.ImageExists(testImage); err != nil || exists {
+		t.Fatalf("Expected the image to be missing, got %v, %v", exists, err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if proxied || f.count() == 0 {
+		t.Errorf("Expected the request to reach the daemon's socket directly")
+	}
+}
+
+func TestDemuxStream(t *testing.T) {
+	var muxed bytes.Buffer
+	dockerstdcopy.NewStdWriter(&muxed, dockerstdcopy.Stdout).Write([]byte("out\n"))
+	dockerstdcopy.NewStdWriter(&muxed, dockerstdcopy.Stderr).Write([]byte("err\n"))
+
+	for _, tc := range []struct {
+		name     string
+		input    []byte
+		raw      bool
+		out, err string
+	}{
+		{"multiplexed", muxed.Bytes(), false, "out\n", "err\n"},
+		{"raw", []byte("Router started\r\nlistening\r\n"), true, "Router started\r\nlistening\r\n", ""},
+		{"empty", nil, false, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			raw, err := demuxStream(&stdout, &stderr, bytes.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if raw != tc.raw || stdout.String() != tc.out || stderr.String() != tc.err {
+				t.Errorf("Expected raw=%v, %q and %q, got raw=%v, %q and %q", tc.raw, tc.out, tc.err, raw, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestContainerLogPath(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	path, err := c.ContainerLogPath(id)
+	if errors.Is(err, driver.ErrNotSupported) {
+		t.Skipf("The engine's default log driver is not file based: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(path) {
+		t.Errorf("Expected an absolute log path, got %q", path)
+	}
+}
+
+func TestNetworkGateway(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	pool := driver.IPAMPool{Subnet: "10.212.43.0/24", Gateway: "10.212.43.1"}
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{IPAM: driver.IPAMConfig{Config: []driver.IPAMPool{pool}}}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	gateway, err := c.NetworkGateway(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway != pool.Gateway {
+		t.Errorf("Expected gateway %s, got %s", pool.Gateway, gateway)
+	}
+}
+
+func TestGenerateSystemdUnitNotSupported(t *testing.T) {
+	c := &dockerClient{}
+	if _, err := c.GenerateSystemdUnit("router", driver.SystemdOptions{}); !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected systemd units to be unsupported, got %v", err)
+	}
+}
+
+func TestContainerExecMaxOutputBytes(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	res, err := c.ContainerExecWithOptions(id, driver.ExecOptions{
+		Cmd:            []string{"sh", "-c", "head -c 1048576 /dev/zero"},
+		MaxOutputBytes: 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated {
+		t.Errorf("Expected the output to be marked truncated")
+	}
+	if n := res.OutBuffer.Len(); n != 1024 {
+		t.Errorf("Expected 1024 bytes to be kept, got %d", n)
+	}
+	if res.ExitCode != 0 {
+		t.Errorf("Expected the command to run to completion, got exit code %d", res.ExitCode)
+	}
+}
+
+func TestContainerCreateAutoAttachNetworks(t *testing.T) {
+	c := newTestClient(t)
+	c.autoAttach = true
+	network := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() { c.NetworkRemove(network, true) })
+	id := runTestContainer(t, c, driver.ContainerSpec{Labels: map[string]string{driver.NetworkLabel: network}})
+
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := icd.Networks[network]; !ok {
+		t.Errorf("Expected the container to be connected to %s, got %v", network, icd.Networks)
+	}
+}
+
+func TestContainerNetworkConfig(t *testing.T) {
+	c := newTestClient(t)
+	config := driver.NetworkConfig{
+		Hostname:   "router",
+		DomainName: "skupper.local",
+		DNS:        []string{"10.0.0.53"},
+		ExtraHosts: []string{"controller:10.0.0.7"},
+	}
+	id := runTestContainer(t, c, driver.ContainerSpec{NetworkConfig: config})
+
+	got, err := c.ContainerNetworkConfig(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("Expected %+v, got %+v", config, got)
+	}
+	if resolv := execOutput(t, c, id, "cat", "/etc/resolv.conf"); !strings.Contains(resolv, "nameserver 10.0.0.53") {
+		t.Errorf("Expected the nameserver in resolv.conf, got:\n%s", resolv)
+	}
+}
+
+func TestContainerCreateRuntime(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{Runtime: "runc"})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Runtime != "runc" {
+		t.Errorf("Expected runtime runc, got %q", icd.Runtime)
+	}
+
+	_, err = c.ContainerCreate(driver.ContainerSpec{
+		Name:    fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:   testImage,
+		Runtime: "no-such-runtime",
+	})
+	if err == nil || !strings.Contains(err.Error(), "Unknown runtime no-such-runtime") {
+		t.Errorf("Expected an unknown runtime error, got %v", err)
+	}
+}
+
+func TestSupportsFeature(t *testing.T) {
+	c := newTestClient(t)
+	for _, feature := range []driver.Feature{driver.SwarmSecrets, driver.CgroupV2, driver.BuildKit, driver.RootlessNetworking} {
+		if _, err := c.SupportsFeature(feature); err != nil {
+			t.Errorf("Expected %s to be probed, got %v", feature, err)
+		}
+	}
+	// the tests talk to a local engine, so it shares the host's cgroups
+	_, err := os.Stat("/sys/fs/cgroup/cgroup.controllers")
+	if cgroupV2, _ := c.SupportsFeature(driver.CgroupV2); cgroupV2 != (err == nil) {
+		t.Errorf("Expected cgroup v2 support to be %v, got %v", err == nil, cgroupV2)
+	}
+	if _, err := c.SupportsFeature(driver.Feature("teleport")); err == nil {
+		t.Errorf("Expected an unknown feature to fail")
+	}
+}
+
+func TestContainerStartPortConflict(t *testing.T) {
+	c := newTestClient(t)
+	assigned, err := driver.ParsePortSpec("0:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports, err := c.ContainerPorts(runTestContainer(t, c, driver.ContainerSpec{Ports: assigned}))
+	if err != nil || len(ports) == 0 {
+		t.Fatalf("Expected an assigned host port, got %+v, %v", ports, err)
+	}
+	taken, err := driver.ParsePortSpec(fmt.Sprintf("%d:80", ports[0].HostPort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		PullPolicy: driver.PullMissing,
+		Ports:      taken,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true}) })
+
+	err = c.ContainerStart(res.ID)
+	var conflict *driver.PortConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected a PortConflictError, got %v", err)
+	}
+	if !strings.HasSuffix(conflict.Port, fmt.Sprintf(":%d", ports[0].HostPort)) {
+		t.Errorf("Expected the conflict on port %d, got %s", ports[0].HostPort, conflict.Port)
+	}
+}
+
+func TestContainerLabelsAndAnnotations(t *testing.T) {
+	c := newTestClient(t)
+	labels := map[string]string{"application": "skupper"}
+	icd, err := c.ContainerInspect(runTestContainer(t, c, driver.ContainerSpec{Labels: labels}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Labels["application"] != "skupper" || len(icd.Annotations) != 0 {
+		t.Errorf("Expected the label and no annotations, got %v and %v", icd.Labels, icd.Annotations)
+	}
+
+	_, err = c.ContainerCreate(driver.ContainerSpec{
+		Name:        "router",
+		Image:       testImage,
+		Annotations: map[string]string{"io.skupper.site": "west"},
+	})
+	if !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected annotations to be unsupported, got %v", err)
+	}
+}
+
+func TestContainerCreateSharedPidNamespace(t *testing.T) {
+	c := newTestClient(t)
+	router := runTestContainer(t, c, driver.ContainerSpec{})
+	mode := driver.NamespaceModeContainer + router
+	sidecar := runTestContainer(t, c, driver.ContainerSpec{
+		PidMode: mode,
+		Mounts:  []driver.Mount{entrypointMount(t, "exec sleep 300")},
+	})
+
+	icd, err := c.ContainerInspect(sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.PidMode != mode {
+		t.Errorf("Expected pid mode %s, got %q", mode, icd.PidMode)
+	}
+	if ps := execOutput(t, c, sidecar, "ps", "-o", "args"); !strings.Contains(ps, "nginx: master process") {
+		t.Errorf("Expected the sidecar to see the router's processes, got:\n%s", ps)
+	}
+
+	_, err = c.ContainerCreate(driver.ContainerSpec{
+		Name:    fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:   testImage,
+		PidMode: "container:ce-drivers-missing",
+	})
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected a missing target to be reported, got %v", err)
+	}
+}
+
+func TestImagesDiskUsageSharedLayers(t *testing.T) {
+	c := newTestClient(t)
+	runTestContainer(t, c, driver.ContainerSpec{})
+
+	// build an image on top of testImage, sharing all of its layers
+	tag := fmt.Sprintf("ce-drivers-test:%d", time.Now().UnixNano())
+	var buildContext bytes.Buffer
+	tw := tar.NewWriter(&buildContext)
+	dockerfile := []byte("FROM " + testImage + "\nLABEL io.skupper.test=shared\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0644, Size: int64(len(dockerfile))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(dockerfile)
+	tw.Close()
+	client, release, err := c.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	resp, err := client.ImageBuild(context.Background(), &buildContext, dockertypes.ImageBuildOptions{Tags: []string{tag}, Remove: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	t.Cleanup(func() {
+		client, release, err := c.acquire()
+		if err != nil {
+			return
+		}
+		defer release()
+		client.ImageRemove(context.Background(), tag, dockertypes.ImageRemoveOptions{Force: true})
+	})
+
+	report, err := c.ImagesDiskUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size, exclusive int64
+	for _, image := range report.Images {
+		size += image.Size
+		exclusive += image.ExclusiveSize
+	}
+	if exclusive >= size {
+		t.Errorf("Expected shared layers to make the exclusive sizes %d smaller than the sizes %d", exclusive, size)
+	}
+	if report.TotalSize <= 0 || report.TotalSize >= size {
+		t.Errorf("Expected the total %d to count shared layers once, below %d", report.TotalSize, size)
+	}
+}
+
+func TestContainerExecDetached(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	start := time.Now()
+	execID, err := c.ContainerExecDetached(id, driver.ExecOptions{Cmd: []string{"sh", "-c", "sleep 2; exit 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the exec to be started without waiting for it, took %v", elapsed)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		ei, err := c.ExecInspect(execID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ei.Running {
+			if ei.ExitCode != 3 {
+				t.Errorf("Expected the exec to exit 3, got %d", ei.ExitCode)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the exec to complete, still running: %+v", ei)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestContainerInspectOperationError(t *testing.T) {
+	c := newTestClient(t)
+	_, err := c.ContainerInspect("ce-drivers-missing")
+	var opErr *driver.OperationError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("Expected an OperationError, got %v", err)
+	}
+	if opErr.Op != "ContainerInspect" || opErr.Resource != "ce-drivers-missing" {
+		t.Errorf("Expected the op and resource to be named, got %s on %s", opErr.Op, opErr.Resource)
+	}
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected the error to still match ErrNotFound, got %v", err)
+	}
+}
+
+func TestContainerRestartCount(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		RestartPolicy: driver.RestartPolicy{Name: "always"},
+		// crash shortly after each start
+		Mounts: []driver.Mount{entrypointMount(t, "sleep 1; exit 1")},
+	})
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		count, err := c.ContainerRestartCount(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the restart count to increment after the container crashed")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func TestEnsureNetworkOwner(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() { c.NetworkRemove(name, true) })
+
+	created, err := driver.EnsureNetwork(c, name, driver.NetworkCreateOptions{Owner: "site-west"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused, err := driver.EnsureNetwork(c, name, driver.NetworkCreateOptions{Owner: "site-west"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.ID != created.ID {
+		t.Errorf("Expected network %s to be reused, got %s", created.ID, reused.ID)
+	}
+	_, err = driver.EnsureNetwork(c, name, driver.NetworkCreateOptions{Owner: "site-east"})
+	if !errors.As(err, new(*driver.NetworkOwnedByOtherError)) {
+		t.Errorf("Expected a NetworkOwnedByOtherError, got %v", err)
+	}
+}
+
+func TestWatchPath(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.WatchPath(ctx, id, "/etc/nginx/nginx.conf", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execOutput(t, c, id, "sh", "-c", "echo '# reload' >> /etc/nginx/nginx.conf")
+	select {
+	case ev := <-events:
+		if ev.Op != driver.PathModified {
+			t.Errorf("Expected the file to be modified, got %+v", ev)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected a change event")
+	}
+	cancel()
+	for range events {
+	}
+}
+
+func TestContainerInspectEntrypointAndCmd(t *testing.T) {
+	c := newTestClient(t)
+	// specs cannot override the command, so expect testImage's own
+	icd, err := c.ContainerInspect(runTestContainer(t, c, driver.ContainerSpec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(icd.Entrypoint, []string{"/docker-entrypoint.sh"}) {
+		t.Errorf("Expected the image's entrypoint, got %q", icd.Entrypoint)
+	}
+	if !reflect.DeepEqual(icd.Cmd, []string{"nginx", "-g", "daemon off;"}) {
+		t.Errorf("Expected the image's cmd, got %q", icd.Cmd)
+	}
+}
+
+func TestContainerSummary(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	id := runTestContainer(t, c, driver.ContainerSpec{Name: name})
+	time.Sleep(time.Second)
+
+	summary, err := c.ContainerSummary(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != id || summary.Name != name || !summary.State.Running {
+		t.Errorf("Expected running container %s, got %+v", name, summary)
+	}
+	if summary.Uptime <= 0 || summary.RestartCount != 0 {
+		t.Errorf("Expected uptime and no restarts, got %v and %d", summary.Uptime, summary.RestartCount)
+	}
+	if summary.Stats.MemoryUsage == 0 || summary.Stats.PIDs == 0 {
+		t.Errorf("Expected a stats snapshot, got %+v", summary.Stats)
+	}
+	if summary.Health != "" {
+		t.Errorf("Expected no health without a healthcheck, got %q", summary.Health)
+	}
+}
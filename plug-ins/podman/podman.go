@@ -4,83 +4,434 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/containers/podman/v2/libpod/define"
 	"github.com/containers/podman/v2/pkg/api/handlers"
 	"github.com/containers/podman/v2/pkg/bindings"
 	"github.com/containers/podman/v2/pkg/bindings/containers"
+	"github.com/containers/podman/v2/pkg/bindings/generate"
 	"github.com/containers/podman/v2/pkg/bindings/images"
 	"github.com/containers/podman/v2/pkg/bindings/network"
+	"github.com/containers/podman/v2/pkg/bindings/system"
+	"github.com/containers/podman/v2/pkg/bindings/volumes"
 	"github.com/containers/podman/v2/pkg/domain/entities"
+	"github.com/containers/podman/v2/pkg/signal"
 	"github.com/containers/podman/v2/pkg/specgen"
 
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
 	"github.com/ajssmith/ce-drivers/driver"
 )
 
 type podmanClient struct {
+	// baseCtx derives from the caller supplied context and is cancelled
+	// by Close; the connection derives from it
+	baseCtx                  context.Context
+	cancel                   context.CancelFunc
 	ctx                      context.Context
+	socket                   string
 	timeout                  time.Duration
 	imagePullProgessDeadline time.Duration
+	pulls                    driver.Semaphore
+	autoAttach               bool
+
+	// reconnectLock serializes reconnection attempts and protects lastReconnect
+	// and socket; connLock protects ctx and reconnect, which calls read
+	reconnectLock sync.Mutex
+	connLock      sync.RWMutex
+	reconnect     driver.ReconnectPolicy
+	lastReconnect time.Time
 }
 
 var Driver podmanClient
 
-func (c *podmanClient) New() error {
+// NewDriver returns a driver of its own, for callers such as driver.Pool
+// that connect to several services. Driver is the shared one.
+func NewDriver() driver.Driver {
+	return &podmanClient{}
+}
+
+const defaultSocket = "unix:/run/podman/podman.sock"
+
+// New connects to the podman service. The bindings connection derives from
+// ctx, so cancelling it fails all subsequent calls.
+func (c *podmanClient) New(ctx context.Context, options driver.ConnectOptions) (err error) {
 	fmt.Println("Inside podman plugin new")
+	defer c.wrapErr(&err, "New", "")
+	if options.APIVersion != "" {
+		return fmt.Errorf("API version pinning: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
 
 	sock_dir := os.Getenv("XDG_RUNTIME_DIR")
 	fmt.Println("sock_dir: ", sock_dir)
 	//	socket := "unix:" + sock_dir + "/podman/podman.sock"
 	//	socket := "unix:/run/user/1000/podman/podman.sock"
-	socket := "unix:/run/podman/podman.sock"
+	socket := defaultSocket
+	if options.Host != "" {
+		socket = options.Host
+	}
+	if !options.Proxy.IsZero() && strings.HasPrefix(socket, "tcp://") {
+		return fmt.Errorf("Proxies: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
 
-	ctx, err := bindings.NewConnection(context.Background(), socket)
+	baseCtx, cancel := context.WithCancel(ctx)
+	conn, err := bindings.NewConnection(baseCtx, socket)
 	if err != nil {
+		cancel()
 		return fmt.Errorf("Coudnt's connect to docker: %w", err)
 	}
-	Driver.ctx = ctx
-	Driver.timeout = driver.DefaultTimeout
-	Driver.imagePullProgessDeadline = driver.DefaultImagePullingProgressReportInterval
+	c.baseCtx = baseCtx
+	c.cancel = cancel
+	c.setConn(conn, options.Reconnect)
+	c.socket = socket
+	c.timeout = driver.DefaultTimeout
+	c.imagePullProgessDeadline = driver.DefaultImagePullingProgressReportInterval
+	maxPulls := options.MaxConcurrentPulls
+	if maxPulls <= 0 {
+		maxPulls = driver.DefaultMaxConcurrentPulls
+	}
+	c.pulls = driver.NewSemaphore(maxPulls)
+	c.autoAttach = options.AutoAttachNetworks
 
 	return nil
 }
 
-func (c *podmanClient) ImageInspect(id string) (*driver.ImageInspect, error) {
+// Close cancels the driver's context, failing any operation in progress.
+// The bindings keep no connection open between requests.
+func (c *podmanClient) Close() (err error) {
+	fmt.Println("Inside podman plugin close")
+	defer c.wrapErr(&err, "Close", "")
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// conn returns the current bindings connection.
+func (c *podmanClient) conn() context.Context {
+	c.connLock.RLock()
+	defer c.connLock.RUnlock()
+	return c.ctx
+}
+
+// policy returns the current reconnect policy.
+func (c *podmanClient) policy() driver.ReconnectPolicy {
+	c.connLock.RLock()
+	defer c.connLock.RUnlock()
+	return c.reconnect
+}
+
+// setConn replaces the bindings connection and the reconnect policy.
+func (c *podmanClient) setConn(conn context.Context, policy driver.ReconnectPolicy) {
+	if policy.MinInterval == 0 {
+		policy.MinInterval = driver.DefaultReconnectInterval
+	}
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	c.ctx = conn
+	c.reconnect = policy
+}
+
+// isConnectionError reports whether err indicates the bindings connection
+// to the podman service has been lost.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// the service closed the connection before responding
+	var uerr *url.Error
+	if errors.As(err, &uerr) && errors.Is(uerr.Err, io.EOF) {
+		return true
+	}
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// reconnectOnce re-establishes the bindings connection unless another
+// attempt was made within the policy's MinInterval.
+func (c *podmanClient) reconnectOnce() error {
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+
+	if err := c.baseCtx.Err(); err != nil {
+		return err
+	}
+	policy := c.policy()
+	if time.Since(c.lastReconnect) < policy.MinInterval {
+		return fmt.Errorf("Reconnect attempted less than %v ago", policy.MinInterval)
+	}
+	c.lastReconnect = time.Now()
+
+	ctx, err := bindings.NewConnection(c.baseCtx, c.socket)
+	if err != nil {
+		return fmt.Errorf("Couldn't reconnect to podman: %w", err)
+	}
+	c.setConn(ctx, policy)
+	return nil
+}
+
+// Reconnect replaces the bindings connection with one to the socket in
+// options and adopts its reconnect policy. Timeouts and the pull limit set
+// by New are kept. Calls already in flight finish, or fail, on the old
+// connection.
+func (c *podmanClient) Reconnect(options driver.ConnectOptions) (err error) {
+	fmt.Println("Inside podman plugin reconnect")
+	defer c.wrapErr(&err, "Reconnect", "")
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+
+	if c.baseCtx == nil {
+		return fmt.Errorf("Driver is not connected, call New first")
+	}
+	if options.APIVersion != "" {
+		return fmt.Errorf("API version pinning: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
+	if err := c.baseCtx.Err(); err != nil {
+		return err
+	}
+	socket := defaultSocket
+	if options.Host != "" {
+		socket = options.Host
+	}
+	if !options.Proxy.IsZero() && strings.HasPrefix(socket, "tcp://") {
+		return fmt.Errorf("Proxies: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
+	conn, err := bindings.NewConnection(c.baseCtx, socket)
+	if err != nil {
+		return fmt.Errorf("Couldn't reconnect to podman: %w", err)
+	}
+	c.setConn(conn, options.Reconnect)
+	c.socket = socket
+	c.lastReconnect = time.Now()
+	return nil
+}
+
+// withReconnect runs fn and, when it fails because the connection dropped
+// and the reconnect policy allows it, reconnects and runs fn a second time.
+// fn must be safe to repeat, as the service may have run the first attempt.
+// The bindings don't pass the base context on to their requests, so its
+// cancellation is checked here.
+func (c *podmanClient) withReconnect(fn func() error) error {
+	if err := c.baseCtx.Err(); err != nil {
+		return err
+	}
+	err := fn()
+	if !c.policy().Enabled || !isConnectionError(err) {
+		return err
+	}
+	if rerr := c.reconnectOnce(); rerr != nil {
+		return fmt.Errorf("%w (reconnect failed: %v)", err, rerr)
+	}
+	return fn()
+}
+
+// reconnectAfter runs fn once, for calls that change the service's state.
+// When fn fails because the connection dropped, the connection is
+// re-established for later calls and fn's error is returned, as the
+// service may have acted on the request before the connection dropped.
+func (c *podmanClient) reconnectAfter(fn func() error) error {
+	if err := c.baseCtx.Err(); err != nil {
+		return err
+	}
+	err := fn()
+	if !c.policy().Enabled || !isConnectionError(err) {
+		return err
+	}
+	if rerr := c.reconnectOnce(); rerr != nil {
+		return fmt.Errorf("%w (reconnect failed: %v)", err, rerr)
+	}
+	return err
+}
+
+// wrapErr names the failed operation and its resource in *err. The
+// bindings report a missing resource as a 404 error model.
+func (c *podmanClient) wrapErr(err *error, op string, resource string) {
+	var model entities.ErrorModel
+	notFound := errors.As(*err, &model) && model.ResponseCode == http.StatusNotFound
+	*err = driver.WrapOperationError("podman", op, resource, *err, notFound)
+}
+
+// SupportsFeature probes the service's info for features that depend on
+// its configuration. Podman v2 has neither secrets nor BuildKit.
+func (c *podmanClient) SupportsFeature(feature driver.Feature) (_ bool, err error) {
+	fmt.Println("Inside podman supports feature: ", feature)
+	defer c.wrapErr(&err, "SupportsFeature", "")
+	var info *define.Info
+	err = c.withReconnect(func() (err error) {
+		info, err = system.Info(c.conn())
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	switch feature {
+	case driver.SwarmSecrets, driver.BuildKit:
+		return false, nil
+	case driver.CgroupV2:
+		return info.Host.CGroupsVersion == "v2", nil
+	case driver.RootlessNetworking:
+		return info.Host.Rootless, nil
+	}
+	return false, fmt.Errorf("Unknown feature %s", feature)
+}
+
+func (c *podmanClient) ImageInspect(id string, options driver.ImageInspectOptions) (_ *driver.ImageInspect, err error) {
 	fmt.Println("In podman inspect image")
+	defer c.wrapErr(&err, "ImageInspect", id)
 
-	data, err := images.GetImage(c.ctx, id, nil)
+	var data *entities.ImageInspectReport
+	err = c.withReconnect(func() (err error) {
+		data, err = images.GetImage(c.conn(), id, nil)
+		return err
+	})
 	if err != nil {
 		return &driver.ImageInspect{}, err
 	}
 	image := &driver.ImageInspect{
-		ID:       data.ID,
-		Size:     data.Size,
-		RepoTags: data.RepoTags,
+		ID:           data.ID,
+		Size:         data.Size,
+		RepoTags:     data.RepoTags,
+		RepoDigests:  data.RepoDigests,
+		Labels:       data.Labels,
+		Os:           data.Os,
+		Architecture: data.Architecture,
+	}
+	if options.Platform != "" {
+		if err := driver.CheckPlatform(image, options.Platform); err != nil {
+			return nil, err
+		}
 	}
 	return image, nil
 }
 
-func (c *podmanClient) ImagesPull(refStr string, options driver.ImagePullOptions) ([]string, error) {
-	strSlice, err := images.Pull(c.ctx, refStr, entities.ImagePullOptions{})
+func (c *podmanClient) ImageExists(ref string) (_ bool, err error) {
+	fmt.Println("In podman image exists")
+	defer c.wrapErr(&err, "ImageExists", ref)
+	var exists bool
+	err = c.withReconnect(func() (err error) {
+		exists, err = images.Exists(c.conn(), ref)
+		return err
+	})
+	return exists, err
+}
+
+// ImageTag adds dst as a reference to the image src.
+func (c *podmanClient) ImageTag(src string, dst string) (err error) {
+	fmt.Println("In podman image tag")
+	defer c.wrapErr(&err, "ImageTag", src)
+	repo, tag, err := driver.SplitTag(dst)
+	if err != nil {
+		return err
+	}
+	return c.reconnectAfter(func() error {
+		return images.Tag(c.conn(), src, tag, repo)
+	})
+}
+
+// ImageWait blocks until ref is present locally, e.g. pulled by another
+// process, or ctx is done.
+func (c *podmanClient) ImageWait(ctx context.Context, ref string, interval time.Duration) (err error) {
+	defer c.wrapErr(&err, "ImageWait", ref)
+	return driver.WaitForImage(ctx, c, ref, interval)
+}
+
+// ImageSave returns a docker-archive tarball of the images in refs.
+func (c *podmanClient) ImageSave(ctx context.Context, refs []string) (_ io.ReadCloser, err error) {
+	fmt.Println("In podman image save")
+	defer c.wrapErr(&err, "ImageSave", "")
+	ctx, cancel := driver.LinkContext(c.conn(), ctx)
+	format := "docker-archive"
+	pr, pw := io.Pipe()
+	go func() {
+		err := images.MultiExport(ctx, refs, pw, &format, nil)
+		pw.CloseWithError(err)
+	}()
+	return &cancelReadCloser{ReadCloser: pr, cancel: cancel}, nil
+}
+
+// ImageLoad loads an image archive and returns the names of the images it
+// held.
+func (c *podmanClient) ImageLoad(ctx context.Context, r io.Reader) (_ []string, err error) {
+	fmt.Println("In podman image load")
+	defer c.wrapErr(&err, "ImageLoad", "")
+	ctx, cancel := driver.LinkContext(c.conn(), ctx)
+	defer cancel()
+	report, err := images.Load(ctx, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return report.Names, nil
+}
+
+// cancelReadCloser cancels the request it reads from when closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	r.cancel()
+	return r.ReadCloser.Close()
+}
+
+func (c *podmanClient) ImagesPull(refStr string, options driver.ImagePullOptions) (_ []string, err error) {
+	defer c.wrapErr(&err, "ImagesPull", refStr)
+	ctx, cancel := driver.LinkContext(c.baseCtx, options.Context)
+	defer cancel()
+	if err := c.pulls.Acquire(ctx); err != nil {
+		return nil, driver.ContextErr(options.Context, ctx)
+	}
+	defer c.pulls.Release()
+	var strSlice []string
+	err = c.reconnectAfter(func() (err error) {
+		// the bindings keep the client in the context's values, so derive from it
+		pullCtx, cancel := driver.LinkContext(c.conn(), ctx)
+		defer cancel()
+		strSlice, err = images.Pull(pullCtx, refStr, entities.ImagePullOptions{})
+		return err
+	})
+	if cerr := driver.ContextErr(options.Context, ctx); err != nil && cerr != nil {
+		// the podman bindings report no layer progress to resume from
+		return nil, &driver.PullInterruptedError{Ref: refStr, Err: cerr}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Could not pull image: %w", err)
 	}
 	return strSlice, nil
 }
 
-func (c *podmanClient) ImagesList(options driver.ImageListOptions) ([]driver.ImageSummary, error) {
+func (c *podmanClient) ImagesList(options driver.ImageListOptions) (_ []driver.ImageSummary, err error) {
 	fmt.Println("In podman list images")
+	defer c.wrapErr(&err, "ImagesList", "")
 
-	images, err := images.List(c.ctx, nil, nil)
+	var list []*entities.ImageSummary
+	err = c.withReconnect(func() (err error) {
+		list, err = images.List(c.conn(), nil, nil)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 	var summary []driver.ImageSummary
-	for _, image := range images {
+	for _, image := range list {
 		summary = append(summary, driver.ImageSummary{
 			ID:       image.ID,
 			Created:  image.Created,
@@ -92,36 +443,333 @@ func (c *podmanClient) ImagesList(options driver.ImageListOptions) ([]driver.Ima
 	return summary, nil
 }
 
-func (c *podmanClient) ContainerCreate(image string) (driver.ContainerCreateResponse, error) {
+// ImagesDiskUsage sizes the local images from the service's disk usage
+// report. Podman does not report the combined size of all layers, so
+// TotalSize adds the largest shared size to the exclusive sizes, which
+// undercounts when images share different layers.
+func (c *podmanClient) ImagesDiskUsage() (_ driver.ImagesDiskReport, err error) {
+	fmt.Println("In podman images disk usage")
+	defer c.wrapErr(&err, "ImagesDiskUsage", "")
+	var df *entities.SystemDfReport
+	err = c.withReconnect(func() (err error) {
+		df, err = system.DiskUsage(c.conn())
+		return err
+	})
+	if err != nil {
+		return driver.ImagesDiskReport{}, err
+	}
+	var report driver.ImagesDiskReport
+	var maxShared int64
+	for _, image := range df.Images {
+		usage := driver.ImageDiskUsage{
+			ID:            image.ImageID,
+			Size:          image.Size,
+			SharedSize:    image.SharedSize,
+			ExclusiveSize: image.UniqueSize,
+		}
+		if image.Repository != "<none>" {
+			usage.RepoTags = []string{image.Repository + ":" + image.Tag}
+		}
+		report.Images = append(report.Images, usage)
+		report.TotalSize += image.UniqueSize
+		if image.SharedSize > maxShared {
+			maxShared = image.SharedSize
+		}
+	}
+	report.TotalSize += maxShared
+	return report, nil
+}
+
+// portMappings converts the driver port mappings into specgen port
+// mappings. Podman cannot allocate a host port from a range for a single
+// container port.
+func portMappings(ports []driver.PortMapping) ([]specgen.PortMapping, error) {
+	var pms []specgen.PortMapping
+	for _, p := range ports {
+		if p.HostPortEnd != 0 {
+			return nil, fmt.Errorf("Host port range %d-%d is not supported by podman", p.HostPort, p.HostPortEnd)
+		}
+		pms = append(pms, specgen.PortMapping{
+			HostIP:        p.HostIP,
+			HostPort:      p.HostPort,
+			ContainerPort: p.ContainerPort,
+			Protocol:      p.Protocol,
+		})
+	}
+	return pms, nil
+}
+
+func (c *podmanClient) ContainerCreate(spec driver.ContainerSpec) (_ driver.ContainerCreateResponse, err error) {
 	fmt.Println("Inside podman container create")
-	s := specgen.NewSpecGenerator(image, false)
-	r, err := containers.CreateWithSpec(c.ctx, s)
+	defer c.wrapErr(&err, "ContainerCreate", spec.Name)
+	if err := spec.Validate(); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if err := driver.PullForPolicy(c, spec.Image, spec.PullPolicy); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if err := driver.VerifyDigest(c, spec.Image); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if spec.Platform != "" {
+		// podman runs whichever variant is stored locally, so only
+		// check that it is the requested one
+		if _, err := c.ImageInspect(spec.Image, driver.ImageInspectOptions{Platform: spec.Platform}); err != nil {
+			return driver.ContainerCreateResponse{}, err
+		}
+	}
+	if len(spec.Secrets) > 0 {
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Secret mounts: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
+	if len(spec.DeviceRequests) > 0 {
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Device requests: %w by podman", driver.ErrNotSupported)
+	}
+	if spec.NetworkConfig.DomainName != "" {
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Domain names: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
+	if err := driver.CheckNamespaceTargets(c, spec); err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	env, err := spec.ResolveEnv()
 	if err != nil {
 		return driver.ContainerCreateResponse{}, err
 	}
+	s := specgen.NewSpecGenerator(spec.Image, false)
+	s.Name = spec.Name
+	s.Env = envMap(env)
+	s.Labels = spec.EngineLabels()
+	s.Annotations = spec.Annotations
+	s.User = spec.User
+	s.WorkDir = spec.WorkingDir
+	s.OCIRuntime = spec.Runtime
+	s.Hostname = spec.NetworkConfig.Hostname
+	for _, server := range spec.NetworkConfig.DNS {
+		// Validate has checked that each server is an ip address
+		s.DNSServers = append(s.DNSServers, net.ParseIP(server))
+	}
+	s.HostAdd = spec.NetworkConfig.ExtraHosts
+	if spec.StopSignal != "" {
+		sig, err := signal.ParseSignalNameOrNumber(spec.StopSignal)
+		if err != nil {
+			return driver.ContainerCreateResponse{}, fmt.Errorf("Invalid stop signal %s: %w", spec.StopSignal, err)
+		}
+		s.StopSignal = &sig
+	}
+	if spec.StopTimeout != nil {
+		timeout := uint(*spec.StopTimeout)
+		s.StopTimeout = &timeout
+	}
+	s.Groups = spec.GroupAdd
+	for _, m := range spec.Mounts {
+		options := []string{"rw"}
+		if m.ReadOnly {
+			options = []string{"ro"}
+		}
+		if m.Type == driver.TypeVolume {
+			s.Volumes = append(s.Volumes, &specgen.NamedVolume{
+				Name:    m.Source,
+				Dest:    m.Target,
+				Options: options,
+			})
+			continue
+		}
+		s.Mounts = append(s.Mounts, specs.Mount{
+			Type:        string(m.Type),
+			Source:      m.Source,
+			Destination: m.Target,
+			Options:     options,
+		})
+	}
+	for target, options := range spec.Tmpfs {
+		m := specs.Mount{
+			Type:        "tmpfs",
+			Source:      "tmpfs",
+			Destination: target,
+		}
+		if options != "" {
+			m.Options = strings.Split(options, ",")
+		}
+		s.Mounts = append(s.Mounts, m)
+	}
+	s.RestartPolicy = spec.RestartPolicy.Name
+	if spec.RestartPolicy.MaximumRetryCount > 0 {
+		retries := uint(spec.RestartPolicy.MaximumRetryCount)
+		s.RestartRetries = &retries
+	}
+	pms, err := portMappings(spec.Ports)
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	s.PortMappings = pms
+	shmSize := spec.ShmSize
+	if shmSize == 0 {
+		shmSize = driver.DefaultShmSize
+	}
+	s.ShmSize = &shmSize
+	s.ResourceLimits = resourceLimits(spec.Resources)
+	if spec.Resources.OOMScoreAdj != 0 {
+		oomScoreAdj := spec.Resources.OOMScoreAdj
+		s.OOMScoreAdj = &oomScoreAdj
+	}
+	s.Init = spec.Init
+	s.CgroupParent = spec.CgroupParent
+	namespaces := []struct {
+		mode string
+		ns   *specgen.Namespace
+	}{
+		{spec.PidMode, &s.PidNS},
+		{spec.IpcMode, &s.IpcNS},
+		{spec.UTSMode, &s.UtsNS},
+	}
+	for _, n := range namespaces {
+		if n.mode == "" {
+			continue
+		}
+		if *n.ns, err = specgen.ParseNamespace(n.mode); err != nil {
+			return driver.ContainerCreateResponse{}, err
+		}
+	}
+	if spec.CgroupnsMode != "" {
+		s.CgroupNS = specgen.Namespace{NSMode: specgen.NamespaceMode(spec.CgroupnsMode)}
+	}
+	for _, d := range spec.Devices {
+		// specgen parses the device path in the cli's src:dst:perms form
+		device := d.PathOnHost
+		if d.PathInContainer != "" {
+			device += ":" + d.PathInContainer
+			if d.CgroupPermissions != "" {
+				device += ":" + d.CgroupPermissions
+			}
+		}
+		s.Devices = append(s.Devices, specs.LinuxDevice{Path: device})
+	}
+	for _, u := range spec.Ulimits {
+		s.Rlimits = append(s.Rlimits, specs.POSIXRlimit{
+			Type: "RLIMIT_" + strings.ToUpper(u.Name),
+			Soft: uint64(u.Soft),
+			Hard: uint64(u.Hard),
+		})
+	}
+	var r entities.ContainerCreateResponse
+	err = c.reconnectAfter(func() (err error) {
+		r, err = containers.CreateWithSpec(c.conn(), s)
+		return err
+	})
+	if err != nil && spec.ReuseExisting && driver.IsNameConflict(err) {
+		return driver.ReuseExisting(c, spec)
+	}
+	if err != nil && spec.Runtime != "" && strings.Contains(err.Error(), "OCI runtime") {
+		return driver.ContainerCreateResponse{}, fmt.Errorf("Unknown runtime %s: %w", spec.Runtime, err)
+	}
+	if err != nil {
+		return driver.ContainerCreateResponse{}, err
+	}
+	if c.autoAttach {
+		if err := driver.AttachLabeledNetworks(c, r.ID, spec.Labels); err != nil {
+			c.ContainerRemove(r.ID, driver.RemoveOptions{Force: true})
+			return driver.ContainerCreateResponse{}, err
+		}
+	}
 
-	return driver.ContainerCreateResponse{ID: r.ID}, nil
+	return driver.ContainerCreateResponse{ID: r.ID, Warnings: r.Warnings}, nil
+}
+
+// resourceLimits converts the driver resources into OCI resource limits,
+// or nil when none are set.
+func resourceLimits(r driver.Resources) *specs.LinuxResources {
+	if r.Memory == 0 && r.MemoryReservation == 0 && r.NanoCPUs == 0 && r.CPUShares == 0 {
+		return nil
+	}
+	limits := &specs.LinuxResources{}
+	if r.Memory != 0 || r.MemoryReservation != 0 {
+		limits.Memory = &specs.LinuxMemory{}
+		if r.Memory != 0 {
+			limits.Memory.Limit = &r.Memory
+		}
+		if r.MemoryReservation != 0 {
+			limits.Memory.Reservation = &r.MemoryReservation
+		}
+	}
+	if r.NanoCPUs != 0 || r.CPUShares != 0 {
+		limits.CPU = &specs.LinuxCPU{}
+		if r.NanoCPUs != 0 {
+			// same conversion as the podman cli's --cpus
+			period := uint64(100000)
+			quota := r.NanoCPUs * int64(period) / 1e9
+			limits.CPU.Period = &period
+			limits.CPU.Quota = &quota
+		}
+		if r.CPUShares != 0 {
+			shares := uint64(r.CPUShares)
+			limits.CPU.Shares = &shares
+		}
+	}
+	return limits
+}
+
+// envMap converts KEY=VALUE pairs into the map specgen expects.
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		} else {
+			m[kv[0]] = ""
+		}
+	}
+	return m
 }
 
-func (c *podmanClient) ContainerStart(id string) error {
+func (c *podmanClient) ContainerStart(id string) (err error) {
 	fmt.Println("Inside podman start container")
-	err := containers.Start(c.ctx, id, nil)
-	return err
+	defer c.wrapErr(&err, "ContainerStart", id)
+	err = c.reconnectAfter(func() error {
+		return containers.Start(c.conn(), id, nil)
+	})
+	return driver.StartFailure(id, err)
 }
 
-func (c *podmanClient) ContainerWait(id string, status string, timeout time.Duration, interval time.Duration) error {
+func (c *podmanClient) ContainerWait(id string, status string, timeout time.Duration, interval time.Duration) (err error) {
 	fmt.Println("Inside podman container wait")
+	defer c.wrapErr(&err, "ContainerWait", id)
 	// TODO: Should we have retry with context here?
 	waitState := define.ContainerStateRunning
-	_, err := containers.Wait(c.ctx, id, &waitState)
-	return err
+	return c.withReconnect(func() error {
+		_, err := containers.Wait(c.conn(), id, &waitState)
+		return err
+	})
 }
 
-func (c *podmanClient) ContainerList(driver.ContainerListOptions) ([]driver.Container, error) {
+// ContainerWaitWithOptions polls the container until it reaches opts.State.
+func (c *podmanClient) ContainerWaitWithOptions(id string, opts driver.WaitOptions) (err error) {
+	fmt.Println("Inside podman container wait with options")
+	defer c.wrapErr(&err, "ContainerWaitWithOptions", id)
+	return driver.WaitForState(c.baseCtx, c, id, opts)
+}
+
+func (c *podmanClient) ContainerList(options driver.ContainerListOptions) (_ []driver.Container, err error) {
 	fmt.Println("Inside podman container list")
-	// TODO convert options
-	var latestContainers = 1
-	cl, err := containers.List(c.ctx, nil, nil, &latestContainers, nil, nil, nil)
+	defer c.wrapErr(&err, "ContainerList", "")
+	filters := map[string][]string{}
+	for key, values := range options.Filters {
+		filters[key] = values
+	}
+	if options.Since != "" {
+		filters["since"] = []string{options.Since}
+	}
+	if options.Before != "" {
+		filters["before"] = []string{options.Before}
+	}
+	var last *int
+	if options.Limit > 0 {
+		last = &options.Limit
+	}
+	var cl []entities.ListContainer
+	err = c.withReconnect(func() (err error) {
+		cl, err = containers.List(c.conn(), filters, &options.All, last, nil, nil, nil)
+		return err
+	})
 	var dc []driver.Container
 	for _, container := range cl {
 		// TODO all fields
@@ -138,60 +786,715 @@ func (c *podmanClient) ContainerList(driver.ContainerListOptions) ([]driver.Cont
 			//Mounts:  container.Mounts,
 		})
 	}
+	if err == nil && options.Inspect {
+		err = driver.BackfillContainers(dc, c.ContainerInspect)
+	}
 	return dc, err
 }
 
-func (c *podmanClient) ContainerInspect(id string) (*driver.InspectContainerData, error) {
+func (c *podmanClient) ContainerInspect(id string) (_ *driver.InspectContainerData, err error) {
 	fmt.Println("Inside podman container inspect")
-	cd, err := containers.Inspect(c.ctx, id, nil)
+	defer c.wrapErr(&err, "ContainerInspect", id)
+	var cd *inspectContainerData
+	err = c.withReconnect(func() (err error) {
+		cd, err = inspectContainer(c.conn(), id)
+		return err
+	})
 	if err != nil {
 		return &driver.InspectContainerData{}, err
 	}
 	icd := &driver.InspectContainerData{
-		ID:      cd.ID,
-		Created: cd.Created,
-		Path:    cd.Path,
-		Args:    cd.Args,
-		//		State: cd.State,
-		Image:     cd.Image,
-		ImageName: cd.ImageName,
-		Name:      cd.Name,
-		//		Mounts: cd.Mounts,
+		ID:           cd.ID,
+		Created:      cd.Created,
+		Path:         cd.Path,
+		Args:         cd.Args,
+		Image:        cd.Image,
+		ImageName:    cd.ImageName,
+		Name:         cd.Name,
+		Mounts:       convertMounts(cd.Mounts),
+		Runtime:      cd.OCIRuntime,
+		RestartCount: int(cd.RestartCount),
+	}
+	if cd.State != nil {
+		icd.State = convertState(cd.State)
+	}
+	if cd.Config != nil {
+		icd.Env = cd.Config.Env
+		icd.Labels = cd.Config.Labels
+		// podman v2 reports the entrypoint joined by spaces
+		icd.Entrypoint = strings.Fields(cd.Config.Entrypoint)
+		icd.Cmd = cd.Config.Cmd
+		if cd.Config.StopSignal != 0 {
+			icd.StopSignal = strconv.Itoa(int(cd.Config.StopSignal))
+		}
+		if cd.Config.StopTimeout != nil {
+			stopTimeout := int(*cd.Config.StopTimeout)
+			icd.StopTimeout = &stopTimeout
+		}
+		icd.Annotations = cd.Config.Annotations
+		icd.NetworkConfig.Hostname = cd.Config.Hostname
+		icd.NetworkConfig.DomainName = cd.Config.DomainName
+	}
+	if cd.HostConfig != nil {
+		icd.Resources = driver.Resources{
+			Memory:            cd.HostConfig.Memory,
+			MemoryReservation: cd.HostConfig.MemoryReservation,
+			NanoCPUs:          cd.HostConfig.NanoCpus,
+			CPUShares:         int64(cd.HostConfig.CpuShares),
+			OOMScoreAdj:       cd.HostConfig.OomScoreAdj,
+		}
+		icd.PidMode = cd.HostConfig.PidMode
+		icd.IpcMode = cd.HostConfig.IpcMode
+		icd.UTSMode = cd.HostConfig.UTSMode
+		icd.CgroupParent = cd.HostConfig.CgroupParent
+		icd.CgroupnsMode = cd.HostConfig.CgroupMode
+		icd.NetworkConfig.DNS = cd.HostConfig.Dns
+		icd.NetworkConfig.ExtraHosts = cd.HostConfig.ExtraHosts
+		if rp := cd.HostConfig.RestartPolicy; rp != nil {
+			icd.RestartPolicy = driver.RestartPolicy{
+				Name:              rp.Name,
+				MaximumRetryCount: int(rp.MaximumRetryCount),
+			}
+		}
+		for key, bindings := range cd.HostConfig.PortBindings {
+			for _, binding := range bindings {
+				port, err := driver.ParsePortBinding(key, binding.HostIP, binding.HostPort)
+				if err != nil {
+					return nil, err
+				}
+				icd.PortBindings = append(icd.PortBindings, port)
+			}
+		}
+	}
+	if cd.NetworkSettings != nil {
+		icd.Networks = make(map[string]driver.EndpointResource)
+		for name, n := range cd.NetworkSettings.Networks {
+			if n != nil {
+				icd.Networks[name] = convertEndpoint(cd.Name, n)
+			}
+		}
 	}
 	return icd, err
 }
 
-func (c *podmanClient) ContainerStop(id string) error {
+// ContainerImageDigest returns the digest of the image the container runs.
+func (c *podmanClient) ContainerImageDigest(id string) (_ string, err error) {
+	fmt.Println("Inside podman container image digest: ", id)
+	defer c.wrapErr(&err, "ContainerImageDigest", id)
+	return driver.ContainerImageDigest(c, id)
+}
+
+// ImageUpToDate reports whether the container runs the image ref points
+// to locally; the podman v2 bindings cannot query the registry without pulling.
+func (c *podmanClient) ImageUpToDate(id string, ref string) (_ bool, err error) {
+	fmt.Println("Inside podman image up to date: ", id)
+	defer c.wrapErr(&err, "ImageUpToDate", id)
+	return driver.ImageUpToDate(c, id, ref)
+}
+
+// ResolveContainer returns the full ID of the container with the given
+// name or unique ID prefix.
+func (c *podmanClient) ResolveContainer(nameOrPrefix string) (_ string, err error) {
+	fmt.Println("Inside podman resolve container: ", nameOrPrefix)
+	defer c.wrapErr(&err, "ResolveContainer", nameOrPrefix)
+	return driver.ResolveContainer(c, nameOrPrefix)
+}
+
+func (c *podmanClient) ContainerPorts(id string) (_ []driver.Port, err error) {
+	fmt.Println("Inside podman container ports")
+	defer c.wrapErr(&err, "ContainerPorts", id)
+	var cd *inspectContainerData
+	err = c.withReconnect(func() (err error) {
+		cd, err = inspectContainer(c.conn(), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ports []driver.Port
+	if cd.NetworkSettings == nil {
+		return ports, nil
+	}
+	for key, bindings := range cd.NetworkSettings.Ports {
+		for _, binding := range bindings {
+			port, err := driver.ParsePortBinding(key, binding.HostIP, binding.HostPort)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// ContainerNetworkConfig returns the container's effective hostname and
+// DNS settings.
+func (c *podmanClient) ContainerNetworkConfig(id string) (_ driver.NetworkConfig, err error) {
+	fmt.Println("Inside podman container network config: ", id)
+	defer c.wrapErr(&err, "ContainerNetworkConfig", id)
+	return driver.ContainerNetworkConfig(c, id)
+}
+
+// fileMode converts a raw st_mode into an os.FileMode.
+func fileMode(mode uint32) os.FileMode {
+	fm := os.FileMode(mode & 0777)
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		fm |= os.ModeDir
+	case syscall.S_IFLNK:
+		fm |= os.ModeSymlink
+	case syscall.S_IFCHR:
+		fm |= os.ModeDevice | os.ModeCharDevice
+	case syscall.S_IFBLK:
+		fm |= os.ModeDevice
+	case syscall.S_IFIFO:
+		fm |= os.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		fm |= os.ModeSocket
+	}
+	if mode&syscall.S_ISUID != 0 {
+		fm |= os.ModeSetuid
+	}
+	if mode&syscall.S_ISGID != 0 {
+		fm |= os.ModeSetgid
+	}
+	if mode&syscall.S_ISVTX != 0 {
+		fm |= os.ModeSticky
+	}
+	return fm
+}
+
+// ContainerStatPath stats path by exec'ing stat in the container, since the
+// podman v2 bindings have no archive stat call. The container must be
+// running and ship stat and readlink.
+func (c *podmanClient) ContainerStatPath(id string, path string) (_ driver.PathStat, err error) {
+	fmt.Println("Inside podman container stat path")
+	defer c.wrapErr(&err, "ContainerStatPath", id)
+	res, err := c.ContainerExecWithOptions(id, driver.ExecOptions{Cmd: []string{"stat", "-c", "%s %f %Y", "--", path}})
+	if err != nil {
+		return driver.PathStat{}, err
+	}
+	switch res.ExitCode {
+	case 0:
+	case 126, 127:
+		return driver.PathStat{}, fmt.Errorf("Couldn't stat %s in container %s: stat is not available", path, id)
+	default:
+		// without a reason on stderr, a failed stat is taken to mean the
+		// path is missing
+		stderr := res.Stderr()
+		if stderr == "" || strings.Contains(stderr, "No such file or directory") {
+			return driver.PathStat{}, &driver.PathNotFoundError{ID: id, Path: path}
+		}
+		return driver.PathStat{}, fmt.Errorf("Couldn't stat %s in container %s: exit code %d: %s", path, id, res.ExitCode, strings.TrimSpace(res.Combined()))
+	}
+	var (
+		size  int64
+		mode  uint32
+		mtime int64
+	)
+	if _, err := fmt.Sscanf(res.Stdout(), "%d %x %d", &size, &mode, &mtime); err != nil {
+		return driver.PathStat{}, fmt.Errorf("Couldn't parse stat output %q: %w", res.Stdout(), err)
+	}
+	stat := driver.PathStat{
+		Name:  filepath.Base(path),
+		Size:  size,
+		Mode:  fileMode(mode),
+		Mtime: time.Unix(mtime, 0),
+	}
+	if stat.Mode&os.ModeSymlink != 0 {
+		res, err := c.ContainerExecWithOptions(id, driver.ExecOptions{Cmd: []string{"readlink", "--", path}})
+		if err != nil {
+			return driver.PathStat{}, err
+		}
+		stat.LinkTarget = strings.TrimSpace(res.Stdout())
+	}
+	return stat, nil
+}
+
+// WatchPath polls the path in the container for changes until ctx is done.
+func (c *podmanClient) WatchPath(ctx context.Context, id string, path string, interval time.Duration) (_ <-chan driver.PathEvent, err error) {
+	fmt.Println("Inside podman watch path")
+	defer c.wrapErr(&err, "WatchPath", id)
+	return driver.WatchPath(ctx, c, id, path, interval)
+}
+
+// ContainerEvents follows the start, die and health_status events of one
+// container until ctx is done, when both channels are closed. A failure of
+// the event stream is sent on the error channel before it is closed.
+func (c *podmanClient) ContainerEvents(ctx context.Context, id string) (_ <-chan driver.Event, _ <-chan error, err error) {
+	fmt.Println("Inside podman container events: ", id)
+	defer c.wrapErr(&err, "ContainerEvents", id)
+
+	raw := make(chan entities.Event)
+	// the bindings ignore the context once streaming, closing cancelChan
+	// is what ends the request
+	cancelChan := make(chan bool)
+	var cancelOnce sync.Once
+	stop := func() {
+		cancelOnce.Do(func() { close(cancelChan) })
+	}
+	done := make(chan error, 1)
+	stream := true
+	go func() {
+		done <- system.Events(c.conn(), raw, cancelChan, nil, nil, map[string][]string{
+			"type":      {"container"},
+			"container": {id},
+		}, &stream)
+	}()
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-c.baseCtx.Done():
+			stop()
+		case <-finished:
+		}
+	}()
+
+	events := make(chan driver.Event)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(finished)
+		defer close(errCh)
+		defer close(events)
+		for {
+			select {
+			case e, ok := <-raw:
+				if !ok {
+					// keep waiting for Events to return its error
+					raw = nil
+					continue
+				}
+				ev, ok := driver.NewContainerEvent(e.Actor.ID, e.Action, e.Actor.Attributes, time.Unix(0, e.TimeNano))
+				if !ok || ctx.Err() != nil {
+					continue
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+				}
+			case err := <-done:
+				stop()
+				if err != nil && ctx.Err() == nil {
+					errCh <- err
+				}
+				return
+			}
+		}
+	}()
+	return events, errCh, nil
+}
+
+// ContainerLogs returns the container's stdout and stderr interleaved.
+// The bindings cannot abort a followed log request, so after the stream
+// is closed its remaining output is discarded until the container stops.
+func (c *podmanClient) ContainerLogs(ctx context.Context, id string, options driver.LogOptions) (_ io.ReadCloser, err error) {
+	fmt.Println("Inside podman container logs: ", id)
+	defer c.wrapErr(&err, "ContainerLogs", id)
+
+	all := true
+	opts := containers.LogOptions{
+		Follow:     &options.Follow,
+		Timestamps: &options.Timestamps,
+		Stdout:     &all,
+		Stderr:     &all,
+	}
+	if options.Tail > 0 {
+		tail := strconv.Itoa(options.Tail)
+		opts.Tail = &tail
+	}
+	if !options.Since.IsZero() {
+		since := options.Since.Format(time.RFC3339Nano)
+		opts.Since = &since
+	}
+	stdoutChan := make(chan string)
+	stderrChan := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		done <- containers.Logs(c.conn(), id, opts, stdoutChan, stderrChan)
+	}()
+
+	pr, pw := io.Pipe()
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+		case <-finished:
+		}
+	}()
+	go func() {
+		defer close(finished)
+		for {
+			var frame string
+			select {
+			case frame = <-stdoutChan:
+			case frame = <-stderrChan:
+			case err := <-done:
+				pw.CloseWithError(err)
+				return
+			}
+			// a write to a closed pipe fails at once, which discards it
+			pw.Write([]byte(frame))
+		}
+	}()
+	return driver.LimitReadCloser(pr, options.MaxOutputBytes), nil
+}
+
+// ContainerLogPath returns the host path of the container's log file.
+// Podman stores json-file logs with its k8s-file driver, so the file is
+// in the CRI log format rather than docker's json lines.
+func (c *podmanClient) ContainerLogPath(id string) (_ string, err error) {
+	fmt.Println("Inside podman container log path: ", id)
+	defer c.wrapErr(&err, "ContainerLogPath", id)
+	var cd *inspectContainerData
+	err = c.withReconnect(func() (err error) {
+		cd, err = inspectContainer(c.conn(), id)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if cd.HostConfig == nil || cd.HostConfig.LogConfig == nil {
+		return "", fmt.Errorf("Container %s reports no log configuration", id)
+	}
+	switch cd.HostConfig.LogConfig.Type {
+	case "k8s-file", "json-file":
+		return cd.LogPath, nil
+	}
+	return "", fmt.Errorf("Log path of %s log driver: %w", cd.HostConfig.LogConfig.Type, driver.ErrNotSupported)
+}
+
+// GenerateSystemdUnit generates systemd units that run the container, e.g.
+// to restart it on boot, keyed by unit name.
+func (c *podmanClient) GenerateSystemdUnit(id string, opts driver.SystemdOptions) (_ map[string]string, err error) {
+	fmt.Println("Inside podman generate systemd unit: ", id)
+	defer c.wrapErr(&err, "GenerateSystemdUnit", id)
+	options := entities.GenerateSystemdOptions{
+		Name:          opts.UseName,
+		New:           opts.New,
+		RestartPolicy: opts.RestartPolicy,
+	}
+	if options.RestartPolicy == "" {
+		options.RestartPolicy = "on-failure"
+	}
+	if opts.StopTimeout != nil {
+		if *opts.StopTimeout < 0 {
+			return nil, fmt.Errorf("Invalid stop timeout %d", *opts.StopTimeout)
+		}
+		timeout := uint(*opts.StopTimeout)
+		options.StopTimeout = &timeout
+	}
+	var report *entities.GenerateSystemdReport
+	err = c.withReconnect(func() (err error) {
+		report, err = generate.Systemd(c.conn(), id, options)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't generate systemd unit for container %s: %w", id, err)
+	}
+	return report.Units, nil
+}
+
+// ContainerSpecOf reconstructs the spec the container was created from,
+// for image, env, labels, mounts, ports and restart policy.
+func (c *podmanClient) ContainerSpecOf(id string) (_ driver.ContainerSpec, err error) {
+	defer c.wrapErr(&err, "ContainerSpecOf", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return driver.ContainerSpec{}, err
+	}
+	return driver.SpecFromInspect(icd), nil
+}
+
+func convertState(state *define.InspectContainerState) *driver.ContainerState {
+	cs := &driver.ContainerState{
+		Status:     state.Status,
+		Running:    state.Running,
+		Paused:     state.Paused,
+		Restarting: state.Restarting,
+		OOMKilled:  state.OOMKilled,
+		Dead:       state.Dead,
+		Pid:        state.Pid,
+		ExitCode:   int(state.ExitCode),
+		Error:      state.Error,
+		StartedAt:  state.StartedAt,
+		FinishedAt: state.FinishedAt,
+	}
+	// podman reports an empty status for containers without a healthcheck
+	if state.Healthcheck.Status != "" {
+		cs.Health = &driver.Health{
+			Status:        state.Healthcheck.Status,
+			FailingStreak: state.Healthcheck.FailingStreak,
+		}
+		for _, l := range state.Healthcheck.Log {
+			start, _ := time.Parse(time.RFC3339Nano, l.Start)
+			end, _ := time.Parse(time.RFC3339Nano, l.End)
+			cs.Health.Log = append(cs.Health.Log, driver.HealthLog{
+				Start:    start,
+				End:      end,
+				ExitCode: l.ExitCode,
+				Output:   l.Output,
+			})
+		}
+	}
+	return cs
+}
+
+func convertMounts(mounts []define.InspectMount) []driver.MountPoint {
+	var mps []driver.MountPoint
+	for _, m := range mounts {
+		mps = append(mps, driver.MountPoint{
+			Type:        driver.MountType(m.Type),
+			Name:        m.Name,
+			Source:      m.Source,
+			Destination: m.Destination,
+			Driver:      m.Driver,
+			Mode:        m.Mode,
+			RW:          m.RW,
+			Propagation: driver.MountPropagation(m.Propagation),
+		})
+	}
+	return mps
+}
+
+// ContainerMatchesSpec reports whether the container was created from a
+// spec equal to spec, going by the SpecHashLabel stamped at create time.
+// Containers created without the label never match.
+func (c *podmanClient) ContainerMatchesSpec(id string, spec driver.ContainerSpec) (_ bool, err error) {
+	fmt.Println("Inside podman container matches spec")
+	defer c.wrapErr(&err, "ContainerMatchesSpec", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	hash, ok := icd.Labels[driver.SpecHashLabel]
+	return ok && hash == driver.SpecHash(spec), nil
+}
+
+// IsManaged reports whether ContainerCreate made the container, going by
+// the ManagedByLabel stamped at create time.
+func (c *podmanClient) IsManaged(id string) (_ bool, err error) {
+	fmt.Println("Inside podman container is managed")
+	defer c.wrapErr(&err, "IsManaged", id)
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		return false, err
+	}
+	return icd.Labels[driver.ManagedByLabel] == driver.ManagedByValue, nil
+}
+
+// ContainerExitCode returns the exit code of a stopped container.
+func (c *podmanClient) ContainerExitCode(id string) (_ int, err error) {
+	fmt.Println("Inside podman container exit code")
+	defer c.wrapErr(&err, "ContainerExitCode", id)
+	var cd *inspectContainerData
+	err = c.withReconnect(func() (err error) {
+		cd, err = inspectContainer(c.conn(), id)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if cd.State == nil {
+		return 0, fmt.Errorf("No state reported for container %s", id)
+	}
+	if cd.State.Running {
+		return 0, &driver.ContainerRunningError{ID: id}
+	}
+	return int(cd.State.ExitCode), nil
+}
+
+// ContainerUptime returns how long the running container has been up.
+func (c *podmanClient) ContainerUptime(id string) (_ time.Duration, err error) {
+	fmt.Println("Inside podman container uptime")
+	defer c.wrapErr(&err, "ContainerUptime", id)
+	return driver.ContainerUptime(c, id)
+}
+
+// ContainerRestartCount returns how many times the container was restarted.
+func (c *podmanClient) ContainerRestartCount(id string) (_ int, err error) {
+	fmt.Println("Inside podman container restart count")
+	defer c.wrapErr(&err, "ContainerRestartCount", id)
+	return driver.ContainerRestartCount(c, id)
+}
+
+func (c *podmanClient) ContainerStop(id string) (err error) {
 	fmt.Println("Inside podman stop container")
-	err := containers.Stop(c.ctx, id, nil)
-	return err
+	defer c.wrapErr(&err, "ContainerStop", id)
+	return c.reconnectAfter(func() error {
+		return containers.Stop(c.conn(), id, nil)
+	})
+}
+
+// ContainerStopWithTimeout stops the container, giving it timeout, in
+// whole seconds, to exit before the service kills it.
+func (c *podmanClient) ContainerStopWithTimeout(id string, timeout time.Duration) (err error) {
+	fmt.Println("Inside podman stop container with timeout")
+	defer c.wrapErr(&err, "ContainerStopWithTimeout", id)
+	if timeout < 0 {
+		return fmt.Errorf("Invalid stop timeout %v", timeout)
+	}
+	seconds := uint(timeout / time.Second)
+	return c.reconnectAfter(func() error {
+		return containers.Stop(c.conn(), id, &seconds)
+	})
 }
 
-func (c *podmanClient) ContainerRemove(id string) error {
-	force := true
+func (c *podmanClient) ContainerRemove(id string, options driver.RemoveOptions) (err error) {
 	fmt.Println("Inside podman container remove")
-	return containers.Remove(c.ctx, id, &force, &force)
+	defer c.wrapErr(&err, "ContainerRemove", id)
+	return c.reconnectAfter(func() error {
+		return containers.Remove(c.conn(), id, &options.Force, &options.RemoveVolumes)
+	})
 }
 
-func (c *podmanClient) NetworkCreate(name string, options driver.NetworkCreateOptions) (driver.NetworkCreateResponse, error) {
+func (c *podmanClient) NetworkCreate(name string, options driver.NetworkCreateOptions) (_ driver.NetworkCreateResponse, err error) {
 	fmt.Println("Inside podman network create")
-	nco := entities.NetworkCreateOptions{
-		Driver: options.Driver,
-		//		Options: options.Options,
-		//		Labels:  options.Labels,
+	defer c.wrapErr(&err, "NetworkCreate", name)
+	if err := options.Validate(); err != nil {
+		return driver.NetworkCreateResponse{}, err
+	}
+	// podman services store network labels and driver options from 3.0
+	// on; older ones would drop them, so refuse them instead. Owner is
+	// kept in a label too.
+	labels := options.EngineLabels()
+	if len(labels) > 0 || len(options.Options) > 0 {
+		major, err := c.serverMajorVersion()
+		if err != nil {
+			return driver.NetworkCreateResponse{}, err
+		}
+		if major < 3 {
+			return driver.NetworkCreateResponse{}, fmt.Errorf("Network labels and options: %w by podman services before 3.0", driver.ErrNotSupported)
+		}
+	}
+	nco := networkCreateOptions{
+		NetworkCreateOptions: entities.NetworkCreateOptions{
+			Driver: options.Driver,
+			IPv6:   options.EnableIPv6,
+		},
+		Labels:  labels,
+		Options: options.Options,
 	}
-	resp, err := network.Create(c.ctx, nco, &name)
+	// podman networks take a single subnet and no auxiliary addresses
+	if len(options.IPAM.Config) > 1 {
+		return driver.NetworkCreateResponse{}, fmt.Errorf("Multiple IPAM pools: %w by podman", driver.ErrNotSupported)
+	}
+	for _, pool := range options.IPAM.Config {
+		if len(pool.AuxAddresses) > 0 {
+			return driver.NetworkCreateResponse{}, fmt.Errorf("IPAM aux addresses: %w by podman", driver.ErrNotSupported)
+		}
+		if pool.Subnet != "" {
+			_, subnet, _ := net.ParseCIDR(pool.Subnet)
+			nco.Subnet = *subnet
+		}
+		if pool.IPRange != "" {
+			_, ipRange, _ := net.ParseCIDR(pool.IPRange)
+			nco.Range = *ipRange
+		}
+		nco.Gateway = net.ParseIP(pool.Gateway)
+	}
+	err = c.reconnectAfter(func() (err error) {
+		_, err = createNetwork(c.conn(), name, nco)
+		return err
+	})
 	if err != nil {
 		return driver.NetworkCreateResponse{}, err
 	}
-	fmt.Printf("Network create response %+v\n", resp)
-	return driver.NetworkCreateResponse{}, err
+	// podman identifies CNI networks by name; its report only names the
+	// config file written for the network
+	return driver.NetworkCreateResponse{ID: name}, nil
+}
+
+// networkCreateOptions adds the labels and driver options that podman 3
+// services accept to the create options the v2 bindings know.
+type networkCreateOptions struct {
+	entities.NetworkCreateOptions
+	Labels  map[string]string `json:",omitempty"`
+	Options map[string]string `json:",omitempty"`
+}
+
+// inspectContainerData adds the stop timeout that podman 3 services
+// report to the inspect data the v2 bindings know.
+type inspectContainerData struct {
+	define.InspectContainerData
+	Config *inspectContainerConfig
+}
+
+type inspectContainerConfig struct {
+	define.InspectContainerConfig
+	StopTimeout *uint
+}
+
+// inspectContainer fetches the libpod inspect data of a container, as
+// containers.Inspect drops the fields newer services add.
+func inspectContainer(ctx context.Context, id string) (*inspectContainerData, error) {
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.DoRequest(nil, http.MethodGet, "/containers/%s/json", nil, nil, id)
+	if err != nil {
+		return nil, err
+	}
+	var inspect inspectContainerData
+	return &inspect, response.Process(&inspect)
 }
 
-func (c *podmanClient) NetworkInspect(id string) (driver.NetworkResource, error) {
+// createNetwork posts options to the libpod API, as network.Create only
+// sends the fields of entities.NetworkCreateOptions.
+func createNetwork(ctx context.Context, name string, options networkCreateOptions) (*entities.NetworkCreateReport, error) {
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("name", name)
+	response, err := conn.DoRequest(bytes.NewReader(body), http.MethodPost, "/networks/create", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var report entities.NetworkCreateReport
+	return &report, response.Process(&report)
+}
+
+// serverMajorVersion returns the major version of the podman service.
+func (c *podmanClient) serverMajorVersion() (int, error) {
+	var report *entities.SystemVersionReport
+	err := c.withReconnect(func() (err error) {
+		report, err = system.Version(c.conn())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if report.Server == nil {
+		return 0, fmt.Errorf("Podman service reported no version")
+	}
+	major, err := strconv.Atoi(strings.SplitN(report.Server.Version, ".", 2)[0])
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't parse podman version %s: %w", report.Server.Version, err)
+	}
+	return major, nil
+}
+
+func (c *podmanClient) NetworkInspect(id string) (_ driver.NetworkResource, err error) {
 	fmt.Println("Inside podman network inspect")
+	defer c.wrapErr(&err, "NetworkInspect", id)
 	// nir is map[string]interface
-	nir, err := network.Inspect(c.ctx, id)
+	var nir []entities.NetworkInspectReport
+	err = c.withReconnect(func() (err error) {
+		nir, err = network.Inspect(c.conn(), id)
+		return err
+	})
+	if err != nil {
+		return driver.NetworkResource{}, err
+	}
 	//	fmt.Println("nir name: ", nir[0]["name"])
 	name := fmt.Sprintf("%v", nir[0]["name"])
 	//	fmt.Println("nir cniversion: ", nir[0]["cniVersion"])
@@ -202,32 +1505,356 @@ func (c *podmanClient) NetworkInspect(id string) (driver.NetworkResource, error)
 	//	if _, ok := nir["name"]; ok {
 	//		dnr.Name = nir["name"]
 	//	}
-	return driver.NetworkResource{Name: name}, err
+	ipam := cniIPAM(nir[0])
+	nr := driver.NetworkResource{Name: name, Labels: cniLabels(nir[0]), IPAM: ipam}
+	for _, pool := range ipam.Config {
+		if ip, _, err := net.ParseCIDR(pool.Subnet); err == nil && ip.To4() == nil {
+			nr.EnableIPv6 = true
+		}
+	}
+	return nr, err
+}
+
+// cniLabels returns the labels podman 3 and later keep in the args of a
+// network config list.
+func cniLabels(conf map[string]interface{}) map[string]string {
+	args, _ := conf["args"].(map[string]interface{})
+	values, _ := args["podman_labels"].(map[string]interface{})
+	if len(values) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(values))
+	for k, v := range values {
+		labels[k], _ = v.(string)
+	}
+	return labels
+}
+
+// cniIPAM extracts the address pools from the ipam section of the CNI
+// plugins in a network config list.
+func cniIPAM(conf map[string]interface{}) driver.IPAMConfig {
+	var ipam driver.IPAMConfig
+	plugins, _ := conf["plugins"].([]interface{})
+	for _, p := range plugins {
+		plugin, _ := p.(map[string]interface{})
+		section, ok := plugin["ipam"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ipam.Driver, _ = section["type"].(string)
+		ranges, _ := section["ranges"].([]interface{})
+		for _, r := range ranges {
+			set, _ := r.([]interface{})
+			for _, entry := range set {
+				pool, _ := entry.(map[string]interface{})
+				subnet, _ := pool["subnet"].(string)
+				gateway, _ := pool["gateway"].(string)
+				ipam.Config = append(ipam.Config, driver.IPAMPool{
+					Subnet:  subnet,
+					Gateway: gateway,
+				})
+			}
+		}
+	}
+	return ipam
 }
 
-func (c *podmanClient) NetworkRemove(id string) error {
-	force := true
+func (c *podmanClient) NetworkList(options driver.NetworkListOptions) (_ []driver.NetworkResource, err error) {
+	fmt.Println("Inside podman network list")
+	defer c.wrapErr(&err, "NetworkList", "")
+	for key := range options.Filters {
+		if key != "name" && key != "label" {
+			return nil, fmt.Errorf("Network filter %s: %w by podman", key, driver.ErrNotSupported)
+		}
+	}
+	var reports []*entities.NetworkListReport
+	err = c.withReconnect(func() (err error) {
+		reports, err = network.List(c.conn(), entities.NetworkListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var nrs []driver.NetworkResource
+	for _, report := range reports {
+		if names, ok := options.Filters["name"]; ok && !matchesAny(report.Name, names) {
+			continue
+		}
+		// the config list's raw bytes hold the labels, which libcni does
+		// not parse
+		var conf map[string]interface{}
+		if len(report.Bytes) > 0 {
+			if err := json.Unmarshal(report.Bytes, &conf); err != nil {
+				return nil, fmt.Errorf("Couldn't parse network %s: %w", report.Name, err)
+			}
+		}
+		nr := driver.NetworkResource{ID: report.Name, Name: report.Name, Labels: cniLabels(conf)}
+		if !driver.MatchLabels(nr.Labels, options.Filters["label"]) {
+			continue
+		}
+		nrs = append(nrs, nr)
+	}
+	return nrs, nil
+}
+
+func matchesAny(name string, values []string) bool {
+	for _, v := range values {
+		if strings.Contains(name, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkContainers returns the IDs of the containers attached to the
+// network; podman's network inspect does not report them.
+func (c *podmanClient) networkContainers(id string) ([]string, error) {
+	all := true
+	var cl []entities.ListContainer
+	err := c.withReconnect(func() (err error) {
+		cl, err = containers.List(c.conn(), map[string][]string{"network": {id}}, &all, nil, nil, nil, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, container := range cl {
+		ids = append(ids, container.ID)
+	}
+	return ids, nil
+}
+
+// NetworkRemove removes the network. When force is set, attached containers
+// are disconnected first; otherwise a network with attached containers
+// yields a driver.NetworkInUseError.
+func (c *podmanClient) NetworkRemove(id string, force bool) (err error) {
 	fmt.Println("Inside podman network remove for: ", id)
-	_, err := network.Remove(c.ctx, id, &force)
-	return err
+	defer c.wrapErr(&err, "NetworkRemove", id)
+	endpoints, err := c.networkContainers(id)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) > 0 {
+		if !force {
+			return &driver.NetworkInUseError{Network: id, Endpoints: endpoints}
+		}
+		for _, container := range endpoints {
+			if err := c.NetworkDisconnect(id, container, true); err != nil {
+				return err
+			}
+		}
+	}
+	// podman's own force flag would remove the attached containers too
+	noForce := false
+	return c.reconnectAfter(func() error {
+		reports, err := network.Remove(c.conn(), id, &noForce)
+		if err != nil {
+			return err
+		}
+		for _, report := range reports {
+			if report.Err != nil {
+				return report.Err
+			}
+		}
+		return nil
+	})
 }
 
-func (c *podmanClient) NetworkConnect(id string, container string, aliases []string) error {
+// NetworkConnect attaches container to the network and returns the
+// endpoint podman assigned, read back from the container's settings.
+func (c *podmanClient) NetworkConnect(id string, container string, aliases []string) (_ driver.EndpointResource, err error) {
 	fmt.Println("Inside podman network connect: ", id, container)
-	err := network.Connect(c.ctx, id, entities.NetworkConnectOptions{
-		Container: container,
-		Aliases:   aliases,
+	defer c.wrapErr(&err, "NetworkConnect", id)
+	err = c.reconnectAfter(func() error {
+		return network.Connect(c.conn(), id, entities.NetworkConnectOptions{
+			Container: container,
+			Aliases:   aliases,
+		})
 	})
-	return err
+	if err != nil {
+		return driver.EndpointResource{}, err
+	}
+
+	var cd *define.InspectContainerData
+	err = c.withReconnect(func() (err error) {
+		cd, err = containers.Inspect(c.conn(), container, nil)
+		return err
+	})
+	if err != nil {
+		return driver.EndpointResource{}, fmt.Errorf("Couldn't read endpoint of container %s: %w", container, err)
+	}
+	if cd.NetworkSettings != nil {
+		for name, n := range cd.NetworkSettings.Networks {
+			if n == nil || (name != id && n.NetworkID != id) {
+				continue
+			}
+			return convertEndpoint(cd.Name, n), nil
+		}
+	}
+	return driver.EndpointResource{}, fmt.Errorf("Container %s has no endpoint on network %s", container, id)
 }
 
-func (c *podmanClient) NetworkDisconnect(id string, container string, force bool) error {
+func convertEndpoint(name string, n *define.InspectAdditionalNetwork) driver.EndpointResource {
+	endpoint := driver.EndpointResource{
+		Name:       name,
+		EndpointID: n.EndpointID,
+		MacAddress: n.MacAddress,
+		Gateway:    n.Gateway,
+	}
+	if n.IPAddress != "" {
+		endpoint.IPv4Address = fmt.Sprintf("%s/%d", n.IPAddress, n.IPPrefixLen)
+	}
+	if n.GlobalIPv6Address != "" {
+		endpoint.IPv6Address = fmt.Sprintf("%s/%d", n.GlobalIPv6Address, n.GlobalIPv6PrefixLen)
+	}
+	return endpoint
+}
+
+func (c *podmanClient) NetworkDisconnect(id string, container string, force bool) (err error) {
 	fmt.Println("Inside podman network disconnect: ", id, container)
-	err := network.Disconnect(c.ctx, id, entities.NetworkDisconnectOptions{
-		Container: container,
-		Force:     force,
+	defer c.wrapErr(&err, "NetworkDisconnect", id)
+	return c.reconnectAfter(func() error {
+		return network.Disconnect(c.conn(), id, entities.NetworkDisconnectOptions{
+			Container: container,
+			Force:     force,
+		})
 	})
-	return err
+}
+
+// ContainerConnectNetworks connects the container to several networks.
+func (c *podmanClient) ContainerConnectNetworks(id string, endpoints map[string]driver.EndpointConfig, opts driver.ConnectNetworksOptions) (err error) {
+	fmt.Println("Inside podman container connect networks: ", id)
+	defer c.wrapErr(&err, "ContainerConnectNetworks", id)
+	return driver.ContainerConnectNetworks(c, id, endpoints, opts)
+}
+
+// ContainersPrune removes the stopped containers matching filters.
+func (c *podmanClient) ContainersPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside podman containers prune")
+	defer c.wrapErr(&err, "ContainersPrune", "")
+	var report *entities.ContainerPruneReport
+	err = c.reconnectAfter(func() (err error) {
+		report, err = containers.Prune(c.conn(), filters)
+		return err
+	})
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	var pr driver.PruneReport
+	for id, size := range report.ID {
+		pr.Deleted = append(pr.Deleted, id)
+		pr.SpaceReclaimed += uint64(size)
+	}
+	var errs []error
+	for id, err := range report.Err {
+		errs = append(errs, fmt.Errorf("Couldn't prune container %s: %w", id, err))
+	}
+	if len(errs) > 0 {
+		return pr, &driver.AggregateError{Errors: errs}
+	}
+	return pr, nil
+}
+
+// ImagesPrune removes the dangling images matching filters. Podman does
+// not report the space reclaimed.
+func (c *podmanClient) ImagesPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside podman images prune")
+	defer c.wrapErr(&err, "ImagesPrune", "")
+	all := false
+	var deleted []string
+	err = c.reconnectAfter(func() (err error) {
+		deleted, err = images.Prune(c.conn(), &all, filters)
+		return err
+	})
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	return driver.PruneReport{Deleted: deleted}, nil
+}
+
+func (c *podmanClient) NetworksPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside podman networks prune")
+	defer c.wrapErr(&err, "NetworksPrune", "")
+	return driver.PruneReport{}, fmt.Errorf("Network prune: %w by the podman v2 bindings", driver.ErrNotSupported)
+}
+
+// VolumesPrune removes the volumes that no container uses. The v2
+// bindings take no filters, and podman does not report the space
+// reclaimed.
+func (c *podmanClient) VolumesPrune(filters driver.Filters) (_ driver.PruneReport, err error) {
+	fmt.Println("Inside podman volumes prune")
+	defer c.wrapErr(&err, "VolumesPrune", "")
+	if len(filters) > 0 {
+		return driver.PruneReport{}, fmt.Errorf("Volume prune filters: %w by the podman v2 bindings", driver.ErrNotSupported)
+	}
+	var reports []*entities.VolumePruneReport
+	err = c.reconnectAfter(func() (err error) {
+		reports, err = volumes.Prune(c.conn())
+		return err
+	})
+	if err != nil {
+		return driver.PruneReport{}, err
+	}
+	var pr driver.PruneReport
+	var errs []error
+	for _, r := range reports {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't prune volume %s: %w", r.Id, r.Err))
+			continue
+		}
+		pr.Deleted = append(pr.Deleted, r.Id)
+	}
+	if len(errs) > 0 {
+		return pr, &driver.AggregateError{Errors: errs}
+	}
+	return pr, nil
+}
+
+func (c *podmanClient) ContainerStatsSnapshot(id string) (_ driver.ContainerStats, err error) {
+	fmt.Println("Inside podman container stats snapshot")
+	defer c.wrapErr(&err, "ContainerStatsSnapshot", id)
+	stream := false
+	var reports chan entities.ContainerStatsReport
+	err = c.withReconnect(func() (err error) {
+		reports, err = containers.Stats(c.conn(), []string{id}, &stream)
+		return err
+	})
+	if err != nil {
+		return driver.ContainerStats{}, err
+	}
+	report, ok := <-reports
+	if !ok {
+		return driver.ContainerStats{}, fmt.Errorf("No stats returned for container %s", id)
+	}
+	if report.Error != nil {
+		return driver.ContainerStats{}, report.Error
+	}
+	if len(report.Stats) == 0 {
+		return driver.ContainerStats{}, fmt.Errorf("No stats returned for container %s", id)
+	}
+	stats := report.Stats[0]
+	return driver.ContainerStats{
+		ID:            stats.ContainerID,
+		Read:          time.Now(),
+		CPUPercent:    stats.CPU,
+		MemoryUsage:   stats.MemUsage,
+		MemoryLimit:   stats.MemLimit,
+		MemoryPercent: stats.MemPerc,
+		NetworkRx:     stats.NetInput,
+		NetworkTx:     stats.NetOutput,
+		BlockRead:     stats.BlockInput,
+		BlockWrite:    stats.BlockOutput,
+		PIDs:          stats.PIDs,
+	}, nil
+}
+
+// ContainerSummary returns the container's state, uptime, restart count,
+// stats and health together.
+func (c *podmanClient) ContainerSummary(id string) (_ driver.ContainerSummary, err error) {
+	fmt.Println("Inside podman container summary")
+	defer c.wrapErr(&err, "ContainerSummary", id)
+	return driver.SummarizeContainer(c, id)
 }
 
 type PmWriteCloser struct {
@@ -238,8 +1865,9 @@ func (pwc *PmWriteCloser) Close() error {
 	return nil
 }
 
-func (c *podmanClient) ContainerExecKeeper(id string, cmd []string) (driver.ExecResult, error) {
+func (c *podmanClient) ContainerExecKeeper(id string, cmd []string) (_ driver.ExecResult, err error) {
 	fmt.Println("Inside docker container exec")
+	defer c.wrapErr(&err, "ContainerExecKeeper", id)
 
 	//TODO: there may be a better way to capture, stderr too?
 	stdout := os.Stdout
@@ -251,7 +1879,7 @@ func (c *podmanClient) ContainerExecKeeper(id string, cmd []string) (driver.Exec
 	execConfig.AttachStderr = true
 	execConfig.Cmd = cmd
 
-	execID, err := containers.ExecCreate(c.ctx, id, execConfig)
+	execID, err := containers.ExecCreate(c.conn(), id, execConfig)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
@@ -262,7 +1890,7 @@ func (c *podmanClient) ContainerExecKeeper(id string, cmd []string) (driver.Exec
 	streams.AttachOutput = true
 	streams.AttachError = true
 
-	err = containers.ExecStartAndAttach(c.ctx, execID, streams)
+	err = containers.ExecStartAndAttach(c.conn(), execID, streams)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
@@ -272,7 +1900,8 @@ func (c *podmanClient) ContainerExecKeeper(id string, cmd []string) (driver.Exec
 	copyDone := make(chan struct{})
 
 	go func() {
-		_, err = io.Copy(&outBuf, r)
+		// the named err holds the result, which this must not overwrite
+		io.Copy(&outBuf, r)
 		r.Close()
 		close(copyDone)
 	}()
@@ -283,27 +1912,29 @@ func (c *podmanClient) ContainerExecKeeper(id string, cmd []string) (driver.Exec
 		<-copyDone
 	}()
 
-	inspectOut, err := containers.ExecInspect(c.ctx, execID)
+	inspectOut, err := containers.ExecInspect(c.conn(), execID)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
 	return driver.ExecResult{ExitCode: inspectOut.ExitCode, OutBuffer: &outBuf, ErrBuffer: nil}, nil
 }
 
-func (c *podmanClient) ContainerExec(id string, cmd []string) (driver.ExecResult, error) {
+func (c *podmanClient) ContainerExec(id string, cmd []string) (_ driver.ExecResult, err error) {
 	fmt.Println("Inside docker container exec")
+	defer c.wrapErr(&err, "ContainerExec", id)
 
 	//TODO: there may be a better way to capture, stderr too?
 	stdout := os.Stdout
 	r, w, err := os.Pipe()
 	os.Stdout = w
 
+	startedAt := time.Now()
 	execConfig := new(handlers.ExecCreateConfig)
 	execConfig.AttachStdout = true
 	execConfig.AttachStderr = true
 	execConfig.Cmd = cmd
 
-	execID, err := containers.ExecCreate(c.ctx, id, execConfig)
+	execID, err := containers.ExecCreate(c.conn(), id, execConfig)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
@@ -314,7 +1945,7 @@ func (c *podmanClient) ContainerExec(id string, cmd []string) (driver.ExecResult
 	streams.AttachOutput = true
 	streams.AttachError = true
 
-	err = containers.ExecStartAndAttach(c.ctx, execID, streams)
+	err = containers.ExecStartAndAttach(c.conn(), execID, streams)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
@@ -323,7 +1954,8 @@ func (c *podmanClient) ContainerExec(id string, cmd []string) (driver.ExecResult
 	copyDone := make(chan struct{})
 
 	go func() {
-		_, err = io.Copy(&outBuf, r)
+		// the named err holds the result, which this must not overwrite
+		io.Copy(&outBuf, r)
 		r.Close()
 		copyDone <- struct{}{}
 	}()
@@ -334,9 +1966,257 @@ func (c *podmanClient) ContainerExec(id string, cmd []string) (driver.ExecResult
 		<-copyDone
 	}()
 
-	inspectOut, err := containers.ExecInspect(c.ctx, execID)
+	inspectOut, err := containers.ExecInspect(c.conn(), execID)
 	if err != nil {
 		return driver.ExecResult{}, err
 	}
-	return driver.ExecResult{ExitCode: inspectOut.ExitCode, OutBuffer: &outBuf, ErrBuffer: &errBuf}, nil
+	return driver.ExecResult{
+		ExecID:    execID,
+		ExitCode:  inspectOut.ExitCode,
+		OutBuffer: &outBuf,
+		ErrBuffer: &errBuf,
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}, nil
+}
+
+// gatedWriter lets a caller's writer serve as an attach stream that can be
+// cut off, so that an attach left running after a timeout stops writing
+// into output already handed back to the caller.
+type gatedWriter struct {
+	lock   sync.Mutex
+	w      io.Writer
+	closed bool
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return len(p), nil
+	}
+	return g.w.Write(p)
+}
+
+func (g *gatedWriter) Close() error {
+	return nil
+}
+
+// cutOff discards everything written from now on.
+func (g *gatedWriter) cutOff() {
+	g.lock.Lock()
+	g.closed = true
+	g.lock.Unlock()
+}
+
+// ContainerExecStream runs opts.Cmd in the container, copying its output
+// to stdout and stderr as it is produced, and returns its exit code.
+func (c *podmanClient) ContainerExecStream(id string, opts driver.ExecOptions, stdout io.Writer, stderr io.Writer) (_ int, err error) {
+	fmt.Println("Inside podman container exec stream")
+	defer c.wrapErr(&err, "ContainerExecStream", id)
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	_, exitCode, err := c.execAttached(id, opts, stdout, stderr)
+	return exitCode, err
+}
+
+// ContainerExecWithOptions runs opts.Cmd in the container and returns its
+// buffered output. On timeout the ExecTimeoutError carries the partial
+// output.
+func (c *podmanClient) ContainerExecWithOptions(id string, opts driver.ExecOptions) (_ driver.ExecResult, err error) {
+	fmt.Println("Inside podman container exec with options")
+	defer c.wrapErr(&err, "ContainerExecWithOptions", id)
+	var outBuf, errBuf bytes.Buffer
+	stdout := driver.CapWriter(&outBuf, opts.MaxOutputBytes)
+	stderr := driver.CapWriter(&errBuf, opts.MaxOutputBytes)
+	startedAt := time.Now()
+	execID, exitCode, err := c.execAttached(id, opts, stdout, stderr)
+	res := driver.ExecResult{
+		ExecID:    execID,
+		ExitCode:  exitCode,
+		OutBuffer: &outBuf,
+		ErrBuffer: &errBuf,
+		Tty:       opts.Tty,
+		Truncated: driver.IsTruncated(stdout) || driver.IsTruncated(stderr),
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}
+	if timeoutErr, ok := err.(*driver.ExecTimeoutError); ok {
+		timeoutErr.Result = res
+	}
+	return res, err
+}
+
+// execAttached runs an exec with its output attached to stdout and stderr
+// and returns its exec ID and exit code. When opts.Timeout elapses the
+// process is killed and an ExecTimeoutError is returned without waiting
+// for the attach, which the bindings cannot cancel, to end.
+func (c *podmanClient) execAttached(id string, opts driver.ExecOptions, stdout io.Writer, stderr io.Writer) (string, int, error) {
+	execConfig := new(handlers.ExecCreateConfig)
+	execConfig.AttachStdout = true
+	execConfig.AttachStderr = true
+	execConfig.Tty = opts.Tty
+	execConfig.Env = opts.Env
+	execConfig.WorkingDir = opts.WorkingDir
+	execConfig.User = opts.User
+	execConfig.Cmd = opts.Cmd
+
+	var execID string
+	err := c.reconnectAfter(func() (err error) {
+		execID, err = containers.ExecCreate(c.conn(), id, execConfig)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	out := &gatedWriter{w: stdout}
+	errOut := &gatedWriter{w: stderr}
+	streams := new(define.AttachStreams)
+	streams.OutputStream = out
+	streams.ErrorStream = errOut
+	streams.AttachOutput = true
+	streams.AttachError = true
+	attachDone := make(chan error, 1)
+	go func() {
+		attachDone <- containers.ExecStartAndAttach(c.conn(), execID, streams)
+	}()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-attachDone:
+		if err != nil {
+			return execID, 0, err
+		}
+	case <-timeout:
+		out.cutOff()
+		errOut.cutOff()
+		killErr := c.killExec(execID)
+		return execID, 0, &driver.ExecTimeoutError{ContainerID: id, ExecID: execID, Timeout: opts.Timeout, KillErr: killErr}
+	}
+
+	inspectOut, err := containers.ExecInspect(c.conn(), execID)
+	if err != nil {
+		return execID, 0, err
+	}
+	return execID, inspectOut.ExitCode, nil
+}
+
+// killExec kills a timed out exec's process. The podman v2 bindings have
+// no API for it, so this signals the pid podman reports, which
+// driver.KillExecProcess only does when the service is local to the driver.
+func (c *podmanClient) killExec(execID string) error {
+	inspect, err := containers.ExecInspect(c.conn(), execID)
+	if err != nil {
+		return err
+	}
+	if !inspect.Running {
+		return nil
+	}
+	c.reconnectLock.Lock()
+	socket := c.socket
+	c.reconnectLock.Unlock()
+	return driver.KillExecProcess(socket, inspect.ContainerID, inspect.Pid)
+}
+
+// NetworkGateway returns the IPv4 gateway of the network.
+func (c *podmanClient) NetworkGateway(id string) (_ string, err error) {
+	defer c.wrapErr(&err, "NetworkGateway", id)
+	return driver.NetworkGateway(c, id)
+}
+
+// WaitForPort blocks until something in the container listens on port,
+// or ctx is done.
+func (c *podmanClient) WaitForPort(ctx context.Context, id string, port int, proto string) (err error) {
+	defer c.wrapErr(&err, "WaitForPort", id)
+	return driver.WaitForPort(ctx, c, id, port, proto)
+}
+
+// ContainerExecDetached starts opts.Cmd in the container without waiting
+// for it and returns the exec ID for ExecInspect. The podman v2 bindings
+// cannot start an exec detached, so the session is attached in the
+// background with its output discarded; this returns once the process has
+// started. opts.Timeout and opts.MaxOutputBytes do not apply.
+func (c *podmanClient) ContainerExecDetached(id string, opts driver.ExecOptions) (_ string, err error) {
+	fmt.Println("Inside podman container exec detached: ", id)
+	defer c.wrapErr(&err, "ContainerExecDetached", id)
+	execConfig := new(handlers.ExecCreateConfig)
+	execConfig.AttachStdout = true
+	execConfig.AttachStderr = true
+	execConfig.Tty = opts.Tty
+	execConfig.Env = opts.Env
+	execConfig.WorkingDir = opts.WorkingDir
+	execConfig.User = opts.User
+	execConfig.Cmd = opts.Cmd
+
+	var execID string
+	err = c.reconnectAfter(func() (err error) {
+		execID, err = containers.ExecCreate(c.conn(), id, execConfig)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	streams := new(define.AttachStreams)
+	streams.OutputStream = &gatedWriter{w: ioutil.Discard}
+	streams.ErrorStream = &gatedWriter{w: ioutil.Discard}
+	streams.AttachOutput = true
+	streams.AttachError = true
+	attachDone := make(chan error, 1)
+	go func() {
+		attachDone <- containers.ExecStartAndAttach(c.conn(), execID, streams)
+	}()
+
+	// a session that has not started yet looks like one that exited 0, so
+	// wait for its process before handing out the ID
+	ctx, cancel := context.WithTimeout(c.baseCtx, c.timeout)
+	defer cancel()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-attachDone:
+			return execID, err
+		case <-ctx.Done():
+			return execID, ctx.Err()
+		case <-ticker.C:
+		}
+		session, err := containers.ExecInspect(c.conn(), execID)
+		if err != nil {
+			return execID, err
+		}
+		if session.Running || session.Pid > 0 {
+			return execID, nil
+		}
+	}
+}
+
+func (c *podmanClient) ExecInspect(execID string) (_ driver.ExecInspect, err error) {
+	fmt.Println("Inside podman exec inspect")
+	defer c.wrapErr(&err, "ExecInspect", execID)
+	var session *define.InspectExecSession
+	err = c.withReconnect(func() (err error) {
+		session, err = containers.ExecInspect(c.conn(), execID)
+		return err
+	})
+	if err != nil {
+		return driver.ExecInspect{}, err
+	}
+	return driver.ExecInspect{
+		ID:          session.ID,
+		ContainerID: session.ContainerID,
+		Running:     session.Running,
+		ExitCode:    session.ExitCode,
+		Pid:         session.Pid,
+	}, nil
 }
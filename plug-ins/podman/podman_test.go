@@ -0,0 +1,1559 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajssmith/ce-drivers/driver"
+)
+
+// fakeService is a podman service on a unix socket. It answers pings,
+// version queries, image exists checks, pulls, container starts, execs and
+// network creates and lists, and can drop connections without responding.
+type fakeService struct {
+	socket string
+	lock   sync.Mutex
+	drop   int
+	pings  int
+	starts int
+	// pulls in flight, and the most seen at once
+	pulling    int
+	maxPulling int
+	// version is the podman version reported
+	version string
+	// networks holds each created network's config list
+	networks map[string]map[string]interface{}
+	// execs holds each exec session, keyed by its ID
+	execs map[string]*fakeExec
+	// exec answers an exec's command with its output and exit code; nil
+	// runs every command silently with exit code 0
+	exec func(cmd []string) (stdout string, stderr string, exitCode int)
+	// failExecInspect fails the inspect of exec sessions that have run
+	failExecInspect bool
+}
+
+type fakeExec struct {
+	cmd      []string
+	started  bool
+	exitCode int
+}
+
+func newFakeService(t *testing.T) *fakeService {
+	dir, err := ioutil.TempDir("", "podman-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "podman.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeService{
+		socket:   "unix://" + path,
+		version:  "3.0.1",
+		networks: map[string]map[string]interface{}{},
+		execs:    map[string]*fakeExec{},
+	}
+	srv := &http.Server{Handler: f}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	})
+	return f
+}
+
+// dropNext drops the next n requests. The bindings try each request three
+// times, so dropping three loses a call.
+func (f *fakeService) dropNext(n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.drop = n
+}
+
+func (f *fakeService) counts() (int, int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.pings, f.starts
+}
+
+func (f *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/images/pull") {
+		f.servePull(w, r)
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.drop > 0 {
+		f.drop--
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+	// no keep-alive, so a dropped request is never retried by the transport
+	w.Header().Set("Connection", "close")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/_ping"):
+		f.pings++
+		w.Header().Set("Libpod-API-Version", "2.0.0")
+		fmt.Fprint(w, "OK")
+	case strings.HasSuffix(r.URL.Path, "/images/quay.io/skupper/router/exists"):
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(r.URL.Path, "/exec") && r.Method == http.MethodPost:
+		f.createExec(w, r)
+	case strings.Contains(r.URL.Path, "/exec/") && strings.HasSuffix(r.URL.Path, "/start"):
+		f.startExec(w, path.Base(path.Dir(r.URL.Path)))
+	case strings.Contains(r.URL.Path, "/exec/") && strings.HasSuffix(r.URL.Path, "/json"):
+		f.inspectExec(w, path.Base(path.Dir(r.URL.Path)))
+	case strings.HasSuffix(r.URL.Path, "/start") && r.Method == http.MethodPost:
+		f.starts++
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(r.URL.Path, "/version"):
+		fmt.Fprintf(w, `{"Version":%q}`, f.version)
+	case strings.HasSuffix(r.URL.Path, "/networks/create"):
+		f.createNetwork(w, r)
+	case strings.HasSuffix(r.URL.Path, "/networks/json"):
+		f.listNetworks(w)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"cause":"no such object","message":"no such object","response":404}`)
+	}
+}
+
+// createNetwork stores a config list for the network as podman 3 would,
+// with the labels in its args.
+func (f *fakeService) createNetwork(w http.ResponseWriter, r *http.Request) {
+	var options struct {
+		Labels  map[string]string
+		Options map[string]string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	conf := map[string]interface{}{"cniVersion": "0.4.0", "name": name}
+	if len(options.Labels) > 0 {
+		conf["args"] = map[string]interface{}{"podman_labels": options.Labels}
+	}
+	f.networks[name] = conf
+	fmt.Fprintf(w, `{"Filename":"/etc/cni/net.d/%s.conflist"}`, name)
+}
+
+func (f *fakeService) createExec(w http.ResponseWriter, r *http.Request) {
+	var config struct {
+		Cmd []string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	id := fmt.Sprintf("exec%d", len(f.execs))
+	f.execs[id] = &fakeExec{cmd: config.Cmd}
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"Id":%q}`, id)
+}
+
+// startExec runs the exec and streams its output multiplexed over the
+// hijacked connection, as the bindings read it from the socket.
+func (f *fakeService) startExec(w http.ResponseWriter, id string) {
+	e, ok := f.execs[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var stdout, stderr string
+	if f.exec != nil {
+		stdout, stderr, e.exitCode = f.exec(e.cmd)
+	}
+	e.started = true
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	// keep the stream out of the read that takes the response headers
+	time.Sleep(20 * time.Millisecond)
+	for fd, data := range []string{1: stdout, 2: stderr} {
+		if data == "" {
+			continue
+		}
+		header := []byte{byte(fd), 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+		conn.Write(append(header, data...))
+	}
+}
+
+func (f *fakeService) inspectExec(w http.ResponseWriter, id string) {
+	e, ok := f.execs[id]
+	if !ok || (e.started && f.failExecInspect) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"cause":"exec session state unknown","message":"exec session state unknown","response":500}`)
+		return
+	}
+	fmt.Fprintf(w, `{"ID":%q,"Running":false,"ExitCode":%d,"ProcessConfig":{"tty":false}}`, id, e.exitCode)
+}
+
+func (f *fakeService) listNetworks(w http.ResponseWriter) {
+	var list []map[string]interface{}
+	for name, conf := range f.networks {
+		data, _ := json.Marshal(conf)
+		list = append(list, map[string]interface{}{"Name": name, "CNIVersion": "0.4.0", "Bytes": data})
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// testImage keeps running with its default command and has a shell.
+const testImage = "docker.io/library/nginx:alpine"
+
+// newTestClient connects to the local podman service, skipping the test
+// when none is reachable.
+func newTestClient(t *testing.T) *podmanClient {
+	t.Helper()
+	c := &podmanClient{}
+	if err := c.New(context.Background(), driver.ConnectOptions{}); err != nil {
+		t.Skipf("No podman service: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// runTestContainer creates and starts a container of testImage from spec,
+// removing it when the test ends.
+func runTestContainer(t *testing.T, c *podmanClient, spec driver.ContainerSpec) string {
+	t.Helper()
+	if spec.Name == "" {
+		spec.Name = fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	}
+	if spec.Image == "" {
+		spec.Image = testImage
+	}
+	if spec.PullPolicy == "" {
+		spec.PullPolicy = driver.PullMissing
+	}
+	res, err := c.ContainerCreate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true}) })
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	return res.ID
+}
+
+// execOutput runs cmd in the container and returns its trimmed stdout.
+func execOutput(t *testing.T, c *podmanClient, id string, cmd ...string) string {
+	t.Helper()
+	res, err := c.ContainerExec(id, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 0 {
+		t.Fatalf("%v exited %d: %s", cmd, res.ExitCode, res.Combined())
+	}
+	return strings.TrimSpace(res.Stdout())
+}
+
+// entrypointMount replaces testImage's entrypoint with a shell script, as
+// specs have no command.
+func entrypointMount(t *testing.T, script string) driver.Mount {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "entrypoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "entrypoint.sh")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return driver.Mount{Type: driver.TypeBind, Source: path, Target: "/docker-entrypoint.sh", ReadOnly: true}
+}
+
+// servePull takes a while to pull any image, counting the pulls in flight.
+func (f *fakeService) servePull(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	f.pulling++
+	if f.pulling > f.maxPulling {
+		f.maxPulling = f.pulling
+	}
+	f.lock.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	f.lock.Lock()
+	f.pulling--
+	f.lock.Unlock()
+	w.Header().Set("Connection", "close")
+	fmt.Fprintf(w, `{"images":["%s"]}`, r.URL.Query().Get("reference"))
+}
+
+func connectFake(t *testing.T, f *fakeService, options driver.ConnectOptions) *podmanClient {
+	c := &podmanClient{}
+	options.Host = f.socket
+	if err := c.New(context.Background(), options); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestContainerExecInspectFailure(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{})
+	f.failExecInspect = true
+	if _, err := c.ContainerExec("router", []string{"true"}); err == nil {
+		t.Errorf("Expected ContainerExec to report the failed exec inspect")
+	}
+	if _, err := c.ContainerExecKeeper("router", []string{"true"}); err == nil {
+		t.Errorf("Expected ContainerExecKeeper to report the failed exec inspect")
+	}
+}
+
+func TestReconnectAfterDroppedConnection(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{Reconnect: driver.ReconnectPolicy{Enabled: true, MinInterval: time.Nanosecond}})
+
+	f.dropNext(3)
+	exists, err := c.ImageExists("quay.io/skupper/router")
+	if err != nil {
+		t.Fatalf("Expected the call to reconnect and succeed, got %v", err)
+	}
+	if !exists {
+		t.Errorf("Expected the image to exist")
+	}
+	if pings, _ := f.counts(); pings != 2 {
+		t.Errorf("Expected a ping from New and one from the reconnect, got %d", pings)
+	}
+}
+
+func TestReconnectFailureReported(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{Reconnect: driver.ReconnectPolicy{Enabled: true, MinInterval: time.Hour}})
+	c.reconnectLock.Lock()
+	c.lastReconnect = time.Now()
+	c.reconnectLock.Unlock()
+
+	f.dropNext(3)
+	_, err := c.ImageExists("quay.io/skupper/router")
+	if err == nil || !strings.Contains(err.Error(), "reconnect failed") {
+		t.Errorf("Expected the reconnect failure in the error, got %v", err)
+	}
+}
+
+func TestReconnectUsesNewEndpoint(t *testing.T) {
+	first := newFakeService(t)
+	second := newFakeService(t)
+	c := connectFake(t, first, driver.ConnectOptions{})
+
+	if err := c.Reconnect(driver.ConnectOptions{Host: second.socket}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStart("router"); err != nil {
+		t.Fatal(err)
+	}
+	if _, starts := first.counts(); starts != 0 {
+		t.Errorf("Expected no starts on the old endpoint, got %d", starts)
+	}
+	if pings, starts := second.counts(); pings != 1 || starts != 1 {
+		t.Errorf("Expected a ping and a start on the new endpoint, got %d and %d", pings, starts)
+	}
+}
+
+func TestReconnectDoesNotRepeatMutatingCalls(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{Reconnect: driver.ReconnectPolicy{Enabled: true, MinInterval: time.Nanosecond}})
+
+	f.dropNext(3)
+	if err := c.ContainerStart("router"); err == nil {
+		t.Fatalf("Expected the dropped start to fail")
+	}
+	pings, starts := f.counts()
+	if pings != 2 {
+		t.Errorf("Expected the failed start to reconnect, got %d pings", pings)
+	}
+	if starts != 0 {
+		t.Errorf("Expected the start not to be repeated, got %d starts", starts)
+	}
+	if err := c.ContainerStart("router"); err != nil {
+		t.Errorf("Expected the next start to use the new connection, got %v", err)
+	}
+}
+
+func TestReconnectDisabled(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{})
+
+	f.dropNext(3)
+	if _, err := c.ImageExists("quay.io/skupper/router"); err == nil {
+		t.Fatalf("Expected the dropped call to fail without a reconnect policy")
+	}
+	if pings, _ := f.counts(); pings != 1 {
+		t.Errorf("Expected no reconnect, got %d pings", pings)
+	}
+}
+
+func TestReconnectStormGuard(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{Reconnect: driver.ReconnectPolicy{Enabled: true, MinInterval: time.Hour}})
+
+	f.dropNext(3)
+	if _, err := c.ImageExists("quay.io/skupper/router"); err != nil {
+		t.Fatalf("Expected the first drop to reconnect, got %v", err)
+	}
+	f.dropNext(3)
+	if _, err := c.ImageExists("quay.io/skupper/router"); err == nil {
+		t.Fatalf("Expected a second drop within MinInterval to fail")
+	}
+	if pings, _ := f.counts(); pings != 2 {
+		t.Errorf("Expected a single reconnect, got %d pings", pings)
+	}
+}
+
+func TestCancelledBaseContextFailsCalls(t *testing.T) {
+	f := newFakeService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &podmanClient{}
+	if err := c.New(ctx, driver.ConnectOptions{Host: f.socket}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ImageExists("quay.io/skupper/router"); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := c.ImageExists("quay.io/skupper/router"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a context cancelled error, got %v", err)
+	}
+	if err := c.ContainerStart("router"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a context cancelled error, got %v", err)
+	}
+}
+
+func TestContainerInspectCreated(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age := time.Since(icd.Created); age < -time.Minute || age > 5*time.Minute {
+		t.Errorf("Expected the container to have been created just now, got %v", icd.Created)
+	}
+}
+
+func TestContainerStatsSnapshot(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	stats, err := c.ContainerStatsSnapshot(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.MemoryUsage == 0 {
+		t.Errorf("Expected nonzero memory usage for a running container")
+	}
+}
+
+func TestContainerCreateUlimits(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Ulimits: []driver.Ulimit{{Name: "nofile", Soft: 65536, Hard: 65536}},
+	})
+	if got := execOutput(t, c, id, "sh", "-c", "ulimit -n"); got != "65536" {
+		t.Errorf("Expected nofile 65536, got %s", got)
+	}
+}
+
+func TestContainerCreateShmSize(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{ShmSize: 128 * 1024 * 1024})
+	if got := execOutput(t, c, id, "sh", "-c", "df -k /dev/shm | tail -1 | awk '{print $2}'"); got != "131072" {
+		t.Errorf("Expected a 131072k /dev/shm, got %s", got)
+	}
+}
+
+func TestContainerCreateInit(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{Init: true})
+	if got := execOutput(t, c, id, "cat", "/proc/1/comm"); got != "catatonit" {
+		t.Errorf("Expected catatonit as pid 1, got %s", got)
+	}
+}
+
+func TestConcurrentPullsAreLimited(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{MaxConcurrentPulls: 3})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := c.ImagesPull(fmt.Sprintf("quay.io/skupper/router:%d", i), driver.ImagePullOptions{})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.maxPulling > 3 {
+		t.Errorf("Expected at most 3 pulls in flight, got %d", f.maxPulling)
+	}
+	if f.maxPulling < 2 {
+		t.Errorf("Expected pulls to run concurrently, got at most %d", f.maxPulling)
+	}
+}
+
+func TestContainerPortsAssigned(t *testing.T) {
+	c := newTestClient(t)
+	mappings, err := driver.ParsePortSpec("0:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := runTestContainer(t, c, driver.ContainerSpec{Ports: mappings})
+	ports, err := c.ContainerPorts(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) == 0 || ports[0].ContainerPort != 80 || ports[0].HostPort == 0 {
+		t.Errorf("Expected port 80 bound to an assigned host port, got %+v", ports)
+	}
+}
+
+func TestNetworkLabels(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{})
+
+	labels := map[string]string{"application": "skupper"}
+	resp, err := c.NetworkCreate("skupper", driver.NetworkCreateOptions{Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "skupper" {
+		t.Errorf("Expected the network's ID, got %q", resp.ID)
+	}
+	if _, err := c.NetworkCreate("other", driver.NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := c.NetworkList(driver.NetworkListOptions{Filters: driver.LabelFilter("application", "skupper")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "skupper" || list[0].Labels["application"] != "skupper" {
+		t.Errorf("Expected only the labeled network, got %+v", list)
+	}
+}
+
+func TestNetworkLabelsNeedPodman3(t *testing.T) {
+	f := newFakeService(t)
+	f.version = "2.2.1"
+	c := connectFake(t, f, driver.ConnectOptions{})
+
+	_, err := c.NetworkCreate("skupper", driver.NetworkCreateOptions{Owner: "site-a"})
+	if !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected labels to be refused by podman 2, got %v", err)
+	}
+	if _, err := c.NetworkCreate("skupper", driver.NetworkCreateOptions{}); err != nil {
+		t.Errorf("Expected an unlabeled network to be created, got %v", err)
+	}
+}
+
+func TestNetworkRemoveInUse(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	if _, err := c.NetworkConnect(name, id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.NetworkRemove(name, false)
+	var inUse *driver.NetworkInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("Expected a NetworkInUseError, got %v", err)
+	}
+	if len(inUse.Endpoints) != 1 || inUse.Endpoints[0] != id {
+		t.Errorf("Expected endpoint %s, got %v", id, inUse.Endpoints)
+	}
+	if err := c.NetworkRemove(name, true); err != nil {
+		t.Errorf("Expected a forced remove to disconnect and remove, got %v", err)
+	}
+}
+
+func TestContainerExecDuration(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	before := time.Now()
+	res, err := c.ContainerExec(id, []string{"sleep", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StartedAt.Before(before) || res.StartedAt.After(time.Now()) {
+		t.Errorf("Expected the start time around %v, got %v", before, res.StartedAt)
+	}
+	if res.Duration < time.Second || res.Duration > 10*time.Second {
+		t.Errorf("Expected a duration of about a second, got %v", res.Duration)
+	}
+}
+
+func TestContainerCreateCgroupParent(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{CgroupParent: "cedriverstest.slice"})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.CgroupParent != "cedriverstest.slice" {
+		t.Errorf("Expected cgroup parent cedriverstest.slice, got %q", icd.CgroupParent)
+	}
+}
+
+func TestNetworkCreateSubnet(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	pool := driver.IPAMPool{Subnet: "10.212.42.0/24", Gateway: "10.212.42.1"}
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{IPAM: driver.IPAMConfig{Config: []driver.IPAMPool{pool}}}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	network, err := c.NetworkInspect(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(network.IPAM.Config) != 1 {
+		t.Fatalf("Expected one IPAM pool, got %+v", network.IPAM.Config)
+	}
+	if got := network.IPAM.Config[0]; got.Subnet != pool.Subnet || got.Gateway != pool.Gateway {
+		t.Errorf("Expected subnet %s gateway %s, got %s gateway %s", pool.Subnet, pool.Gateway, got.Subnet, got.Gateway)
+	}
+}
+
+func TestNetworkCreateIPv6(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	pool := driver.IPAMPool{Subnet: "fd00:ce:42::/64", Gateway: "fd00:ce:42::1"}
+	options := driver.NetworkCreateOptions{
+		EnableIPv6: true,
+		IPAM:       driver.IPAMConfig{Config: []driver.IPAMPool{pool}},
+	}
+	if _, err := c.NetworkCreate(name, options); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	network, err := c.NetworkInspect(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, got := range network.IPAM.Config {
+		found = found || got.Subnet == pool.Subnet
+	}
+	if !found {
+		t.Errorf("Expected the IPv6 subnet %s, got %+v", pool.Subnet, network.IPAM.Config)
+	}
+}
+
+func TestContainerCreateDevices(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Devices: []driver.DeviceMapping{{PathOnHost: "/dev/null", PathInContainer: "/dev/telemetry"}},
+	})
+	if got := execOutput(t, c, id, "sh", "-c", "test -c /dev/telemetry && head -c 16 /dev/telemetry | wc -c"); got != "0" {
+		t.Errorf("Expected /dev/telemetry to read as /dev/null, got %q", got)
+	}
+}
+
+func TestExecInspect(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	res, err := c.ContainerExec(id, []string{"sh", "-c", "exit 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExecID == "" {
+		t.Fatal("Expected the exec session ID")
+	}
+	ei, err := c.ExecInspect(res.ExecID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ei.ID != res.ExecID || ei.Running || ei.ExitCode != 3 {
+		t.Errorf("Expected finished session %s exited 3, got %+v", res.ExecID, ei)
+	}
+}
+
+func TestContainerExitCode(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Mounts: []driver.Mount{entrypointMount(t, "sleep 1\nexit 42")},
+	})
+	if _, err := c.ContainerExitCode(id); !errors.As(err, new(*driver.ContainerRunningError)) {
+		t.Errorf("Expected a ContainerRunningError while running, got %v", err)
+	}
+	if err := c.ContainerWait(id, "exited", 30*time.Second, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	code, err := c.ContainerExitCode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 42 {
+		t.Errorf("Expected exit code 42, got %d", code)
+	}
+}
+
+func TestContainerCreateUser(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		User:     "1000:1000",
+		GroupAdd: []string{"2000"},
+		// nginx needs root, so just keep the container running
+		Mounts: []driver.Mount{entrypointMount(t, "exec sleep 300")},
+	})
+	if got := execOutput(t, c, id, "id", "-u"); got != "1000" {
+		t.Errorf("Expected uid 1000, got %q", got)
+	}
+	if got := strings.Fields(execOutput(t, c, id, "id", "-G")); !reflect.DeepEqual(got, []string{"1000", "2000"}) {
+		t.Errorf("Expected groups 1000 and 2000, got %q", got)
+	}
+}
+
+func TestContainerCreateWorkingDir(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{WorkingDir: "/usr/share/nginx/html"})
+	if got := execOutput(t, c, id, "pwd"); got != "/usr/share/nginx/html" {
+		t.Errorf("Expected working directory /usr/share/nginx/html, got %q", got)
+	}
+}
+
+func TestNetworkConnectReturnsEndpoint(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+
+	ep, err := c.NetworkConnect(name, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.ParseIP(strings.Split(ep.IPv4Address, "/")[0]) == nil {
+		t.Errorf("Expected an IPv4 address, got %+v", ep)
+	}
+}
+
+func TestWaitForPort(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.WaitForPort(ctx, id, 80, "tcp"); err != nil {
+		t.Errorf("Expected nginx to listen on port 80, got %v", err)
+	}
+}
+
+func TestContainerCreateTmpfs(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{Tmpfs: map[string]string{"/scratch": "size=64m"}})
+	mounts := execOutput(t, c, id, "mount")
+	found := false
+	for _, line := range strings.Split(mounts, "\n") {
+		// tmpfs on /scratch type tmpfs (rw,...)
+		fields := strings.Fields(line)
+		found = found || len(fields) >= 5 && fields[2] == "/scratch" && fields[4] == "tmpfs"
+	}
+	if !found {
+		t.Errorf("Expected a tmpfs at /scratch, got:\n%s", mounts)
+	}
+}
+
+func TestAPIVersionPinNotSupported(t *testing.T) {
+	f := newFakeService(t)
+	c := &podmanClient{}
+	err := c.New(context.Background(), driver.ConnectOptions{Host: f.socket, APIVersion: "2.0.0"})
+	if !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestContainerCreateStopSignal(t *testing.T) {
+	c := newTestClient(t)
+	timeout := 5
+	id := runTestContainer(t, c, driver.ContainerSpec{StopSignal: "SIGQUIT", StopTimeout: &timeout})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// podman reports the signal by number
+	if icd.StopSignal != "3" {
+		t.Errorf("Expected stop signal 3 (SIGQUIT), got %q", icd.StopSignal)
+	}
+	if icd.StopTimeout == nil || *icd.StopTimeout != timeout {
+		t.Errorf("Expected stop timeout %d, got %v", timeout, icd.StopTimeout)
+	}
+}
+
+func TestImageLabel(t *testing.T) {
+	c := newTestClient(t)
+	if err := driver.PullForPolicy(c, testImage, driver.PullMissing); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := driver.ImageLabel(c, testImage, "maintainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !strings.Contains(value, "nginx") {
+		t.Errorf("Expected the nginx maintainer label, got %q (set %v)", value, ok)
+	}
+}
+
+func TestContainerListPages(t *testing.T) {
+	c := newTestClient(t)
+	if err := driver.PullForPolicy(c, testImage, driver.PullMissing); err != nil {
+		t.Fatal(err)
+	}
+	value := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	var created []string
+	for i := 0; i < 5; i++ {
+		res, err := c.ContainerCreate(driver.ContainerSpec{
+			Name:   fmt.Sprintf("ce-drivers-%s-%d", value, i),
+			Image:  testImage,
+			Labels: map[string]string{"ce-drivers-test": value},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+		created = append(created, res.ID)
+	}
+
+	var listed []string
+	options := driver.ContainerListOptions{Limit: 2, Filters: driver.LabelFilter("ce-drivers-test", value)}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages, got more: %v", listed)
+		}
+		page, err := c.ContainerList(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("Expected at most 2 containers per page, got %d", len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, container := range page {
+			listed = append(listed, container.ID)
+		}
+		options.Before = page[len(page)-1].ID
+	}
+	// newest first
+	for i, j := 0, len(created)-1; i < j; i, j = i+1, j-1 {
+		created[i], created[j] = created[j], created[i]
+	}
+	if !reflect.DeepEqual(listed, created) {
+		t.Errorf("Expected %v, got %v", created, listed)
+	}
+}
+
+func TestContainerStatPath(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	stat, err := c.ContainerStatPath(id, "/etc/nginx/nginx.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Name != "nginx.conf" || stat.Size == 0 || stat.Mode.IsDir() || stat.Mtime.IsZero() {
+		t.Errorf("Expected a non-empty regular file, got %+v", stat)
+	}
+	dir, err := c.ContainerStatPath(id, "/etc/nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dir.Mode.IsDir() {
+		t.Errorf("Expected /etc/nginx to be a directory, got %v", dir.Mode)
+	}
+	_, err = c.ContainerStatPath(id, "/etc/nginx/missing.conf")
+	if !errors.As(err, new(*driver.PathNotFoundError)) {
+		t.Errorf("Expected a PathNotFoundError, got %v", err)
+	}
+	// a failure other than a missing path is not reported as one
+	_, err = c.ContainerStatPath(id, "/etc/nginx/nginx.conf/child")
+	if err == nil || errors.As(err, new(*driver.PathNotFoundError)) {
+		t.Errorf("Expected a stat error other than PathNotFoundError, got %v", err)
+	}
+}
+
+func TestContainerStatPathNotFound(t *testing.T) {
+	f := newFakeService(t)
+	c := connectFake(t, f, driver.ConnectOptions{})
+	f.exec = func(cmd []string) (string, string, int) {
+		switch path := cmd[len(cmd)-1]; path {
+		case "/etc/hostname":
+			return "13 81a4 1600000000\n", "", 0
+		case "/etc/hostname/child":
+			return "", "stat: can't stat '" + path + "': Not a directory\n", 1
+		default:
+			return "", "stat: can't stat '" + path + "': No such file or directory\n", 1
+		}
+	}
+	stat, err := c.ContainerStatPath("router", "/etc/hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Name != "hostname" || stat.Size != 13 || !stat.Mode.IsRegular() {
+		t.Errorf("Expected a regular file of 13 bytes, got %+v", stat)
+	}
+	_, err = c.ContainerStatPath("router", "/etc/missing")
+	if !errors.As(err, new(*driver.PathNotFoundError)) {
+		t.Errorf("Expected a PathNotFoundError, got %v", err)
+	}
+	_, err = c.ContainerStatPath("router", "/etc/hostname/child")
+	if err == nil || errors.As(err, new(*driver.PathNotFoundError)) || !strings.Contains(err.Error(), "Not a directory") {
+		t.Errorf("Expected a stat error carrying its output, got %v", err)
+	}
+}
+
+// countingWriter counts the bytes written to it and keeps none.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestContainerExecStream(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	const size = 16 << 20
+	var stdout, stderr countingWriter
+	exitCode, err := c.ContainerExecStream(id, driver.ExecOptions{
+		Cmd: []string{"sh", "-c", fmt.Sprintf("head -c %d /dev/zero; echo oops >&2; exit 3", size)},
+	}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", exitCode)
+	}
+	if stdout.n != size {
+		t.Errorf("Expected %d bytes on stdout, got %d", size, stdout.n)
+	}
+	if stderr.n != int64(len("oops\n")) {
+		t.Errorf("Expected 5 bytes on stderr, got %d", stderr.n)
+	}
+}
+
+func TestContainerCreateOOMScoreAdj(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Resources: driver.Resources{OOMScoreAdj: 500, MemoryReservation: 64 << 20},
+	})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Resources.OOMScoreAdj != 500 || icd.Resources.MemoryReservation != 64<<20 {
+		t.Errorf("Expected OOM score adjustment 500 and a 64MiB reservation, got %+v", icd.Resources)
+	}
+	if got := execOutput(t, c, id, "cat", "/proc/1/oom_score_adj"); got != "500" {
+		t.Errorf("Expected oom_score_adj 500, got %s", got)
+	}
+}
+
+func TestContainerInspectResources(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Resources: driver.Resources{Memory: 256 << 20},
+	})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Resources.Memory != 256<<20 {
+		t.Errorf("Expected a 256MiB memory limit, got %+v", icd.Resources)
+	}
+}
+
+func TestContainerCreatePlatform(t *testing.T) {
+	c := newTestClient(t)
+	spec := driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		Platform:   "linux/" + runtime.GOARCH,
+		PullPolicy: driver.PullMissing,
+	}
+	res, err := c.ContainerCreate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+	icd, err := c.ContainerInspect(res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := c.ImageInspect(icd.Image, driver.ImageInspectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Architecture != runtime.GOARCH {
+		t.Errorf("Expected a %s container, got %s", runtime.GOARCH, image.Architecture)
+	}
+
+	// podman runs the variant stored locally, which is for this host
+	other := "linux/arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "linux/amd64"
+	}
+	spec.Name += "-other"
+	spec.Platform = other
+	if res, err := c.ContainerCreate(spec); err == nil {
+		c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+		t.Errorf("Expected creating a %s container from a %s image to fail", other, runtime.GOARCH)
+	}
+}
+
+func TestContainerEvents(t *testing.T) {
+	c := newTestClient(t)
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		PullPolicy: driver.PullMissing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	events, errs, err := c.ContainerEvents(ctx, res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStart(res.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ContainerStop(res.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var actions []string
+	for len(actions) == 0 || actions[len(actions)-1] != driver.EventDie {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("Events closed after %v: %v", actions, <-errs)
+			}
+			if ev.ID != res.ID {
+				t.Errorf("Expected events of %s only, got %+v", res.ID, ev)
+			}
+			actions = append(actions, ev.Action)
+		case <-ctx.Done():
+			t.Fatalf("Expected start and die events, got %v", actions)
+		}
+	}
+	if actions[0] != driver.EventStart {
+		t.Errorf("Expected start before die, got %v", actions)
+	}
+}
+
+func TestWaitForLogPattern(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Mounts: []driver.Mount{entrypointMount(t, "echo starting\nsleep 2\necho 'Router started'\nexec sleep 300")},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	line, err := driver.WaitForLogPattern(ctx, c, id, regexp.MustCompile(`Router started`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "Router started" {
+		t.Errorf("Expected the matching line, got %q", line)
+	}
+}
+
+func TestContainerLogPath(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	path, err := c.ContainerLogPath(id)
+	if errors.Is(err, driver.ErrNotSupported) {
+		t.Skipf("The engine's default log driver is not file based: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(path) {
+		t.Errorf("Expected an absolute log path, got %q", path)
+	}
+}
+
+func TestNetworkGateway(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	pool := driver.IPAMPool{Subnet: "10.212.43.0/24", Gateway: "10.212.43.1"}
+	if _, err := c.NetworkCreate(name, driver.NetworkCreateOptions{IPAM: driver.IPAMConfig{Config: []driver.IPAMPool{pool}}}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.NetworkRemove(name, true)
+
+	gateway, err := c.NetworkGateway(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway != pool.Gateway {
+		t.Errorf("Expected gateway %s, got %s", pool.Gateway, gateway)
+	}
+}
+
+func TestGenerateSystemdUnit(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	id := runTestContainer(t, c, driver.ContainerSpec{Name: name})
+	units, err := c.GenerateSystemdUnit(id, driver.SystemdOptions{UseName: true, RestartPolicy: "always"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unit string
+	for unitName, content := range units {
+		if strings.HasPrefix(unitName, "container-"+name) {
+			unit = content
+		}
+	}
+	if unit == "" {
+		t.Fatalf("Expected a unit for container %s, got %v", name, units)
+	}
+	if !strings.Contains(unit, name) || !strings.Contains(unit, "Restart=always") {
+		t.Errorf("Expected the unit to start %s and always restart, got:\n%s", name, unit)
+	}
+}
+
+func TestContainerExecTimeout(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	start := time.Now()
+	_, err := c.ContainerExecWithOptions(id, driver.ExecOptions{
+		Cmd:     []string{"sh", "-c", "echo started; exec sleep 31"},
+		Timeout: time.Second,
+	})
+	var timeout *driver.ExecTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Expected an ExecTimeoutError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the exec to stop at the timeout, took %v", elapsed)
+	}
+	if got := strings.TrimSpace(timeout.Result.Stdout()); got != "started" {
+		t.Errorf("Expected the output captured before the timeout, got %q", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	ps := execOutput(t, c, id, "ps", "-o", "args")
+	for strings.Contains(ps, "sleep 31") && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		ps = execOutput(t, c, id, "ps", "-o", "args")
+	}
+	if strings.Contains(ps, "sleep 31") {
+		t.Errorf("Expected the timed out process to be killed, got:\n%s", ps)
+	}
+}
+
+func TestContainerExecMaxOutputBytes(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	res, err := c.ContainerExecWithOptions(id, driver.ExecOptions{
+		Cmd:            []string{"sh", "-c", "head -c 1048576 /dev/zero"},
+		MaxOutputBytes: 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated {
+		t.Errorf("Expected the output to be marked truncated")
+	}
+	if n := res.OutBuffer.Len(); n != 1024 {
+		t.Errorf("Expected 1024 bytes to be kept, got %d", n)
+	}
+	if res.ExitCode != 0 {
+		t.Errorf("Expected the command to run to completion, got exit code %d", res.ExitCode)
+	}
+}
+
+func TestContainerCreateAutoAttachNetworks(t *testing.T) {
+	c := newTestClient(t)
+	c.autoAttach = true
+	network := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() { c.NetworkRemove(network, true) })
+	id := runTestContainer(t, c, driver.ContainerSpec{Labels: map[string]string{driver.NetworkLabel: network}})
+
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := icd.Networks[network]; !ok {
+		t.Errorf("Expected the container to be connected to %s, got %v", network, icd.Networks)
+	}
+}
+
+func TestContainerNetworkConfig(t *testing.T) {
+	c := newTestClient(t)
+	config := driver.NetworkConfig{
+		Hostname:   "router",
+		DNS:        []string{"10.0.0.53"},
+		ExtraHosts: []string{"controller:10.0.0.7"},
+	}
+	id := runTestContainer(t, c, driver.ContainerSpec{NetworkConfig: config})
+
+	got, err := c.ContainerNetworkConfig(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hostname != config.Hostname || !reflect.DeepEqual(got.DNS, config.DNS) || !reflect.DeepEqual(got.ExtraHosts, config.ExtraHosts) {
+		t.Errorf("Expected %+v, got %+v", config, got)
+	}
+	if hostname := execOutput(t, c, id, "hostname"); hostname != "router" {
+		t.Errorf("Expected hostname router, got %q", hostname)
+	}
+}
+
+func TestContainerCreateRuntime(t *testing.T) {
+	c := newTestClient(t)
+	// hosts have crun, runc or both, so ask for the one the default uses
+	icd, err := c.ContainerInspect(runTestContainer(t, c, driver.ContainerSpec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultRuntime := icd.Runtime
+	if defaultRuntime == "" {
+		t.Fatal("Expected the default runtime to be reported")
+	}
+	icd, err = c.ContainerInspect(runTestContainer(t, c, driver.ContainerSpec{Runtime: defaultRuntime}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Runtime != defaultRuntime {
+		t.Errorf("Expected runtime %s, got %q", defaultRuntime, icd.Runtime)
+	}
+
+	_, err = c.ContainerCreate(driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		PullPolicy: driver.PullMissing,
+		Runtime:    "no-such-runtime",
+	})
+	if err == nil {
+		t.Errorf("Expected an unknown runtime to fail")
+	}
+}
+
+func TestSupportsFeature(t *testing.T) {
+	c := newTestClient(t)
+	for _, feature := range []driver.Feature{driver.SwarmSecrets, driver.CgroupV2, driver.BuildKit, driver.RootlessNetworking} {
+		if _, err := c.SupportsFeature(feature); err != nil {
+			t.Errorf("Expected %s to be probed, got %v", feature, err)
+		}
+	}
+	// the tests talk to a local engine, so it shares the host's cgroups
+	_, err := os.Stat("/sys/fs/cgroup/cgroup.controllers")
+	if cgroupV2, _ := c.SupportsFeature(driver.CgroupV2); cgroupV2 != (err == nil) {
+		t.Errorf("Expected cgroup v2 support to be %v, got %v", err == nil, cgroupV2)
+	}
+	if _, err := c.SupportsFeature(driver.Feature("teleport")); err == nil {
+		t.Errorf("Expected an unknown feature to fail")
+	}
+}
+
+func TestSupportsFeatureRootless(t *testing.T) {
+	c := newTestClient(t)
+	rootless, err := c.SupportsFeature(driver.RootlessNetworking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the default service socket belongs to the user running the tests
+	if expected := os.Geteuid() != 0; rootless != expected {
+		t.Errorf("Expected rootless to be %v, got %v", expected, rootless)
+	}
+	if secrets, _ := c.SupportsFeature(driver.SwarmSecrets); secrets {
+		t.Errorf("Expected podman v2 to have no swarm secrets")
+	}
+}
+
+func TestContainerStartPortConflict(t *testing.T) {
+	c := newTestClient(t)
+	assigned, err := driver.ParsePortSpec("0:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports, err := c.ContainerPorts(runTestContainer(t, c, driver.ContainerSpec{Ports: assigned}))
+	if err != nil || len(ports) == 0 {
+		t.Fatalf("Expected an assigned host port, got %+v, %v", ports, err)
+	}
+	taken, err := driver.ParsePortSpec(fmt.Sprintf("%d:80", ports[0].HostPort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.ContainerCreate(driver.ContainerSpec{
+		Name:       fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:      testImage,
+		PullPolicy: driver.PullMissing,
+		Ports:      taken,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.ContainerRemove(res.ID, driver.RemoveOptions{Force: true}) })
+
+	err = c.ContainerStart(res.ID)
+	var conflict *driver.PortConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected a PortConflictError, got %v", err)
+	}
+	if !strings.HasSuffix(conflict.Port, fmt.Sprintf(":%d", ports[0].HostPort)) {
+		t.Errorf("Expected the conflict on port %d, got %s", ports[0].HostPort, conflict.Port)
+	}
+}
+
+func TestContainerLabelsAndAnnotations(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		Labels:      map[string]string{"application": "skupper"},
+		Annotations: map[string]string{"io.skupper.site": "west"},
+	})
+	icd, err := c.ContainerInspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.Labels["application"] != "skupper" {
+		t.Errorf("Expected the label to round-trip, got %v", icd.Labels)
+	}
+	if icd.Annotations["io.skupper.site"] != "west" {
+		t.Errorf("Expected the annotation to round-trip, got %v", icd.Annotations)
+	}
+	if _, ok := icd.Labels["io.skupper.site"]; ok {
+		t.Errorf("Expected the annotation to be kept apart from the labels")
+	}
+}
+
+func TestContainerCreateSharedPidNamespace(t *testing.T) {
+	c := newTestClient(t)
+	router := runTestContainer(t, c, driver.ContainerSpec{})
+	mode := driver.NamespaceModeContainer + router
+	sidecar := runTestContainer(t, c, driver.ContainerSpec{
+		PidMode: mode,
+		Mounts:  []driver.Mount{entrypointMount(t, "exec sleep 300")},
+	})
+
+	icd, err := c.ContainerInspect(sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icd.PidMode != mode {
+		t.Errorf("Expected pid mode %s, got %q", mode, icd.PidMode)
+	}
+	if ps := execOutput(t, c, sidecar, "ps", "-o", "args"); !strings.Contains(ps, "nginx: master process") {
+		t.Errorf("Expected the sidecar to see the router's processes, got:\n%s", ps)
+	}
+
+	_, err = c.ContainerCreate(driver.ContainerSpec{
+		Name:    fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano()),
+		Image:   testImage,
+		PidMode: "container:ce-drivers-missing",
+	})
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected a missing target to be reported, got %v", err)
+	}
+}
+
+func TestContainerExecDetached(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	start := time.Now()
+	execID, err := c.ContainerExecDetached(id, driver.ExecOptions{Cmd: []string{"sh", "-c", "sleep 2; exit 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the exec to be started without waiting for it, took %v", elapsed)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		ei, err := c.ExecInspect(execID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ei.Running {
+			if ei.ExitCode != 3 {
+				t.Errorf("Expected the exec to exit 3, got %d", ei.ExitCode)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the exec to complete, still running: %+v", ei)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestContainerExecDetachedStartsSession(t *testing.T) {
+	f := newFakeService(t)
+	f.exec = func(cmd []string) (string, string, int) {
+		return "", "", 3
+	}
+	c := connectFake(t, f, driver.ConnectOptions{})
+	execID, err := c.ContainerExecDetached("router", driver.ExecOptions{Cmd: []string{"sh", "-c", "exit 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.lock.Lock()
+	started := f.execs[execID] != nil && f.execs[execID].started
+	f.lock.Unlock()
+	if !started {
+		t.Fatalf("Expected exec %s to have been started", execID)
+	}
+	ei, err := c.ExecInspect(execID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ei.ExitCode != 3 {
+		t.Errorf("Expected the exec to exit 3, got %d", ei.ExitCode)
+	}
+}
+
+func TestContainerInspectOperationError(t *testing.T) {
+	c := newTestClient(t)
+	_, err := c.ContainerInspect("ce-drivers-missing")
+	var opErr *driver.OperationError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("Expected an OperationError, got %v", err)
+	}
+	if opErr.Op != "ContainerInspect" || opErr.Resource != "ce-drivers-missing" {
+		t.Errorf("Expected the op and resource to be named, got %s on %s", opErr.Op, opErr.Resource)
+	}
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Errorf("Expected the error to still match ErrNotFound, got %v", err)
+	}
+}
+
+func TestContainerRestartCount(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{
+		RestartPolicy: driver.RestartPolicy{Name: "always"},
+		// crash shortly after each start
+		Mounts: []driver.Mount{entrypointMount(t, "sleep 1; exit 1")},
+	})
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		count, err := c.ContainerRestartCount(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the restart count to increment after the container crashed")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func TestWatchPath(t *testing.T) {
+	c := newTestClient(t)
+	id := runTestContainer(t, c, driver.ContainerSpec{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.WatchPath(ctx, id, "/etc/nginx/nginx.conf", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execOutput(t, c, id, "sh", "-c", "echo '# reload' >> /etc/nginx/nginx.conf")
+	select {
+	case ev := <-events:
+		if ev.Op != driver.PathModified {
+			t.Errorf("Expected the file to be modified, got %+v", ev)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected a change event")
+	}
+	cancel()
+	for range events {
+	}
+}
+
+func TestContainerInspectEntrypointAndCmd(t *testing.T) {
+	c := newTestClient(t)
+	// specs cannot override the command, so expect testImage's own
+	icd, err := c.ContainerInspect(runTestContainer(t, c, driver.ContainerSpec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(icd.Entrypoint, []string{"/docker-entrypoint.sh"}) {
+		t.Errorf("Expected the image's entrypoint, got %q", icd.Entrypoint)
+	}
+	if !reflect.DeepEqual(icd.Cmd, []string{"nginx", "-g", "daemon off;"}) {
+		t.Errorf("Expected the image's cmd, got %q", icd.Cmd)
+	}
+}
+
+func TestContainerSummary(t *testing.T) {
+	c := newTestClient(t)
+	name := fmt.Sprintf("ce-drivers-test-%d", time.Now().UnixNano())
+	id := runTestContainer(t, c, driver.ContainerSpec{Name: name})
+	time.Sleep(time.Second)
+
+	summary, err := c.ContainerSummary(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != id || summary.Name != name || !summary.State.Running {
+		t.Errorf("Expected running container %s, got %+v", name, summary)
+	}
+	if summary.Uptime <= 0 || summary.RestartCount != 0 {
+		t.Errorf("Expected uptime and no restarts, got %v and %d", summary.Uptime, summary.RestartCount)
+	}
+	if summary.Stats.MemoryUsage == 0 || summary.Stats.PIDs == 0 {
+		t.Errorf("Expected a stats snapshot, got %+v", summary.Stats)
+	}
+	if summary.Health != "" {
+		t.Errorf("Expected no health without a healthcheck, got %q", summary.Health)
+	}
+}